@@ -3,6 +3,7 @@ package main
 import (
 	"bytes"
 	"context"
+	"encoding/csv"
 	"encoding/json"
 	"flag"
 	"fmt"
@@ -17,6 +18,8 @@ import (
 	"time"
 
 	"github.com/parquet-go/parquet-go"
+	"redcat/internal/config"
+	"redcat/internal/storage/valkey"
 )
 
 // ParquetPlace matches Foursquare parquet schema
@@ -47,32 +50,58 @@ type ParquetPlace struct {
 	PlacemakerURL     string   `parquet:"placemaker_url,optional"`
 }
 
-// APIPlace is the JSON body for POST /api/v1/places
+// APIPlace is the JSON body for POST /api/v1/places (PlaceCreate schema)
 type APIPlace struct {
-	ID             string   `json:"id"`
-	Name           string   `json:"name"`
-	Lat            float64  `json:"lat"`
-	Lon            float64  `json:"lon"`
-	Address        string   `json:"address,omitempty"`
-	Locality       string   `json:"locality,omitempty"`
-	Region         string   `json:"region,omitempty"`
-	Postcode       string   `json:"postcode,omitempty"`
-	AdminRegion    string   `json:"admin_region,omitempty"`
-	PostTown       string   `json:"post_town,omitempty"`
-	PoBox          string   `json:"po_box,omitempty"`
-	Country        string   `json:"country,omitempty"`
-	DateCreated    string   `json:"date_created,omitempty"`
-	DateRefreshed  string   `json:"date_refreshed,omitempty"`
-	DateClosed     string   `json:"date_closed,omitempty"`
-	Tel            string   `json:"tel,omitempty"`
-	Website        string   `json:"website,omitempty"`
-	Email          string   `json:"email,omitempty"`
-	FacebookID     string   `json:"facebook_id,omitempty"`
-	Instagram      string   `json:"instagram,omitempty"`
-	Twitter        string   `json:"twitter,omitempty"`
-	CategoryIDs    []string `json:"category_ids"`
-	CategoryLabels []string `json:"category_labels,omitempty"`
-	PlacemakerURL  string   `json:"placemaker_url,omitempty"`
+	ID             string      `json:"id"`
+	Name           string      `json:"name"`
+	Location       APILocation `json:"location"`
+	Address        *APIAddress `json:"address,omitempty"`
+	AdminRegion    string      `json:"admin_region,omitempty"`
+	PostTown       string      `json:"post_town,omitempty"`
+	PoBox          string      `json:"po_box,omitempty"`
+	CategoryIDs    []string    `json:"category_ids"`
+	CategoryLabels []string    `json:"category_labels,omitempty"`
+	Contact        *APIContact `json:"contact,omitempty"`
+	Social         *APISocial  `json:"social,omitempty"`
+	Dates          *APIDates   `json:"dates,omitempty"`
+	PlacemakerURL  string      `json:"placemaker_url,omitempty"`
+}
+
+type APILocation struct {
+	Lat float64 `json:"lat"`
+	Lon float64 `json:"lon"`
+}
+
+type APIAddress struct {
+	Street   string `json:"street,omitempty"`
+	Locality string `json:"locality,omitempty"`
+	Region   string `json:"region,omitempty"`
+	Postcode string `json:"postcode,omitempty"`
+	Country  string `json:"country,omitempty"`
+}
+
+type APIContact struct {
+	Phone   string `json:"phone,omitempty"`
+	Website string `json:"website,omitempty"`
+	Email   string `json:"email,omitempty"`
+}
+
+type APISocial struct {
+	FacebookID string `json:"facebook_id,omitempty"`
+	Instagram  string `json:"instagram,omitempty"`
+	Twitter    string `json:"twitter,omitempty"`
+}
+
+type APIDates struct {
+	Created   string `json:"created,omitempty"`
+	Refreshed string `json:"refreshed,omitempty"`
+	Closed    string `json:"closed,omitempty"`
+}
+
+// APIAlias is one entry of the AliasBatch schema.
+type APIAlias struct {
+	ID          string `json:"id"`
+	CanonicalID string `json:"canonical_id"`
 }
 
 var (
@@ -82,30 +111,22 @@ var (
 	limit       = flag.Int("limit", 0, "max records to load (0 = all)")
 	dryRun      = flag.Bool("dry-run", false, "don't send to API")
 	slim        = flag.Bool("slim", false, "only send id, name, lat, lon, category_ids, country")
+	aliasFile   = flag.String("aliases", "", "CSV of old_id,canonical_id rows to register as aliases after loading")
+	backfill    = flag.Bool("backfill", false, "recompute the derived index fields (has_address, H3 cells) of every stored place, connecting to Valkey with the server's VALKEY_* variables")
 )
 
-func main() {
-	flag.Parse()
-
-	if *parquetFile == "" {
-		log.Fatal("--file required")
-	}
-
-	// Open parquet file with generic reader
-	f, err := os.Open(*parquetFile)
-	if err != nil {
-		log.Fatalf("open file: %v", err)
-	}
-	defer f.Close()
+// aliasBatchSize is the most aliases one POST /api/v1/aliases accepts.
+const aliasBatchSize = 1000
 
-	reader := parquet.NewGenericReader[ParquetPlace](f)
-	defer reader.Close()
+// backfillBatchSize is the SCAN count of the backfill and so the number of
+// places read and rewritten per round trip.
+const backfillBatchSize = 500
 
-	numRows := int(reader.NumRows())
-	log.Printf("Parquet file: %s, rows: %d", *parquetFile, numRows)
+func main() {
+	flag.Parse()
 
-	if *limit > 0 && *limit < numRows {
-		numRows = *limit
+	if *parquetFile == "" && *aliasFile == "" && !*backfill {
+		log.Fatal("--file, --aliases or --backfill required")
 	}
 
 	// Setup graceful shutdown
@@ -130,6 +151,40 @@ func main() {
 		},
 	}
 
+	if *parquetFile != "" {
+		loadPlaces(ctx, client)
+	}
+	// aliases go last so that they point at places already loaded
+	if *aliasFile != "" && ctx.Err() == nil {
+		if err := syncAliases(ctx, client, *aliasFile); err != nil {
+			log.Fatalf("aliases: %v", err)
+		}
+	}
+	if *backfill && ctx.Err() == nil {
+		if err := backfillPlaces(ctx); err != nil {
+			log.Fatalf("backfill: %v", err)
+		}
+	}
+}
+
+func loadPlaces(ctx context.Context, client *http.Client) {
+	// Open parquet file with generic reader
+	f, err := os.Open(*parquetFile)
+	if err != nil {
+		log.Fatalf("open file: %v", err)
+	}
+	defer f.Close()
+
+	reader := parquet.NewGenericReader[ParquetPlace](f)
+	defer reader.Close()
+
+	numRows := int(reader.NumRows())
+	log.Printf("Parquet file: %s, rows: %d", *parquetFile, numRows)
+
+	if *limit > 0 && *limit < numRows {
+		numRows = *limit
+	}
+
 	// Worker pool
 	placeCh := make(chan ParquetPlace, *workers*10)
 	var wg sync.WaitGroup
@@ -149,8 +204,10 @@ func main() {
 		}()
 	}
 
-	// Progress reporter
+	// Progress reporter, stopped once loading returns
 	start := time.Now()
+	stop := make(chan struct{})
+	defer close(stop)
 	go func() {
 		ticker := time.NewTicker(5 * time.Second)
 		defer ticker.Stop()
@@ -158,6 +215,8 @@ func main() {
 			select {
 			case <-ctx.Done():
 				return
+			case <-stop:
+				return
 			case <-ticker.C:
 				loaded := atomic.LoadInt64(&totalLoaded)
 				errors := atomic.LoadInt64(&totalErrors)
@@ -244,42 +303,44 @@ func sendPlace(ctx context.Context, client *http.Client, p ParquetPlace) error {
 		ap = APIPlace{
 			ID:          p.FsqPlaceID,
 			Name:        p.Name,
-			Lat:         p.Latitude,
-			Lon:         p.Longitude,
+			Location:    APILocation{Lat: p.Latitude, Lon: p.Longitude},
+			Address:     &APIAddress{Country: p.Country},
 			CategoryIDs: p.FsqCategoryIDs,
-			Country:     p.Country,
 		}
 	} else {
 		ap = APIPlace{
-			ID:             p.FsqPlaceID,
-			Name:           p.Name,
-			Lat:            p.Latitude,
-			Lon:            p.Longitude,
-			Address:        p.Address,
-			Locality:       p.Locality,
-			Region:         p.Region,
-			Postcode:       p.Postcode,
+			ID:       p.FsqPlaceID,
+			Name:     p.Name,
+			Location: APILocation{Lat: p.Latitude, Lon: p.Longitude},
+			Address: &APIAddress{
+				Street:   p.Address,
+				Locality: p.Locality,
+				Region:   p.Region,
+				Postcode: p.Postcode,
+				Country:  p.Country,
+			},
 			AdminRegion:    p.AdminRegion,
 			PostTown:       p.PostTown,
 			PoBox:          p.PoBox,
-			Country:        p.Country,
-			DateCreated:    p.DateCreated,
-			DateRefreshed:  p.DateRefreshed,
-			DateClosed:     p.DateClosed,
-			Tel:            p.Tel,
-			Website:        p.Website,
-			Email:          p.Email,
-			FacebookID:     fmtFacebookID(p.FacebookID),
-			Instagram:      p.Instagram,
-			Twitter:        p.Twitter,
 			CategoryIDs:    p.FsqCategoryIDs,
 			CategoryLabels: p.FsqCategoryLabels,
-			PlacemakerURL:  p.PlacemakerURL,
+			Contact:        &APIContact{Phone: p.Tel, Website: p.Website, Email: p.Email},
+			Social: &APISocial{
+				FacebookID: fmtFacebookID(p.FacebookID),
+				Instagram:  p.Instagram,
+				Twitter:    p.Twitter,
+			},
+			Dates: &APIDates{
+				Created:   p.DateCreated,
+				Refreshed: p.DateRefreshed,
+				Closed:    p.DateClosed,
+			},
+			PlacemakerURL: p.PlacemakerURL,
 		}
 	}
 
 	body, _ := json.Marshal(ap)
-	req, err := http.NewRequestWithContext(ctx, "POST", *apiURL+"/api/v1/places", bytes.NewReader(body))
+	req, err := http.NewRequestWithContext(ctx, "POST", *apiURL+"/api/v1/places?upsert=true", bytes.NewReader(body))
 	if err != nil {
 		return err
 	}
@@ -297,3 +358,134 @@ func sendPlace(ctx context.Context, client *http.Client, p ParquetPlace) error {
 	}
 	return nil
 }
+
+// syncAliases registers the old_id,canonical_id rows of a CSV file, whose
+// first row may be that header, in batches of aliasBatchSize.
+func syncAliases(ctx context.Context, client *http.Client, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	r := csv.NewReader(f)
+	r.FieldsPerRecord = 2
+	r.TrimLeadingSpace = true
+
+	var batch []APIAlias
+	inBatch := map[string]bool{}
+	var sent, failed, line int
+	flush := func() {
+		switch {
+		case len(batch) == 0 || *dryRun:
+			sent += len(batch)
+		case sendAliases(ctx, client, batch) != nil:
+			failed += len(batch)
+		default:
+			sent += len(batch)
+		}
+		batch = batch[:0]
+		clear(inBatch)
+	}
+	for {
+		rec, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		line++
+		if line == 1 && rec[0] == "old_id" {
+			continue
+		}
+		if rec[0] == rec[1] {
+			continue
+		}
+		// the API rejects an ID listed twice in one batch; the later row wins
+		if inBatch[rec[0]] {
+			flush()
+		}
+		inBatch[rec[0]] = true
+		batch = append(batch, APIAlias{ID: rec[0], CanonicalID: rec[1]})
+		if len(batch) == aliasBatchSize {
+			flush()
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+	}
+	flush()
+
+	log.Printf("Aliases: %d registered, %d failed", sent, failed)
+	return nil
+}
+
+func sendAliases(ctx context.Context, client *http.Client, aliases []APIAlias) error {
+	body, _ := json.Marshal(map[string]any{"aliases": aliases})
+	req, err := http.NewRequestWithContext(ctx, "POST", *apiURL+"/api/v1/aliases", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+
+	if resp.StatusCode != http.StatusNoContent {
+		log.Printf("aliases: status %d: %s", resp.StatusCode, msg)
+		return fmt.Errorf("status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// backfillPlaces brings the index schema up to date and then writes the
+// derived fields of every stored place, so that fields added to the index
+// after the places were loaded (has_address, H3 cells) cover them without
+// a reload. It talks to Valkey directly: the API has no endpoint that
+// rewrites places without changing them.
+func backfillPlaces(ctx context.Context) error {
+	cfg := config.FromEnv()
+	cli, err := valkey.NewClient(cfg.ValkeyAddrs, cfg.ValkeyUser, cfg.ValkeyPass)
+	if err != nil {
+		return err
+	}
+	defer cli.Close()
+
+	added, err := valkey.EnsurePlacesIndex(ctx, cli.R, cfg.IndexName, cfg.KeyPrefix)
+	if err != nil {
+		return err
+	}
+	if len(added) > 0 {
+		log.Printf("Index %s: added fields %v", cfg.IndexName, added)
+	}
+
+	store := valkey.NewPlacesStorage(cli.R, cfg.IndexName, cfg.KeyPrefix)
+	start := time.Now()
+	var batches, scanned, updated, corrupted int
+	err = store.ScanIDs(ctx, backfillBatchSize, func(ids []string) error {
+		scanned += len(ids)
+		if *dryRun {
+			return ctx.Err()
+		}
+		n, bad, err := store.Backfill(ctx, ids)
+		if err != nil {
+			return err
+		}
+		updated += n
+		corrupted += len(bad)
+		for _, id := range bad {
+			log.Printf("backfill: skipping corrupted record %s", id)
+		}
+		if batches++; batches%100 == 0 {
+			log.Printf("Progress: %d scanned, %d updated", scanned, updated)
+		}
+		return ctx.Err()
+	})
+	log.Printf("Backfill: %d scanned, %d updated, %d corrupted in %v", scanned, updated, corrupted, time.Since(start))
+	return err
+}
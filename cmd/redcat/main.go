@@ -10,7 +10,10 @@ import (
 
 	"redcat/internal/api"
 	"redcat/internal/config"
+	"redcat/internal/domain/model"
+	"redcat/internal/service/geofences"
 	"redcat/internal/service/places"
+	"redcat/internal/service/webhooks"
 	"redcat/internal/storage/valkey"
 )
 
@@ -23,15 +26,47 @@ func main() {
 
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
-	if err := valkey.EnsurePlacesIndex(ctx, cli.R, cfg.IndexName, cfg.KeyPrefix); err != nil {
-		log.Fatalf("ensure index: %v", err)
+	added, err := valkey.EnsurePlacesIndex(ctx, cli.R, cfg.IndexName, cfg.KeyPrefix)
+	if err != nil { log.Fatalf("ensure index: %v", err) }
+	if len(added) > 0 { log.Printf("index %s: added fields %v", cfg.IndexName, added) }
+	if err := valkey.EnsureGeofencesIndex(ctx, cli.R, cfg.GeofenceIndexName, cfg.GeofenceKeyPrefix); err != nil {
+		log.Fatalf("ensure geofence index: %v", err)
 	}
 
 	store := valkey.NewPlacesStorage(cli.R, cfg.IndexName, cfg.KeyPrefix)
-	svc := places.New(store)
+	history := valkey.NewHistoryStorage(cli.R, cfg.KeyPrefix, cfg.HistoryMaxLen, cfg.HistoryMaxAge)
+	feed := valkey.NewChangeFeed(cli.R, "changes:"+cfg.IndexName, cfg.ChangesShards, cfg.ChangesMaxLen)
+	hookOpts := []webhooks.Option{
+		webhooks.WithHTTPClient(webhooks.NewHTTPClient(cfg.WebhookTimeout, cfg.WebhookAllowPrivate)),
+		webhooks.WithRenderer(func(p model.Place) any { return api.PlaceFromModel(p) }),
+		webhooks.WithRetry(cfg.WebhookMaxAttempts, time.Second, time.Hour),
+	}
+	if cfg.WebhookAllowPrivate { hookOpts = append(hookOpts, webhooks.WithPrivateTargets()) }
+	hooks := webhooks.New(valkey.NewWebhookStorage(cli.R, cfg.IndexName, cfg.WebhookMaxDead), hookOpts...)
+	dupes := valkey.NewDuplicateStorage(cli.R, cfg.IndexName)
+	opts := []places.Option{places.WithHistory(history), places.WithChangeFeed(feed), places.WithPublisher(hooks), places.WithDuplicateQueue(dupes)}
+	if cfg.SoftDelete { opts = append(opts, places.WithSoftDelete()) }
+	svc := places.New(store, opts...)
+
+	fences := geofences.New(valkey.NewGeofenceStorage(cli.R, cfg.GeofenceIndexName, cfg.GeofenceKeyPrefix))
+
+	runCtx, stop := context.WithCancel(context.Background())
+	defer stop()
+	go hooks.RunDispatcher(runCtx, time.Second)
+	go svc.RunRelay(runCtx, cfg.ChangesRelayInterval)
+	if cfg.SoftDelete {
+		go svc.RunPurger(runCtx, cfg.SoftDeleteRetention, cfg.PurgeInterval)
+	}
+	if cfg.Dedupe {
+		go svc.RunDeduper(runCtx, cfg.DedupeInterval)
+	}
+
+	actorProxies, err := api.ParseActorProxies(cfg.ActorProxies)
+	if err != nil { log.Fatalf("ACTOR_PROXIES: %v", err) }
+	if len(actorProxies) == 0 { log.Printf("ACTOR_PROXIES unset: audit entries record every actor as anonymous") }
 
 	s := api.New()
-	api.Register(s.App(), api.Handlers{Places: svc})
+	api.Register(s.App(), api.Handlers{Places: svc, Webhooks: hooks, Geofences: fences, Strict: cfg.StrictValidation, TileMaxAge: cfg.TileMaxAge, ActorProxies: actorProxies})
 
 	go func() {
 		if err := s.App().Listen(cfg.HTTPAddr); err != nil {
@@ -42,5 +77,6 @@ func main() {
 	sig := make(chan os.Signal, 1)
 	signal.Notify(sig, syscall.SIGINT, syscall.SIGTERM)
 	<-sig
+	stop()
 	_ = s.App().Shutdown()
 }
\ No newline at end of file
@@ -1,6 +1,6 @@
 module redcat
 
-go 1.23.0
+go 1.24.9
 
 require (
 	github.com/gofiber/adaptor/v2 v2.2.1
@@ -8,6 +8,8 @@ require (
 	github.com/parquet-go/parquet-go v0.27.0
 	github.com/prometheus/client_golang v1.20.5
 	github.com/redis/rueidis v1.0.68
+	google.golang.org/protobuf v1.34.2
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
@@ -32,5 +34,4 @@ require (
 	github.com/valyala/fasthttp v1.51.0 // indirect
 	github.com/valyala/tcplisten v1.0.0 // indirect
 	golang.org/x/sys v0.38.0 // indirect
-	google.golang.org/protobuf v1.34.2 // indirect
 )
@@ -0,0 +1,58 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/netip"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
+	"redcat/internal/domain/audit"
+)
+
+// ActorHeader carries the authenticated user, set by the auth proxy in front
+// of the API. Anyone can send it, so it is only believed on requests coming
+// straight from one of Handlers.ActorProxies; all others are recorded as
+// audit.Anonymous.
+const ActorHeader = "X-Forwarded-User"
+
+// actorContext returns the request context annotated with the actor for the
+// audit trail. The header value is copied because fiber reuses its buffer.
+func (h Handlers) actorContext(c *fiber.Ctx) context.Context {
+	if !h.fromActorProxy(c) { return audit.WithActor(c.Context(), audit.Anonymous) }
+	return audit.WithActor(c.Context(), utils.CopyString(c.Get(ActorHeader)))
+}
+
+// fromActorProxy reports whether the peer of the connection, not an address
+// taken from forwarding headers, is a trusted proxy.
+func (h Handlers) fromActorProxy(c *fiber.Ctx) bool {
+	if len(h.ActorProxies) == 0 { return false }
+	addr, ok := netip.AddrFromSlice(c.Context().RemoteIP())
+	if !ok { return false }
+	addr = addr.Unmap()
+	for _, p := range h.ActorProxies {
+		if p.Contains(addr) { return true }
+	}
+	return false
+}
+
+// ParseActorProxies parses proxy addresses for Handlers.ActorProxies, each an
+// IP or a CIDR prefix.
+func ParseActorProxies(list []string) ([]netip.Prefix, error) {
+	out := make([]netip.Prefix, 0, len(list))
+	for _, s := range list {
+		s = strings.TrimSpace(s)
+		if s == "" { continue }
+		if !strings.Contains(s, "/") {
+			addr, err := netip.ParseAddr(s)
+			if err != nil { return nil, fmt.Errorf("actor proxy %q: %w", s, err) }
+			out = append(out, netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen()))
+			continue
+		}
+		p, err := netip.ParsePrefix(s)
+		if err != nil { return nil, fmt.Errorf("actor proxy %q: %w", s, err) }
+		out = append(out, p.Masked())
+	}
+	return out, nil
+}
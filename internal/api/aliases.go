@@ -0,0 +1,50 @@
+package api
+
+import (
+	"log/slog"
+	"net/http"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
+)
+
+// registerAliases adds the endpoints managing old place IDs that
+// GET /places/:id redirects to their canonical place.
+func registerAliases(app *fiber.App, h Handlers) {
+	app.Post("/api/v1/aliases", func(c *fiber.Ctx) error {
+		var req AliasBatch
+		if err := h.decodeBody(c, &req); err != nil {
+			slog.Warn("register aliases: invalid body", slog.String("error", err.Error()))
+			return err
+		}
+		if err := req.validate(); err != nil {
+			return err
+		}
+
+		if err := h.Places.RegisterAliases(c.Context(), req.ToModel()); err != nil {
+			return err
+		}
+
+		slog.Info("aliases registered", slog.Int("count", len(req.Aliases)))
+		return c.SendStatus(http.StatusNoContent)
+	})
+
+	app.Get("/api/v1/aliases/:id", func(c *fiber.Ctx) error {
+		id := utils.CopyString(c.Params("id"))
+		to, err := h.Places.Alias(c.Context(), id)
+		if err != nil {
+			return err
+		}
+		return c.JSON(Alias{ID: id, CanonicalID: to})
+	})
+
+	app.Delete("/api/v1/aliases/:id", func(c *fiber.Ctx) error {
+		id := utils.CopyString(c.Params("id"))
+		if err := h.Places.DeleteAlias(c.Context(), id); err != nil {
+			return err
+		}
+
+		slog.Info("alias deleted", slog.String("id", id))
+		return c.SendStatus(http.StatusNoContent)
+	})
+}
@@ -0,0 +1,73 @@
+package api_test
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"redcat/internal/api"
+	"redcat/internal/domain/model"
+	svc "redcat/internal/service/places"
+)
+
+func TestAliases(t *testing.T) {
+	spec := loadSpec(t)
+	store := newMemStore(
+		model.Place{ID: "new1", Name: "New 1", Lat: 1, Lon: 1, CategoryIDs: []string{"cafe"}, Version: 1},
+		model.Place{ID: "new2", Name: "New 2", Lat: 2, Lon: 2, CategoryIDs: []string{"cafe"}, Version: 1},
+	)
+	app := fiber.New()
+	api.Register(app, api.Handlers{Places: svc.New(store)})
+	alias := func(id, to string) map[string]any { return map[string]any{"id": id, "canonical_id": to} }
+	register := func(aliases ...map[string]any) (int, any) {
+		return doJSON(t, app, http.MethodPost, "/api/v1/aliases", map[string]any{"aliases": aliases})
+	}
+
+	if status, body := register(alias("old1", "new1"), alias("older", "old1"), alias("new2", "new1")); status != http.StatusNoContent {
+		t.Fatalf("register: expected 204, got %d: %v", status, body)
+	}
+
+	// aliases resolve through chains; live places are never shadowed
+	for id, want := range map[string]string{"old1": "new1", "older": "new1"} {
+		status, body := doJSON(t, app, http.MethodGet, "/api/v1/places/"+id, nil)
+		if status != http.StatusMovedPermanently { t.Fatalf("get %s: expected 301, got %d: %v", id, status, body) }
+		for _, e := range spec.validate(spec.schema("PlaceMoved"), body, "PlaceMoved") {
+			t.Error(e)
+		}
+		if m := body.(map[string]any); m["id"] != id || m["moved_to"] != want { t.Fatalf("get %s: %v", id, m) }
+
+		status, body = doJSON(t, app, http.MethodGet, "/api/v1/aliases/"+id, nil)
+		if status != http.StatusOK || body.(map[string]any)["canonical_id"] != want { t.Fatalf("alias %s: %d %v", id, status, body) }
+		for _, e := range spec.validate(spec.schema("Alias"), body, "Alias") {
+			t.Error(e)
+		}
+	}
+	if status, body := doJSON(t, app, http.MethodGet, "/api/v1/places/new2", nil); status != http.StatusOK || body.(map[string]any)["id"] != "new2" {
+		t.Fatalf("live place with an alias: want 200 new2, got %d: %v", status, body)
+	}
+
+	for name, aliases := range map[string][]map[string]any{
+		"empty":        {},
+		"self":         {alias("x", "x")},
+		"cycle":        {alias("new1", "older")},
+		"batch cycle":  {alias("p", "q"), alias("q", "p")},
+		"long cycle":   {alias("a", "b"), alias("b", "c"), alias("c", "a")},
+		"mixed cycle":  {alias("new1", "m"), alias("m", "older")},
+		"listed twice": {alias("x", "new1"), alias("x", "new2")},
+		"bad id":       {alias("x y", "new1")},
+	} {
+		if status, body := register(aliases...); status != http.StatusBadRequest {
+			t.Errorf("register %s: expected 400, got %d: %v", name, status, body)
+		}
+	}
+
+	if status, _ := doJSON(t, app, http.MethodDelete, "/api/v1/aliases/old1", nil); status != http.StatusNoContent {
+		t.Fatalf("delete: expected 204, got %d", status)
+	}
+	if status, _ := doJSON(t, app, http.MethodDelete, "/api/v1/aliases/old1", nil); status != http.StatusNotFound {
+		t.Fatalf("delete again: expected 404, got %d", status)
+	}
+	if status, _ := doJSON(t, app, http.MethodGet, "/api/v1/places/old1", nil); status != http.StatusNotFound {
+		t.Fatalf("get deleted alias: expected 404, got %d", status)
+	}
+}
@@ -0,0 +1,726 @@
+package api
+
+import (
+	"encoding/json"
+	"time"
+
+	"redcat/internal/domain/audit"
+	"redcat/internal/domain/dedupe"
+	"redcat/internal/domain/events"
+	"redcat/internal/domain/geo"
+	"redcat/internal/domain/model"
+	wh "redcat/internal/domain/webhooks"
+	svc "redcat/internal/service/places"
+)
+
+// Request/response bodies mirroring the schemas in api/openapi.yaml.
+// Handlers never serialise model.Place directly; they map through these.
+
+type Location struct {
+	Lat float64 `json:"lat"`
+	Lon float64 `json:"lon"`
+}
+
+type Address struct {
+	Street   string `json:"street,omitempty"`
+	Locality string `json:"locality,omitempty"`
+	Region   string `json:"region,omitempty"`
+	Postcode string `json:"postcode,omitempty"`
+	Country  string `json:"country,omitempty"`
+}
+
+type Contact struct {
+	Phone      string `json:"phone,omitempty"`
+	Website    string `json:"website,omitempty"`
+	Email      string `json:"email,omitempty"`
+	FacebookID string `json:"facebook_id,omitempty"`
+	Instagram  string `json:"instagram,omitempty"`
+	Twitter    string `json:"twitter,omitempty"`
+}
+
+type Social struct {
+	FacebookID string `json:"facebook_id,omitempty"`
+	Instagram  string `json:"instagram,omitempty"`
+	Twitter    string `json:"twitter,omitempty"`
+}
+
+type PlaceDates struct {
+	Created   string `json:"created,omitempty"`
+	Refreshed string `json:"refreshed,omitempty"`
+	Closed    string `json:"closed,omitempty"`
+}
+
+type BBox struct {
+	XMin float64 `json:"xmin"`
+	YMin float64 `json:"ymin"`
+	XMax float64 `json:"xmax"`
+	YMax float64 `json:"ymax"`
+}
+
+// Place is the Place schema.
+type Place struct {
+	ID             string      `json:"id"`
+	Name           string      `json:"name"`
+	Location       Location    `json:"location"`
+	Address        *Address    `json:"address,omitempty"`
+	Locality       string      `json:"locality,omitempty"`
+	Region         string      `json:"region,omitempty"`
+	Postcode       string      `json:"postcode,omitempty"`
+	AdminRegion    string      `json:"admin_region,omitempty"`
+	PostTown       string      `json:"post_town,omitempty"`
+	PoBox          string      `json:"po_box,omitempty"`
+	Country        string      `json:"country,omitempty"`
+	CategoryIDs    []string    `json:"category_ids"`
+	CategoryLabels []string    `json:"category_labels,omitempty"`
+	Contact        *Contact    `json:"contact,omitempty"`
+	Dates          *PlaceDates `json:"dates,omitempty"`
+	Social         *Social     `json:"social,omitempty"`
+	PlacemakerURL  string      `json:"placemaker_url,omitempty"`
+	BBox           *BBox       `json:"bbox,omitempty"`
+	Dt             string      `json:"dt,omitempty"`
+}
+
+// PlaceWithDistance is a search hit.
+type PlaceWithDistance struct {
+	Place
+	DistanceM float64 `json:"distance_m"`
+	// CategoryID is the requested category a diversified search matched.
+	CategoryID string `json:"category_id,omitempty"`
+}
+
+// PlaceCreate is the body of POST /places. Fields beyond the public
+// PlaceCreate schema (admin_region, dates, ...) carry the full Foursquare
+// record for loaders like cmd/migrator.
+type PlaceCreate struct {
+	ID             string      `json:"id"`
+	Name           string      `json:"name"`
+	Location       *Location   `json:"location"`
+	Address        *Address    `json:"address,omitempty"`
+	AdminRegion    string      `json:"admin_region,omitempty"`
+	PostTown       string      `json:"post_town,omitempty"`
+	PoBox          string      `json:"po_box,omitempty"`
+	CategoryIDs    []string    `json:"category_ids"`
+	CategoryLabels []string    `json:"category_labels,omitempty"`
+	Contact        *Contact    `json:"contact,omitempty"`
+	Social         *Social     `json:"social,omitempty"`
+	Dates          *PlaceDates `json:"dates,omitempty"`
+	PlacemakerURL  string      `json:"placemaker_url,omitempty"`
+	BBox           *BBox       `json:"bbox,omitempty"`
+	Dt             string      `json:"dt,omitempty"`
+}
+
+// PlaceUpdate is the body of PUT /places/{id}; nil members are left unchanged.
+type PlaceUpdate struct {
+	Name        *string   `json:"name,omitempty"`
+	Location    *Location `json:"location,omitempty"`
+	Address     *Address  `json:"address,omitempty"`
+	CategoryIDs []string  `json:"category_ids,omitempty"`
+	Contact     *Contact  `json:"contact,omitempty"`
+	Social      *Social   `json:"social,omitempty"`
+}
+
+type SearchRequest struct {
+	Location     *Location        `json:"location"`
+	CategoryIDs  []string         `json:"category_ids"`
+	Limit        int64            `json:"limit"`
+	DistanceMode geo.DistanceMode `json:"distance_mode"`
+	Fields       []string         `json:"fields"`
+	Hydrate      string           `json:"hydrate"`
+	// PerCategoryLimit searches each of CategoryIDs separately, returning
+	// up to this many places per category ordered by Diversify.
+	PerCategoryLimit int64         `json:"per_category_limit"`
+	Diversify        svc.Diversify `json:"diversify"`
+}
+
+// BatchSearchQuery is one point of a batch search; filters left unset fall
+// back to the batch-level values.
+type BatchSearchQuery struct {
+	Location     *Location        `json:"location"`
+	CategoryIDs  []string         `json:"category_ids,omitempty"`
+	Limit        int64            `json:"limit,omitempty"`
+	DistanceMode geo.DistanceMode `json:"distance_mode,omitempty"`
+}
+
+type BatchSearchRequest struct {
+	Queries      []BatchSearchQuery `json:"queries"`
+	CategoryIDs  []string           `json:"category_ids"`
+	Limit        int64              `json:"limit"`
+	DistanceMode geo.DistanceMode   `json:"distance_mode"`
+	Fields       []string           `json:"fields"`
+	Hydrate      string             `json:"hydrate"`
+}
+
+// BatchSearchItem is the outcome of one query; Error is set instead of
+// results when that query failed.
+type BatchSearchItem struct {
+	Places []PlaceWithDistance `json:"places"`
+	Total  int                 `json:"total"`
+	Error  *ErrorResponse      `json:"error,omitempty"`
+}
+
+// BatchSearchResponse lists one item per query, in request order.
+type BatchSearchResponse struct {
+	Results []BatchSearchItem `json:"results"`
+}
+
+// MatrixPoint is an origin or destination of the distance matrix: exactly
+// one of PlaceID and Location. Responses always carry the location.
+type MatrixPoint struct {
+	PlaceID  string    `json:"place_id,omitempty"`
+	Location *Location `json:"location,omitempty"`
+}
+
+type DistanceMatrixRequest struct {
+	Origins      []MatrixPoint    `json:"origins"`
+	Destinations []MatrixPoint    `json:"destinations"`
+	DistanceMode geo.DistanceMode `json:"distance_mode"`
+}
+
+type MatrixElement struct {
+	DistanceM  float64 `json:"distance_m"`
+	BearingDeg float64 `json:"bearing_deg"`
+}
+
+// DistanceMatrixResponse has Rows[i][j] from Origins[i] to Destinations[j].
+type DistanceMatrixResponse struct {
+	Origins      []MatrixPoint     `json:"origins"`
+	Destinations []MatrixPoint     `json:"destinations"`
+	DistanceMode geo.DistanceMode  `json:"distance_mode"`
+	Rows         [][]MatrixElement `json:"rows"`
+}
+
+// FacetsRequest selects the area to count: radius_m around location, or bbox.
+type FacetsRequest struct {
+	Location    *Location `json:"location,omitempty"`
+	RadiusM     float64   `json:"radius_m,omitempty"`
+	BBox        *BBox     `json:"bbox,omitempty"`
+	CategoryIDs []string  `json:"category_ids"`
+	Top         int64     `json:"top"`
+	Labels      bool      `json:"labels"`
+}
+
+type FacetBucket struct {
+	Value string `json:"value"`
+	Count int64  `json:"count"`
+	Label string `json:"label,omitempty"`
+}
+
+type FacetsResponse struct {
+	Total      int64         `json:"total"`
+	Categories []FacetBucket `json:"categories"`
+	Countries  []FacetBucket `json:"countries"`
+}
+
+// HeatmapCell is the number of places in an H3 cell.
+type HeatmapCell struct {
+	Cell  string `json:"cell"`
+	Count int64  `json:"count"`
+}
+
+// HeatmapResponse lists the fullest cells first; Truncated reports that
+// more cells than listed hold places.
+type HeatmapResponse struct {
+	Res       int           `json:"res"`
+	Cells     []HeatmapCell `json:"cells"`
+	Truncated bool          `json:"truncated"`
+}
+
+func heatmapCells(in []svc.HeatmapCell) []HeatmapCell {
+	out := make([]HeatmapCell, len(in))
+	for i, c := range in { out[i] = HeatmapCell{Cell: c.Cell, Count: c.Count} }
+	return out
+}
+
+// Cluster is the places of a grid cell; Cell is the cell's z/x/y tile and
+// Location the mean position of its places.
+type Cluster struct {
+	Cell       string        `json:"cell"`
+	Count      int64         `json:"count"`
+	Location   Location      `json:"location"`
+	PlaceIDs   []string      `json:"place_ids"`
+	Categories []FacetBucket `json:"categories"`
+}
+
+type ClustersResponse struct {
+	Zoom     int       `json:"zoom"`
+	Total    int64     `json:"total"`
+	Clusters []Cluster `json:"clusters"`
+}
+
+func clusters(zoom int, in []svc.Cluster) ClustersResponse {
+	out := ClustersResponse{Zoom: zoom, Clusters: make([]Cluster, len(in))}
+	for i, c := range in {
+		out.Total += c.Count
+		out.Clusters[i] = Cluster{
+			Cell: c.Cell.String(), Count: c.Count, Location: Location{Lat: c.Lat, Lon: c.Lon},
+			PlaceIDs: c.PlaceIDs, Categories: facetBuckets(c.Categories),
+		}
+	}
+	return out
+}
+
+// ReverseComponent is an inferred address component; confidence is in
+// [0, 1].
+type ReverseComponent struct {
+	Value      string  `json:"value"`
+	Confidence float64 `json:"confidence"`
+}
+
+// ReverseAddress is the street address of the nearest place having one.
+type ReverseAddress struct {
+	Street    string  `json:"street"`
+	PlaceID   string  `json:"place_id"`
+	DistanceM float64 `json:"distance_m"`
+}
+
+// ReverseResponse leaves out components no nearby place has.
+type ReverseResponse struct {
+	Location   Location          `json:"location"`
+	Address    *ReverseAddress   `json:"address,omitempty"`
+	Locality   *ReverseComponent `json:"locality,omitempty"`
+	Region     *ReverseComponent `json:"region,omitempty"`
+	Postcode   *ReverseComponent `json:"postcode,omitempty"`
+	Country    *ReverseComponent `json:"country,omitempty"`
+	Neighbours int               `json:"neighbours"`
+}
+
+func reverseResponse(loc Location, r svc.ReverseResult) ReverseResponse {
+	comp := func(c svc.ReverseComponent) *ReverseComponent {
+		if c.Value == "" { return nil }
+		return &ReverseComponent{Value: c.Value, Confidence: c.Confidence}
+	}
+	out := ReverseResponse{
+		Location: loc, Locality: comp(r.Locality), Region: comp(r.Region), Postcode: comp(r.Postcode), Country: comp(r.Country),
+		Neighbours: r.Neighbours,
+	}
+	if r.Address != "" { out.Address = &ReverseAddress{Street: r.Address, PlaceID: r.AddressPlaceID, DistanceM: r.AddressDistanceM} }
+	return out
+}
+
+// RouteSearchRequest takes the route as exactly one of an encoded polyline
+// and a GeoJSON LineString.
+type RouteSearchRequest struct {
+	Polyline          string        `json:"polyline,omitempty"`
+	PolylinePrecision int           `json:"polyline_precision,omitempty"`
+	Line              *geo.Geometry `json:"line,omitempty"`
+	BufferM           float64       `json:"buffer_m"`
+	CategoryIDs       []string      `json:"category_ids"`
+	Limit             int64         `json:"limit"`
+	Fields            []string      `json:"fields"`
+	Hydrate           string        `json:"hydrate"`
+}
+
+// PlaceAlongRoute is a corridor hit; DistanceM is its distance from the route.
+type PlaceAlongRoute struct {
+	Place
+	DistanceM float64 `json:"distance_m"`
+	AlongM    float64 `json:"along_m"`
+	DetourM   float64 `json:"detour_m"`
+}
+
+type RouteSearchResponse struct {
+	Places []PlaceAlongRoute `json:"places"`
+	Total  int               `json:"total"`
+	// Complete is false when dense areas may hold more matching places
+	// than were found.
+	Complete bool    `json:"complete"`
+	LengthM  float64 `json:"length_m"`
+}
+
+type SearchQuery struct {
+	Location         Location `json:"location"`
+	Limit            int64    `json:"limit"`
+	PerCategoryLimit int64    `json:"per_category_limit,omitempty"`
+}
+
+type SearchResponse struct {
+	Places []PlaceWithDistance `json:"places"`
+	Total  int                 `json:"total"`
+	Query  SearchQuery         `json:"query"`
+}
+
+// HistoryEntry is one audit record of GET /places/{id}/history.
+type HistoryEntry struct {
+	ID      string         `json:"id"`
+	Action  string         `json:"action"`
+	Actor   string         `json:"actor"`
+	At      string         `json:"at"`
+	Changes []audit.Change `json:"changes"`
+}
+
+type HistoryResponse struct {
+	Entries    []HistoryEntry `json:"entries"`
+	NextCursor string         `json:"next_cursor,omitempty"`
+}
+
+// ChangeEvent is one mutation of GET /changes; Place is absent for deletes.
+type ChangeEvent struct {
+	ID      string `json:"id"`
+	PlaceID string `json:"place_id"`
+	Action  string `json:"action"`
+	At      string `json:"at"`
+	Version int64  `json:"version"`
+	Place   *Place `json:"place,omitempty"`
+}
+
+type ChangesResponse struct {
+	Events []ChangeEvent `json:"events"`
+	Cursor string        `json:"cursor"`
+}
+
+// WebhookFilter narrows which mutations a webhook receives; every set
+// criterion must hold.
+type WebhookFilter struct {
+	BBox        *BBox    `json:"bbox,omitempty"`
+	Countries   []string `json:"countries,omitempty"`
+	CategoryIDs []string `json:"category_ids,omitempty"`
+}
+
+type WebhookCreate struct {
+	URL    string        `json:"url"`
+	Secret string        `json:"secret"`
+	Filter WebhookFilter `json:"filter"`
+}
+
+// Webhook is a registered subscription; the secret is never returned.
+type Webhook struct {
+	ID        string        `json:"id"`
+	URL       string        `json:"url"`
+	Filter    WebhookFilter `json:"filter"`
+	CreatedAt string        `json:"created_at"`
+}
+
+type WebhookList struct {
+	Webhooks []Webhook `json:"webhooks"`
+}
+
+// DeadLetter is a delivery that exhausted its attempts.
+type DeadLetter struct {
+	ID             string          `json:"id"`
+	SubscriptionID string          `json:"subscription_id"`
+	Event          string          `json:"event"`
+	Attempts       int             `json:"attempts"`
+	LastError      string          `json:"last_error"`
+	CreatedAt      string          `json:"created_at"`
+	Payload        json.RawMessage `json:"payload"`
+}
+
+type DeadLetterList struct {
+	Deliveries []DeadLetter `json:"deliveries"`
+}
+
+// PlaceMoved is the body of the 301 answered for an alias of a place.
+type PlaceMoved struct {
+	ID      string `json:"id"`
+	MovedTo string `json:"moved_to"`
+}
+
+// Alias maps an old place ID to the ID the place lives on as.
+type Alias struct {
+	ID          string `json:"id"`
+	CanonicalID string `json:"canonical_id"`
+}
+
+// AliasBatch is the body of POST /aliases.
+type AliasBatch struct {
+	Aliases []Alias `json:"aliases"`
+}
+
+// ToModel maps old IDs to canonical ones.
+func (r AliasBatch) ToModel() map[string]string {
+	out := make(map[string]string, len(r.Aliases))
+	for _, a := range r.Aliases { out[a.ID] = a.CanonicalID }
+	return out
+}
+
+// DuplicateCandidate is a pair of places queued as likely duplicates; see
+// dedupe.Score for the scores.
+type DuplicateCandidate struct {
+	A          DuplicateEntry `json:"a"`
+	B          DuplicateEntry `json:"b"`
+	Score      float64        `json:"score"`
+	Scores     dedupe.Scores  `json:"scores"`
+	DistanceM  float64        `json:"distance_m"`
+	DetectedAt string         `json:"detected_at"`
+}
+
+type DuplicateEntry struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+type DuplicateList struct {
+	Candidates []DuplicateCandidate `json:"candidates"`
+}
+
+// MergeRequest is the body of POST /places/{id}/merge.
+type MergeRequest struct {
+	DuplicateID string `json:"duplicate_id"`
+}
+
+// GeofenceCreate is the body of POST /geofences; GeofenceUpdate of PUT,
+// which replaces the whole fence.
+type GeofenceCreate struct {
+	ID         string         `json:"id,omitempty"`
+	Name       string         `json:"name"`
+	Geometry   *geo.Geometry  `json:"geometry"`
+	Properties map[string]any `json:"properties,omitempty"`
+}
+
+type GeofenceUpdate struct {
+	Name       string         `json:"name"`
+	Geometry   *geo.Geometry  `json:"geometry"`
+	Properties map[string]any `json:"properties,omitempty"`
+}
+
+// Geofence is the Geofence schema; Geometry is omitted from lookups unless
+// requested.
+type Geofence struct {
+	ID         string         `json:"id"`
+	Name       string         `json:"name"`
+	Geometry   *geo.Geometry  `json:"geometry,omitempty"`
+	Properties map[string]any `json:"properties,omitempty"`
+	BBox       BBox           `json:"bbox"`
+	AreaM2     float64        `json:"area_m2"`
+}
+
+type GeofenceLookupRequest struct {
+	Location        *Location `json:"location"`
+	IncludeGeometry bool      `json:"include_geometry,omitempty"`
+}
+
+type GeofenceLookupResponse struct {
+	Geofences []Geofence `json:"geofences"`
+}
+
+// PlaceFromModel maps a stored place to the Place schema. Empty nested
+// objects are omitted.
+func PlaceFromModel(p model.Place) Place {
+	cats := p.CategoryIDs
+	if cats == nil { cats = []string{} }
+	out := Place{
+		ID:             p.ID,
+		Name:           p.Name,
+		Location:       Location{Lat: p.Lat, Lon: p.Lon},
+		Locality:       p.Locality,
+		Region:         p.Region,
+		Postcode:       p.Postcode,
+		AdminRegion:    p.AdminRegion,
+		PostTown:       p.PostTown,
+		PoBox:          p.PoBox,
+		Country:        p.Country,
+		CategoryIDs:    cats,
+		CategoryLabels: p.CategoryLabels,
+		PlacemakerURL:  p.PlacemakerURL,
+		Dt:             p.Dt,
+	}
+	if a := (Address{Street: p.Address, Locality: p.Locality, Region: p.Region, Postcode: p.Postcode, Country: p.Country}); a != (Address{}) {
+		out.Address = &a
+	}
+	if c := (Contact{Phone: p.Tel, Website: p.Website, Email: p.Email, FacebookID: p.FacebookID, Instagram: p.Instagram, Twitter: p.Twitter}); c != (Contact{}) {
+		out.Contact = &c
+	}
+	if s := (Social{FacebookID: p.FacebookID, Instagram: p.Instagram, Twitter: p.Twitter}); s != (Social{}) {
+		out.Social = &s
+	}
+	if d := (PlaceDates{Created: p.DateCreated, Refreshed: p.DateRefreshed, Closed: p.DateClosed}); d != (PlaceDates{}) {
+		out.Dates = &d
+	}
+	if b := (BBox{XMin: p.BBox.XMin, YMin: p.BBox.YMin, XMax: p.BBox.XMax, YMax: p.BBox.YMax}); b != (BBox{}) {
+		out.BBox = &b
+	}
+	return out
+}
+
+// ToModel maps a create request onto model.Place.
+func (r PlaceCreate) ToModel() model.Place {
+	p := model.Place{
+		ID:             r.ID,
+		Name:           r.Name,
+		AdminRegion:    r.AdminRegion,
+		PostTown:       r.PostTown,
+		PoBox:          r.PoBox,
+		CategoryIDs:    r.CategoryIDs,
+		CategoryLabels: r.CategoryLabels,
+		PlacemakerURL:  r.PlacemakerURL,
+		Dt:             r.Dt,
+	}
+	if r.Location != nil { p.Lat, p.Lon = r.Location.Lat, r.Location.Lon }
+	if r.Address != nil { applyAddress(&p, *r.Address) }
+	if r.Contact != nil { applyContact(&p, *r.Contact) }
+	if r.Social != nil { applySocial(&p, *r.Social) }
+	if d := r.Dates; d != nil {
+		p.DateCreated, p.DateRefreshed, p.DateClosed = d.Created, d.Refreshed, d.Closed
+	}
+	if b := r.BBox; b != nil {
+		p.BBox.XMin, p.BBox.YMin, p.BBox.XMax, p.BBox.YMax = b.XMin, b.YMin, b.XMax, b.YMax
+	}
+	return p
+}
+
+// ApplyTo merges the non-nil members of r into p.
+func (r PlaceUpdate) ApplyTo(p *model.Place) {
+	if r.Name != nil { p.Name = *r.Name }
+	if r.Location != nil { p.Lat, p.Lon = r.Location.Lat, r.Location.Lon }
+	if r.Address != nil { applyAddress(p, *r.Address) }
+	if r.CategoryIDs != nil { p.CategoryIDs = r.CategoryIDs }
+	if r.Contact != nil { applyContact(p, *r.Contact) }
+	if r.Social != nil { applySocial(p, *r.Social) }
+}
+
+func applyAddress(p *model.Place, a Address) {
+	p.Address, p.Locality, p.Region, p.Postcode, p.Country = a.Street, a.Locality, a.Region, a.Postcode, a.Country
+}
+
+func applyContact(p *model.Place, c Contact) {
+	p.Tel, p.Website, p.Email = c.Phone, c.Website, c.Email
+	if c.FacebookID != "" { p.FacebookID = c.FacebookID }
+	if c.Instagram != "" { p.Instagram = c.Instagram }
+	if c.Twitter != "" { p.Twitter = c.Twitter }
+}
+
+func applySocial(p *model.Place, s Social) {
+	p.FacebookID, p.Instagram, p.Twitter = s.FacebookID, s.Instagram, s.Twitter
+}
+
+func historyEntries(entries []audit.Entry) []HistoryEntry {
+	out := make([]HistoryEntry, 0, len(entries))
+	for _, e := range entries {
+		changes := e.Changes
+		if changes == nil { changes = []audit.Change{} }
+		out = append(out, HistoryEntry{
+			ID: e.ID, Action: string(e.Action), Actor: e.Actor,
+			At: e.At.UTC().Format(time.RFC3339Nano), Changes: changes,
+		})
+	}
+	return out
+}
+
+func changeEvents(evs []events.PlaceChanged) []ChangeEvent {
+	out := make([]ChangeEvent, 0, len(evs))
+	for _, e := range evs {
+		ce := ChangeEvent{
+			ID: e.ID, PlaceID: e.PlaceID, Action: string(e.Action),
+			At: e.At.UTC().Format(time.RFC3339Nano), Version: e.Version,
+		}
+		if e.Place != nil {
+			p := PlaceFromModel(*e.Place)
+			ce.Place = &p
+		}
+		out = append(out, ce)
+	}
+	return out
+}
+
+func placesByCategory(res []svc.CategoryResult) []PlaceWithDistance {
+	out := make([]PlaceWithDistance, 0, len(res))
+	for _, r := range res {
+		out = append(out, PlaceWithDistance{Place: PlaceFromModel(r.Place), DistanceM: r.DistanceM, CategoryID: r.CategoryID})
+	}
+	return out
+}
+
+func placesWithDistance(res []svc.SearchResult) []PlaceWithDistance {
+	out := make([]PlaceWithDistance, 0, len(res))
+	for _, r := range res {
+		out = append(out, PlaceWithDistance{Place: PlaceFromModel(r.Place), DistanceM: r.DistanceM})
+	}
+	return out
+}
+
+func (r WebhookCreate) ToModel() wh.Subscription {
+	f := wh.Filter{Countries: r.Filter.Countries, CategoryIDs: r.Filter.CategoryIDs}
+	if b := r.Filter.BBox; b != nil { f.BBox = &wh.BBox{XMin: b.XMin, YMin: b.YMin, XMax: b.XMax, YMax: b.YMax} }
+	return wh.Subscription{URL: r.URL, Secret: r.Secret, Filter: f}
+}
+
+func webhookFromModel(sub wh.Subscription) Webhook {
+	f := WebhookFilter{Countries: sub.Filter.Countries, CategoryIDs: sub.Filter.CategoryIDs}
+	if b := sub.Filter.BBox; b != nil { f.BBox = &BBox{XMin: b.XMin, YMin: b.YMin, XMax: b.XMax, YMax: b.YMax} }
+	return Webhook{ID: sub.ID, URL: sub.URL, Filter: f, CreatedAt: sub.CreatedAt.UTC().Format(time.RFC3339Nano)}
+}
+
+func webhookList(subs []wh.Subscription) WebhookList {
+	out := make([]Webhook, 0, len(subs))
+	for _, sub := range subs { out = append(out, webhookFromModel(sub)) }
+	return WebhookList{Webhooks: out}
+}
+
+func deadLetterList(ds []wh.Delivery) DeadLetterList {
+	out := make([]DeadLetter, 0, len(ds))
+	for _, d := range ds {
+		out = append(out, DeadLetter{
+			ID: d.ID, SubscriptionID: d.SubscriptionID, Event: d.Event, Attempts: d.Attempts,
+			LastError: d.LastError, CreatedAt: d.CreatedAt.UTC().Format(time.RFC3339Nano), Payload: d.Payload,
+		})
+	}
+	return DeadLetterList{Deliveries: out}
+}
+
+func duplicateList(cs []dedupe.Candidate) DuplicateList {
+	out := make([]DuplicateCandidate, 0, len(cs))
+	for _, c := range cs {
+		out = append(out, DuplicateCandidate{
+			A: DuplicateEntry(c.A), B: DuplicateEntry(c.B), Score: c.Score, Scores: c.Scores,
+			DistanceM: c.DistanceM, DetectedAt: c.DetectedAt.UTC().Format(time.RFC3339Nano),
+		})
+	}
+	return DuplicateList{Candidates: out}
+}
+
+func GeofenceFromModel(f model.Geofence, withGeometry bool) Geofence {
+	b := f.Geometry.Bounds()
+	out := Geofence{
+		ID: f.ID, Name: f.Name, Properties: f.Properties,
+		BBox:   BBox{XMin: b.XMin, YMin: b.YMin, XMax: b.XMax, YMax: b.YMax},
+		AreaM2: f.Geometry.Area(),
+	}
+	if withGeometry {
+		g := f.Geometry.GeoJSON()
+		out.Geometry = &g
+	}
+	return out
+}
+
+func geofenceLookup(fs []model.Geofence, withGeometry bool) GeofenceLookupResponse {
+	out := make([]Geofence, 0, len(fs))
+	for _, f := range fs { out = append(out, GeofenceFromModel(f, withGeometry)) }
+	return GeofenceLookupResponse{Geofences: out}
+}
+
+func matrixPoints(pts []MatrixPoint) []svc.MatrixPoint {
+	out := make([]svc.MatrixPoint, len(pts))
+	for i, p := range pts {
+		out[i].PlaceID = p.PlaceID
+		if p.Location != nil { out[i].Lat, out[i].Lon = p.Location.Lat, p.Location.Lon }
+	}
+	return out
+}
+
+func distanceMatrix(m svc.Matrix, mode geo.DistanceMode) DistanceMatrixResponse {
+	points := func(pts []svc.MatrixPoint) []MatrixPoint {
+		out := make([]MatrixPoint, len(pts))
+		for i, p := range pts { out[i] = MatrixPoint{PlaceID: p.PlaceID, Location: &Location{Lat: p.Lat, Lon: p.Lon}} }
+		return out
+	}
+	rows := make([][]MatrixElement, len(m.Cells))
+	for i, row := range m.Cells {
+		rows[i] = make([]MatrixElement, len(row))
+		for j, c := range row { rows[i][j] = MatrixElement{DistanceM: c.DistanceM, BearingDeg: c.BearingDeg} }
+	}
+	return DistanceMatrixResponse{Origins: points(m.Origins), Destinations: points(m.Destinations), DistanceMode: mode, Rows: rows}
+}
+
+func placesAlongRoute(res []svc.RouteResult) []PlaceAlongRoute {
+	out := make([]PlaceAlongRoute, 0, len(res))
+	for _, r := range res {
+		out = append(out, PlaceAlongRoute{Place: PlaceFromModel(r.Place), DistanceM: r.OffsetM, AlongM: r.AlongM, DetourM: r.DetourM})
+	}
+	return out
+}
+
+func facetBuckets(in []svc.FacetCount) []FacetBucket {
+	out := make([]FacetBucket, len(in))
+	for i, c := range in { out[i] = FacetBucket{Value: c.Value, Count: c.Count, Label: c.Label} }
+	return out
+}
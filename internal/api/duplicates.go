@@ -0,0 +1,63 @@
+package api
+
+import (
+	"log/slog"
+	"net/http"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
+)
+
+// registerDuplicates adds the review queue of likely duplicate places and
+// the merge endpoint.
+func registerDuplicates(app *fiber.App, h Handlers) {
+	app.Get("/api/v1/duplicates", func(c *fiber.Ctx) error {
+		limit, err := duplicatesLimit(c)
+		if err != nil {
+			return err
+		}
+
+		cs, err := h.Places.Duplicates(c.Context(), limit)
+		if err != nil {
+			return err
+		}
+		return c.JSON(duplicateList(cs))
+	})
+
+	app.Delete("/api/v1/duplicates/:a/:b", func(c *fiber.Ctx) error {
+		a, b := utils.CopyString(c.Params("a")), utils.CopyString(c.Params("b"))
+		if err := h.Places.DismissDuplicate(c.Context(), a, b); err != nil {
+			return err
+		}
+
+		slog.Info("duplicate dismissed", slog.String("a", a), slog.String("b", b))
+		return c.SendStatus(http.StatusNoContent)
+	})
+
+	app.Post("/api/v1/places/:id/merge", func(c *fiber.Ctx) error {
+		id := placeID(c)
+		var req MergeRequest
+		if err := h.decodeBody(c, &req); err != nil {
+			slog.Warn("merge places: invalid body", slog.String("error", err.Error()))
+			return err
+		}
+		if err := req.validate(); err != nil {
+			return err
+		}
+		version, err := ifMatch(c)
+		if err != nil {
+			return err
+		}
+
+		slog.Info("merging places", slog.String("id", id), slog.String("duplicate_id", req.DuplicateID))
+
+		p, err := h.Places.Merge(h.actorContext(c), id, req.DuplicateID, version)
+		if err != nil {
+			return err
+		}
+
+		slog.Info("places merged", slog.String("id", id), slog.String("duplicate_id", req.DuplicateID))
+		setETag(c, p.Version)
+		return c.JSON(PlaceFromModel(p))
+	})
+}
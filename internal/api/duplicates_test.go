@@ -0,0 +1,116 @@
+package api_test
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"redcat/internal/api"
+	"redcat/internal/domain/model"
+	svc "redcat/internal/service/places"
+)
+
+func TestDuplicates(t *testing.T) {
+	spec := loadSpec(t)
+	ctx := context.Background()
+	// b is 22 m north of a, c 11 m east of it, d and e 2 km away
+	store := newMemStore(
+		model.Place{ID: "a", Name: "Kafeneio Zorbas", Lat: 35.1700, Lon: 33.3600, Tel: "+357 22 123456", CategoryIDs: []string{"cafe"}, Version: 1},
+		model.Place{ID: "b", Name: "Kafeneio Zorba", Lat: 35.1702, Lon: 33.3600, Tel: "22123456", Website: "https://zorbas.cy", CategoryIDs: []string{"cafe", "bakery"}, Version: 1},
+		model.Place{ID: "c", Name: "Central Pharmacy", Lat: 35.1700, Lon: 33.3601, Tel: "22123456", CategoryIDs: []string{"pharmacy"}, Version: 1},
+		model.Place{ID: "d", Name: "Kafeneio Zorbas", Lat: 35.1880, Lon: 33.3600, CategoryIDs: []string{"cafe"}, Version: 1},
+		model.Place{ID: "e", Name: "Zorbas Kafeneio", Lat: 35.1880, Lon: 33.3601, CategoryIDs: []string{"cafe"}, Version: 1},
+	)
+	service := svc.New(store, svc.WithDuplicateQueue(newMemDupes()))
+	app := fiber.New()
+	api.Register(app, api.Handlers{Places: service})
+
+	if st, err := service.DetectDuplicates(ctx); err != nil || st.Places != 5 || st.Queued != 2 {
+		t.Fatalf("detect: want 5 places and 2 pairs, got %+v (%v)", st, err)
+	}
+	if st, _ := service.DetectDuplicates(ctx); st.Queued != 0 {
+		t.Fatalf("second pass must not queue known pairs, got %+v", st)
+	}
+
+	status, body := doJSON(t, app, http.MethodGet, "/api/v1/duplicates", nil)
+	if status != http.StatusOK { t.Fatalf("list: expected 200, got %d: %v", status, body) }
+	for _, e := range spec.validate(spec.schema("DuplicateList"), body, "DuplicateList") {
+		t.Error(e)
+	}
+	var list api.DuplicateList
+	raw, _ := json.Marshal(body)
+	_ = json.Unmarshal(raw, &list)
+	if len(list.Candidates) != 2 { t.Fatalf("want 2 candidates, got %s", raw) }
+	ab := list.Candidates[1]
+	if list.Candidates[0].A.ID == "a" { ab = list.Candidates[0] }
+	if ab.A.ID != "a" || ab.B.ID != "b" || ab.Scores.Phone == nil || *ab.Scores.Phone != 1 || ab.Scores.Website != nil || math.Abs(ab.DistanceM-22.2) > 0.2 {
+		t.Fatalf("a|b candidate: %+v", ab)
+	}
+	if status, body := doJSON(t, app, http.MethodGet, "/api/v1/duplicates?limit=0", nil); status != http.StatusBadRequest {
+		t.Fatalf("limit 0: expected 400, got %d: %v", status, body)
+	}
+
+	// dismissed pairs stay out of the queue
+	if status, body := doJSON(t, app, http.MethodDelete, "/api/v1/duplicates/e/d", nil); status != http.StatusNoContent {
+		t.Fatalf("dismiss: expected 204, got %d: %v", status, body)
+	}
+	if status, _ := doJSON(t, app, http.MethodDelete, "/api/v1/duplicates/d/e", nil); status != http.StatusNotFound {
+		t.Fatalf("dismiss again: expected 404, got %d", status)
+	}
+	if st, _ := service.DetectDuplicates(ctx); st.Queued != 0 { t.Fatalf("dismissed pair queued again: %+v", st) }
+
+	for name, c := range map[string]struct {
+		id   string
+		body any
+		want int
+	}{
+		"into itself":  {"a", map[string]any{"duplicate_id": "a"}, http.StatusBadRequest},
+		"bad id":       {"a", map[string]any{"duplicate_id": "x y"}, http.StatusBadRequest},
+		"no duplicate": {"a", map[string]any{"duplicate_id": "zz"}, http.StatusNotFound},
+		"no winner":    {"zz", map[string]any{"duplicate_id": "b"}, http.StatusNotFound},
+	} {
+		if status, body := doJSON(t, app, http.MethodPost, "/api/v1/places/"+c.id+"/merge", c.body); status != c.want {
+			t.Errorf("merge %s: expected %d, got %d: %v", name, c.want, status, body)
+		}
+	}
+
+	status, body = doJSON(t, app, http.MethodPost, "/api/v1/places/a/merge", map[string]any{"duplicate_id": "b"})
+	if status != http.StatusOK { t.Fatalf("merge: expected 200, got %d: %v", status, body) }
+	for _, e := range spec.validate(spec.schema("Place"), body, "Place") {
+		t.Error(e)
+	}
+	merged := body.(map[string]any)
+	if merged["id"] != "a" || merged["name"] != "Kafeneio Zorbas" || merged["contact"].(map[string]any)["website"] != "https://zorbas.cy" || len(merged["category_ids"].([]any)) != 2 {
+		t.Fatalf("merged place: %v", merged)
+	}
+	if _, body := doJSON(t, app, http.MethodGet, "/api/v1/duplicates", nil); len(body.(map[string]any)["candidates"].([]any)) != 0 {
+		t.Fatalf("merged pair must leave the queue: %v", body)
+	}
+
+	// the duplicate's ID redirects to the survivor, also after a second merge
+	redirect := func(path string) (int, string) {
+		resp, err := app.Test(httptest.NewRequest(http.MethodGet, path, nil))
+		if err != nil { t.Fatal(err) }
+		resp.Body.Close()
+		return resp.StatusCode, resp.Header.Get("Location")
+	}
+	if status, loc := redirect("/api/v1/places/b?fields=name"); status != http.StatusMovedPermanently || loc != "/api/v1/places/a?fields=name" {
+		t.Fatalf("get merged place: want 301 to a, got %d %q", status, loc)
+	}
+	if _, err := service.Merge(ctx, "d", "a", svc.AnyVersion); err != nil { t.Fatal(err) }
+	if status, loc := redirect("/api/v1/places/b"); status != http.StatusMovedPermanently || loc != "/api/v1/places/d" {
+		t.Fatalf("get twice merged place: want 301 to d, got %d %q", status, loc)
+	}
+	if status, _ := redirect("/api/v1/places/zz"); status != http.StatusNotFound {
+		t.Fatalf("unknown place: expected 404, got %d", status)
+	}
+	// aliases looping back on themselves end the lookup
+	if err := store.SetAliases(ctx, map[string]string{"x": "y", "y": "x"}); err != nil { t.Fatal(err) }
+	if status, _ := redirect("/api/v1/places/x"); status != http.StatusNotFound {
+		t.Fatalf("aliased in a loop: expected 404, got %d", status)
+	}
+}
@@ -0,0 +1,101 @@
+package api
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/gofiber/fiber/v2"
+	"redcat/internal/domain/errs"
+)
+
+// ErrorResponse is the Error schema of openapi.yaml.
+type ErrorResponse struct {
+	Code    string         `json:"code"`
+	Message string         `json:"message"`
+	Details map[string]any `json:"details,omitempty"`
+}
+
+var kindStatus = map[errs.Kind]int{
+	errs.InvalidRequest:     http.StatusBadRequest,
+	errs.NotFound:           http.StatusNotFound,
+	errs.Conflict:           http.StatusConflict,
+	errs.PreconditionFailed: http.StatusPreconditionFailed,
+	errs.BackendUnavailable: http.StatusServiceUnavailable,
+	errs.Timeout:            http.StatusGatewayTimeout,
+	errs.Gone:               http.StatusGone,
+	errs.Internal:           http.StatusInternalServerError,
+	errs.Corrupted:          http.StatusInternalServerError,
+}
+
+// statusKind maps Fiber's own errors (unknown route, bad method, body too
+// large) onto the same codes.
+func statusKind(status int) errs.Kind {
+	switch {
+	case status == http.StatusNotFound:
+		return errs.NotFound
+	case status == http.StatusConflict:
+		return errs.Conflict
+	case status == http.StatusPreconditionFailed:
+		return errs.PreconditionFailed
+	case status == http.StatusGone:
+		return errs.Gone
+	case status == http.StatusServiceUnavailable:
+		return errs.BackendUnavailable
+	case status == http.StatusGatewayTimeout, status == http.StatusRequestTimeout:
+		return errs.Timeout
+	case status >= 400 && status < 500:
+		return errs.InvalidRequest
+	}
+	return errs.Internal
+}
+
+// ErrorHandler renders every error returned by a handler as the JSON Error
+// envelope. Messages of internal errors are replaced by a generic text and
+// the cause is logged instead of sent to the client.
+func ErrorHandler(c *fiber.Ctx, err error) error {
+	status, body := errorResponse(err)
+	if status >= http.StatusInternalServerError {
+		slog.Error("request failed",
+			slog.String("method", c.Method()),
+			slog.String("path", c.Path()),
+			slog.Int("status", status),
+			slog.String("error", err.Error()),
+		)
+	}
+	return c.Status(status).JSON(body)
+}
+
+// errorResponse maps err to its HTTP status and Error envelope. Corrupted
+// records keep their message and details, which name the record, so
+// operators can repair it; the stored value is only logged.
+func errorResponse(err error) (int, ErrorResponse) {
+	var fe *fiber.Error
+	if errors.As(err, &fe) && errs.As(err) == nil {
+		return fe.Code, ErrorResponse{Code: string(statusKind(fe.Code)), Message: fe.Message}
+	}
+	kind := errs.KindOf(err)
+	body := ErrorResponse{Code: string(kind)}
+	if e := errs.As(err); e != nil {
+		body.Message, body.Details = e.Message, e.Details
+	}
+	switch kind {
+	case errs.Internal:
+		body.Message, body.Details = "internal error", nil
+	case errs.BackendUnavailable, errs.Timeout:
+		if body.Message == "" { body.Message = "backend unavailable" }
+	}
+	return kindStatus[kind], body
+}
+
+// ErrorMiddleware renders errors from downstream handlers with ErrorHandler
+// while still inside the middleware chain, so request logging and metrics
+// observe the final status code.
+func ErrorMiddleware() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if err := c.Next(); err != nil {
+			return ErrorHandler(c, err)
+		}
+		return nil
+	}
+}
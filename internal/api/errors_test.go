@@ -0,0 +1,69 @@
+package api_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"redcat/internal/api"
+	"redcat/internal/domain/errs"
+	svc "redcat/internal/service/places"
+	"redcat/internal/storage/valkey"
+)
+
+func TestErrors_UseEnvelope(t *testing.T) {
+	spec := loadSpec(t)
+
+	cases := []struct {
+		name       string
+		storeErr   error
+		method     string
+		path       string
+		body       any
+		wantStatus int
+		wantCode   string
+	}{
+		{"not found", nil, http.MethodGet, "/api/v1/places/missing", nil, http.StatusNotFound, "NOT_FOUND"},
+		{"unknown route", nil, http.MethodGet, "/api/v1/nope", nil, http.StatusNotFound, "NOT_FOUND"},
+		{"invalid projection", nil, http.MethodGet, "/api/v1/places/p1?fields=bogus", nil, http.StatusBadRequest, "INVALID_REQUEST"},
+		{"internal", errors.New("WRONGTYPE Operation against a key"), http.MethodGet, "/api/v1/places/p1", nil, http.StatusInternalServerError, "INTERNAL"},
+		{"backend down", errs.Wrap(errs.BackendUnavailable, errors.New("dial tcp: refused"), "backend unavailable"),
+			http.MethodPost, "/api/v1/places/search", map[string]any{"location": map[string]any{"lat": 1, "lon": 1}},
+			http.StatusServiceUnavailable, "BACKEND_UNAVAILABLE"},
+		{"timeout", context.DeadlineExceeded, http.MethodDelete, "/api/v1/places/p1", nil, http.StatusGatewayTimeout, "TIMEOUT"},
+		{"corrupted", &valkey.CorruptedRecordError{ID: "p1", Field: "lat", Value: "north", Err: errors.New("invalid syntax")},
+			http.MethodGet, "/api/v1/places/p1", nil, http.StatusInternalServerError, "CORRUPTED_RECORD"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			store := newMemStore(fullPlace)
+			store.err = tc.storeErr
+			app := fiber.New()
+			api.Register(app, api.Handlers{Places: svc.New(store)})
+
+			status, body := doJSON(t, app, tc.method, tc.path, tc.body)
+			if status != tc.wantStatus {
+				t.Fatalf("expected status %d, got %d: %v", tc.wantStatus, status, body)
+			}
+			for _, e := range spec.validate(spec.schema("Error"), body, "Error") {
+				t.Error(e)
+			}
+			env := body.(map[string]any)
+			if env["code"] != tc.wantCode {
+				t.Errorf("expected code %s, got %v", tc.wantCode, env["code"])
+			}
+			if tc.storeErr != nil && strings.Contains(fmt.Sprint(env["message"]), tc.storeErr.Error()) {
+				t.Errorf("backend error leaked to client: %v", env["message"])
+			}
+			if tc.wantCode == "CORRUPTED_RECORD" {
+				if d, _ := env["details"].(map[string]any); d["id"] != "p1" || d["field"] != "lat" || strings.Contains(fmt.Sprint(env), "north") {
+					t.Errorf("corrupted record must be named without its value: %v", env)
+				}
+			}
+		})
+	}
+}
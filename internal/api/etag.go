@@ -0,0 +1,49 @@
+package api
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"redcat/internal/domain/errs"
+	svc "redcat/internal/service/places"
+)
+
+// Places are tagged with their storage version: ETag "<version>". The tag is
+// strong because any write bumps the version.
+
+func etag(version int64) string { return `"` + strconv.FormatInt(version, 10) + `"` }
+
+func setETag(c *fiber.Ctx, version int64) { c.Set(fiber.HeaderETag, etag(version)) }
+
+// ifMatch returns the version required by the If-Match header, or
+// svc.AnyVersion when the header is absent or "*". Weak or foreign tags can
+// never match a strong ETag, so they fail the precondition right away.
+func ifMatch(c *fiber.Ctx) (int64, error) {
+	h := strings.TrimSpace(c.Get(fiber.HeaderIfMatch))
+	if h == "" || h == "*" { return svc.AnyVersion, nil }
+	if strings.Contains(h, ",") {
+		return 0, errs.Invalid("If-Match accepts a single entity tag", map[string]any{"if_match": h})
+	}
+	if !strings.HasPrefix(h, `"`) || !strings.HasSuffix(h, `"`) || len(h) < 2 {
+		return 0, svc.ErrPreconditionFailed
+	}
+	v, err := strconv.ParseInt(h[1:len(h)-1], 10, 64)
+	if err != nil || v < 0 { return 0, svc.ErrPreconditionFailed }
+	return v, nil
+}
+
+// noneMatch reports whether If-None-Match names the current version, using
+// the weak comparison RFC 9110 prescribes for GET.
+func noneMatch(c *fiber.Ctx, version int64) bool { return noneMatchTag(c, etag(version)) }
+
+// noneMatchTag is noneMatch for any strong entity tag cur.
+func noneMatchTag(c *fiber.Ctx, cur string) bool {
+	h := strings.TrimSpace(c.Get(fiber.HeaderIfNoneMatch))
+	if h == "" { return false }
+	if h == "*" { return true }
+	for _, t := range strings.Split(h, ",") {
+		if strings.TrimPrefix(strings.TrimSpace(t), "W/") == cur { return true }
+	}
+	return false
+}
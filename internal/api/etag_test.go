@@ -0,0 +1,65 @@
+package api_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"redcat/internal/api"
+	svc "redcat/internal/service/places"
+)
+
+func TestETags_ConditionalRequests(t *testing.T) {
+	app := fiber.New()
+	api.Register(app, api.Handlers{Places: svc.New(newMemStore())})
+	do := func(method, path, ifHeader, tag string, body any) *http.Response {
+		t.Helper()
+		var r io.Reader
+		if body != nil {
+			b, _ := json.Marshal(body)
+			r = bytes.NewReader(b)
+		}
+		req := httptest.NewRequest(method, path, r)
+		req.Header.Set("Content-Type", "application/json")
+		if ifHeader != "" { req.Header.Set(ifHeader, tag) }
+		resp, err := app.Test(req)
+		if err != nil { t.Fatalf("%s %s: %v", method, path, err) }
+		resp.Body.Close()
+		return resp
+	}
+	create := map[string]any{"id": "p1", "name": "A", "location": map[string]any{"lat": 1, "lon": 2}, "category_ids": []string{"c1"}}
+
+	resp := do(http.MethodPost, "/api/v1/places", "", "", create)
+	if resp.StatusCode != http.StatusCreated || resp.Header.Get("ETag") != `"1"` {
+		t.Fatalf("create: expected 201 with ETag \"1\", got %d %q", resp.StatusCode, resp.Header.Get("ETag"))
+	}
+	if resp = do(http.MethodGet, "/api/v1/places/p1", "If-None-Match", `W/"1"`, nil); resp.StatusCode != http.StatusNotModified {
+		t.Fatalf("GET with current If-None-Match: expected 304, got %d", resp.StatusCode)
+	}
+	if resp = do(http.MethodGet, "/api/v1/places/p1", "If-None-Match", `"0", "7"`, nil); resp.StatusCode != http.StatusOK {
+		t.Fatalf("GET with stale If-None-Match: expected 200, got %d", resp.StatusCode)
+	}
+
+	update := map[string]any{"name": "B"}
+	if resp = do(http.MethodPut, "/api/v1/places/p1", "If-Match", `"2"`, update); resp.StatusCode != http.StatusPreconditionFailed {
+		t.Fatalf("PUT with stale If-Match: expected 412, got %d", resp.StatusCode)
+	}
+	resp = do(http.MethodPut, "/api/v1/places/p1", "If-Match", `"1"`, update)
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("ETag") != `"2"` {
+		t.Fatalf("PUT with current If-Match: expected 200 with ETag \"2\", got %d %q", resp.StatusCode, resp.Header.Get("ETag"))
+	}
+	if resp = do(http.MethodPut, "/api/v1/places/p1", "If-Match", `W/"2"`, update); resp.StatusCode != http.StatusPreconditionFailed {
+		t.Fatalf("PUT with weak If-Match: expected 412, got %d", resp.StatusCode)
+	}
+
+	if resp = do(http.MethodDelete, "/api/v1/places/p1", "If-Match", `"1"`, nil); resp.StatusCode != http.StatusPreconditionFailed {
+		t.Fatalf("DELETE with stale If-Match: expected 412, got %d", resp.StatusCode)
+	}
+	if resp = do(http.MethodDelete, "/api/v1/places/p1", "If-Match", `"2"`, nil); resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("DELETE with current If-Match: expected 204, got %d", resp.StatusCode)
+	}
+}
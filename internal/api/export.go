@@ -0,0 +1,64 @@
+package api
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"log/slog"
+
+	"github.com/gofiber/fiber/v2"
+	"redcat/internal/domain/model"
+)
+
+// registerExport adds the endpoint streaming every place, or those matching
+// a bbox and categories, as JSON or as one GeoJSON FeatureCollection.
+func registerExport(app *fiber.App, h Handlers) {
+	// the colon is escaped so fiber does not read ":export" as a parameter
+	app.Get("/api/v1/places\\:export", func(c *fiber.Ctx) error {
+		ep, err := exportQuery(c)
+		if err != nil {
+			return err
+		}
+		asGeoJSON, err := wantsGeoJSON(c)
+		if err != nil {
+			return err
+		}
+
+		slog.Info("export", slog.Bool("bbox", ep.BBox != nil), slog.Int("categories", len(ep.CategoryIDs)), slog.Bool("geojson", asGeoJSON))
+
+		head, tail := `{"places":[`, "]}\n"
+		c.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+		if asGeoJSON {
+			head, tail = `{"type":"FeatureCollection","features":[`, "]}\n"
+			c.Set(fiber.HeaderContentType, geoJSONContentType)
+		}
+		places := h.Places
+		// the writer runs after the handler has returned, so it must not
+		// touch c and cannot report errors through the status code
+		c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+			enc := json.NewEncoder(w)
+			_, _ = w.WriteString(head)
+			n := 0
+			err := places.Export(context.Background(), ep, func(ps []model.Place) error {
+				for _, p := range ps {
+					if n > 0 { _ = w.WriteByte(',') }
+					n++
+					var v any = PlaceFromModel(p)
+					if asGeoJSON { v = toFeature(v) }
+					if err := enc.Encode(v); err != nil { return err }
+				}
+				// fails once the client has gone, which ends the scan
+				return w.Flush()
+			})
+			if err != nil {
+				// left unterminated so that clients see the document is incomplete
+				slog.Error("export failed", slog.Int("places", n), slog.String("error", err.Error()))
+				return
+			}
+			_, _ = w.WriteString(tail)
+			_ = w.Flush()
+			slog.Info("export completed", slog.Int("places", n))
+		})
+		return nil
+	})
+}
@@ -0,0 +1,46 @@
+package api_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"redcat/internal/api"
+	"redcat/internal/domain/model"
+	svc "redcat/internal/service/places"
+	"redcat/internal/storage/valkey"
+)
+
+func TestExport(t *testing.T) {
+	spec := loadSpec(t)
+	var seed []model.Place
+	for i := range 1200 {
+		seed = append(seed, model.Place{ID: fmt.Sprintf("p%04d", i), Name: "P", Lat: float64(i%90) / 10, Lon: 1, CategoryIDs: []string{[]string{"cafe", "bar"}[i%2]}})
+	}
+	store := newMemStore(seed...)
+	store.Delete(context.Background(), "p0000", valkey.AnyVersion)
+	app := fiber.New()
+	api.Register(app, api.Handlers{Places: svc.New(store)})
+
+	// more places than one scan batch
+	status, body := doJSON(t, app, http.MethodGet, "/api/v1/places:export", nil)
+	if status != http.StatusOK { t.Fatalf("export: expected 200, got %d: %v", status, body) }
+	for _, e := range spec.validate(spec.schema("ExportResponse"), body, "ExportResponse") {
+		t.Error(e)
+	}
+	if n := len(body.(map[string]any)["places"].([]any)); n != 1199 { t.Fatalf("export: want 1199 places, got %d", n) }
+
+	_, body = doJSON(t, app, http.MethodGet, "/api/v1/places:export?category=bar&bbox=0,0,2,0.5", nil)
+	for _, p := range body.(map[string]any)["places"].([]any) {
+		if loc := p.(map[string]any)["location"].(map[string]any); loc["lat"].(float64) > 0.5 || p.(map[string]any)["category_ids"].([]any)[0] != "bar" {
+			t.Fatalf("filtered export: unexpected place %v", p)
+		}
+	}
+	if n := len(body.(map[string]any)["places"].([]any)); n != 42 { t.Fatalf("filtered export: want 42 places, got %d", n) }
+
+	if status, _ := doJSON(t, app, http.MethodGet, "/api/v1/places:export?bbox=1,2", nil); status != http.StatusBadRequest {
+		t.Fatalf("bad bbox: expected 400, got %d", status)
+	}
+}
@@ -0,0 +1,188 @@
+package api_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"redcat/internal/api"
+	"redcat/internal/domain/h3"
+	"redcat/internal/domain/model"
+	svc "redcat/internal/service/places"
+)
+
+func TestFacets(t *testing.T) {
+	spec := loadSpec(t)
+	store := newMemStore(
+		model.Place{ID: "r1", Name: "R1", Lat: 35.170, Lon: 33.360, Country: "CY", CategoryIDs: []string{"rest"}, CategoryLabels: []string{"Dining > Restaurant"}},
+		model.Place{ID: "r2", Name: "R2", Lat: 35.171, Lon: 33.361, Country: "CY", CategoryIDs: []string{"rest", "bar"}, CategoryLabels: []string{"Dining > Restaurant", "Nightlife > Bar"}},
+		model.Place{ID: "h1", Name: "H1", Lat: 35.172, Lon: 33.362, Country: "CY", CategoryIDs: []string{"hotel"}},
+		model.Place{ID: "far", Name: "F", Lat: 34.680, Lon: 33.040, Country: "CY", CategoryIDs: []string{"rest"}},
+		model.Place{ID: "fiji", Name: "Fiji", Lat: -17.0, Lon: 179.9, Country: "FJ", CategoryIDs: []string{"rest"}},
+		model.Place{ID: "taveuni", Name: "Taveuni", Lat: -17.0, Lon: -179.9, Country: "FJ", CategoryIDs: []string{"hotel"}},
+	)
+	app := fiber.New()
+	api.Register(app, api.Handlers{Places: svc.New(store)})
+
+	facets := func(req map[string]any) api.FacetsResponse {
+		t.Helper()
+		status, body := doJSON(t, app, http.MethodPost, "/api/v1/places/facets", req)
+		if status != http.StatusOK { t.Fatalf("facets %v: expected 200, got %d: %v", req, status, body) }
+		for _, e := range spec.validate(spec.schema("FacetsResponse"), body, "FacetsResponse") {
+			t.Error(e)
+		}
+		var res api.FacetsResponse
+		raw, _ := json.Marshal(body)
+		_ = json.Unmarshal(raw, &res)
+		return res
+	}
+
+	res := facets(map[string]any{"location": map[string]any{"lat": 35.17, "lon": 33.36}, "radius_m": 1000, "labels": true, "top": 2})
+	if res.Total != 3 || fmt.Sprint(res.Categories) != "[{rest 2 Dining > Restaurant} {bar 1 Nightlife > Bar}]" || fmt.Sprint(res.Countries) != "[{CY 3 }]" {
+		t.Fatalf("around Nicosia: %+v", res)
+	}
+	res = facets(map[string]any{"bbox": map[string]any{"xmin": 179, "ymin": -18, "xmax": -179, "ymax": -16}})
+	if res.Total != 2 || len(res.Categories) != 2 || res.Categories[0].Label != "" || fmt.Sprint(res.Countries) != "[{FJ 2 }]" {
+		t.Fatalf("bbox across the antimeridian: %+v", res)
+	}
+	res = facets(map[string]any{"location": map[string]any{"lat": 35.17, "lon": 33.36}, "radius_m": 50000, "category_ids": []string{"bar", "hotel"}})
+	if res.Total != 2 || fmt.Sprint(res.Categories) != "[{bar 1 } {hotel 1 } {rest 1 }]" {
+		t.Fatalf("category filter: %+v", res)
+	}
+
+	for name, req := range map[string]map[string]any{
+		"no area":      {"top": 5},
+		"both":         {"location": map[string]any{"lat": 0, "lon": 0}, "radius_m": 10, "bbox": map[string]any{"xmin": 0, "ymin": 0, "xmax": 1, "ymax": 1}},
+		"no radius":    {"location": map[string]any{"lat": 0, "lon": 0}},
+		"radius range": {"location": map[string]any{"lat": 0, "lon": 0}, "radius_m": 50001},
+		"flipped bbox": {"bbox": map[string]any{"xmin": 0, "ymin": 1, "xmax": 1, "ymax": 0}},
+		"top range":    {"location": map[string]any{"lat": 0, "lon": 0}, "radius_m": 10, "top": 101},
+	} {
+		if status, body := doJSON(t, app, http.MethodPost, "/api/v1/places/facets", req); status != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d: %v", name, status, body)
+		}
+	}
+}
+
+func TestHeatmap(t *testing.T) {
+	spec := loadSpec(t)
+	places := []model.Place{
+		{ID: "r1", Name: "R1", Lat: 35.1700, Lon: 33.3600, CategoryIDs: []string{"rest"}},
+		{ID: "r2", Name: "R2", Lat: 35.1701, Lon: 33.3601, CategoryIDs: []string{"rest", "bar"}},
+		{ID: "h1", Name: "H1", Lat: 34.6800, Lon: 33.0400, CategoryIDs: []string{"hotel"}},
+		{ID: "fiji", Name: "Fiji", Lat: -17.0, Lon: 179.9, CategoryIDs: []string{"rest"}},
+		{ID: "taveuni", Name: "Taveuni", Lat: -17.0, Lon: -179.9, CategoryIDs: []string{"hotel"}},
+	}
+	app := fiber.New()
+	api.Register(app, api.Handlers{Places: svc.New(newMemStore(places...))})
+	cell := func(i, res int) string { return h3.FromLatLng(places[i].Lat, places[i].Lon, res).String() }
+
+	heatmap := func(query string) api.HeatmapResponse {
+		t.Helper()
+		status, body := doJSON(t, app, http.MethodGet, "/api/v1/heatmap?"+query, nil)
+		if status != http.StatusOK { t.Fatalf("heatmap %s: expected 200, got %d: %v", query, status, body) }
+		for _, e := range spec.validate(spec.schema("HeatmapResponse"), body, "HeatmapResponse") {
+			t.Error(e)
+		}
+		var res api.HeatmapResponse
+		raw, _ := json.Marshal(body)
+		_ = json.Unmarshal(raw, &res)
+		return res
+	}
+
+	res := heatmap("bbox=32,34,35,36")
+	if want := fmt.Sprintf("[{%s 2} {%s 1}]", cell(0, 7), cell(2, 7)); res.Res != 7 || fmt.Sprint(res.Cells) != want || res.Truncated {
+		t.Fatalf("default resolution: got %+v, want cells %s", res, want)
+	}
+	if res = heatmap("bbox=32,34,35,36&res=5&category=bar,hotel"); fmt.Sprint(res.Cells) != fmt.Sprintf("[{%s 1} {%s 1}]", min(cell(1, 5), cell(2, 5)), max(cell(1, 5), cell(2, 5))) {
+		t.Fatalf("category filter: %+v", res)
+	}
+	if res = heatmap("bbox=179,-18,-179,-16&res=8"); len(res.Cells) != 2 || res.Res != 8 {
+		t.Fatalf("bbox across the antimeridian: %+v", res)
+	}
+
+	for name, query := range map[string]string{
+		"no bbox":      "res=7",
+		"short bbox":   "bbox=0,0,1",
+		"bad number":   "bbox=0,0,x,1",
+		"nan":          "bbox=NaN,0,1,1",
+		"infinite":     "bbox=0,0,Inf,1",
+		"flipped bbox": "bbox=0,1,1,0",
+		"res range":    "bbox=0,0,1,1&res=9",
+		"res integer":  "bbox=0,0,1,1&res=high",
+	} {
+		if status, body := doJSON(t, app, http.MethodGet, "/api/v1/heatmap?"+query, nil); status != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d: %v", name, status, body)
+		}
+	}
+}
+
+func TestClusters(t *testing.T) {
+	spec := loadSpec(t)
+	store := newMemStore(
+		model.Place{ID: "r1", Name: "R1", Lat: 35.1700, Lon: 33.3600, CategoryIDs: []string{"rest"}},
+		model.Place{ID: "r2", Name: "R2", Lat: 35.1701, Lon: 33.3601, CategoryIDs: []string{"rest", "bar"}},
+		model.Place{ID: "r3", Name: "R3", Lat: 35.1702, Lon: 33.3602, CategoryIDs: []string{"rest"}},
+		model.Place{ID: "h1", Name: "H1", Lat: 35.1710, Lon: 33.3610, CategoryIDs: []string{"hotel"}},
+		model.Place{ID: "far", Name: "F", Lat: 34.6800, Lon: 33.0400, CategoryIDs: []string{"rest"}},
+		model.Place{ID: "fiji", Name: "Fiji", Lat: -17.0, Lon: 179.9, CategoryIDs: []string{"rest"}},
+		model.Place{ID: "taveuni", Name: "Taveuni", Lat: -17.0, Lon: -179.9, CategoryIDs: []string{"hotel"}},
+	)
+	app := fiber.New()
+	api.Register(app, api.Handlers{Places: svc.New(store)})
+
+	clusters := func(query string) api.ClustersResponse {
+		t.Helper()
+		status, body := doJSON(t, app, http.MethodGet, "/api/v1/clusters?"+query, nil)
+		if status != http.StatusOK { t.Fatalf("clusters %s: expected 200, got %d: %v", query, status, body) }
+		for _, e := range spec.validate(spec.schema("ClustersResponse"), body, "ClustersResponse") {
+			t.Error(e)
+		}
+		var res api.ClustersResponse
+		raw, _ := json.Marshal(body)
+		_ = json.Unmarshal(raw, &res)
+		return res
+	}
+
+	res := clusters("bbox=32,34,35,36&zoom=9")
+	if res.Zoom != 9 || res.Total != 5 || len(res.Clusters) != 2 {
+		t.Fatalf("want 2 clusters of 5 places, got %+v", res)
+	}
+	c := res.Clusters[0]
+	if c.Cell != "11/1213/810" || c.Count != 4 || c.Location != (api.Location{Lat: 35.170325, Lon: 33.360325}) {
+		t.Fatalf("first cluster: %+v", c)
+	}
+	if fmt.Sprint(c.PlaceIDs) != "[r3 r2 r1]" || fmt.Sprint(c.Categories) != "[{rest 3 } {bar 1 } {hotel 1 }]" {
+		t.Fatalf("first cluster places and categories: %v %v", c.PlaceIDs, c.Categories)
+	}
+	if c = res.Clusters[1]; c.Count != 1 || fmt.Sprint(c.PlaceIDs) != "[far]" {
+		t.Fatalf("second cluster: %+v", c)
+	}
+	if again := clusters("bbox=33.3,35.1,33.4,35.2&zoom=9"); fmt.Sprint(again.Clusters) != fmt.Sprint(res.Clusters[:1]) {
+		t.Fatalf("same cell from another bbox: %+v", again.Clusters)
+	}
+
+	res = clusters("bbox=32,34,35,36&zoom=9&category=hotel,bar")
+	// both are as far from the centroid
+	if len(res.Clusters) != 1 || len(res.Clusters[0].PlaceIDs) != 2 || !slices.Contains(res.Clusters[0].PlaceIDs, "h1") {
+		t.Fatalf("category filter: %+v", res)
+	}
+	res = clusters("bbox=179,-18,-179,-16&zoom=3")
+	if len(res.Clusters) != 2 || res.Clusters[0].Cell != "5/0/17" || res.Clusters[1].Cell != "5/31/17" {
+		t.Fatalf("bbox across the antimeridian: %+v", res)
+	}
+
+	for name, query := range map[string]string{
+		"no zoom":        "bbox=0,0,1,1",
+		"zoom range":     "bbox=0,0,1,1&zoom=21",
+		"no bbox":        "zoom=3",
+		"too many cells": "bbox=-180,-85,180,85&zoom=5",
+	} {
+		if status, body := doJSON(t, app, http.MethodGet, "/api/v1/clusters?"+query, nil); status != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d: %v", name, status, body)
+		}
+	}
+}
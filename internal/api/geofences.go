@@ -0,0 +1,91 @@
+package api
+
+import (
+	"log/slog"
+	"net/http"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
+)
+
+// registerGeofences adds the geofence endpoints; they are absent when no
+// geofence service is configured.
+func registerGeofences(app *fiber.App, h Handlers) {
+	if h.Geofences == nil { return }
+
+	app.Post("/api/v1/geofences/lookup", func(c *fiber.Ctx) error {
+		var req GeofenceLookupRequest
+		if err := h.decodeBody(c, &req); err != nil {
+			return err
+		}
+		if err := req.validate(); err != nil {
+			return err
+		}
+
+		fs, err := h.Geofences.Lookup(c.Context(), req.Location.Lat, req.Location.Lon)
+		if err != nil {
+			return err
+		}
+		return c.JSON(geofenceLookup(fs, req.IncludeGeometry))
+	})
+
+	app.Post("/api/v1/geofences", func(c *fiber.Ctx) error {
+		var req GeofenceCreate
+		if err := h.decodeBody(c, &req); err != nil {
+			slog.Warn("create geofence: invalid body", slog.String("error", err.Error()))
+			return err
+		}
+		f, err := req.validate()
+		if err != nil {
+			return err
+		}
+
+		f, err = h.Geofences.Create(c.Context(), f)
+		if err != nil {
+			return err
+		}
+
+		slog.Info("geofence created", slog.String("id", f.ID), slog.String("name", f.Name))
+		return c.Status(http.StatusCreated).JSON(GeofenceFromModel(f, true))
+	})
+
+	app.Get("/api/v1/geofences/:id", func(c *fiber.Ctx) error {
+		f, err := h.Geofences.Get(c.Context(), utils.CopyString(c.Params("id")))
+		if err != nil {
+			return err
+		}
+		return c.JSON(GeofenceFromModel(f, true))
+	})
+
+	app.Put("/api/v1/geofences/:id", func(c *fiber.Ctx) error {
+		id := utils.CopyString(c.Params("id"))
+		var req GeofenceUpdate
+		if err := h.decodeBody(c, &req); err != nil {
+			slog.Warn("update geofence: invalid body", slog.String("error", err.Error()))
+			return err
+		}
+		f, err := req.validate()
+		if err != nil {
+			return err
+		}
+		f.ID = id
+
+		f, err = h.Geofences.Replace(c.Context(), f)
+		if err != nil {
+			return err
+		}
+
+		slog.Info("geofence updated", slog.String("id", id))
+		return c.JSON(GeofenceFromModel(f, true))
+	})
+
+	app.Delete("/api/v1/geofences/:id", func(c *fiber.Ctx) error {
+		id := utils.CopyString(c.Params("id"))
+		if err := h.Geofences.Delete(c.Context(), id); err != nil {
+			return err
+		}
+
+		slog.Info("geofence deleted", slog.String("id", id))
+		return c.SendStatus(http.StatusNoContent)
+	})
+}
@@ -0,0 +1,73 @@
+package api_test
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"redcat/internal/api"
+	"redcat/internal/domain/model"
+	"redcat/internal/service/geofences"
+)
+
+func TestGeofences_CRUDAndLookup(t *testing.T) {
+	spec := loadSpec(t)
+	app := fiber.New()
+	api.Register(app, api.Handlers{Geofences: geofences.New(&memGeofences{fences: map[string]model.Geofence{}})})
+
+	square := func(x0, y0, x1, y1 float64) map[string]any {
+		return map[string]any{"type": "Polygon", "coordinates": [][][]float64{{{x0, y0}, {x1, y0}, {x1, y1}, {x0, y1}, {x0, y0}}}}
+	}
+	status, body := doJSON(t, app, http.MethodPost, "/api/v1/geofences", map[string]any{
+		"id": "city", "name": "City", "geometry": square(33, 35, 34, 36), "properties": map[string]any{"tier": 1},
+	})
+	if status != http.StatusCreated { t.Fatalf("create: expected 201, got %d: %v", status, body) }
+	for _, e := range spec.validate(spec.schema("Geofence"), body, "Geofence") {
+		t.Error(e)
+	}
+	doJSON(t, app, http.MethodPost, "/api/v1/geofences", map[string]any{"id": "centre", "name": "Centre", "geometry": square(33.3, 35.1, 33.4, 35.2)})
+	doJSON(t, app, http.MethodPost, "/api/v1/geofences", map[string]any{"id": "fiji", "name": "Fiji", "geometry": square(175, -20, -175, -10)})
+
+	if status, _ := doJSON(t, app, http.MethodPost, "/api/v1/geofences", map[string]any{"id": "city", "name": "Again", "geometry": square(0, 0, 1, 1)}); status != http.StatusConflict {
+		t.Errorf("duplicate id: expected 409, got %d", status)
+	}
+	open := map[string]any{"type": "Polygon", "coordinates": [][][]float64{{{0, 0}, {1, 0}, {1, 1}, {0, 1}}}}
+	if status, _ := doJSON(t, app, http.MethodPost, "/api/v1/geofences", map[string]any{"name": "Open", "geometry": open}); status != http.StatusBadRequest {
+		t.Errorf("unclosed ring: expected 400, got %d", status)
+	}
+
+	lookup := func(lat, lon float64) []string {
+		t.Helper()
+		status, body := doJSON(t, app, http.MethodPost, "/api/v1/geofences/lookup", map[string]any{"location": map[string]any{"lat": lat, "lon": lon}})
+		if status != http.StatusOK { t.Fatalf("lookup: expected 200, got %d: %v", status, body) }
+		for _, e := range spec.validate(spec.schema("GeofenceLookupResponse"), body, "GeofenceLookupResponse") {
+			t.Error(e)
+		}
+		var ids []string
+		for _, f := range body.(map[string]any)["geofences"].([]any) {
+			f := f.(map[string]any)
+			if _, ok := f["geometry"]; ok { t.Error("lookup: geometry returned without include_geometry") }
+			ids = append(ids, f["id"].(string))
+		}
+		return ids
+	}
+	if got := lookup(35.15, 33.35); fmt.Sprint(got) != "[centre city]" { t.Errorf("nested lookup: got %v, want smallest first", got) }
+	if got := lookup(35.5, 33.9); fmt.Sprint(got) != "[city]" { t.Errorf("outer lookup: got %v", got) }
+	if got := lookup(-15, 179.5); fmt.Sprint(got) != "[fiji]" { t.Errorf("antimeridian lookup: got %v", got) }
+	if got := lookup(0, 0); len(got) != 0 { t.Errorf("empty lookup: got %v", got) }
+
+	status, body = doJSON(t, app, http.MethodPut, "/api/v1/geofences/city", map[string]any{"name": "City", "geometry": square(40, 40, 41, 41)})
+	if status != http.StatusOK { t.Fatalf("replace: expected 200, got %d: %v", status, body) }
+	if got := lookup(35.5, 33.9); len(got) != 0 { t.Errorf("after replace: got %v", got) }
+	if status, _ := doJSON(t, app, http.MethodPut, "/api/v1/geofences/nope", map[string]any{"name": "X", "geometry": square(0, 0, 1, 1)}); status != http.StatusNotFound {
+		t.Errorf("replace missing: expected 404, got %d", status)
+	}
+
+	if status, _ := doJSON(t, app, http.MethodDelete, "/api/v1/geofences/city", nil); status != http.StatusNoContent {
+		t.Errorf("delete: expected 204, got %d", status)
+	}
+	if status, _ := doJSON(t, app, http.MethodGet, "/api/v1/geofences/city", nil); status != http.StatusNotFound {
+		t.Errorf("get deleted: expected 404, got %d", status)
+	}
+}
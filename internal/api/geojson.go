@@ -0,0 +1,111 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+
+	"github.com/gofiber/fiber/v2"
+	"redcat/internal/validate"
+)
+
+const geoJSONContentType = "application/geo+json"
+
+// Feature and FeatureCollection are the GeoJSON renderings of place
+// responses: a Point at the place's location with every other attribute of
+// the JSON response as properties.
+type Feature struct {
+	Type       string         `json:"type"`
+	ID         string         `json:"id,omitempty"`
+	Geometry   *PointGeometry `json:"geometry"`
+	Properties map[string]any `json:"properties"`
+}
+
+type PointGeometry struct {
+	Type        string     `json:"type"`
+	Coordinates [2]float64 `json:"coordinates"`
+}
+
+type FeatureCollection struct {
+	Type     string    `json:"type"`
+	Features []Feature `json:"features"`
+}
+
+// BatchFeatureCollection is a batch search as one collection: every place
+// carries the query_index of its query in its properties, and the queries
+// that failed are listed in errors, a foreign member GIS tools ignore.
+type BatchFeatureCollection struct {
+	FeatureCollection
+	Errors []BatchFeatureError `json:"errors,omitempty"`
+}
+
+type BatchFeatureError struct {
+	QueryIndex int           `json:"query_index"`
+	Error      ErrorResponse `json:"error"`
+}
+
+// wantsGeoJSON reports whether the response should be GeoJSON: format is
+// geojson, or absent and the Accept header prefers application/geo+json.
+func wantsGeoJSON(c *fiber.Ctx) (bool, error) {
+	switch c.Query("format") {
+	case "geojson":
+		return true, nil
+	case "json":
+		return false, nil
+	case "":
+		return c.Accepts(fiber.MIMEApplicationJSON, geoJSONContentType) == geoJSONContentType, nil
+	}
+	v := &validate.Validator{}
+	v.Add("format", "must be json or geojson")
+	return false, v.Err()
+}
+
+// toFeature renders a response with a location, usually a place, as a
+// Feature whose ID is its id property.
+func toFeature(v any) Feature {
+	raw, _ := json.Marshal(v)
+	d := json.NewDecoder(bytes.NewReader(raw))
+	// keep numbers as written rather than rounding them through float64
+	d.UseNumber()
+	var props map[string]any
+	_ = d.Decode(&props)
+
+	f := Feature{Type: "Feature", Properties: props}
+	f.ID, _ = props["id"].(string)
+	if loc, ok := props["location"].(map[string]any); ok {
+		lat, _ := loc["lat"].(json.Number).Float64()
+		lon, _ := loc["lon"].(json.Number).Float64()
+		f.Geometry = &PointGeometry{Type: "Point", Coordinates: [2]float64{lon, lat}}
+		delete(props, "location")
+	}
+	return f
+}
+
+func featureCollection[T any](places []T) FeatureCollection {
+	fc := FeatureCollection{Type: "FeatureCollection", Features: make([]Feature, len(places))}
+	for i, p := range places { fc.Features[i] = toFeature(p) }
+	return fc
+}
+
+func batchFeatureCollection(items []BatchSearchItem) BatchFeatureCollection {
+	fc := BatchFeatureCollection{FeatureCollection: FeatureCollection{Type: "FeatureCollection", Features: []Feature{}}}
+	for i, item := range items {
+		if item.Error != nil {
+			fc.Errors = append(fc.Errors, BatchFeatureError{QueryIndex: i, Error: *item.Error})
+			continue
+		}
+		for _, p := range item.Places {
+			f := toFeature(p)
+			f.Properties["query_index"] = i
+			fc.Features = append(fc.Features, f)
+		}
+	}
+	return fc
+}
+
+// clusterFeatures renders clusters as Points at their mean position, with
+// the grid cell as the feature ID.
+func clusterFeatures(cs []Cluster) FeatureCollection {
+	fc := featureCollection(cs)
+	for i := range fc.Features { fc.Features[i].ID = cs[i].Cell }
+	return fc
+}
@@ -0,0 +1,118 @@
+package api_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"redcat/internal/api"
+	"redcat/internal/domain/model"
+	svc "redcat/internal/service/places"
+)
+
+func TestGeoJSON(t *testing.T) {
+	spec := loadSpec(t)
+	store := newMemStore(
+		model.Place{ID: "a", Name: "A", Lat: 0.001, Lon: 0.02, Country: "CY", CategoryIDs: []string{"cafe"}},
+		model.Place{ID: "b", Name: "B", Lat: 0.002, Lon: 0.08, CategoryIDs: []string{"cafe"}},
+	)
+	app := fiber.New()
+	api.Register(app, api.Handlers{Places: svc.New(store)})
+
+	do := func(method, path, accept string, body any) (*http.Response, any) {
+		t.Helper()
+		var r io.Reader
+		if body != nil {
+			b, _ := json.Marshal(body)
+			r = bytes.NewReader(b)
+		}
+		req := httptest.NewRequest(method, path, r)
+		req.Header.Set("Content-Type", "application/json")
+		if accept != "" { req.Header.Set("Accept", accept) }
+		resp, err := app.Test(req)
+		if err != nil { t.Fatalf("%s %s: %v", method, path, err) }
+		defer resp.Body.Close()
+		var out any
+		_ = json.NewDecoder(resp.Body).Decode(&out)
+		return resp, out
+	}
+	// check validates a Feature or FeatureCollection response
+	check := func(resp *http.Response, body any) map[string]any {
+		t.Helper()
+		if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "application/geo+json" {
+			t.Fatalf("want 200 application/geo+json, got %d %q: %v", resp.StatusCode, resp.Header.Get("Content-Type"), body)
+		}
+		schema, _ := body.(map[string]any)["type"].(string)
+		for _, e := range spec.validate(spec.schema(schema), body, schema) {
+			t.Error(e)
+		}
+		return body.(map[string]any)
+	}
+
+	search := map[string]any{"location": map[string]any{"lat": 0, "lon": 0}, "limit": 5}
+	fc := check(do(http.MethodPost, "/api/v1/places/search?format=geojson", "", search))
+	features := fc["features"].([]any)
+	f := features[0].(map[string]any)
+	if len(features) != 2 || f["id"] != "a" || fmt.Sprint(f["geometry"]) != "map[coordinates:[0.02 0.001] type:Point]" {
+		t.Fatalf("search: %v", fc)
+	}
+	props := f["properties"].(map[string]any)
+	if props["name"] != "A" || props["country"] != "CY" || props["distance_m"] == nil || props["location"] != nil {
+		t.Fatalf("search properties: %v", props)
+	}
+	if fc = check(do(http.MethodPost, "/api/v1/places/search", "application/geo+json", search)); len(fc["features"].([]any)) != 2 {
+		t.Fatalf("search by Accept: %v", fc)
+	}
+
+	route := map[string]any{"line": map[string]any{"type": "LineString", "coordinates": [][]float64{{0, 0}, {0.1, 0}}}, "buffer_m": 500}
+	fc = check(do(http.MethodPost, "/api/v1/places/along-route", "application/geo+json, application/json;q=0.5", route))
+	if props = fc["features"].([]any)[1].(map[string]any)["properties"].(map[string]any); props["along_m"] == nil || props["id"] != "b" {
+		t.Fatalf("along-route: %v", fc)
+	}
+
+	batch := map[string]any{"queries": []any{
+		map[string]any{"location": map[string]any{"lat": 0, "lon": 0}, "limit": 1},
+		map[string]any{"location": map[string]any{"lat": 91, "lon": 0}},
+		map[string]any{"location": map[string]any{"lat": 0, "lon": 0.1}, "limit": 1},
+	}}
+	resp, body := do(http.MethodPost, "/api/v1/places/search:batch?format=geojson", "", batch)
+	if resp.Header.Get("Content-Type") != "application/geo+json" { t.Fatalf("batch: want GeoJSON, got %d: %v", resp.StatusCode, body) }
+	for _, e := range spec.validate(spec.schema("BatchFeatureCollection"), body, "BatchFeatureCollection") {
+		t.Error(e)
+	}
+	fc = body.(map[string]any)
+	features = fc["features"].([]any)
+	first, last := features[0].(map[string]any)["properties"].(map[string]any), features[1].(map[string]any)["properties"].(map[string]any)
+	errs := fc["errors"].([]any)
+	if len(features) != 2 || first["id"] != "a" || first["query_index"] != float64(0) || last["id"] != "b" || last["query_index"] != float64(2) ||
+		len(errs) != 1 || errs[0].(map[string]any)["query_index"] != float64(1) {
+		t.Fatalf("batch: want a from query 0, b from query 2 and query 1 failed, got %v", fc)
+	}
+
+	fc = check(do(http.MethodGet, "/api/v1/clusters?bbox=-1,-1,1,1&zoom=3", "application/geo+json", nil))
+	if features = fc["features"].([]any); len(features) != 1 || features[0].(map[string]any)["id"] != "5/16/15" ||
+		features[0].(map[string]any)["properties"].(map[string]any)["count"] != float64(2) {
+		t.Fatalf("clusters: want one cluster of both places, got %v", fc)
+	}
+
+	fc = check(do(http.MethodGet, "/api/v1/places:export?format=geojson&bbox=0,0,0.05,1", "", nil))
+	if features = fc["features"].([]any); len(features) != 1 || features[0].(map[string]any)["id"] != "a" {
+		t.Fatalf("export in bbox: want a, got %v", fc)
+	}
+
+	resp, body = do(http.MethodGet, "/api/v1/places/a?format=geojson", "", nil)
+	if f = check(resp, body); f["type"] != "Feature" || f["properties"].(map[string]any)["name"] != "A" || resp.Header.Get("ETag") == "" {
+		t.Fatalf("get: %v", f)
+	}
+	if resp, body = do(http.MethodGet, "/api/v1/places/a?format=json", "application/geo+json", nil); resp.Header.Get("Content-Type") != "application/json" || body.(map[string]any)["location"] == nil {
+		t.Fatalf("format=json overrides Accept: %q %v", resp.Header.Get("Content-Type"), body)
+	}
+	if resp, body = do(http.MethodGet, "/api/v1/places/a?format=kml", "", nil); resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("format=kml: expected 400, got %d: %v", resp.StatusCode, body)
+	}
+}
@@ -0,0 +1,646 @@
+package api_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http/httptest"
+	"slices"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"redcat/internal/domain/audit"
+	"redcat/internal/domain/dedupe"
+	"redcat/internal/domain/errs"
+	"redcat/internal/domain/events"
+	"redcat/internal/domain/geo"
+	"redcat/internal/domain/h3"
+	"redcat/internal/domain/model"
+	wh "redcat/internal/domain/webhooks"
+	"redcat/internal/service/geofences"
+	svc "redcat/internal/service/places"
+	"redcat/internal/service/webhooks"
+	"redcat/internal/storage/valkey"
+)
+
+// In-memory fakes of the stores behind the handlers, shared by the tests of
+// this package, and request helpers.
+
+// memStore is an in-memory svc.Store for exercising handlers without Valkey.
+type memStore struct {
+	mu      sync.Mutex
+	places  map[string]model.Place
+	deleted map[string]time.Time // soft-deleted IDs, still present in places
+	aliases map[string]string
+	outbox  map[string][]events.PlaceChanged // pending feed events per place
+	err     error                // when set, every call fails with it
+}
+
+func newMemStore(seed ...model.Place) *memStore {
+	s := &memStore{places: map[string]model.Place{}, deleted: map[string]time.Time{}}
+	for _, p := range seed { s.places[p.ID] = p }
+	return s
+}
+
+func (s *memStore) Upsert(_ context.Context, p model.Place) (int64, model.Place, error) {
+	s.mu.Lock(); defer s.mu.Unlock()
+	if s.err != nil { return 0, model.Place{}, s.err }
+	before := s.places[p.ID]
+	if _, gone := s.deleted[p.ID]; gone { before = model.Place{} }
+	p.Version = s.places[p.ID].Version + 1
+	s.places[p.ID] = p
+	delete(s.deleted, p.ID)
+	action := audit.Update
+	if before.ID == "" { action = audit.Create }
+	s.record(action, p.ID, p.Version, &p)
+	return p.Version, before, nil
+}
+
+func (s *memStore) Create(_ context.Context, p model.Place) (int64, error) {
+	s.mu.Lock(); defer s.mu.Unlock()
+	if s.err != nil { return 0, s.err }
+	if _, ok := s.places[p.ID]; ok { return 0, valkey.ErrConflict }
+	p.Version = 1
+	s.places[p.ID] = p
+	s.record(audit.Create, p.ID, 1, &p)
+	return 1, nil
+}
+
+// check mirrors the version check of the storage CAS scripts.
+func (s *memStore) check(id string, ifVersion int64) (model.Place, error) {
+	cur, ok := s.places[id]
+	if _, gone := s.deleted[id]; !ok || gone { return model.Place{}, valkey.ErrNotFound }
+	if ifVersion != valkey.AnyVersion && ifVersion != cur.Version { return model.Place{}, valkey.ErrPreconditionFailed }
+	return cur, nil
+}
+
+func (s *memStore) Replace(_ context.Context, p model.Place, ifVersion int64) (int64, error) {
+	s.mu.Lock(); defer s.mu.Unlock()
+	if s.err != nil { return 0, s.err }
+	cur, err := s.check(p.ID, ifVersion)
+	if err != nil { return 0, err }
+	p.Version = cur.Version + 1
+	s.places[p.ID] = p
+	s.record(audit.Update, p.ID, p.Version, &p)
+	return p.Version, nil
+}
+
+func (s *memStore) Get(_ context.Context, id string, _ ...string) (model.Place, error) {
+	s.mu.Lock(); defer s.mu.Unlock()
+	if s.err != nil { return model.Place{}, s.err }
+	p, ok := s.places[id]
+	if _, gone := s.deleted[id]; !ok || gone { return model.Place{}, valkey.ErrNotFound }
+	return p, nil
+}
+
+func (s *memStore) Delete(_ context.Context, id string, ifVersion int64) (model.Place, error) {
+	s.mu.Lock(); defer s.mu.Unlock()
+	if s.err != nil { return model.Place{}, s.err }
+	if ifVersion != valkey.AnyVersion {
+		if _, err := s.check(id, ifVersion); err != nil { return model.Place{}, err }
+	}
+	before, ok := s.places[id]
+	if !ok { return model.Place{}, valkey.ErrNotFound }
+	s.record(audit.Delete, id, before.Version, nil)
+	if _, gone := s.deleted[id]; gone { before = model.Place{} }
+	delete(s.places, id)
+	delete(s.deleted, id)
+	return before, nil
+}
+
+func (s *memStore) SoftDelete(_ context.Context, id string, ifVersion int64, at time.Time) (model.Place, error) {
+	s.mu.Lock(); defer s.mu.Unlock()
+	if s.err != nil { return model.Place{}, s.err }
+	cur, err := s.check(id, ifVersion)
+	if err != nil { return model.Place{}, err }
+	before := cur
+	cur.Version++
+	s.places[id] = cur
+	s.deleted[id] = at
+	s.record(audit.Delete, id, before.Version, nil)
+	return before, nil
+}
+
+func (s *memStore) Restore(_ context.Context, id string) (int64, error) {
+	s.mu.Lock(); defer s.mu.Unlock()
+	if s.err != nil { return 0, s.err }
+	cur, ok := s.places[id]
+	if !ok { return 0, valkey.ErrNotFound }
+	if _, gone := s.deleted[id]; !gone { return 0, valkey.ErrNotDeleted }
+	cur.Version++
+	s.places[id] = cur
+	delete(s.deleted, id)
+	s.record(audit.Restore, id, cur.Version, &cur)
+	return cur.Version, nil
+}
+
+// record queues the feed event of a write, as the storage scripts do.
+func (s *memStore) record(action audit.Action, id string, version int64, p *model.Place) {
+	if s.outbox == nil { s.outbox = map[string][]events.PlaceChanged{} }
+	ev := events.PlaceChanged{PlaceID: id, Action: action, At: time.Now(), Version: version}
+	if p != nil { cp := *p; ev.Place = &cp }
+	s.outbox[id] = append(s.outbox[id], ev)
+}
+
+func (s *memStore) DrainOutbox(ctx context.Context, id string, publish func(context.Context, events.PlaceChanged) error) (int, error) {
+	var n int
+	for {
+		s.mu.Lock()
+		if len(s.outbox[id]) == 0 { s.mu.Unlock(); return n, nil }
+		ev := s.outbox[id][0]
+		s.mu.Unlock()
+		if err := publish(ctx, ev); err != nil { return n, err }
+		s.mu.Lock()
+		s.outbox[id] = s.outbox[id][1:]
+		if len(s.outbox[id]) == 0 { delete(s.outbox, id) }
+		s.mu.Unlock()
+		n++
+	}
+}
+
+func (s *memStore) ScanOutboxes(_ context.Context, _ int64, fn func([]string) error) error {
+	s.mu.Lock()
+	ids := make([]string, 0, len(s.outbox))
+	for id := range s.outbox { ids = append(ids, id) }
+	s.mu.Unlock()
+	sort.Strings(ids)
+	if len(ids) == 0 { return nil }
+	return fn(ids)
+}
+
+func (s *memStore) PurgeDeleted(_ context.Context, cutoff time.Time, _ int64) (int, error) {
+	s.mu.Lock(); defer s.mu.Unlock()
+	if s.err != nil { return 0, s.err }
+	var n int
+	for id, at := range s.deleted {
+		if at.After(cutoff) { continue }
+		delete(s.places, id)
+		delete(s.deleted, id)
+		n++
+	}
+	return n, nil
+}
+
+func (s *memStore) SearchNearest(_ context.Context, sp valkey.SearchParams) ([]valkey.SearchResult, error) {
+	s.mu.Lock(); defer s.mu.Unlock()
+	if s.err != nil { return nil, s.err }
+	ids := make([]string, 0, len(s.places))
+	for id := range s.places {
+		if _, gone := s.deleted[id]; !gone { ids = append(ids, id) }
+	}
+	sort.Strings(ids)
+	out := make([]valkey.SearchResult, 0, len(ids))
+	for i, id := range ids {
+		out = append(out, valkey.SearchResult{Place: s.places[id], DistanceM: float64(i) * 10})
+	}
+	return out, nil
+}
+
+func (s *memStore) Locate(_ context.Context, ids []string) (map[string]model.Place, error) {
+	s.mu.Lock(); defer s.mu.Unlock()
+	if s.err != nil { return nil, s.err }
+	out := map[string]model.Place{}
+	for _, id := range ids {
+		p, ok := s.places[id]
+		if _, gone := s.deleted[id]; ok && !gone { out[id] = model.Place{ID: id, Lat: p.Lat, Lon: p.Lon} }
+	}
+	return out, nil
+}
+
+// SearchNearestBatch ranks by haversine distance, so items with different
+// points get different results.
+func (s *memStore) SearchNearestBatch(_ context.Context, sps []valkey.SearchParams) []valkey.BatchResult {
+	s.mu.Lock(); defer s.mu.Unlock()
+	out := make([]valkey.BatchResult, len(sps))
+	for i, sp := range sps {
+		if s.err != nil {
+			out[i].Err = s.err
+			continue
+		}
+		var res []valkey.SearchResult
+		for id, p := range s.places {
+			if _, gone := s.deleted[id]; gone { continue }
+			if len(sp.CategoryIDs) > 0 && !slices.ContainsFunc(p.CategoryIDs, func(c string) bool { return slices.Contains(sp.CategoryIDs, c) }) { continue }
+			if sp.Within != nil && !sp.Within.Contains(p.Lat, p.Lon) { continue }
+			if sp.HasAddress && p.Address+p.Locality+p.Region+p.Postcode+p.Country == "" { continue }
+			res = append(res, valkey.SearchResult{Place: p, DistanceM: geo.Haversine(sp.Lat, sp.Lon, p.Lat, p.Lon)})
+		}
+		sort.Slice(res, func(a, b int) bool { return res[a].DistanceM < res[b].DistanceM })
+		if sp.Limit > 0 && int64(len(res)) > sp.Limit { res = res[:sp.Limit] }
+		out[i].Results = res
+	}
+	return out
+}
+
+// Facets counts exactly, with haversine for the radius.
+func (s *memStore) Facets(_ context.Context, fp valkey.FacetParams) (valkey.Facets, error) {
+	s.mu.Lock(); defer s.mu.Unlock()
+	if s.err != nil { return valkey.Facets{}, s.err }
+	var out valkey.Facets
+	cats, countries := map[string]int64{}, map[string]int64{}
+	for id, p := range s.places {
+		if _, gone := s.deleted[id]; gone { continue }
+		if !fp.BBox.Contains(p.Lat, p.Lon) { continue }
+		if fp.RadiusM > 0 && geo.Haversine(fp.Lat, fp.Lon, p.Lat, p.Lon) > fp.RadiusM { continue }
+		if len(fp.CategoryIDs) > 0 && !slices.ContainsFunc(p.CategoryIDs, func(c string) bool { return slices.Contains(fp.CategoryIDs, c) }) { continue }
+		out.Total++
+		for _, c := range p.CategoryIDs { cats[c]++ }
+		if p.Country != "" { countries[p.Country]++ }
+	}
+	top := func(m map[string]int64) []valkey.FacetCount {
+		var fc []valkey.FacetCount
+		for v, n := range m { fc = append(fc, valkey.FacetCount{Value: v, Count: n}) }
+		sort.Slice(fc, func(i, j int) bool { return fc[i].Count > fc[j].Count || fc[i].Count == fc[j].Count && fc[i].Value < fc[j].Value })
+		if fp.Top > 0 && int64(len(fc)) > fp.Top { fc = fc[:fp.Top] }
+		return fc
+	}
+	out.Categories, out.Countries = top(cats), top(countries)
+	return out, nil
+}
+
+// Heatmap computes each place's cell rather than reading stored ones.
+func (s *memStore) Heatmap(_ context.Context, hp valkey.HeatmapParams) ([]valkey.FacetCount, bool, error) {
+	s.mu.Lock(); defer s.mu.Unlock()
+	if s.err != nil { return nil, false, s.err }
+	counts := map[string]int64{}
+	for id, p := range s.places {
+		if _, gone := s.deleted[id]; gone { continue }
+		if !hp.BBox.Contains(p.Lat, p.Lon) { continue }
+		if len(hp.CategoryIDs) > 0 && !slices.ContainsFunc(p.CategoryIDs, func(c string) bool { return slices.Contains(hp.CategoryIDs, c) }) { continue }
+		counts[h3.FromLatLng(p.Lat, p.Lon, hp.Res).String()]++
+	}
+	var out []valkey.FacetCount
+	for v, n := range counts { out = append(out, valkey.FacetCount{Value: v, Count: n}) }
+	sort.Slice(out, func(i, j int) bool { return out[i].Count > out[j].Count || out[i].Count == out[j].Count && out[i].Value < out[j].Value })
+	if int64(len(out)) > hp.MaxCells { return out[:hp.MaxCells], true, nil }
+	return out, false, nil
+}
+
+// Grid bins the places in Go the way the aggregates do.
+func (s *memStore) Grid(_ context.Context, gp valkey.GridParams) ([]valkey.GridCell, error) {
+	s.mu.Lock(); defer s.mu.Unlock()
+	if s.err != nil { return nil, s.err }
+	cells := map[[2]int]*valkey.GridCell{}
+	cats := map[[2]int]map[string]int64{}
+	for id, p := range s.places {
+		if _, gone := s.deleted[id]; gone { continue }
+		if !gp.BBox.Contains(p.Lat, p.Lon) { continue }
+		if len(gp.CategoryIDs) > 0 && !slices.ContainsFunc(p.CategoryIDs, func(c string) bool { return slices.Contains(gp.CategoryIDs, c) }) { continue }
+		row := -1
+		for i := 0; i+1 < len(gp.LatEdges); i++ {
+			if p.Lat <= gp.LatEdges[i+1] && (p.Lat > gp.LatEdges[i] || i == 0 && p.Lat == gp.LatEdges[i]) { row = i; break }
+		}
+		if row < 0 { continue }
+		k := [2]int{row, min(int((p.Lon+180)/gp.ColWidth), int(math.Round(360/gp.ColWidth))-1)}
+		if cells[k] == nil { cells[k], cats[k] = &valkey.GridCell{Row: k[0], Col: k[1]}, map[string]int64{} }
+		c := cells[k]
+		c.Count++
+		c.Lat += (p.Lat - c.Lat) / float64(c.Count)
+		c.Lon += (p.Lon - c.Lon) / float64(c.Count)
+		for _, cat := range p.CategoryIDs { cats[k][cat]++ }
+	}
+	var out []valkey.GridCell
+	for k, c := range cells {
+		for v, n := range cats[k] { c.Categories = append(c.Categories, valkey.FacetCount{Value: v, Count: n}) }
+		sort.Slice(c.Categories, func(i, j int) bool { return c.Categories[i].Count > c.Categories[j].Count || c.Categories[i].Count == c.Categories[j].Count && c.Categories[i].Value < c.Categories[j].Value })
+		if gp.TopCategories > 0 && int64(len(c.Categories)) > gp.TopCategories { c.Categories = c.Categories[:gp.TopCategories] }
+		c.Lat, c.Lon = math.Round(c.Lat*1e6)/1e6, math.Round(c.Lon*1e6)/1e6
+		out = append(out, *c)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Row < out[j].Row || out[i].Row == out[j].Row && out[i].Col < out[j].Col })
+	return out, nil
+}
+
+func (s *memStore) CategoryLabels(_ context.Context, ids []string) (map[string]string, error) {
+	s.mu.Lock(); defer s.mu.Unlock()
+	out := map[string]string{}
+	for _, p := range s.places {
+		for i, c := range p.CategoryIDs {
+			if slices.Contains(ids, c) && i < len(p.CategoryLabels) { out[c] = p.CategoryLabels[i] }
+		}
+	}
+	return out, nil
+}
+
+func (s *memStore) GetMany(ctx context.Context, ids []string) ([]model.Place, error) {
+	var out []model.Place
+	for _, id := range ids {
+		p, err := s.Get(ctx, id)
+		if errors.Is(err, valkey.ErrNotFound) { continue }
+		if err != nil { return nil, err }
+		out = append(out, p)
+	}
+	return out, nil
+}
+
+// ScanIDs reports places in ID order, batch at a time.
+func (s *memStore) ScanIDs(_ context.Context, batch int64, fn func([]string) error) error {
+	s.mu.Lock()
+	ids := make([]string, 0, len(s.places))
+	for id := range s.places { ids = append(ids, id) }
+	s.mu.Unlock()
+	sort.Strings(ids)
+	for len(ids) > 0 {
+		n := min(int(batch), len(ids))
+		if err := fn(ids[:n]); err != nil { return err }
+		ids = ids[n:]
+	}
+	return nil
+}
+
+func (s *memStore) SetAliases(_ context.Context, aliases map[string]string) error {
+	s.mu.Lock(); defer s.mu.Unlock()
+	if s.aliases == nil { s.aliases = map[string]string{} }
+	for from, to := range aliases { s.aliases[from] = to }
+	return nil
+}
+
+func (s *memStore) Alias(_ context.Context, id string) (string, error) {
+	s.mu.Lock(); defer s.mu.Unlock()
+	return s.aliases[id], nil
+}
+
+func (s *memStore) DeleteAlias(_ context.Context, id string) error {
+	s.mu.Lock(); defer s.mu.Unlock()
+	if _, ok := s.aliases[id]; !ok { return svc.ErrAliasNotFound }
+	delete(s.aliases, id)
+	return nil
+}
+
+// memDupes is an in-memory svc.DuplicateQueue.
+type memDupes struct {
+	mu        sync.Mutex
+	queue     map[string]dedupe.Candidate
+	dismissed map[string]bool
+}
+
+func newMemDupes() *memDupes {
+	return &memDupes{queue: map[string]dedupe.Candidate{}, dismissed: map[string]bool{}}
+}
+
+func (q *memDupes) Add(_ context.Context, cs []dedupe.Candidate) (int, error) {
+	q.mu.Lock(); defer q.mu.Unlock()
+	var added int
+	for _, c := range cs {
+		if q.dismissed[c.ID()] { continue }
+		if _, ok := q.queue[c.ID()]; !ok { added++ }
+		q.queue[c.ID()] = c
+	}
+	return added, nil
+}
+
+func (q *memDupes) List(_ context.Context, limit int64) ([]dedupe.Candidate, error) {
+	q.mu.Lock(); defer q.mu.Unlock()
+	out := []dedupe.Candidate{}
+	for _, c := range q.queue { out = append(out, c) }
+	sort.Slice(out, func(i, j int) bool { return out[i].Score > out[j].Score })
+	if int64(len(out)) > limit { out = out[:limit] }
+	return out, nil
+}
+
+func (q *memDupes) Dismiss(_ context.Context, a, b string) error {
+	q.mu.Lock(); defer q.mu.Unlock()
+	id := dedupe.PairID(a, b)
+	if _, ok := q.queue[id]; !ok { return svc.ErrCandidateNotFound }
+	delete(q.queue, id)
+	q.dismissed[id] = true
+	return nil
+}
+
+func (q *memDupes) RemovePlace(_ context.Context, id string) error {
+	q.mu.Lock(); defer q.mu.Unlock()
+	for k, c := range q.queue {
+		if c.A.ID == id || c.B.ID == id { delete(q.queue, k) }
+	}
+	return nil
+}
+
+// memHistory is an in-memory svc.HistoryStore; entry IDs are sequence
+// numbers in stream ID form.
+type memHistory struct {
+	mu      sync.Mutex
+	seq     int
+	entries map[string][]audit.Entry
+}
+
+func (h *memHistory) Append(_ context.Context, e audit.Entry) error {
+	h.mu.Lock(); defer h.mu.Unlock()
+	if h.entries == nil { h.entries = map[string][]audit.Entry{} }
+	h.seq++
+	e.ID = fmt.Sprintf("%d-0", h.seq)
+	h.entries[e.PlaceID] = append(h.entries[e.PlaceID], e)
+	return nil
+}
+
+func (h *memHistory) List(_ context.Context, id, before string, limit int64) ([]audit.Entry, string, error) {
+	h.mu.Lock(); defer h.mu.Unlock()
+	var out []audit.Entry
+	all := h.entries[id]
+	for i := len(all) - 1; i >= 0; i-- {
+		if before != "" && all[i].ID >= before { continue }
+		if int64(len(out)) == limit { return out, out[len(out)-1].ID, nil }
+		out = append(out, all[i])
+	}
+	return out, "", nil
+}
+
+// memFeed is a single-shard in-memory svc.ChangeFeed; the cursor is the
+// sequence number of the last event read.
+type memFeed struct {
+	mu      sync.Mutex
+	events  []events.PlaceChanged
+	fails   int // how many of the next publishes fail
+	trimmed int // how many of the oldest events were trimmed
+}
+
+func (f *memFeed) Publish(_ context.Context, ev events.PlaceChanged) error {
+	f.mu.Lock(); defer f.mu.Unlock()
+	if f.fails > 0 {
+		f.fails--
+		return errs.Wrap(errs.BackendUnavailable, errors.New("LOADING"), "backend unavailable")
+	}
+	ev.ID = fmt.Sprintf("%d-0", len(f.events)+1)
+	f.events = append(f.events, ev)
+	return nil
+}
+
+func (f *memFeed) Read(_ context.Context, cursor string, limit int64) ([]events.PlaceChanged, string, error) {
+	f.mu.Lock(); defer f.mu.Unlock()
+	var n int
+	if cursor != "" {
+		if _, err := fmt.Sscanf(cursor, "%d-0", &n); err != nil { return nil, "", errs.Invalid("bad cursor", nil) }
+		if n < f.trimmed { return nil, "", svc.ErrCursorExpired }
+	}
+	n = max(n, f.trimmed)
+	out := f.events[min(n, len(f.events)):]
+	if int64(len(out)) > limit { out = out[:limit] }
+	return out, fmt.Sprintf("%d-0", n+len(out)), nil
+}
+
+var fullPlace = model.Place{
+	ID: "p1", Name: "Cafe", Lat: 35.1753, Lon: 33.3642,
+	Address: "1 Main St", Locality: "Nicosia", Region: "Nicosia District", Postcode: "1000",
+	AdminRegion: "Lefkosia", PostTown: "Nicosia", PoBox: "42", Country: "CY",
+	DateCreated: "2024-01-15", DateRefreshed: "2024-06-20",
+	Tel: "+357 22 123456", Website: "https://example.com", Email: "info@example.com",
+	FacebookID: "123", Instagram: "cafe", Twitter: "cafe",
+	CategoryIDs: []string{"c1"}, CategoryLabels: []string{"Dining > Cafe"},
+	PlacemakerURL: "https://example.com/pm", Dt: "2024-06-20",
+}
+
+func init() {
+	fullPlace.BBox.XMin, fullPlace.BBox.YMin, fullPlace.BBox.XMax, fullPlace.BBox.YMax = 33.3, 35.1, 33.4, 35.2
+}
+
+func doJSON(t *testing.T, app *fiber.App, method, path string, body any) (int, any) {
+	t.Helper()
+	var r io.Reader
+	if body != nil {
+		b, _ := json.Marshal(body)
+		r = bytes.NewReader(b)
+	}
+	req := httptest.NewRequest(method, path, r)
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatalf("%s %s: %v", method, path, err)
+	}
+	defer resp.Body.Close()
+	raw, _ := io.ReadAll(resp.Body)
+	var out any
+	if len(raw) > 0 {
+		if err := json.Unmarshal(raw, &out); err != nil {
+			t.Fatalf("%s %s: invalid JSON %q: %v", method, path, raw, err)
+		}
+	}
+	return resp.StatusCode, out
+}
+
+// memWebhooks is an in-memory webhooks.Store.
+type memWebhooks struct {
+	mu         sync.Mutex
+	subs       map[string]wh.Subscription
+	deliveries map[string]wh.Delivery
+	dead       []wh.Delivery
+}
+
+func newMemWebhooks() *memWebhooks {
+	return &memWebhooks{subs: map[string]wh.Subscription{}, deliveries: map[string]wh.Delivery{}}
+}
+
+func (m *memWebhooks) SaveSubscription(_ context.Context, sub wh.Subscription) error {
+	m.mu.Lock(); defer m.mu.Unlock()
+	m.subs[sub.ID] = sub
+	return nil
+}
+
+func (m *memWebhooks) GetSubscription(_ context.Context, id string) (wh.Subscription, error) {
+	m.mu.Lock(); defer m.mu.Unlock()
+	sub, ok := m.subs[id]
+	if !ok { return wh.Subscription{}, webhooks.ErrNotFound }
+	return sub, nil
+}
+
+func (m *memWebhooks) DeleteSubscription(_ context.Context, id string) error {
+	m.mu.Lock(); defer m.mu.Unlock()
+	if _, ok := m.subs[id]; !ok { return webhooks.ErrNotFound }
+	delete(m.subs, id)
+	return nil
+}
+
+func (m *memWebhooks) ListSubscriptions(_ context.Context) ([]wh.Subscription, error) {
+	m.mu.Lock(); defer m.mu.Unlock()
+	out := make([]wh.Subscription, 0, len(m.subs))
+	for _, sub := range m.subs { out = append(out, sub) }
+	sort.Slice(out, func(i, j int) bool { return out[i].ID < out[j].ID })
+	return out, nil
+}
+
+func (m *memWebhooks) Enqueue(_ context.Context, d wh.Delivery) error {
+	m.mu.Lock(); defer m.mu.Unlock()
+	m.deliveries[d.ID] = d
+	return nil
+}
+
+func (m *memWebhooks) Retry(ctx context.Context, d wh.Delivery) error { return m.Enqueue(ctx, d) }
+
+func (m *memWebhooks) Claim(_ context.Context, now time.Time, lease time.Duration, n int) ([]wh.Delivery, error) {
+	m.mu.Lock(); defer m.mu.Unlock()
+	var out []wh.Delivery
+	for id, d := range m.deliveries {
+		if len(out) == n || d.NextAt.After(now) { continue }
+		out = append(out, d)
+		d.NextAt = now.Add(lease)
+		m.deliveries[id] = d
+	}
+	return out, nil
+}
+
+func (m *memWebhooks) Complete(_ context.Context, id string) error {
+	m.mu.Lock(); defer m.mu.Unlock()
+	delete(m.deliveries, id)
+	return nil
+}
+
+func (m *memWebhooks) DeadLetter(_ context.Context, d wh.Delivery) error {
+	m.mu.Lock(); defer m.mu.Unlock()
+	delete(m.deliveries, d.ID)
+	m.dead = append([]wh.Delivery{d}, m.dead...)
+	return nil
+}
+
+func (m *memWebhooks) DeadLetters(_ context.Context, limit int64) ([]wh.Delivery, error) {
+	m.mu.Lock(); defer m.mu.Unlock()
+	return m.dead[:min(int(limit), len(m.dead))], nil
+}
+
+// memGeofences is an in-memory geofences.Store; Candidates applies the same
+// bbox prefilter as the index.
+type memGeofences struct {
+	mu     sync.Mutex
+	fences map[string]model.Geofence
+}
+
+func (m *memGeofences) Create(_ context.Context, f model.Geofence) error {
+	m.mu.Lock(); defer m.mu.Unlock()
+	if _, ok := m.fences[f.ID]; ok { return geofences.ErrConflict }
+	m.fences[f.ID] = f
+	return nil
+}
+
+func (m *memGeofences) Replace(_ context.Context, f model.Geofence) error {
+	m.mu.Lock(); defer m.mu.Unlock()
+	if _, ok := m.fences[f.ID]; !ok { return geofences.ErrNotFound }
+	m.fences[f.ID] = f
+	return nil
+}
+
+func (m *memGeofences) Get(_ context.Context, id string) (model.Geofence, error) {
+	m.mu.Lock(); defer m.mu.Unlock()
+	f, ok := m.fences[id]
+	if !ok { return model.Geofence{}, geofences.ErrNotFound }
+	return f, nil
+}
+
+func (m *memGeofences) Delete(_ context.Context, id string) error {
+	m.mu.Lock(); defer m.mu.Unlock()
+	if _, ok := m.fences[id]; !ok { return geofences.ErrNotFound }
+	delete(m.fences, id)
+	return nil
+}
+
+func (m *memGeofences) Candidates(_ context.Context, lat, lon float64) ([]model.Geofence, error) {
+	m.mu.Lock(); defer m.mu.Unlock()
+	var out []model.Geofence
+	for _, f := range m.fences {
+		if f.Geometry.Bounds().Contains(lat, lon) { out = append(out, f) }
+	}
+	return out, nil
+}
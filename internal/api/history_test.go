@@ -0,0 +1,229 @@
+package api_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"redcat/internal/api"
+	"redcat/internal/domain/model"
+	svc "redcat/internal/service/places"
+)
+
+func TestHistory_RecordsMutations(t *testing.T) {
+	spec := loadSpec(t)
+	// app.Test connections come from 0.0.0.0
+	proxies, err := api.ParseActorProxies([]string{"0.0.0.0", "10.0.0.0/8"})
+	if err != nil { t.Fatal(err) }
+	app := fiber.New()
+	api.Register(app, api.Handlers{Places: svc.New(newMemStore(), svc.WithHistory(&memHistory{})), ActorProxies: proxies})
+	send := func(method, path string, body any) int {
+		t.Helper()
+		b, _ := json.Marshal(body)
+		req := httptest.NewRequest(method, path, bytes.NewReader(b))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set(api.ActorHeader, "alice")
+		resp, err := app.Test(req)
+		if err != nil { t.Fatalf("%s %s: %v", method, path, err) }
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	send(http.MethodPost, "/api/v1/places", map[string]any{"id": "p1", "name": "A", "location": map[string]any{"lat": 1, "lon": 2}, "category_ids": []string{"c1"}})
+	send(http.MethodPut, "/api/v1/places/p1", map[string]any{"location": map[string]any{"lat": 1.5, "lon": 2}})
+	if status := send(http.MethodDelete, "/api/v1/places/p1", nil); status != http.StatusNoContent {
+		t.Fatalf("delete: expected 204, got %d", status)
+	}
+
+	status, body := doJSON(t, app, http.MethodGet, "/api/v1/places/p1/history?limit=2", nil)
+	if status != http.StatusOK { t.Fatalf("history: expected 200, got %d: %v", status, body) }
+	for _, e := range spec.validate(spec.schema("HistoryResponse"), body, "HistoryResponse") {
+		t.Error(e)
+	}
+	page := body.(map[string]any)
+	entries := page["entries"].([]any)
+	if len(entries) != 2 || page["next_cursor"] == nil { t.Fatalf("first page: want 2 entries and a cursor, got %v", page) }
+	del, upd := entries[0].(map[string]any), entries[1].(map[string]any)
+	if del["action"] != "delete" || upd["action"] != "update" || upd["actor"] != "alice" {
+		t.Fatalf("unexpected entries: %v", entries)
+	}
+	want := []any{map[string]any{"field": "lat", "before": "1", "after": "1.5"}}
+	if got := upd["changes"]; fmt.Sprint(got) != fmt.Sprint(want) { t.Fatalf("update changes: want %v got %v", want, got) }
+
+	_, body = doJSON(t, app, http.MethodGet, "/api/v1/places/p1/history?limit=2&cursor="+page["next_cursor"].(string), nil)
+	page = body.(map[string]any)
+	if entries := page["entries"].([]any); len(entries) != 1 || entries[0].(map[string]any)["action"] != "create" || page["next_cursor"] != nil {
+		t.Fatalf("last page: want the create entry only, got %v", page)
+	}
+
+	if status, _ := doJSON(t, app, http.MethodGet, "/api/v1/places/p1/history?cursor=abc", nil); status != http.StatusBadRequest {
+		t.Fatalf("bad cursor: expected 400, got %d", status)
+	}
+}
+
+func TestHistory_ActorOnlyFromProxies(t *testing.T) {
+	if _, err := api.ParseActorProxies([]string{"10.0.0.1", "proxy.local"}); err == nil { t.Fatal("ParseActorProxies: want an error for a host name") }
+	for name, list := range map[string][]string{"no proxies": nil, "other proxy": {"10.0.0.0/8"}} {
+		proxies, err := api.ParseActorProxies(list)
+		if err != nil { t.Fatal(err) }
+		app := fiber.New()
+		api.Register(app, api.Handlers{Places: svc.New(newMemStore(), svc.WithHistory(&memHistory{})), ActorProxies: proxies})
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/places", strings.NewReader(`{"id":"p1","name":"A","location":{"lat":1,"lon":2},"category_ids":["c1"]}`))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set(api.ActorHeader, "mallory")
+		resp, err := app.Test(req)
+		if err != nil { t.Fatal(err) }
+		resp.Body.Close()
+
+		_, body := doJSON(t, app, http.MethodGet, "/api/v1/places/p1/history", nil)
+		entries := body.(map[string]any)["entries"].([]any)
+		if len(entries) != 1 || entries[0].(map[string]any)["actor"] != "anonymous" {
+			t.Fatalf("%s: want the create by anonymous, got %v", name, entries)
+		}
+	}
+}
+
+func TestChanges_ReplayAndResume(t *testing.T) {
+	spec := loadSpec(t)
+	app := fiber.New()
+	api.Register(app, api.Handlers{Places: svc.New(newMemStore(), svc.WithChangeFeed(&memFeed{}))})
+
+	doJSON(t, app, http.MethodPost, "/api/v1/places", map[string]any{"id": "p1", "name": "A", "location": map[string]any{"lat": 1, "lon": 2}, "category_ids": []string{"c1"}})
+	doJSON(t, app, http.MethodPut, "/api/v1/places/p1", map[string]any{"name": "B"})
+	doJSON(t, app, http.MethodDelete, "/api/v1/places/p1", nil)
+
+	status, body := doJSON(t, app, http.MethodGet, "/api/v1/changes?limit=2", nil)
+	if status != http.StatusOK { t.Fatalf("changes: expected 200, got %d: %v", status, body) }
+	for _, e := range spec.validate(spec.schema("ChangesResponse"), body, "ChangesResponse") {
+		t.Error(e)
+	}
+	page := body.(map[string]any)
+	evs := page["events"].([]any)
+	if len(evs) != 2 || evs[0].(map[string]any)["action"] != "create" || evs[1].(map[string]any)["place"].(map[string]any)["name"] != "B" {
+		t.Fatalf("first page: unexpected events %v", evs)
+	}
+
+	_, body = doJSON(t, app, http.MethodGet, "/api/v1/changes?since="+page["cursor"].(string), nil)
+	page = body.(map[string]any)
+	evs = page["events"].([]any)
+	if len(evs) != 1 || evs[0].(map[string]any)["action"] != "delete" || evs[0].(map[string]any)["place"] != nil {
+		t.Fatalf("resume: want the delete event without place, got %v", evs)
+	}
+
+	start := time.Now()
+	resp, err := app.Test(httptest.NewRequest(http.MethodGet, "/api/v1/changes?wait=1&since="+page["cursor"].(string), nil), 5000)
+	if err != nil { t.Fatalf("long-poll: %v", err) }
+	var idle api.ChangesResponse
+	_ = json.NewDecoder(resp.Body).Decode(&idle)
+	resp.Body.Close()
+	if len(idle.Events) != 0 || time.Since(start) < 900*time.Millisecond {
+		t.Fatalf("long-poll: want an empty page after waiting, got %d events after %v", len(idle.Events), time.Since(start))
+	}
+
+	if status, _ := doJSON(t, app, http.MethodGet, "/api/v1/changes?wait=31", nil); status != http.StatusBadRequest {
+		t.Fatalf("wait above max: expected 400, got %d", status)
+	}
+}
+
+func TestChanges_ExpiredCursor(t *testing.T) {
+	spec := loadSpec(t)
+	feed := &memFeed{}
+	app := fiber.New()
+	api.Register(app, api.Handlers{Places: svc.New(newMemStore(), svc.WithChangeFeed(feed))})
+	doJSON(t, app, http.MethodPost, "/api/v1/places", map[string]any{"id": "p1", "name": "A", "location": map[string]any{"lat": 1, "lon": 2}, "category_ids": []string{"c1"}})
+	doJSON(t, app, http.MethodPut, "/api/v1/places/p1", map[string]any{"name": "B"})
+	doJSON(t, app, http.MethodPut, "/api/v1/places/p1", map[string]any{"name": "C"})
+
+	// the consumer read the create, then the update after it was trimmed
+	feed.trimmed = 2
+	status, body := doJSON(t, app, http.MethodGet, "/api/v1/changes?since=1-0", nil)
+	if status != http.StatusGone { t.Fatalf("expired cursor: expected 410, got %d: %v", status, body) }
+	for _, e := range spec.validate(spec.schema("Error"), body, "Error") {
+		t.Error(e)
+	}
+	if code := body.(map[string]any)["code"]; code != "GONE" { t.Fatalf("expired cursor: want GONE, got %v", code) }
+
+	// a cursor at the last trimmed event missed nothing
+	if status, body := doJSON(t, app, http.MethodGet, "/api/v1/changes?since=2-0", nil); status != http.StatusOK || len(body.(map[string]any)["events"].([]any)) != 1 {
+		t.Fatalf("cursor at the trim: want the remaining event, got %d: %v", status, body)
+	}
+	// starting over reads what is retained
+	if status, body := doJSON(t, app, http.MethodGet, "/api/v1/changes", nil); status != http.StatusOK || len(body.(map[string]any)["events"].([]any)) != 1 {
+		t.Fatalf("start over: want the retained event, got %d: %v", status, body)
+	}
+}
+
+// blindStore fails every Get, so the audit trail can only come from the
+// state the writes themselves replaced.
+type blindStore struct{ *memStore }
+
+func (blindStore) Get(context.Context, string, ...string) (model.Place, error) {
+	return model.Place{}, errors.New("read replica unavailable")
+}
+
+func TestHistory_DiffsReplacedState(t *testing.T) {
+	store := newMemStore(model.Place{ID: "p1", Name: "A", Lat: 1, Lon: 2, CategoryIDs: []string{"c1"}, Version: 1})
+	app := fiber.New()
+	api.Register(app, api.Handlers{Places: svc.New(blindStore{store}, svc.WithHistory(&memHistory{}))})
+
+	upsert := map[string]any{"id": "p1", "name": "B", "location": map[string]any{"lat": 1, "lon": 2}, "category_ids": []string{"c1"}}
+	if status, body := doJSON(t, app, http.MethodPost, "/api/v1/places?upsert=true", upsert); status != http.StatusOK {
+		t.Fatalf("upsert: expected 200, got %d: %v", status, body)
+	}
+	if status, _ := doJSON(t, app, http.MethodDelete, "/api/v1/places/p1", nil); status != http.StatusNoContent {
+		t.Fatalf("delete: expected 204, got %d", status)
+	}
+
+	_, body := doJSON(t, app, http.MethodGet, "/api/v1/places/p1/history", nil)
+	entries := body.(map[string]any)["entries"].([]any)
+	if len(entries) != 2 { t.Fatalf("want 2 entries, got %v", entries) }
+	del, upd := entries[0].(map[string]any), entries[1].(map[string]any)
+	want := []any{map[string]any{"field": "name", "before": "A", "after": "B"}}
+	if upd["action"] != "update" || fmt.Sprint(upd["changes"]) != fmt.Sprint(want) {
+		t.Fatalf("upsert of an existing place: want an update of name, got %v", upd)
+	}
+	if del["action"] != "delete" || !strings.Contains(fmt.Sprint(del["changes"]), "before:B") {
+		t.Fatalf("delete: want the deleted state in the diff, got %v", del)
+	}
+}
+
+func TestChanges_FeedFailures(t *testing.T) {
+	store, feed := newMemStore(), &memFeed{fails: 1}
+	service := svc.New(store, svc.WithChangeFeed(feed))
+	app := fiber.New()
+	api.Register(app, api.Handlers{Places: service})
+	body := map[string]any{"id": "p1", "name": "A", "location": map[string]any{"lat": 1, "lon": 2}, "category_ids": []string{"c1"}}
+
+	// a feed that is down does not fail the committed write; the events
+	// wait in the outbox, in order
+	if status, resp := doJSON(t, app, http.MethodPost, "/api/v1/places", body); status != http.StatusCreated {
+		t.Fatalf("create with feed down: expected 201, got %d: %v", status, resp)
+	}
+	feed.fails = 10
+	if status, resp := doJSON(t, app, http.MethodPut, "/api/v1/places/p1", map[string]any{"name": "B"}); status != http.StatusOK {
+		t.Fatalf("update with feed down: expected 200, got %d: %v", status, resp)
+	}
+	if len(feed.events) != 0 { t.Fatalf("feed down: want no events, got %v", feed.events) }
+
+	// the relay sweep publishes them once the feed is back
+	feed.fails = 0
+	if n, err := service.Relay(context.Background()); err != nil || n != 2 { t.Fatalf("Relay: want 2 events, got %d (%v)", n, err) }
+	if len(feed.events) != 2 || feed.events[0].Action != "create" || feed.events[1].Action != "update" || feed.events[1].Place.Name != "B" {
+		t.Fatalf("relayed events: %+v", feed.events)
+	}
+	if n, _ := service.Relay(context.Background()); n != 0 { t.Fatalf("second Relay: want nothing left, got %d", n) }
+
+	// later writes relay their own events along with nothing older
+	doJSON(t, app, http.MethodDelete, "/api/v1/places/p1", nil)
+	if len(feed.events) != 3 || feed.events[2].Action != "delete" || feed.events[2].Version != 2 {
+		t.Fatalf("delete event: %+v", feed.events)
+	}
+}
@@ -0,0 +1,180 @@
+package api_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"gopkg.in/yaml.v3"
+	"redcat/internal/api"
+	svc "redcat/internal/service/places"
+)
+
+// --- OpenAPI schema validation of live handler responses ---
+
+// openAPISpec is api/openapi.yaml decoded into generic maps.
+type openAPISpec struct {
+	doc map[string]any
+}
+
+func loadSpec(t *testing.T) *openAPISpec {
+	t.Helper()
+	raw, err := os.ReadFile("../../api/openapi.yaml")
+	if err != nil {
+		t.Fatalf("read spec: %v", err)
+	}
+	var doc map[string]any
+	if err := yaml.Unmarshal(raw, &doc); err != nil {
+		t.Fatalf("parse spec: %v", err)
+	}
+	return &openAPISpec{doc: doc}
+}
+
+func (s *openAPISpec) schema(name string) map[string]any {
+	return s.doc["components"].(map[string]any)["schemas"].(map[string]any)[name].(map[string]any)
+}
+
+func (s *openAPISpec) resolve(sch map[string]any) map[string]any {
+	for {
+		ref, ok := sch["$ref"].(string)
+		if !ok { return sch }
+		sch = s.schema(strings.TrimPrefix(ref, "#/components/schemas/"))
+	}
+}
+
+// validate checks v against sch and returns one message per violation.
+// Objects are validated strictly: properties not declared in the schema
+// are reported, so shape drift (e.g. flat lat/lon) is caught.
+func (s *openAPISpec) validate(sch map[string]any, v any, path string) []string {
+	sch = s.resolve(sch)
+	if all, ok := sch["allOf"].([]any); ok {
+		merged := map[string]any{"type": "object", "properties": map[string]any{}}
+		var required []any
+		for _, part := range all {
+			p := s.resolve(part.(map[string]any))
+			for k, pv := range p["properties"].(map[string]any) {
+				merged["properties"].(map[string]any)[k] = pv
+			}
+			if r, ok := p["required"].([]any); ok { required = append(required, r...) }
+		}
+		merged["required"] = required
+		sch = merged
+	}
+	if v == nil {
+		if n, _ := sch["nullable"].(bool); n { return nil }
+		return []string{path + ": null not allowed"}
+	}
+	var probs []string
+	if enum, ok := sch["enum"].([]any); ok {
+		found := false
+		for _, e := range enum { found = found || fmt.Sprint(e) == fmt.Sprint(v) }
+		if !found { probs = append(probs, fmt.Sprintf("%s: %v not in enum", path, v)) }
+	}
+	switch sch["type"] {
+	case "object":
+		obj, ok := v.(map[string]any)
+		if !ok { return append(probs, path+": expected object") }
+		props, _ := sch["properties"].(map[string]any)
+		req, _ := sch["required"].([]any)
+		for _, r := range req {
+			if _, ok := obj[r.(string)]; !ok { probs = append(probs, fmt.Sprintf("%s.%s: required", path, r)) }
+		}
+		_, open := sch["additionalProperties"]
+		for k, pv := range obj {
+			ps, ok := props[k].(map[string]any)
+			if !ok {
+				if !open { probs = append(probs, fmt.Sprintf("%s.%s: not in schema", path, k)) }
+				continue
+			}
+			probs = append(probs, s.validate(ps, pv, path+"."+k)...)
+		}
+	case "array":
+		arr, ok := v.([]any)
+		if !ok { return append(probs, path+": expected array") }
+		for i, item := range arr {
+			probs = append(probs, s.validate(sch["items"].(map[string]any), item, fmt.Sprintf("%s[%d]", path, i))...)
+		}
+	case "string":
+		if _, ok := v.(string); !ok { probs = append(probs, path+": expected string") }
+	case "number", "integer":
+		n, ok := v.(float64)
+		if !ok { return append(probs, path+": expected number") }
+		if sch["type"] == "integer" && n != float64(int64(n)) { probs = append(probs, path+": expected integer") }
+		if min, ok := sch["minimum"].(int); ok && n < float64(min) { probs = append(probs, fmt.Sprintf("%s: %v < %d", path, n, min)) }
+		if max, ok := sch["maximum"].(int); ok && n > float64(max) { probs = append(probs, fmt.Sprintf("%s: %v > %d", path, n, max)) }
+	}
+	return probs
+}
+
+func TestResponses_MatchOpenAPISchema(t *testing.T) {
+	spec := loadSpec(t)
+	app := fiber.New()
+	api.Register(app, api.Handlers{Places: svc.New(newMemStore(fullPlace))})
+
+	cases := []struct {
+		name       string
+		method     string
+		path       string
+		body       any
+		wantStatus int
+		schema     string
+	}{
+		{"get", http.MethodGet, "/api/v1/places/p1", nil, http.StatusOK, "Place"},
+		{"search", http.MethodPost, "/api/v1/places/search", map[string]any{
+			"location": map[string]any{"lat": 35.17, "lon": 33.36}, "category_ids": []string{"c1"}, "limit": 5,
+		}, http.StatusOK, "SearchResponse"},
+		{"create", http.MethodPost, "/api/v1/places", map[string]any{
+			"id": "p2", "name": "New", "location": map[string]any{"lat": 1, "lon": 2}, "category_ids": []string{"c1"},
+			"address": map[string]any{"street": "2 Main St", "country": "CY"},
+			"contact": map[string]any{"phone": "+1"},
+		}, http.StatusCreated, "Place"},
+		{"update", http.MethodPut, "/api/v1/places/p1", map[string]any{
+			"name": "Renamed", "location": map[string]any{"lat": 35.2, "lon": 33.4},
+		}, http.StatusOK, "Place"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			status, body := doJSON(t, app, tc.method, tc.path, tc.body)
+			if status != tc.wantStatus {
+				t.Fatalf("expected status %d, got %d: %v", tc.wantStatus, status, body)
+			}
+			for _, e := range spec.validate(spec.schema(tc.schema), body, tc.schema) {
+				t.Error(e)
+			}
+		})
+	}
+}
+
+func TestCreateThenGet_RoundTrip(t *testing.T) {
+	app := fiber.New()
+	api.Register(app, api.Handlers{Places: svc.New(newMemStore())})
+
+	in := api.PlaceFromModel(fullPlace)
+	status, _ := doJSON(t, app, http.MethodPost, "/api/v1/places", api.PlaceCreate{
+		ID: in.ID, Name: in.Name, Location: &in.Location, Address: in.Address,
+		AdminRegion: in.AdminRegion, PostTown: in.PostTown, PoBox: in.PoBox,
+		CategoryIDs: in.CategoryIDs, CategoryLabels: in.CategoryLabels,
+		Contact: in.Contact, Social: in.Social, Dates: in.Dates,
+		PlacemakerURL: in.PlacemakerURL, BBox: in.BBox, Dt: in.Dt,
+	})
+	if status != http.StatusCreated {
+		t.Fatalf("create: expected 201, got %d", status)
+	}
+	status, got := doJSON(t, app, http.MethodGet, "/api/v1/places/p1", nil)
+	if status != http.StatusOK {
+		t.Fatalf("get: expected 200, got %d", status)
+	}
+	want, _ := json.Marshal(in)
+	var wantAny any
+	_ = json.Unmarshal(want, &wantAny)
+	gotJSON, _ := json.Marshal(got)
+	wantJSON, _ := json.Marshal(wantAny)
+	if !bytes.Equal(gotJSON, wantJSON) {
+		t.Fatalf("round trip mismatch:\n got  %s\n want %s", gotJSON, wantJSON)
+	}
+}
@@ -0,0 +1,95 @@
+package api_test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"redcat/internal/api"
+	svc "redcat/internal/service/places"
+)
+
+func TestCreate_GeneratedIDAndConflict(t *testing.T) {
+	app := fiber.New()
+	api.Register(app, api.Handlers{Places: svc.New(newMemStore(fullPlace))})
+	body := map[string]any{"name": "New", "location": map[string]any{"lat": 1, "lon": 2}, "category_ids": []string{"c1"}}
+
+	status, resp := doJSON(t, app, http.MethodPost, "/api/v1/places", body)
+	if status != http.StatusCreated {
+		t.Fatalf("create without id: expected 201, got %d: %v", status, resp)
+	}
+	if id, _ := resp.(map[string]any)["id"].(string); len(id) != 26 {
+		t.Fatalf("expected generated 26-char id, got %q", id)
+	}
+
+	body["id"] = "p1"
+	status, resp = doJSON(t, app, http.MethodPost, "/api/v1/places", body)
+	if status != http.StatusConflict || resp.(map[string]any)["code"] != "CONFLICT" {
+		t.Fatalf("duplicate id: expected 409 CONFLICT, got %d: %v", status, resp)
+	}
+
+	status, resp = doJSON(t, app, http.MethodPost, "/api/v1/places?upsert=true", body)
+	if status != http.StatusOK || resp.(map[string]any)["name"] != "New" {
+		t.Fatalf("upsert: expected 200 with new name, got %d: %v", status, resp)
+	}
+
+	delete(body, "id")
+	if status, _ = doJSON(t, app, http.MethodPost, "/api/v1/places?upsert=true", body); status != http.StatusBadRequest {
+		t.Fatalf("upsert without id: expected 400, got %d", status)
+	}
+	body["id"] = "bad{id}"
+	if status, _ = doJSON(t, app, http.MethodPost, "/api/v1/places", body); status != http.StatusBadRequest {
+		t.Fatalf("id with braces: expected 400, got %d", status)
+	}
+}
+
+func TestDelete_NotFoundAndSoftDelete(t *testing.T) {
+	hard := fiber.New()
+	api.Register(hard, api.Handlers{Places: svc.New(newMemStore(fullPlace))})
+	if status, _ := doJSON(t, hard, http.MethodDelete, "/api/v1/places/p1", nil); status != http.StatusNoContent {
+		t.Fatalf("delete: expected 204, got %d", status)
+	}
+	if status, resp := doJSON(t, hard, http.MethodDelete, "/api/v1/places/p1", nil); status != http.StatusNotFound {
+		t.Fatalf("delete missing: expected 404, got %d: %v", status, resp)
+	}
+
+	store := newMemStore(fullPlace)
+	service := svc.New(store, svc.WithSoftDelete())
+	soft := fiber.New()
+	api.Register(soft, api.Handlers{Places: service})
+
+	if status, _ := doJSON(t, soft, http.MethodDelete, "/api/v1/places/p1", nil); status != http.StatusNoContent {
+		t.Fatalf("soft delete: expected 204, got %d", status)
+	}
+	if status, _ := doJSON(t, soft, http.MethodGet, "/api/v1/places/p1", nil); status != http.StatusNotFound {
+		t.Fatalf("get soft-deleted: expected 404, got %d", status)
+	}
+	_, resp := doJSON(t, soft, http.MethodPost, "/api/v1/places/search", map[string]any{"location": map[string]any{"lat": 1, "lon": 1}})
+	if n := resp.(map[string]any)["total"]; n != float64(0) {
+		t.Fatalf("search: soft-deleted place returned, total=%v", n)
+	}
+	if status, _ := doJSON(t, soft, http.MethodDelete, "/api/v1/places/p1", nil); status != http.StatusNotFound {
+		t.Fatalf("delete soft-deleted: expected 404, got %d", status)
+	}
+
+	status, resp := doJSON(t, soft, http.MethodPost, "/api/v1/places/p1/restore", nil)
+	if status != http.StatusOK || resp.(map[string]any)["id"] != "p1" {
+		t.Fatalf("restore: expected 200 with place, got %d: %v", status, resp)
+	}
+	if status, resp := doJSON(t, soft, http.MethodPost, "/api/v1/places/p1/restore", nil); status != http.StatusConflict {
+		t.Fatalf("restore live place: expected 409, got %d: %v", status, resp)
+	}
+
+	doJSON(t, soft, http.MethodDelete, "/api/v1/places/p1", nil)
+	if n, err := service.Purge(context.Background(), time.Hour); err != nil || n != 0 {
+		t.Fatalf("purge within retention: want 0, got %d (%v)", n, err)
+	}
+	if n, err := service.Purge(context.Background(), -time.Second); err != nil || n != 1 {
+		t.Fatalf("purge after retention: want 1, got %d (%v)", n, err)
+	}
+	if status, _ := doJSON(t, soft, http.MethodPost, "/api/v1/places/p1/restore", nil); status != http.StatusNotFound {
+		t.Fatalf("restore purged: expected 404, got %d", status)
+	}
+}
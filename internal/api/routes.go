@@ -1,118 +1,481 @@
 package api
 
 import (
+	"errors"
 	"log/slog"
 	"net/http"
+	"net/netip"
+	"net/url"
 	"strings"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
-	"redcat/internal/domain/model"
+	"github.com/gofiber/fiber/v2/utils"
+	"redcat/internal/domain/errs"
+	"redcat/internal/domain/geo"
+	"redcat/internal/domain/geo/tile"
+	"redcat/internal/service/geofences"
 	svc "redcat/internal/service/places"
+	"redcat/internal/service/webhooks"
+	"redcat/internal/validate"
 )
 
 type Handlers struct {
-	Places *svc.Service
+	Places    *svc.Service
+	Webhooks  *webhooks.Service
+	Geofences *geofences.Service
+	// Strict rejects request bodies with properties not in the schema.
+	Strict bool
+	// TileMaxAge is the Cache-Control max-age of vector tiles.
+	TileMaxAge time.Duration
+	// ActorProxies are the auth proxies trusted to set ActorHeader; when
+	// empty every mutation is recorded as anonymous.
+	ActorProxies []netip.Prefix
 }
 
 func Register(app *fiber.App, h Handlers) {
+	app.Use(ErrorMiddleware())
 	app.Get("/healthz", func(c *fiber.Ctx) error { return c.SendStatus(http.StatusOK) })
 
 	app.Post("/api/v1/places/search", func(c *fiber.Ctx) error {
-		var req struct {
-			Location    struct{ Lat, Lon float64 } `json:"location"`
-			CategoryIDs []string                   `json:"category_ids"`
-			Limit       int64                      `json:"limit"`
+		var req SearchRequest
+		if err := h.decodeBody(c, &req); err != nil {
+			slog.Warn("search: invalid body", slog.String("error", err.Error()))
+			return err
 		}
-		if err := c.BodyParser(&req); err != nil {
-			slog.Warn("search: invalid JSON", slog.String("error", err.Error()))
-			return fiber.NewError(http.StatusBadRequest, "invalid JSON")
+		if err := req.validate(); err != nil {
+			return err
 		}
+		asGeoJSON, err := wantsGeoJSON(c)
+		if err != nil {
+			return err
+		}
+		hydrate, _ := parseHydrate(req.Hydrate)
 
 		slog.Info("search",
 			slog.Float64("lat", req.Location.Lat),
 			slog.Float64("lon", req.Location.Lon),
 			slog.Int64("limit", req.Limit),
 			slog.Int("categories", len(req.CategoryIDs)),
+			slog.Int64("per_category_limit", req.PerCategoryLimit),
 		)
 
-		res, err := h.Places.SearchNearest(c.Context(), svc.SearchParams{
+		sp := svc.SearchParams{
 			Lat: req.Location.Lat, Lon: req.Location.Lon,
 			Limit: req.Limit, CategoryIDs: req.CategoryIDs,
+			DistanceMode: req.DistanceMode,
+			Fields: req.Fields, Hydrate: hydrate,
+		}
+		var places []PlaceWithDistance
+		if req.PerCategoryLimit > 0 {
+			res, err := h.Places.SearchDiversified(c.Context(), sp, req.PerCategoryLimit, req.Diversify)
+			if err != nil {
+				return err
+			}
+			places = placesByCategory(res)
+		} else {
+			res, err := h.Places.SearchNearest(c.Context(), sp)
+			if err != nil {
+				return err
+			}
+			places = placesWithDistance(res)
+		}
+
+		slog.Info("search completed", slog.Int("results", len(places)))
+
+		if asGeoJSON {
+			return c.JSON(featureCollection(places), geoJSONContentType)
+		}
+		return c.JSON(SearchResponse{
+			Places: places,
+			Total:  len(places),
+			Query:  SearchQuery{Location: *req.Location, Limit: req.Limit, PerCategoryLimit: req.PerCategoryLimit},
 		})
+	})
+
+	// the colon is escaped so fiber does not read ":batch" as a parameter
+	app.Post("/api/v1/places/search\\:batch", func(c *fiber.Ctx) error {
+		var req BatchSearchRequest
+		if err := h.decodeBody(c, &req); err != nil {
+			slog.Warn("batch search: invalid body", slog.String("error", err.Error()))
+			return err
+		}
+		if err := req.validate(); err != nil {
+			return err
+		}
+		asGeoJSON, err := wantsGeoJSON(c)
 		if err != nil {
-			slog.Error("search failed", slog.String("error", err.Error()))
-			return fiber.NewError(http.StatusInternalServerError, err.Error())
-		}
-
-		slog.Info("search completed", slog.Int("results", len(res)))
-
-		items := make([]map[string]any, 0, len(res))
-		for _, r := range res {
-			p := r.Place
-			item := map[string]any{
-				"id":           p.ID,
-				"name":         p.Name,
-				"location":     map[string]any{"lat": p.Lat, "lon": p.Lon},
-				"address":      p.Address,
-				"locality":     p.Locality,
-				"region":       p.Region,
-				"postcode":     p.Postcode,
-				"admin_region": p.AdminRegion,
-				"post_town":    p.PostTown,
-				"po_box":       p.PoBox,
-				"country":      p.Country,
-				"category_ids": p.CategoryIDs,
-				"distance_m":   r.DistanceM,
+			return err
+		}
+		hydrate, _ := parseHydrate(req.Hydrate)
+
+		items := make([]BatchSearchItem, len(req.Queries))
+		var (
+			params []svc.SearchParams
+			idx    []int
+		)
+		for i, q := range req.Queries {
+			if err := q.validate(); err != nil {
+				_, body := errorResponse(err)
+				items[i] = BatchSearchItem{Places: []PlaceWithDistance{}, Error: &body}
+				continue
+			}
+			sp := svc.SearchParams{
+				Lat: q.Location.Lat, Lon: q.Location.Lon,
+				Limit: req.Limit, CategoryIDs: req.CategoryIDs, DistanceMode: req.DistanceMode,
+				Fields: req.Fields, Hydrate: hydrate,
 			}
-			items = append(items, item)
+			if q.Limit != 0 { sp.Limit = q.Limit }
+			if q.CategoryIDs != nil { sp.CategoryIDs = q.CategoryIDs }
+			if q.DistanceMode != "" { sp.DistanceMode = q.DistanceMode }
+			params = append(params, sp)
+			idx = append(idx, i)
+		}
+
+		slog.Info("batch search", slog.Int("queries", len(req.Queries)), slog.Int("valid", len(params)))
+
+		for j, r := range h.Places.SearchNearestBatch(c.Context(), params) {
+			if r.Err != nil {
+				status, body := errorResponse(r.Err)
+				if status >= http.StatusInternalServerError {
+					slog.Error("batch search: query failed", slog.Int("index", idx[j]), slog.String("error", r.Err.Error()))
+				}
+				items[idx[j]] = BatchSearchItem{Places: []PlaceWithDistance{}, Error: &body}
+				continue
+			}
+			places := placesWithDistance(r.Results)
+			items[idx[j]] = BatchSearchItem{Places: places, Total: len(places)}
+		}
+		if asGeoJSON {
+			return c.JSON(batchFeatureCollection(items), geoJSONContentType)
+		}
+		return c.JSON(BatchSearchResponse{Results: items})
+	})
+
+	app.Post("/api/v1/places/facets", func(c *fiber.Ctx) error {
+		var req FacetsRequest
+		if err := h.decodeBody(c, &req); err != nil {
+			return err
+		}
+		if err := req.validate(); err != nil {
+			return err
+		}
+		fp := svc.FacetParams{CategoryIDs: req.CategoryIDs, Top: req.Top, Labels: req.Labels}
+		if fp.Top == 0 { fp.Top = validate.FacetTopDefault }
+		if req.BBox != nil {
+			fp.BBox = &geo.BBox{XMin: req.BBox.XMin, YMin: req.BBox.YMin, XMax: req.BBox.XMax, YMax: req.BBox.YMax}
+		} else {
+			fp.Lat, fp.Lon, fp.RadiusM = req.Location.Lat, req.Location.Lon, req.RadiusM
+		}
+		res, err := h.Places.Facets(c.Context(), fp)
+		if err != nil {
+			return err
+		}
+		return c.JSON(FacetsResponse{Total: res.Total, Categories: facetBuckets(res.Categories), Countries: facetBuckets(res.Countries)})
+	})
+
+	app.Get("/api/v1/heatmap", func(c *fiber.Ctx) error {
+		bbox, res, categoryIDs, err := heatmapQuery(c)
+		if err != nil {
+			return err
+		}
+		cells, truncated, err := h.Places.Heatmap(c.Context(), bbox, res, categoryIDs, validate.HeatmapCellsMax)
+		if err != nil {
+			return err
+		}
+		return c.JSON(HeatmapResponse{Res: res, Cells: heatmapCells(cells), Truncated: truncated})
+	})
+
+	app.Get("/api/v1/clusters", func(c *fiber.Ctx) error {
+		cp, err := clustersQuery(c)
+		if err != nil {
+			return err
+		}
+		asGeoJSON, err := wantsGeoJSON(c)
+		if err != nil {
+			return err
+		}
+		res, err := h.Places.Clusters(c.Context(), cp)
+		if err != nil {
+			return err
+		}
+		if asGeoJSON {
+			return c.JSON(clusterFeatures(clusters(cp.Zoom, res).Clusters), geoJSONContentType)
+		}
+		return c.JSON(clusters(cp.Zoom, res))
+	})
+
+	app.Get("/tiles/places/:z/:x/:y.mvt", func(c *fiber.Ctx) error {
+		t, err := tileParam(c)
+		if err != nil {
+			return err
+		}
+		v := &validate.Validator{}
+		categoryIDs := listQuery(c, v, "category", validate.SearchCategoriesMax)
+		if err := v.Err(); err != nil {
+			return err
+		}
+		content, err := h.Places.Tile(c.Context(), t, categoryIDs)
+		if err != nil {
+			return err
+		}
+		return sendTile(c, tile.EncodeMVT(tileLayers(t, content)...), h.TileMaxAge)
+	})
+
+	app.Get("/api/v1/reverse", func(c *fiber.Ctx) error {
+		lat, lon, err := reverseQuery(c)
+		if err != nil {
+			return err
+		}
+		res, err := h.Places.Reverse(c.Context(), lat, lon)
+		if err != nil {
+			return err
+		}
+		return c.JSON(reverseResponse(Location{Lat: lat, Lon: lon}, res))
+	})
+
+	app.Post("/api/v1/places/along-route", func(c *fiber.Ctx) error {
+		var req RouteSearchRequest
+		if err := h.decodeBody(c, &req); err != nil {
+			return err
+		}
+		line, err := req.validate()
+		if err != nil {
+			return err
+		}
+		asGeoJSON, err := wantsGeoJSON(c)
+		if err != nil {
+			return err
+		}
+		hydrate, _ := parseHydrate(req.Hydrate)
+		if req.Limit == 0 { req.Limit = validate.RouteLimitDefault }
+
+		slog.Info("route search", slog.Int("points", len(line)), slog.Float64("buffer_m", req.BufferM))
+
+		res, complete, err := h.Places.AlongRoute(c.Context(), svc.RouteParams{
+			Line: line, BufferM: req.BufferM, CategoryIDs: req.CategoryIDs,
+			Limit: req.Limit, Fields: req.Fields, Hydrate: hydrate,
+		})
+		if err != nil {
+			return err
 		}
-		return c.JSON(fiber.Map{"places": items, "total": len(items), "query": fiber.Map{"location": req.Location, "limit": req.Limit}})
+		places := placesAlongRoute(res)
+		if asGeoJSON {
+			return c.JSON(featureCollection(places), geoJSONContentType)
+		}
+		return c.JSON(RouteSearchResponse{Places: places, Total: len(places), Complete: complete, LengthM: geo.LineLength(line)})
+	})
+
+	app.Post("/api/v1/distance-matrix", func(c *fiber.Ctx) error {
+		var req DistanceMatrixRequest
+		if err := h.decodeBody(c, &req); err != nil {
+			return err
+		}
+		if err := req.validate(); err != nil {
+			return err
+		}
+		mode := req.DistanceMode
+		if mode == "" { mode = geo.DistanceHaversine }
+
+		m, err := h.Places.DistanceMatrix(c.Context(), matrixPoints(req.Origins), matrixPoints(req.Destinations), mode)
+		if err != nil {
+			return err
+		}
+		return c.JSON(distanceMatrix(m, mode))
 	})
 
 	app.Post("/api/v1/places", func(c *fiber.Ctx) error {
-		var p model.Place
-		if err := c.BodyParser(&p); err != nil {
-			slog.Warn("create place: invalid JSON", slog.String("error", err.Error()))
-			return fiber.NewError(http.StatusBadRequest, "invalid JSON")
+		var req PlaceCreate
+		if err := h.decodeBody(c, &req); err != nil {
+			slog.Warn("create place: invalid body", slog.String("error", err.Error()))
+			return err
 		}
-		if strings.TrimSpace(p.ID) == "" || strings.TrimSpace(p.Name) == "" {
-			slog.Warn("create place: missing required fields", slog.String("id", p.ID), slog.String("name", p.Name))
-			return fiber.NewError(http.StatusBadRequest, "id and name required")
+		upsert := c.QueryBool("upsert")
+		if err := req.validate(upsert); err != nil {
+			slog.Warn("create place: validation failed", slog.String("id", req.ID), slog.String("error", err.Error()))
+			return err
+		}
+		p := req.ToModel()
+
+		if upsert {
+			slog.Info("upserting place", slog.String("id", p.ID), slog.String("name", p.Name))
+			p, err := h.Places.Upsert(h.actorContext(c), p)
+			if err != nil {
+				return err
+			}
+			setETag(c, p.Version)
+			return c.JSON(PlaceFromModel(p))
 		}
 
 		slog.Info("creating place", slog.String("id", p.ID), slog.String("name", p.Name))
 
-		if err := h.Places.Add(c.Context(), p); err != nil {
-			slog.Error("create place failed", slog.String("id", p.ID), slog.String("error", err.Error()))
-			return fiber.NewError(http.StatusInternalServerError, err.Error())
+		p, err := h.Places.Create(h.actorContext(c), p)
+		if err != nil {
+			return err
 		}
 
 		slog.Info("place created", slog.String("id", p.ID))
-		return c.Status(http.StatusCreated).JSON(fiber.Map{"item": p})
+		setETag(c, p.Version)
+		return c.Status(http.StatusCreated).JSON(PlaceFromModel(p))
 	})
 
 	app.Get("/api/v1/places/:id", func(c *fiber.Ctx) error {
-		id := c.Params("id")
+		id := placeID(c)
 		slog.Info("getting place", slog.String("id", id))
 
-		p, err := h.Places.Get(c.Context(), id)
+		hydrate, err := parseHydrate(c.Query("hydrate"))
+		if err != nil {
+			return err
+		}
+		asGeoJSON, err := wantsGeoJSON(c)
 		if err != nil {
+			return err
+		}
+		var fields []string
+		if f := c.Query("fields"); f != "" && !hydrate {
+			fields = strings.Split(f, ",")
+		}
+		if err := svc.ValidateFields(fields); err != nil {
+			return err
+		}
+
+		p, err := h.Places.Get(c.Context(), id, fields...)
+		if errors.Is(err, svc.ErrNotFound) {
+			to, rerr := h.Places.MovedTo(c.Context(), id)
+			if rerr != nil {
+				return rerr
+			}
+			if to != "" {
+				return redirectPlace(c, id, to)
+			}
 			slog.Warn("place not found", slog.String("id", id))
-			return fiber.NewError(http.StatusNotFound, "not found")
 		}
-		return c.JSON(p)
+		if err != nil {
+			return err
+		}
+		setETag(c, p.Version)
+		c.Vary(fiber.HeaderAccept)
+		if noneMatch(c, p.Version) {
+			return c.SendStatus(http.StatusNotModified)
+		}
+		if asGeoJSON {
+			return c.JSON(toFeature(PlaceFromModel(p)), geoJSONContentType)
+		}
+		return c.JSON(PlaceFromModel(p))
+	})
+
+	app.Put("/api/v1/places/:id", func(c *fiber.Ctx) error {
+		id := placeID(c)
+		var req PlaceUpdate
+		if err := h.decodeBody(c, &req); err != nil {
+			slog.Warn("update place: invalid body", slog.String("error", err.Error()))
+			return err
+		}
+		if err := req.validate(); err != nil {
+			return err
+		}
+		version, err := ifMatch(c)
+		if err != nil {
+			return err
+		}
+
+		slog.Info("updating place", slog.String("id", id))
+
+		p, err := h.Places.Update(h.actorContext(c), id, version, req.ApplyTo)
+		if err != nil {
+			return err
+		}
+
+		slog.Info("place updated", slog.String("id", id))
+		setETag(c, p.Version)
+		return c.JSON(PlaceFromModel(p))
 	})
 
 	app.Delete("/api/v1/places/:id", func(c *fiber.Ctx) error {
-		id := c.Params("id")
+		id := placeID(c)
 		slog.Info("deleting place", slog.String("id", id))
 
-		if err := h.Places.Delete(c.Context(), id); err != nil {
-			slog.Error("delete place failed", slog.String("id", id), slog.String("error", err.Error()))
-			return fiber.NewError(http.StatusInternalServerError, err.Error())
+		version, err := ifMatch(c)
+		if err != nil {
+			return err
+		}
+		if err := h.Places.Delete(h.actorContext(c), id, version); err != nil {
+			return err
 		}
 
 		slog.Info("place deleted", slog.String("id", id))
 		return c.SendStatus(http.StatusNoContent)
 	})
+
+	app.Get("/api/v1/places/:id/history", func(c *fiber.Ctx) error {
+		id := placeID(c)
+		limit, cursor, err := historyPage(c)
+		if err != nil {
+			return err
+		}
+
+		entries, next, err := h.Places.History(c.Context(), id, cursor, limit)
+		if err != nil {
+			return err
+		}
+		return c.JSON(HistoryResponse{Entries: historyEntries(entries), NextCursor: next})
+	})
+
+	app.Get("/api/v1/changes", func(c *fiber.Ctx) error {
+		limit, wait, err := changesPage(c)
+		if err != nil {
+			return err
+		}
+
+		evs, cursor, err := h.Places.Changes(c.Context(), c.Query("since"), limit, wait)
+		if err != nil {
+			return err
+		}
+		return c.JSON(ChangesResponse{Events: changeEvents(evs), Cursor: cursor})
+	})
+
+	app.Post("/api/v1/places/:id/restore", func(c *fiber.Ctx) error {
+		id := placeID(c)
+		slog.Info("restoring place", slog.String("id", id))
+
+		p, err := h.Places.Restore(h.actorContext(c), id)
+		if err != nil {
+			return err
+		}
+
+		slog.Info("place restored", slog.String("id", id))
+		setETag(c, p.Version)
+		return c.JSON(PlaceFromModel(p))
+	})
+
+	registerDuplicates(app, h)
+	registerAliases(app, h)
+	registerWebhooks(app, h)
+	registerGeofences(app, h)
+	registerExport(app, h)
+}
+
+// redirectPlace answers a request for an alias of the place to with a
+// permanent redirect to it, keeping the query. Clients that do not follow
+// redirects find the new ID in moved_to.
+func redirectPlace(c *fiber.Ctx, id, to string) error {
+	loc := "/api/v1/places/" + url.PathEscape(to)
+	if q := c.Request().URI().QueryString(); len(q) > 0 { loc += "?" + string(q) }
+	c.Location(loc)
+	return c.Status(http.StatusMovedPermanently).JSON(PlaceMoved{ID: id, MovedTo: to})
+}
+
+// placeID returns the :id route parameter. It is copied because fiber
+// reuses the buffer behind it once the handler returns.
+func placeID(c *fiber.Ctx) string { return utils.CopyString(c.Params("id")) }
+
+// parseHydrate accepts "" (projection applies) or "full" (every stored field).
+func parseHydrate(v string) (bool, error) {
+	switch v {
+	case "":
+		return false, nil
+	case "full":
+		return true, nil
+	}
+	return false, errs.Invalid(`hydrate must be "full" when set`, map[string]any{"hydrate": v})
 }
@@ -109,6 +109,86 @@ func TestSearchPlaces_Contract_InvalidRequest(t *testing.T) {
 	}
 }
 
+func TestSearchPlaces_Projection_Invalid(t *testing.T) {
+	app := fiber.New()
+	api.Register(app, api.Handlers{})
+
+	bodies := map[string]string{
+		"unknown field":   `{"location":{"lat":1,"lon":1},"fields":["name","nope"]}`,
+		"bad hydrate":     `{"location":{"lat":1,"lon":1},"hydrate":"partial"}`,
+		"bad distance":    `{"location":{"lat":1,"lon":1},"distance_mode":"manhattan"}`,
+	}
+	for name, body := range bodies {
+		t.Run(name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/places/search", bytes.NewBufferString(body))
+			req.Header.Set("Content-Type", "application/json")
+			resp, err := app.Test(req)
+			if err != nil {
+				t.Fatalf("request failed: %v", err)
+			}
+			defer resp.Body.Close()
+			if resp.StatusCode != http.StatusBadRequest {
+				t.Errorf("expected status 400, got %d", resp.StatusCode)
+			}
+		})
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/places/abc?fields=name,nope", nil)
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("GET with unknown field: expected 400, got %d", resp.StatusCode)
+	}
+}
+
+func TestValidation_ReportsAllFieldErrors(t *testing.T) {
+	app := fiber.New()
+	api.Register(app, api.Handlers{})
+
+	status, body := doJSON(t, app, http.MethodPost, "/api/v1/places/search", map[string]any{
+		"location": map[string]any{"lat": 500, "lon": -200}, "limit": 1000,
+	})
+	if status != http.StatusBadRequest {
+		t.Fatalf("expected status 400, got %d", status)
+	}
+	fields := body.(map[string]any)["details"].(map[string]any)["fields"].([]any)
+	got := map[string]bool{}
+	for _, f := range fields {
+		got[f.(map[string]any)["field"].(string)] = true
+	}
+	for _, want := range []string{"location.lat", "location.lon", "limit"} {
+		if !got[want] {
+			t.Errorf("expected error for %s, got %v", want, fields)
+		}
+	}
+
+	status, _ = doJSON(t, app, http.MethodPost, "/api/v1/places", map[string]any{
+		"id": "x", "name": "X", "location": map[string]any{"lat": 1, "lon": 1},
+		"category_ids": make([]string, 21),
+	})
+	if status != http.StatusBadRequest {
+		t.Errorf("create with 21 categories: expected 400, got %d", status)
+	}
+}
+
+func TestValidation_StrictRejectsUnknownFields(t *testing.T) {
+	body := map[string]any{"location": map[string]any{"lat": 1, "lon": 1}, "radius": 5}
+
+	strict := fiber.New()
+	api.Register(strict, api.Handlers{Strict: true})
+	status, resp := doJSON(t, strict, http.MethodPost, "/api/v1/places/search", body)
+	if status != http.StatusBadRequest {
+		t.Fatalf("strict: expected 400, got %d", status)
+	}
+	fields := resp.(map[string]any)["details"].(map[string]any)["fields"].([]any)
+	if len(fields) != 1 || fields[0].(map[string]any)["field"] != "radius" {
+		t.Errorf("strict: expected error naming radius, got %v", fields)
+	}
+}
+
 // --- Create Place Contract Tests ---
 
 // TestCreatePlace_Contract_ValidRequest documents the contract for valid requests.
@@ -146,7 +226,8 @@ func TestCreatePlace_Contract_MissingFields(t *testing.T) {
 		wantStatus int
 	}{
 		{
-			name:       "missing id",
+			// id is optional (generated), but location and category_ids are not
+			name:       "missing id and location",
 			body:       map[string]any{"name": "Test"},
 			wantStatus: http.StatusBadRequest,
 		},
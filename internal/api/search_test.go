@@ -0,0 +1,288 @@
+package api_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"redcat/internal/api"
+	"redcat/internal/domain/errs"
+	"redcat/internal/domain/geo"
+	"redcat/internal/domain/model"
+	svc "redcat/internal/service/places"
+)
+
+func TestSearchBatch_InputOrderAndPerItemErrors(t *testing.T) {
+	spec := loadSpec(t)
+	store := newMemStore(
+		model.Place{ID: "nicosia", Name: "N", Lat: 35.17, Lon: 33.36, CategoryIDs: []string{"cafe"}},
+		model.Place{ID: "limassol", Name: "L", Lat: 34.68, Lon: 33.04, CategoryIDs: []string{"bar"}},
+	)
+	app := fiber.New()
+	api.Register(app, api.Handlers{Places: svc.New(store)})
+
+	status, body := doJSON(t, app, http.MethodPost, "/api/v1/places/search:batch", map[string]any{
+		"limit": 1,
+		"queries": []map[string]any{
+			{"location": map[string]any{"lat": 34.7, "lon": 33.0}},
+			{"location": map[string]any{"lat": 100, "lon": 0}},
+			{"location": map[string]any{"lat": 35.2, "lon": 33.4}},
+			{"location": map[string]any{"lat": 35.2, "lon": 33.4}, "category_ids": []string{"bar"}},
+		},
+	})
+	if status != http.StatusOK { t.Fatalf("batch: expected 200, got %d: %v", status, body) }
+	for _, e := range spec.validate(spec.schema("BatchSearchResponse"), body, "BatchSearchResponse") {
+		t.Error(e)
+	}
+	results := body.(map[string]any)["results"].([]any)
+	first := func(i int) any {
+		places := results[i].(map[string]any)["places"].([]any)
+		if len(places) != 1 { return nil }
+		return places[0].(map[string]any)["id"]
+	}
+	if len(results) != 4 || first(0) != "limassol" || first(2) != "nicosia" || first(3) != "limassol" {
+		t.Fatalf("want results in input order with per-query filters, got %v", results)
+	}
+	if e, _ := results[1].(map[string]any)["error"].(map[string]any); e == nil || e["code"] != "INVALID_REQUEST" {
+		t.Fatalf("invalid query: want an INVALID_REQUEST item error, got %v", results[1])
+	}
+
+	store.err = errs.New(errs.BackendUnavailable, "down")
+	_, body = doJSON(t, app, http.MethodPost, "/api/v1/places/search:batch", map[string]any{
+		"queries": []map[string]any{{"location": map[string]any{"lat": 0, "lon": 0}}},
+	})
+	item := body.(map[string]any)["results"].([]any)[0].(map[string]any)
+	if item["error"].(map[string]any)["code"] != "BACKEND_UNAVAILABLE" {
+		t.Fatalf("backend failure: want a BACKEND_UNAVAILABLE item error, got %v", item)
+	}
+
+	if status, _ := doJSON(t, app, http.MethodPost, "/api/v1/places/search:batch", map[string]any{"queries": []any{}}); status != http.StatusBadRequest {
+		t.Fatalf("empty batch: expected 400, got %d", status)
+	}
+}
+
+func TestDistanceMatrix(t *testing.T) {
+	spec := loadSpec(t)
+	app := fiber.New()
+	api.Register(app, api.Handlers{Places: svc.New(newMemStore(fullPlace))})
+
+	status, body := doJSON(t, app, http.MethodPost, "/api/v1/distance-matrix", map[string]any{
+		"origins":      []map[string]any{{"place_id": "p1"}, {"location": map[string]any{"lat": 34.7071, "lon": 33.0226}}},
+		"destinations": []map[string]any{{"location": map[string]any{"lat": 34.7071, "lon": 33.0226}}},
+		"distance_mode": "ellipsoidal",
+	})
+	if status != http.StatusOK { t.Fatalf("matrix: expected 200, got %d: %v", status, body) }
+	for _, e := range spec.validate(spec.schema("DistanceMatrixResponse"), body, "DistanceMatrixResponse") {
+		t.Error(e)
+	}
+	var m api.DistanceMatrixResponse
+	raw, _ := json.Marshal(body)
+	_ = json.Unmarshal(raw, &m)
+	if m.Origins[0].Location == nil || m.Origins[0].Location.Lat != fullPlace.Lat {
+		t.Fatalf("place origin not resolved: %+v", m.Origins[0])
+	}
+	want := geo.Ellipsoidal(fullPlace.Lat, fullPlace.Lon, 34.7071, 33.0226)
+	if cell := m.Rows[0][0]; cell.DistanceM != want || cell.BearingDeg < 180 || cell.BearingDeg > 270 {
+		t.Fatalf("Nicosia -> Limassol: got %+v, want %.0f m heading south-west", cell, want)
+	}
+	if cell := m.Rows[1][0]; cell.DistanceM != 0 {
+		t.Fatalf("same point: want 0 m, got %+v", cell)
+	}
+
+	status, body = doJSON(t, app, http.MethodPost, "/api/v1/distance-matrix", map[string]any{
+		"origins": []map[string]any{{"place_id": "p1"}, {"place_id": "ghost"}}, "destinations": []map[string]any{{"place_id": "p1"}},
+	})
+	if status != http.StatusNotFound || fmt.Sprint(body.(map[string]any)["details"].(map[string]any)["place_ids"]) != "[ghost]" {
+		t.Fatalf("unknown place: want 404 naming it, got %d %v", status, body)
+	}
+
+	many := make([]map[string]any, 101)
+	for i := range many { many[i] = map[string]any{"location": map[string]any{"lat": 0, "lon": 0}} }
+	if status, _ := doJSON(t, app, http.MethodPost, "/api/v1/distance-matrix", map[string]any{"origins": many, "destinations": many}); status != http.StatusBadRequest {
+		t.Fatalf("101×101 matrix: expected 400, got %d", status)
+	}
+	if status, _ := doJSON(t, app, http.MethodPost, "/api/v1/distance-matrix", map[string]any{
+		"origins": []map[string]any{{"place_id": "p1", "location": map[string]any{"lat": 0, "lon": 0}}}, "destinations": []map[string]any{{"place_id": "p1"}},
+	}); status != http.StatusBadRequest {
+		t.Fatalf("point with both place_id and location: expected 400, got %d", status)
+	}
+}
+
+func TestAlongRoute(t *testing.T) {
+	spec := loadSpec(t)
+	store := newMemStore(
+		model.Place{ID: "far-along", Name: "A", Lat: 0.002, Lon: 0.08, CategoryIDs: []string{"cafe"}},
+		model.Place{ID: "near-start", Name: "B", Lat: -0.001, Lon: 0.02, CategoryIDs: []string{"cafe"}},
+		model.Place{ID: "off-route", Name: "C", Lat: 0.01, Lon: 0.05, CategoryIDs: []string{"cafe"}},
+		model.Place{ID: "bar", Name: "D", Lat: 0.002, Lon: 0.05, CategoryIDs: []string{"bar"}},
+	)
+	app := fiber.New()
+	api.Register(app, api.Handlers{Places: svc.New(store)})
+
+	line := map[string]any{"type": "LineString", "coordinates": [][]float64{{0, 0}, {0.1, 0}}}
+	status, body := doJSON(t, app, http.MethodPost, "/api/v1/places/along-route", map[string]any{
+		"line": line, "buffer_m": 500, "category_ids": []string{"cafe"},
+	})
+	if status != http.StatusOK { t.Fatalf("along-route: expected 200, got %d: %v", status, body) }
+	for _, e := range spec.validate(spec.schema("RouteSearchResponse"), body, "RouteSearchResponse") {
+		t.Error(e)
+	}
+	var res api.RouteSearchResponse
+	raw, _ := json.Marshal(body)
+	_ = json.Unmarshal(raw, &res)
+	if len(res.Places) != 2 || res.Places[0].ID != "near-start" || res.Places[1].ID != "far-along" {
+		t.Fatalf("want near-start then far-along, got %+v", res.Places)
+	}
+	if p := res.Places[1]; math.Abs(p.DistanceM-222) > 5 || math.Abs(p.AlongM-8900) > 50 || p.DetourM != 2*p.DistanceM {
+		t.Fatalf("far-along: unexpected offsets %+v", p)
+	}
+	if !res.Complete || math.Abs(res.LengthM-11132) > 20 {
+		t.Fatalf("want complete search over ~11.1 km, got complete=%v length=%.0f", res.Complete, res.LengthM)
+	}
+
+	// Google's reference polyline, ~900 km: a 1 m buffer needs far too many circles.
+	for name, req := range map[string]map[string]any{
+		"no route":     {"buffer_m": 500},
+		"both":         {"line": line, "polyline": "_p~iF~ps|U", "buffer_m": 500},
+		"one point":    {"line": map[string]any{"type": "LineString", "coordinates": [][]float64{{0, 0}}}, "buffer_m": 500},
+		"bad polyline": {"polyline": "_p~iF~ps|U_", "buffer_m": 500},
+		"bad buffer":   {"line": line, "buffer_m": 0},
+		"too long":     {"polyline": "_p~iF~ps|U_ulLnnqC_mqNvxq`@", "buffer_m": 1},
+	} {
+		if status, body := doJSON(t, app, http.MethodPost, "/api/v1/places/along-route", req); status != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d: %v", name, status, body)
+		}
+	}
+	status, body = doJSON(t, app, http.MethodPost, "/api/v1/places/along-route", map[string]any{"polyline": "_p~iF~ps|U_ulLnnqC_mqNvxq`@", "buffer_m": 100})
+	details, _ := body.(map[string]any)["details"].(map[string]any)
+	if status != http.StatusBadRequest || details["max_samples"] != float64(svc.RouteSamplesMax) || details["min_buffer_m"].(float64) <= 100 {
+		t.Fatalf("too long: want the sample limit and the smallest buffer that fits, got %d: %v", status, body)
+	}
+
+	// ~111 km at 150 m runs ~740 searches in chunks
+	long := map[string]any{"type": "LineString", "coordinates": [][]float64{{0, 0}, {1, 0}}}
+	status, body = doJSON(t, app, http.MethodPost, "/api/v1/places/along-route", map[string]any{"line": long, "buffer_m": 150})
+	if status != http.StatusOK || body.(map[string]any)["total"] != float64(1) {
+		t.Fatalf("long route: want the one place within 150 m, got %d: %v", status, body)
+	}
+}
+
+func TestSearch_PerCategoryLimit(t *testing.T) {
+	spec := loadSpec(t)
+	store := newMemStore(
+		model.Place{ID: "cafe1", Name: "C1", Lat: 35.1700, Lon: 33.3600, CategoryIDs: []string{"cafe"}},
+		model.Place{ID: "cafe2", Name: "C2", Lat: 35.1710, Lon: 33.3600, CategoryIDs: []string{"cafe"}},
+		model.Place{ID: "cafe3", Name: "C3", Lat: 35.1720, Lon: 33.3600, CategoryIDs: []string{"cafe"}},
+		model.Place{ID: "both", Name: "B", Lat: 35.1730, Lon: 33.3600, CategoryIDs: []string{"cafe", "pharmacy"}},
+		model.Place{ID: "pharmacy", Name: "P", Lat: 35.1900, Lon: 33.3600, CategoryIDs: []string{"pharmacy"}},
+	)
+	app := fiber.New()
+	api.Register(app, api.Handlers{Places: svc.New(store)})
+
+	search := func(req map[string]any) []string {
+		t.Helper()
+		req["location"] = map[string]any{"lat": 35.17, "lon": 33.36}
+		req["category_ids"] = []string{"cafe", "pharmacy"}
+		status, body := doJSON(t, app, http.MethodPost, "/api/v1/places/search", req)
+		if status != http.StatusOK { t.Fatalf("search %v: expected 200, got %d: %v", req, status, body) }
+		for _, e := range spec.validate(spec.schema("SearchResponse"), body, "SearchResponse") {
+			t.Error(e)
+		}
+		var ids []string
+		for _, p := range body.(map[string]any)["places"].([]any) {
+			m := p.(map[string]any)
+			ids = append(ids, fmt.Sprint(m["id"], "/", m["category_id"]))
+		}
+		return ids
+	}
+
+	for _, id := range search(map[string]any{}) {
+		if !strings.HasSuffix(id, "/<nil>") { t.Fatalf("plain search must not set category_id: %s", id) }
+	}
+	if got := fmt.Sprint(search(map[string]any{"per_category_limit": 2})); got != "[cafe1/cafe both/pharmacy cafe2/cafe pharmacy/pharmacy]" {
+		t.Fatalf("interleaved: got %s", got)
+	}
+	if got := fmt.Sprint(search(map[string]any{"per_category_limit": 2, "diversify": "grouped", "limit": 3})); got != "[cafe1/cafe cafe2/cafe both/pharmacy]" {
+		t.Fatalf("grouped: got %s", got)
+	}
+	if got := fmt.Sprint(search(map[string]any{"per_category_limit": 4, "limit": 3})); got != "[cafe1/cafe both/pharmacy cafe2/cafe]" {
+		t.Fatalf("interleaved with limit: got %s", got)
+	}
+	// "both" is the nearest pharmacy but was already taken as a café
+	if got := fmt.Sprint(search(map[string]any{"per_category_limit": 4, "diversify": "grouped"})); got != "[cafe1/cafe cafe2/cafe cafe3/cafe both/cafe pharmacy/pharmacy]" {
+		t.Fatalf("grouped duplicate: got %s", got)
+	}
+
+	for name, req := range map[string]map[string]any{
+		"no categories":      {"location": map[string]any{"lat": 0, "lon": 0}, "per_category_limit": 2},
+		"diversify alone":    {"location": map[string]any{"lat": 0, "lon": 0}, "category_ids": []string{"cafe"}, "diversify": "grouped"},
+		"unknown diversify":  {"location": map[string]any{"lat": 0, "lon": 0}, "category_ids": []string{"cafe"}, "per_category_limit": 2, "diversify": "random"},
+		"per-category limit": {"location": map[string]any{"lat": 0, "lon": 0}, "category_ids": []string{"cafe"}, "per_category_limit": 201},
+	} {
+		if status, body := doJSON(t, app, http.MethodPost, "/api/v1/places/search", req); status != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d: %v", name, status, body)
+		}
+	}
+}
+
+func TestReverse(t *testing.T) {
+	spec := loadSpec(t)
+	store := newMemStore(
+		model.Place{ID: "bare", Name: "No address", Lat: 35.1700, Lon: 33.3600},
+		model.Place{ID: "a", Name: "A", Lat: 35.1705, Lon: 33.3600, Locality: "Strovolos", Country: "CY"},
+		model.Place{ID: "b", Name: "B", Lat: 35.1710, Lon: 33.3600, Address: "1 Main St", Locality: "Nicosia", Postcode: "1010", Country: "CY"},
+		model.Place{ID: "c", Name: "C", Lat: 35.1715, Lon: 33.3600, Locality: "NICOSIA", Region: "Lefkosia", Country: "CY"},
+		model.Place{ID: "d", Name: "D", Lat: 35.1720, Lon: 33.3600, Locality: "Nicosia", Country: "CY"},
+		model.Place{ID: "far", Name: "Far", Lat: 34.68, Lon: 33.04, Locality: "Limassol", Country: "CY"},
+	)
+	app := fiber.New()
+	api.Register(app, api.Handlers{Places: svc.New(store)})
+
+	status, body := doJSON(t, app, http.MethodGet, "/api/v1/reverse?lat=35.17&lon=33.36", nil)
+	if status != http.StatusOK { t.Fatalf("reverse: expected 200, got %d: %v", status, body) }
+	for _, e := range spec.validate(spec.schema("ReverseResponse"), body, "ReverseResponse") {
+		t.Error(e)
+	}
+	var res api.ReverseResponse
+	raw, _ := json.Marshal(body)
+	_ = json.Unmarshal(raw, &res)
+	if res.Neighbours != 4 || res.Locality == nil || res.Locality.Value != "Nicosia" || res.Country.Value != "CY" {
+		t.Fatalf("want Nicosia, CY from 4 neighbours, got %s", raw)
+	}
+	if res.Address == nil || res.Address.Street != "1 Main St" || res.Address.PlaceID != "b" || math.Abs(res.Address.DistanceM-111) > 2 {
+		t.Fatalf("address: %s", raw)
+	}
+	// everyone agrees on the country, the nearest place disagrees on the locality
+	if !(res.Country.Confidence > res.Locality.Confidence && res.Locality.Confidence > 0.4 && res.Country.Confidence <= 1) {
+		t.Fatalf("confidences: country %v locality %v", res.Country.Confidence, res.Locality.Confidence)
+	}
+	if res.Region.Value != "Lefkosia" || res.Postcode.Value != "1010" {
+		t.Fatalf("region and postcode: %s", raw)
+	}
+
+	// a crowd of places without address data nearer than any with it
+	var crowd []model.Place
+	for i := range 30 {
+		crowd = append(crowd, model.Place{ID: fmt.Sprintf("bare%d", i), Name: "Bare", Lat: 10, Lon: 10 + float64(i)/1e5})
+	}
+	crowd = append(crowd, model.Place{ID: "addressed", Name: "A", Lat: 10.01, Lon: 10, Locality: "Town", Country: "NG"})
+	app2 := fiber.New()
+	api.Register(app2, api.Handlers{Places: svc.New(newMemStore(crowd...))})
+	status, body = doJSON(t, app2, http.MethodGet, "/api/v1/reverse?lat=10&lon=10", nil)
+	if status != http.StatusOK || body.(map[string]any)["locality"].(map[string]any)["value"] != "Town" || body.(map[string]any)["neighbours"] != float64(1) {
+		t.Fatalf("nearest places without address: want Town from the one addressed place, got %d: %v", status, body)
+	}
+
+	if status, body = doJSON(t, app, http.MethodGet, "/api/v1/reverse?lat=0&lon=0", nil); status != http.StatusNotFound {
+		t.Fatalf("no data nearby: expected 404, got %d: %v", status, body)
+	}
+	for name, q := range map[string]string{"no lon": "lat=1", "lat range": "lat=91&lon=0", "not a number": "lat=x&lon=0"} {
+		if status, body := doJSON(t, app, http.MethodGet, "/api/v1/reverse?"+q, nil); status != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d: %v", name, status, body)
+		}
+	}
+}
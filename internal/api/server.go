@@ -21,6 +21,7 @@ func New() *Server {
 
 	app := fiber.New(fiber.Config{
 		DisableStartupMessage: false,
+		ErrorHandler:          ErrorHandler,
 	})
 
 	// Prometheus metrics endpoint (before other middlewares)
@@ -0,0 +1,73 @@
+package api
+
+import (
+	"hash/fnv"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"redcat/internal/domain/errs"
+	"redcat/internal/domain/geo/tile"
+	svc "redcat/internal/service/places"
+	"redcat/internal/validate"
+)
+
+const mvtContentType = "application/vnd.mapbox-vector-tile"
+
+// tileParam parses the z, x and y path parameters; y carries the .mvt
+// suffix in the route.
+func tileParam(c *fiber.Ctx) (tile.Tile, error) {
+	v := &validate.Validator{}
+	var t tile.Tile
+	for _, p := range []struct {
+		name string
+		dst  *int
+	}{{"z", &t.Z}, {"x", &t.X}, {"y", &t.Y}} {
+		n, err := strconv.Atoi(c.Params(p.name))
+		if err != nil { v.Add(p.name, "must be an integer") }
+		*p.dst = n
+	}
+	if err := v.Err(); err != nil { return tile.Tile{}, err }
+	v.Range("z", float64(t.Z), validate.TileZoomMin, validate.TileZoomMax)
+	if err := v.Err(); err != nil { return tile.Tile{}, err }
+	if !t.Valid() {
+		return tile.Tile{}, errs.Invalid("x and y must be below 2^z", map[string]any{"z": t.Z, "x": t.X, "y": t.Y})
+	}
+	return t, nil
+}
+
+// tileLayers renders a tile's content as the "places" or "clusters" layer.
+// Multi-valued properties are comma-separated; category_label is the label
+// of the first category.
+func tileLayers(t tile.Tile, tc svc.TileContent) []tile.Layer {
+	places := tile.Layer{Name: "places"}
+	for _, p := range tc.Places {
+		f := tile.Feature{Props: map[string]any{"id": p.ID, "name": p.Name, "category_ids": strings.Join(p.CategoryIDs, ",")}}
+		f.X, f.Y = t.Point(p.Lat, p.Lon, tile.Extent)
+		if len(p.CategoryLabels) > 0 { f.Props["category_label"] = p.CategoryLabels[0] }
+		if p.Country != "" { f.Props["country"] = p.Country }
+		places.Features = append(places.Features, f)
+	}
+	clusters := tile.Layer{Name: "clusters"}
+	for _, c := range tc.Clusters {
+		f := tile.Feature{Props: map[string]any{"count": c.Count, "cell": c.Cell.String(), "place_ids": strings.Join(c.PlaceIDs, ",")}}
+		f.X, f.Y = t.Point(c.Lat, c.Lon, tile.Extent)
+		if len(c.Categories) > 0 { f.Props["category_id"] = c.Categories[0].Value }
+		clusters.Features = append(clusters.Features, f)
+	}
+	return []tile.Layer{places, clusters}
+}
+
+// sendTile writes an encoded tile with a strong ETag over its bytes and a
+// Cache-Control of maxAge, answering 304 to a matching If-None-Match.
+func sendTile(c *fiber.Ctx, body []byte, maxAge time.Duration) error {
+	h := fnv.New64a()
+	h.Write(body)
+	tag := `"` + strconv.FormatUint(h.Sum64(), 16) + `"`
+	c.Set(fiber.HeaderETag, tag)
+	c.Set(fiber.HeaderCacheControl, "public, max-age="+strconv.Itoa(int(maxAge.Seconds())))
+	if noneMatchTag(c, tag) { return c.SendStatus(fiber.StatusNotModified) }
+	c.Set(fiber.HeaderContentType, mvtContentType)
+	return c.Send(body)
+}
@@ -0,0 +1,76 @@
+package api_test
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"redcat/internal/api"
+	"redcat/internal/domain/geo/tile"
+	"redcat/internal/domain/model"
+	svc "redcat/internal/service/places"
+)
+
+func TestTiles(t *testing.T) {
+	store := newMemStore(
+		model.Place{ID: "r1", Name: "R1", Lat: 35.1700, Lon: 33.3600, Country: "CY", CategoryIDs: []string{"rest"}, CategoryLabels: []string{"Dining > Restaurant"}},
+		model.Place{ID: "r2", Name: "R2", Lat: 35.1701, Lon: 33.3601, CategoryIDs: []string{"bar"}},
+	)
+	app := fiber.New()
+	api.Register(app, api.Handlers{Places: svc.New(store), TileMaxAge: time.Minute})
+
+	get := func(path, inm string) (*http.Response, []byte) {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		if inm != "" { req.Header.Set("If-None-Match", inm) }
+		resp, err := app.Test(req)
+		if err != nil { t.Fatalf("GET %s: %v", path, err) }
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		return resp, body
+	}
+
+	tl := tile.At(35.1700, 33.3600, 16)
+	resp, body := get("/tiles/places/"+tl.String()+".mvt", "")
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "application/vnd.mapbox-vector-tile" || resp.Header.Get("Cache-Control") != "public, max-age=60" {
+		t.Fatalf("place tile: %d %v", resp.StatusCode, resp.Header)
+	}
+	x1, y1 := tl.Point(35.1700, 33.3600, tile.Extent)
+	x2, y2 := tl.Point(35.1701, 33.3601, tile.Extent)
+	want := tile.EncodeMVT(tile.Layer{Name: "places", Features: []tile.Feature{
+		{X: x1, Y: y1, Props: map[string]any{"id": "r1", "name": "R1", "category_ids": "rest", "category_label": "Dining > Restaurant", "country": "CY"}},
+		{X: x2, Y: y2, Props: map[string]any{"id": "r2", "name": "R2", "category_ids": "bar"}},
+	}})
+	if !bytes.Equal(body, want) {
+		t.Fatalf("place tile: got %q, want %q", body, want)
+	}
+	etag := resp.Header.Get("ETag")
+	if resp, _ = get("/tiles/places/"+tl.String()+".mvt", etag); resp.StatusCode != http.StatusNotModified {
+		t.Fatalf("If-None-Match %s: got %d", etag, resp.StatusCode)
+	}
+	if _, body = get("/tiles/places/"+tl.String()+".mvt?category=bar", ""); bytes.Contains(body, []byte("r1")) || !bytes.Contains(body, []byte("r2")) {
+		t.Fatalf("category filter: %q", body)
+	}
+
+	tl = tile.At(35.1700, 33.3600, 8)
+	if _, body = get("/tiles/places/"+tl.String()+".mvt", ""); !bytes.Contains(body, []byte("clusters")) || !bytes.Contains(body, []byte("r1")) || !bytes.Contains(body, []byte("r2")) {
+		t.Fatalf("cluster tile: %q", body)
+	}
+	if resp, body = get("/tiles/places/8/0/0.mvt", ""); resp.StatusCode != http.StatusOK || len(body) != 0 {
+		t.Fatalf("empty tile: %d %q", resp.StatusCode, body)
+	}
+
+	for name, path := range map[string]string{
+		"zoom range": "/tiles/places/23/0/0.mvt",
+		"x range":    "/tiles/places/2/4/0.mvt",
+		"bad y":      "/tiles/places/2/0/a.mvt",
+	} {
+		if resp, body := get(path, ""); resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d: %s", name, resp.StatusCode, body)
+		}
+	}
+}
@@ -0,0 +1,384 @@
+package api
+
+import (
+	"fmt"
+	"math"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"redcat/internal/domain/geo"
+	"redcat/internal/domain/model"
+	svc "redcat/internal/service/places"
+	"redcat/internal/validate"
+)
+
+// decodeBody parses the JSON request body into dst; unknown properties are
+// rejected when the server runs in strict mode.
+func (h Handlers) decodeBody(c *fiber.Ctx, dst any) error {
+	return validate.DecodeJSON(c.Body(), dst, h.Strict)
+}
+
+// validate checks a create body; upsert requires a client-supplied id.
+func (r PlaceCreate) validate(upsert bool) error {
+	v := &validate.Validator{}
+	if upsert { v.Required("id", r.ID != "") }
+	if r.ID != "" { v.ID("id", r.ID) }
+	v.Length("name", r.Name, validate.NameMinLen, validate.NameMaxLen)
+	v.Required("location", r.Location != nil)
+	if r.Location != nil { v.Location("location", r.Location.Lat, r.Location.Lon) }
+	v.Items("category_ids", len(r.CategoryIDs), validate.PlaceCategoriesMin, validate.PlaceCategoriesMax)
+	return v.Err()
+}
+
+func (r PlaceUpdate) validate() error {
+	v := &validate.Validator{}
+	if r.Name != nil { v.Length("name", *r.Name, validate.NameMinLen, validate.NameMaxLen) }
+	if r.Location != nil { v.Location("location", r.Location.Lat, r.Location.Lon) }
+	if r.CategoryIDs != nil {
+		v.Items("category_ids", len(r.CategoryIDs), validate.PlaceCategoriesMin, validate.PlaceCategoriesMax)
+	}
+	return v.Err()
+}
+
+func (r SearchRequest) validate() error {
+	v := &validate.Validator{}
+	v.Required("location", r.Location != nil)
+	if r.Location != nil { v.Location("location", r.Location.Lat, r.Location.Lon) }
+	v.Items("category_ids", len(r.CategoryIDs), 0, validate.SearchCategoriesMax)
+	// limit 0 means "use the default"
+	if r.Limit != 0 { v.Range("limit", float64(r.Limit), validate.LimitMin, validate.LimitMax) }
+	if !r.DistanceMode.Valid() { v.Add("distance_mode", "must be one of knn, haversine, ellipsoidal") }
+	if r.PerCategoryLimit != 0 {
+		v.Range("per_category_limit", float64(r.PerCategoryLimit), validate.PerCategoryLimitMin, validate.PerCategoryLimitMax)
+		if len(r.CategoryIDs) == 0 { v.Add("category_ids", "required with per_category_limit") }
+	}
+	if !r.Diversify.Valid() { v.Add("diversify", "must be one of interleave, grouped") }
+	if r.Diversify != "" && r.PerCategoryLimit == 0 { v.Add("diversify", "requires per_category_limit") }
+	if _, err := parseHydrate(r.Hydrate); err != nil { v.Check("hydrate", err) }
+	v.Check("fields", svc.ValidateFields(r.Fields))
+	return v.Err()
+}
+
+// checkBBox validates a box whose xmin > xmax wraps across the antimeridian.
+func checkBBox(v *validate.Validator, field string, b BBox) {
+	v.Range(field+".xmin", b.XMin, validate.LonMin, validate.LonMax)
+	v.Range(field+".xmax", b.XMax, validate.LonMin, validate.LonMax)
+	v.Range(field+".ymin", b.YMin, validate.LatMin, validate.LatMax)
+	v.Range(field+".ymax", b.YMax, validate.LatMin, validate.LatMax)
+	if b.YMin > b.YMax { v.Add(field, "ymin must not exceed ymax") }
+}
+
+func (r FacetsRequest) validate() error {
+	v := &validate.Validator{}
+	switch {
+	case (r.Location == nil) == (r.BBox == nil):
+		v.Add("location", "exactly one of location and bbox is required")
+	case r.Location != nil:
+		v.Location("location", r.Location.Lat, r.Location.Lon)
+		v.Range("radius_m", r.RadiusM, validate.FacetRadiusMin, validate.FacetRadiusMax)
+	default:
+		checkBBox(v, "bbox", *r.BBox)
+		if r.RadiusM != 0 { v.Add("radius_m", "only allowed with location") }
+	}
+	v.Items("category_ids", len(r.CategoryIDs), 0, validate.SearchCategoriesMax)
+	if r.Top != 0 { v.Range("top", float64(r.Top), validate.FacetTopMin, validate.FacetTopMax) }
+	return v.Err()
+}
+
+func (r WebhookCreate) validate() error {
+	v := &validate.Validator{}
+	v.Required("url", r.URL != "")
+	if r.URL != "" {
+		u, err := url.Parse(r.URL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			v.Add("url", "must be an absolute http or https URL")
+		}
+		v.Length("url", r.URL, 1, validate.WebhookURLMaxLen)
+	}
+	v.Length("secret", r.Secret, validate.WebhookSecretMinLen, validate.WebhookSecretMaxLen)
+	if b := r.Filter.BBox; b != nil { checkBBox(v, "filter.bbox", *b) }
+	v.Items("filter.countries", len(r.Filter.Countries), 0, validate.WebhookFilterItemsMax)
+	v.Items("filter.category_ids", len(r.Filter.CategoryIDs), 0, validate.WebhookFilterItemsMax)
+	return v.Err()
+}
+
+// validate checks a create body and returns the fence it describes.
+func (r GeofenceCreate) validate() (model.Geofence, error) {
+	v := &validate.Validator{}
+	if r.ID != "" { v.ID("id", r.ID) }
+	f := geofenceBody(v, r.Name, r.Geometry)
+	f.ID, f.Properties = r.ID, r.Properties
+	return f, v.Err()
+}
+
+func (r GeofenceUpdate) validate() (model.Geofence, error) {
+	v := &validate.Validator{}
+	f := geofenceBody(v, r.Name, r.Geometry)
+	f.Properties = r.Properties
+	return f, v.Err()
+}
+
+// geofenceBody checks the name and parses the geometry shared by the
+// create and update bodies.
+func geofenceBody(v *validate.Validator, name string, g *geo.Geometry) model.Geofence {
+	v.Length("name", name, validate.NameMinLen, validate.NameMaxLen)
+	v.Required("geometry", g != nil)
+	f := model.Geofence{Name: name}
+	if g == nil { return f }
+	mp, err := geo.ParsePolygonal(*g)
+	if err != nil {
+		v.Check("geometry", err)
+		return f
+	}
+	var n int
+	for _, p := range mp {
+		for _, r := range p { n += len(r) }
+	}
+	if n > validate.GeofenceVerticesMax { v.Add("geometry", "must have at most %d positions", validate.GeofenceVerticesMax) }
+	f.Geometry = mp
+	return f
+}
+
+func (r GeofenceLookupRequest) validate() error {
+	v := &validate.Validator{}
+	v.Required("location", r.Location != nil)
+	if r.Location != nil { v.Location("location", r.Location.Lat, r.Location.Lon) }
+	return v.Err()
+}
+
+// validate checks the batch-level fields; the queries themselves are
+// checked one by one so a bad query only fails its own item.
+func (r BatchSearchRequest) validate() error {
+	v := &validate.Validator{}
+	v.Items("queries", len(r.Queries), validate.BatchQueriesMin, validate.BatchQueriesMax)
+	v.Items("category_ids", len(r.CategoryIDs), 0, validate.SearchCategoriesMax)
+	if r.Limit != 0 { v.Range("limit", float64(r.Limit), validate.LimitMin, validate.LimitMax) }
+	if !r.DistanceMode.Valid() { v.Add("distance_mode", "must be one of knn, haversine, ellipsoidal") }
+	if _, err := parseHydrate(r.Hydrate); err != nil { v.Check("hydrate", err) }
+	v.Check("fields", svc.ValidateFields(r.Fields))
+	return v.Err()
+}
+
+func (q BatchSearchQuery) validate() error {
+	v := &validate.Validator{}
+	v.Required("location", q.Location != nil)
+	if q.Location != nil { v.Location("location", q.Location.Lat, q.Location.Lon) }
+	v.Items("category_ids", len(q.CategoryIDs), 0, validate.SearchCategoriesMax)
+	if q.Limit != 0 { v.Range("limit", float64(q.Limit), validate.LimitMin, validate.LimitMax) }
+	if !q.DistanceMode.Valid() { v.Add("distance_mode", "must be one of knn, haversine, ellipsoidal") }
+	return v.Err()
+}
+
+func (r DistanceMatrixRequest) validate() error {
+	v := &validate.Validator{}
+	v.Items("origins", len(r.Origins), validate.MatrixPointsMin, validate.MatrixPointsMax)
+	v.Items("destinations", len(r.Destinations), validate.MatrixPointsMin, validate.MatrixPointsMax)
+	if n := len(r.Origins) * len(r.Destinations); n > validate.MatrixCellsMax {
+		v.Add("destinations", "origins × destinations must be at most %d, got %d", validate.MatrixCellsMax, n)
+	}
+	switch r.DistanceMode {
+	case "", geo.DistanceHaversine, geo.DistanceEllipsoidal:
+	default:
+		v.Add("distance_mode", "must be one of haversine, ellipsoidal")
+	}
+	sides := []struct {
+		name string
+		pts  []MatrixPoint
+	}{{"origins", r.Origins}, {"destinations", r.Destinations}}
+	for _, side := range sides {
+		for i, p := range side.pts {
+			field := fmt.Sprintf("%s[%d]", side.name, i)
+			switch {
+			case (p.PlaceID == "") == (p.Location == nil):
+				v.Add(field, "must have exactly one of place_id and location")
+			case p.Location != nil:
+				v.Location(field+".location", p.Location.Lat, p.Location.Lon)
+			default:
+				v.ID(field+".place_id", p.PlaceID)
+			}
+		}
+	}
+	return v.Err()
+}
+
+// validate checks the request and returns the decoded route.
+func (r RouteSearchRequest) validate() ([]geo.Point, error) {
+	v := &validate.Validator{}
+	var (
+		line []geo.Point
+		err  error
+	)
+	switch {
+	case (r.Polyline == "") == (r.Line == nil):
+		v.Add("polyline", "exactly one of polyline and line is required")
+	case r.Line != nil:
+		if line, err = geo.ParseLineString(*r.Line); err != nil { v.Check("line", err) }
+	default:
+		precision := r.PolylinePrecision
+		if precision == 0 { precision = 5 }
+		if precision != 5 && precision != 6 { v.Add("polyline_precision", "must be 5 or 6") }
+		if line, err = geo.DecodePolyline(r.Polyline, precision); err != nil { v.Check("polyline", err) }
+	}
+	if line != nil {
+		field := "polyline"
+		if r.Line != nil { field = "line" }
+		if err := geo.ValidateLine(line); err != nil { v.Check(field, err) }
+		v.Items(field, len(line), validate.RoutePointsMin, validate.RoutePointsMax)
+	}
+	v.Range("buffer_m", r.BufferM, validate.RouteBufferMin, validate.RouteBufferMax)
+	v.Items("category_ids", len(r.CategoryIDs), 0, validate.SearchCategoriesMax)
+	if r.Limit != 0 { v.Range("limit", float64(r.Limit), validate.RouteLimitMin, validate.RouteLimitMax) }
+	if _, err := parseHydrate(r.Hydrate); err != nil { v.Check("hydrate", err) }
+	v.Check("fields", svc.ValidateFields(r.Fields))
+	return line, v.Err()
+}
+
+// historyPage parses the limit and cursor query parameters of the history
+// endpoint.
+func historyPage(c *fiber.Ctx) (limit int64, cursor string, err error) {
+	v := &validate.Validator{}
+	limit = queryInt(c, v, "limit", validate.HistoryLimitDefault, validate.HistoryLimitMin, validate.HistoryLimitMax)
+	cursor = c.Query("cursor")
+	if cursor != "" { v.StreamID("cursor", cursor) }
+	return limit, cursor, v.Err()
+}
+
+// changesPage parses the query of GET /changes. The since cursor is
+// checked by the feed, which knows the shard count.
+func changesPage(c *fiber.Ctx) (limit int64, wait time.Duration, err error) {
+	v := &validate.Validator{}
+	limit = queryInt(c, v, "limit", validate.ChangesLimitDefault, validate.ChangesLimitMin, validate.ChangesLimitMax)
+	secs := queryInt(c, v, "wait", 0, 0, validate.ChangesWaitMax)
+	return limit, time.Duration(secs) * time.Second, v.Err()
+}
+
+// heatmapQuery parses the query of GET /heatmap.
+func heatmapQuery(c *fiber.Ctx) (bbox geo.BBox, res int, categoryIDs []string, err error) {
+	v := &validate.Validator{}
+	bbox = bboxQuery(c, v)
+	res = int(queryInt(c, v, "res", validate.HeatmapResDefault, validate.HeatmapResMin, validate.HeatmapResMax))
+	categoryIDs = listQuery(c, v, "category", validate.SearchCategoriesMax)
+	return bbox, res, categoryIDs, v.Err()
+}
+
+// clustersQuery parses the query of GET /clusters.
+func clustersQuery(c *fiber.Ctx) (svc.ClusterParams, error) {
+	v := &validate.Validator{}
+	cp := svc.ClusterParams{BBox: bboxQuery(c, v), CategoryIDs: listQuery(c, v, "category", validate.SearchCategoriesMax)}
+	v.Required("zoom", c.Query("zoom") != "")
+	cp.Zoom = int(queryInt(c, v, "zoom", 0, validate.ClusterZoomMin, validate.ClusterZoomMax))
+	return cp, v.Err()
+}
+
+// exportQuery parses the optional filters of GET /places:export.
+func exportQuery(c *fiber.Ctx) (svc.ExportParams, error) {
+	v := &validate.Validator{}
+	ep := svc.ExportParams{CategoryIDs: listQuery(c, v, "category", validate.SearchCategoriesMax)}
+	if c.Query("bbox") != "" {
+		b := bboxQuery(c, v)
+		ep.BBox = &b
+	}
+	return ep, v.Err()
+}
+
+// reverseQuery parses the query of GET /reverse.
+func reverseQuery(c *fiber.Ctx) (lat, lon float64, err error) {
+	v := &validate.Validator{}
+	lat, lon = queryFloat(c, v, "lat"), queryFloat(c, v, "lon")
+	v.Range("lat", lat, validate.LatMin, validate.LatMax)
+	v.Range("lon", lon, validate.LonMin, validate.LonMax)
+	return lat, lon, v.Err()
+}
+
+// queryFloat reads a required number query parameter.
+func queryFloat(c *fiber.Ctx, v *validate.Validator, name string) float64 {
+	q := c.Query(name)
+	v.Required(name, q != "")
+	if q == "" { return 0 }
+	x, err := strconv.ParseFloat(q, 64)
+	if err != nil || math.IsNaN(x) {
+		v.Add(name, "must be a number")
+		return 0
+	}
+	return x
+}
+
+// bboxQuery reads the required bbox query parameter, xmin,ymin,xmax,ymax.
+func bboxQuery(c *fiber.Ctx, v *validate.Validator) geo.BBox {
+	q := c.Query("bbox")
+	v.Required("bbox", q != "")
+	if q == "" { return geo.BBox{} }
+	var xs []float64
+	for _, s := range strings.Split(q, ",") {
+		x, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+		if err != nil || math.IsNaN(x) || math.IsInf(x, 0) { break }
+		xs = append(xs, x)
+	}
+	if len(xs) != 4 {
+		v.Add("bbox", "must be four numbers xmin,ymin,xmax,ymax")
+		return geo.BBox{}
+	}
+	b := BBox{XMin: xs[0], YMin: xs[1], XMax: xs[2], YMax: xs[3]}
+	checkBBox(v, "bbox", b)
+	return geo.BBox{XMin: b.XMin, YMin: b.YMin, XMax: b.XMax, YMax: b.YMax}
+}
+
+// listQuery reads an optional comma-separated query parameter.
+func listQuery(c *fiber.Ctx, v *validate.Validator, name string, max int) []string {
+	q := c.Query(name)
+	if q == "" { return nil }
+	items := strings.Split(q, ",")
+	v.Items(name, len(items), 0, max)
+	return items
+}
+
+// queryInt reads an optional integer query parameter within [min, max].
+func queryInt(c *fiber.Ctx, v *validate.Validator, name string, def, min, max int64) int64 {
+	q := c.Query(name)
+	if q == "" { return def }
+	n, err := strconv.ParseInt(q, 10, 64)
+	if err != nil {
+		v.Add(name, "must be an integer")
+		return def
+	}
+	v.Range(name, float64(n), float64(min), float64(max))
+	return n
+}
+
+// validate checks the batch; every old ID may be listed once.
+func (r AliasBatch) validate() error {
+	v := &validate.Validator{}
+	v.Items("aliases", len(r.Aliases), validate.AliasesMin, validate.AliasesMax)
+	seen := make(map[string]bool, len(r.Aliases))
+	for i, a := range r.Aliases {
+		field := fmt.Sprintf("aliases[%d]", i)
+		v.ID(field+".id", a.ID)
+		v.ID(field+".canonical_id", a.CanonicalID)
+		if seen[a.ID] { v.Add(field+".id", "listed more than once") }
+		seen[a.ID] = true
+	}
+	return v.Err()
+}
+
+func (r MergeRequest) validate() error {
+	v := &validate.Validator{}
+	v.ID("duplicate_id", r.DuplicateID)
+	return v.Err()
+}
+
+// duplicatesLimit parses the limit query parameter of the duplicate queue.
+func duplicatesLimit(c *fiber.Ctx) (int64, error) {
+	v := &validate.Validator{}
+	limit := queryInt(c, v, "limit", validate.DuplicatesLimitDefault, validate.DuplicatesLimitMin, validate.DuplicatesLimitMax)
+	return limit, v.Err()
+}
+
+// deadLettersLimit parses the limit query parameter of the dead-letter list.
+func deadLettersLimit(c *fiber.Ctx) (int64, error) {
+	v := &validate.Validator{}
+	limit := queryInt(c, v, "limit", validate.DeadLettersLimitDefault, validate.DeadLettersLimitMin, validate.DeadLettersLimitMax)
+	return limit, v.Err()
+}
@@ -0,0 +1,74 @@
+package api
+
+import (
+	"log/slog"
+	"net/http"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
+)
+
+// registerWebhooks adds the subscription endpoints; they are absent when
+// no webhook service is configured.
+func registerWebhooks(app *fiber.App, h Handlers) {
+	if h.Webhooks == nil { return }
+
+	app.Post("/api/v1/webhooks", func(c *fiber.Ctx) error {
+		var req WebhookCreate
+		if err := h.decodeBody(c, &req); err != nil {
+			slog.Warn("create webhook: invalid body", slog.String("error", err.Error()))
+			return err
+		}
+		if err := req.validate(); err != nil {
+			return err
+		}
+
+		sub, err := h.Webhooks.Subscribe(c.Context(), req.ToModel())
+		if err != nil {
+			return err
+		}
+
+		slog.Info("webhook created", slog.String("id", sub.ID), slog.String("url", sub.URL))
+		return c.Status(http.StatusCreated).JSON(webhookFromModel(sub))
+	})
+
+	app.Get("/api/v1/webhooks", func(c *fiber.Ctx) error {
+		subs, err := h.Webhooks.Subscriptions(c.Context())
+		if err != nil {
+			return err
+		}
+		return c.JSON(webhookList(subs))
+	})
+
+	// registered before /webhooks/:id so it is not taken for an ID
+	app.Get("/api/v1/webhooks/dead-letters", func(c *fiber.Ctx) error {
+		limit, err := deadLettersLimit(c)
+		if err != nil {
+			return err
+		}
+
+		ds, err := h.Webhooks.DeadLetters(c.Context(), limit)
+		if err != nil {
+			return err
+		}
+		return c.JSON(deadLetterList(ds))
+	})
+
+	app.Get("/api/v1/webhooks/:id", func(c *fiber.Ctx) error {
+		sub, err := h.Webhooks.Subscription(c.Context(), utils.CopyString(c.Params("id")))
+		if err != nil {
+			return err
+		}
+		return c.JSON(webhookFromModel(sub))
+	})
+
+	app.Delete("/api/v1/webhooks/:id", func(c *fiber.Ctx) error {
+		id := utils.CopyString(c.Params("id"))
+		if err := h.Webhooks.Unsubscribe(c.Context(), id); err != nil {
+			return err
+		}
+
+		slog.Info("webhook deleted", slog.String("id", id))
+		return c.SendStatus(http.StatusNoContent)
+	})
+}
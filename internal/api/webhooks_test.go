@@ -0,0 +1,134 @@
+package api_test
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"redcat/internal/api"
+	"redcat/internal/domain/model"
+	svc "redcat/internal/service/places"
+	"redcat/internal/service/webhooks"
+)
+
+func TestWebhooks_SignedDeliveryRetryAndDeadLetter(t *testing.T) {
+	spec := loadSpec(t)
+	const secret = "0123456789abcdef"
+
+	var (
+		mu       sync.Mutex
+		failing  bool
+		received []map[string]any
+	)
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if _, ok := webhooks.Verify(secret, r.Header.Get(webhooks.SignatureHeader), body); !ok {
+			t.Errorf("delivery %s: bad signature", r.Header.Get(webhooks.DeliveryHeader))
+		}
+		mu.Lock(); defer mu.Unlock()
+		if failing {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		var payload map[string]any
+		_ = json.Unmarshal(body, &payload)
+		received = append(received, payload)
+	}))
+	defer receiver.Close()
+
+	hooks := webhooks.New(newMemWebhooks(),
+		webhooks.WithRenderer(func(p model.Place) any { return api.PlaceFromModel(p) }),
+		webhooks.WithRetry(2, time.Millisecond, time.Millisecond),
+		webhooks.WithPrivateTargets(), // the receiver listens on loopback
+	)
+	app := fiber.New()
+	api.Register(app, api.Handlers{Places: svc.New(newMemStore(), svc.WithPublisher(hooks)), Webhooks: hooks})
+
+	if status, _ := doJSON(t, app, http.MethodPost, "/api/v1/webhooks", map[string]any{"url": "ftp://x", "secret": "short"}); status != http.StatusBadRequest {
+		t.Fatalf("invalid webhook: expected 400, got %d", status)
+	}
+	status, body := doJSON(t, app, http.MethodPost, "/api/v1/webhooks", map[string]any{
+		"url": receiver.URL, "secret": secret,
+		"filter": map[string]any{"countries": []string{"CY"}, "bbox": map[string]any{"xmin": 32, "ymin": 34, "xmax": 35, "ymax": 36}},
+	})
+	if status != http.StatusCreated { t.Fatalf("create webhook: expected 201, got %d: %v", status, body) }
+	for _, e := range spec.validate(spec.schema("Webhook"), body, "Webhook") {
+		t.Error(e)
+	}
+	id := body.(map[string]any)["id"].(string)
+	if _, ok := body.(map[string]any)["secret"]; ok { t.Fatal("create webhook: secret must not be returned") }
+
+	place := func(id, country string, lat float64) map[string]any {
+		return map[string]any{"id": id, "name": id, "location": map[string]any{"lat": lat, "lon": 33.3}, "category_ids": []string{"c1"}, "address": map[string]any{"country": country}}
+	}
+	doJSON(t, app, http.MethodPost, "/api/v1/places", place("in", "CY", 35.1))
+	doJSON(t, app, http.MethodPost, "/api/v1/places", place("other-country", "GR", 35.1))
+	doJSON(t, app, http.MethodPost, "/api/v1/places", place("outside", "CY", 40))
+
+	if n, err := hooks.Dispatch(context.Background()); err != nil || n != 1 {
+		t.Fatalf("dispatch: want 1 delivery, got %d (%v)", n, err)
+	}
+	if len(received) != 1 || received[0]["place_id"] != "in" || received[0]["event"] != "create" {
+		t.Fatalf("want the create of the matching place, got %v", received)
+	}
+	for _, e := range spec.validate(spec.schema("WebhookPayload"), received[0], "WebhookPayload") {
+		t.Error(e)
+	}
+
+	mu.Lock(); failing = true; mu.Unlock()
+	doJSON(t, app, http.MethodDelete, "/api/v1/places/in", nil)
+	for range 2 {
+		time.Sleep(5 * time.Millisecond)
+		if n, _ := hooks.Dispatch(context.Background()); n != 0 { t.Fatalf("dispatch to failing receiver: want 0 deliveries, got %d", n) }
+	}
+	status, body = doJSON(t, app, http.MethodGet, "/api/v1/webhooks/dead-letters", nil)
+	if status != http.StatusOK { t.Fatalf("dead letters: expected 200, got %d", status) }
+	for _, e := range spec.validate(spec.schema("DeadLetterList"), body, "DeadLetterList") {
+		t.Error(e)
+	}
+	dead := body.(map[string]any)["deliveries"].([]any)
+	if len(dead) != 1 || dead[0].(map[string]any)["event"] != "delete" || dead[0].(map[string]any)["attempts"] != float64(2) {
+		t.Fatalf("want the delete dead-lettered after 2 attempts, got %v", dead)
+	}
+
+	if status, _ := doJSON(t, app, http.MethodDelete, "/api/v1/webhooks/"+id, nil); status != http.StatusNoContent {
+		t.Fatalf("delete webhook: expected 204, got %d", status)
+	}
+	if status, _ := doJSON(t, app, http.MethodGet, "/api/v1/webhooks/"+id, nil); status != http.StatusNotFound {
+		t.Fatalf("deleted webhook: expected 404, got %d", status)
+	}
+}
+
+func TestWebhooks_RejectPrivateTargets(t *testing.T) {
+	hooks := webhooks.New(newMemWebhooks())
+	app := fiber.New()
+	api.Register(app, api.Handlers{Places: svc.New(newMemStore()), Webhooks: hooks})
+
+	for _, u := range []string{
+		"http://127.0.0.1:8080/hook", "http://localhost/hook", "http://10.0.0.5/hook",
+		"http://169.254.169.254/latest/meta-data/", "http://[::1]/hook", "http://[::ffff:192.168.0.1]/hook",
+	} {
+		status, body := doJSON(t, app, http.MethodPost, "/api/v1/webhooks", map[string]any{"url": u, "secret": "0123456789abcdef"})
+		if status != http.StatusBadRequest { t.Errorf("%s: expected 400, got %d: %v", u, status, body) }
+	}
+
+	// the dialer refuses loopback even for a host that passed the check
+	var hits atomic.Int32
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { hits.Add(1) }))
+	defer receiver.Close()
+	if _, err := webhooks.NewHTTPClient(time.Second, false).Post(receiver.URL, "application/json", nil); err == nil || !strings.Contains(err.Error(), "not a public address") {
+		t.Fatalf("dial to loopback: want a refusal, got %v", err)
+	}
+	resp, err := webhooks.NewHTTPClient(time.Second, true).Post(receiver.URL, "application/json", nil)
+	if err != nil { t.Fatalf("dial with private targets allowed: %v", err) }
+	resp.Body.Close()
+	if hits.Load() != 1 { t.Fatalf("receiver: want only the allowed request, got %d", hits.Load()) }
+}
@@ -2,7 +2,9 @@ package config
 
 import (
 	"os"
+	"strconv"
 	"strings"
+	"time"
 )
 
 type Config struct {
@@ -12,6 +14,43 @@ type Config struct {
 	ValkeyPass string
 	IndexName  string
 	KeyPrefix  string
+	// StrictValidation rejects request bodies with unknown properties.
+	StrictValidation bool
+	// SoftDelete keeps deleted places restorable for SoftDeleteRetention;
+	// a purger removes expired ones every PurgeInterval.
+	SoftDelete          bool
+	SoftDeleteRetention time.Duration
+	PurgeInterval       time.Duration
+	// HistoryMaxLen and HistoryMaxAge bound each place's audit stream;
+	// zero disables the limit.
+	HistoryMaxLen int64
+	HistoryMaxAge time.Duration
+	// ChangesShards is the number of change feed streams; changing it
+	// invalidates consumers' cursors. ChangesMaxLen bounds each stream.
+	// ChangesRelayInterval is how often events a write could not relay to
+	// the feed are swept from the outboxes.
+	ChangesShards        int
+	ChangesMaxLen        int64
+	ChangesRelayInterval time.Duration
+	// Webhook deliveries are attempted WebhookMaxAttempts times, each
+	// bounded by WebhookTimeout; WebhookMaxDead caps the dead-letter list.
+	// WebhookAllowPrivate permits loopback and private targets (local dev).
+	WebhookMaxAttempts  int
+	WebhookTimeout      time.Duration
+	WebhookMaxDead      int64
+	WebhookAllowPrivate bool
+	// Geofences live in their own index and key prefix.
+	GeofenceIndexName string
+	GeofenceKeyPrefix string
+	// TileMaxAge is how long clients and CDNs may cache vector tiles.
+	TileMaxAge time.Duration
+	// Dedupe queues likely duplicate places for review every
+	// DedupeInterval; merging works either way.
+	Dedupe         bool
+	DedupeInterval time.Duration
+	// ActorProxies lists the IPs or CIDRs of the auth proxies whose
+	// X-Forwarded-User names the actor in the audit trail; empty trusts none.
+	ActorProxies []string
 }
 
 func FromEnv() Config {
@@ -22,6 +61,25 @@ func FromEnv() Config {
 		ValkeyPass:  os.Getenv("VALKEY_PASS"),
 		IndexName:   getenv("VALKEY_INDEX", "index_places"),
 		KeyPrefix:   getenv("VALKEY_PREFIX", "places:"),
+		StrictValidation: getenvBool("STRICT_VALIDATION", false),
+		SoftDelete:          getenvBool("SOFT_DELETE", false),
+		SoftDeleteRetention: getenvDuration("SOFT_DELETE_RETENTION", 30*24*time.Hour),
+		PurgeInterval:       getenvDuration("PURGE_INTERVAL", time.Hour),
+		HistoryMaxLen:       getenvInt("HISTORY_MAX_LEN", 1000),
+		HistoryMaxAge:       getenvDuration("HISTORY_MAX_AGE", 365*24*time.Hour),
+		ChangesShards:       int(getenvInt("CHANGES_SHARDS", 16)),
+		ChangesMaxLen:       getenvInt("CHANGES_MAX_LEN", 100000),
+		ChangesRelayInterval: getenvDuration("CHANGES_RELAY_INTERVAL", time.Minute),
+		WebhookMaxAttempts:  int(getenvInt("WEBHOOK_MAX_ATTEMPTS", 8)),
+		WebhookTimeout:      getenvDuration("WEBHOOK_TIMEOUT", 10*time.Second),
+		WebhookMaxDead:      getenvInt("WEBHOOK_MAX_DEAD", 10000),
+		WebhookAllowPrivate: getenvBool("WEBHOOK_ALLOW_PRIVATE", false),
+		GeofenceIndexName:   getenv("VALKEY_GEOFENCE_INDEX", "index_geofences"),
+		GeofenceKeyPrefix:   getenv("VALKEY_GEOFENCE_PREFIX", "geofences:"),
+		TileMaxAge:          getenvDuration("TILE_MAX_AGE", 5*time.Minute),
+		Dedupe:              getenvBool("DEDUPE", false),
+		DedupeInterval:      getenvDuration("DEDUPE_INTERVAL", 24*time.Hour),
+		ActorProxies:        getenvList("ACTOR_PROXIES"),
 	}
 }
 
@@ -46,3 +104,35 @@ func getenv(k, d string) string {
 	}
 	return d
 }
+
+func getenvBool(k string, d bool) bool {
+	if v, err := strconv.ParseBool(os.Getenv(k)); err == nil {
+		return v
+	}
+	return d
+}
+
+func getenvDuration(k string, d time.Duration) time.Duration {
+	if v, err := time.ParseDuration(os.Getenv(k)); err == nil && v > 0 {
+		return v
+	}
+	return d
+}
+
+func getenvInt(k string, d int64) int64 {
+	if v, err := strconv.ParseInt(os.Getenv(k), 10, 64); err == nil && v >= 0 {
+		return v
+	}
+	return d
+}
+
+// getenvList reads a comma-separated list, nil when unset.
+func getenvList(k string) []string {
+	var out []string
+	for _, p := range strings.Split(os.Getenv(k), ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			out = append(out, p)
+		}
+	}
+	return out
+}
@@ -0,0 +1,114 @@
+// Package audit describes place change records: who changed a place, when,
+// and which fields went from what to what.
+package audit
+
+import (
+	"context"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"redcat/internal/domain/model"
+)
+
+type Action string
+
+const (
+	Create  Action = "create"
+	Update  Action = "update"
+	Delete  Action = "delete"
+	Restore Action = "restore"
+)
+
+// Anonymous is the actor recorded when the request carried no identity.
+const Anonymous = "anonymous"
+
+// Entry is one recorded mutation. ID is assigned by the store and orders
+// entries of the same place.
+type Entry struct {
+	ID      string
+	PlaceID string
+	Action  Action
+	Actor   string
+	At      time.Time
+	Changes []Change
+}
+
+// Change is one field of a place before and after a mutation; "" stands for
+// an absent value.
+type Change struct {
+	Field  string `json:"field"`
+	Before string `json:"before"`
+	After  string `json:"after"`
+}
+
+type actorKey struct{}
+
+// WithActor returns ctx carrying the identity of whoever triggers mutations.
+func WithActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, actorKey{}, actor)
+}
+
+// ActorFrom returns the actor stored by WithActor, or Anonymous.
+func ActorFrom(ctx context.Context) string {
+	if a, _ := ctx.Value(actorKey{}).(string); a != "" { return a }
+	return Anonymous
+}
+
+// Diff lists the fields that differ between before and after, named by their
+// JSON names (nested ones dotted, e.g. "bbox.xmin"). Pass a zero Place as
+// before for a create and as after for a delete.
+func Diff(before, after model.Place) []Change {
+	b, a := Flatten(before), Flatten(after)
+	var out []Change
+	for _, f := range fieldNames {
+		if b[f] != a[f] { out = append(out, Change{Field: f, Before: b[f], After: a[f]}) }
+	}
+	return out
+}
+
+// fieldNames is the flattened field order of model.Place.
+var fieldNames = func() []string {
+	var names []string
+	walk(reflect.ValueOf(model.Place{}), "", func(name string, _ reflect.Value) { names = append(names, name) })
+	return names
+}()
+
+// Flatten renders every audited field of p as a string. Zero numbers and
+// empty lists render as "" so they compare equal to absent values; Version
+// is bookkeeping and is skipped.
+func Flatten(p model.Place) map[string]string {
+	out := make(map[string]string, len(fieldNames))
+	walk(reflect.ValueOf(p), "", func(name string, v reflect.Value) { out[name] = format(v) })
+	return out
+}
+
+func walk(v reflect.Value, prefix string, fn func(string, reflect.Value)) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "" || name == "-" || name == "version" { continue }
+		if f.Type.Kind() == reflect.Struct {
+			walk(v.Field(i), prefix+name+".", fn)
+			continue
+		}
+		fn(prefix+name, v.Field(i))
+	}
+}
+
+func format(v reflect.Value) string {
+	switch v.Kind() {
+	case reflect.String:
+		return v.String()
+	case reflect.Float64:
+		if v.Float() == 0 { return "" }
+		return strconv.FormatFloat(v.Float(), 'f', -1, 64)
+	case reflect.Slice:
+		parts := make([]string, v.Len())
+		for i := range parts { parts[i] = v.Index(i).String() }
+		return strings.Join(parts, ",")
+	}
+	return ""
+}
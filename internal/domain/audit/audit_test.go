@@ -0,0 +1,33 @@
+package audit
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"redcat/internal/domain/model"
+)
+
+func TestDiff(t *testing.T) {
+	before := model.Place{ID: "p1", Name: "Cafe", Lat: 35.1, Lon: 33.3, CategoryIDs: []string{"a"}, Version: 1}
+	after := before
+	after.Lat, after.Version = 35.2, 2
+	after.CategoryIDs = []string{"a", "b"}
+	after.BBox.XMin = 33.25
+
+	got := Diff(before, after)
+	want := []Change{
+		{Field: "lat", Before: "35.1", After: "35.2"},
+		{Field: "category_ids", Before: "a", After: "a,b"},
+		{Field: "bbox.xmin", Before: "", After: "33.25"},
+	}
+	if !reflect.DeepEqual(got, want) { t.Fatalf("want %+v got %+v", want, got) }
+
+	if got := Diff(before, before); len(got) != 0 { t.Fatalf("identical places: want no changes, got %+v", got) }
+	if got := Diff(model.Place{}, before); len(got) != 5 { t.Fatalf("create: want 5 changes, got %+v", got) }
+}
+
+func TestActor(t *testing.T) {
+	if a := ActorFrom(context.Background()); a != Anonymous { t.Fatalf("want %q got %q", Anonymous, a) }
+	if a := ActorFrom(WithActor(context.Background(), "alice")); a != "alice" { t.Fatalf("want alice got %q", a) }
+}
@@ -0,0 +1,245 @@
+// Package dedupe scores how likely two places are the same venue, describes
+// the candidate pairs queued for review and combines the records of a pair
+// once a reviewer merges it.
+package dedupe
+
+import (
+	"math"
+	"slices"
+	"strings"
+	"time"
+	"unicode"
+
+	"redcat/internal/domain/model"
+)
+
+const (
+	// MaxDistanceM is the distance at which the distance score reaches 0;
+	// farther places are never candidates.
+	MaxDistanceM = 150.0
+	// Threshold is the Score a pair needs to be queued.
+	Threshold = 0.75
+	// MinNameScore is the name similarity a pair needs to be queued whatever
+	// its other scores; neighbouring shops sharing a phone are not one place.
+	MinNameScore = 0.6
+)
+
+// Weights of the scores in Score. Phone, website and categories only count
+// when both places have them.
+const (
+	weightName       = 0.45
+	weightDistance   = 0.25
+	weightPhone      = 0.1
+	weightWebsite    = 0.1
+	weightCategories = 0.1
+)
+
+// Scores are the similarities of a pair in [0, 1]. Phone, Website and
+// Categories are nil when either place lacks the attribute.
+type Scores struct {
+	Name       float64  `json:"name"`
+	Distance   float64  `json:"distance"`
+	Phone      *float64 `json:"phone,omitempty"`
+	Website    *float64 `json:"website,omitempty"`
+	Categories *float64 `json:"categories,omitempty"`
+}
+
+// Total is the weighted mean of the available scores.
+func (s Scores) Total() float64 {
+	sum, weights := weightName*s.Name+weightDistance*s.Distance, weightName+weightDistance
+	for _, o := range []struct {
+		v *float64
+		w float64
+	}{{s.Phone, weightPhone}, {s.Website, weightWebsite}, {s.Categories, weightCategories}} {
+		if o.v == nil { continue }
+		sum += o.w * *o.v
+		weights += o.w
+	}
+	return math.Round(sum/weights*1000) / 1000
+}
+
+// Duplicate reports whether the scores are high enough to queue the pair.
+func (s Scores) Duplicate() bool { return s.Name >= MinNameScore && s.Total() >= Threshold }
+
+// Score compares two places distanceM metres apart.
+func Score(a, b model.Place, distanceM float64) Scores {
+	s := Scores{
+		Name:     NameSimilarity(a.Name, b.Name),
+		Distance: math.Max(0, 1-distanceM/MaxDistanceM),
+	}
+	if pa, pb := normalizePhone(a.Tel), normalizePhone(b.Tel); pa != "" && pb != "" {
+		s.Phone = match(strings.HasSuffix(pa, pb) || strings.HasSuffix(pb, pa))
+	}
+	if wa, wb := normalizeWebsite(a.Website), normalizeWebsite(b.Website); wa != "" && wb != "" {
+		s.Website = match(wa == wb)
+	}
+	if len(a.CategoryIDs) > 0 && len(b.CategoryIDs) > 0 {
+		j := jaccard(a.CategoryIDs, b.CategoryIDs)
+		s.Categories = &j
+	}
+	return s
+}
+
+func match(ok bool) *float64 {
+	v := 0.0
+	if ok { v = 1 }
+	return &v
+}
+
+// NormalizeName lower-cases a name, turns punctuation into spaces and
+// collapses runs of spaces, so "Joe's  Café!" becomes "joe s café".
+func NormalizeName(name string) string {
+	f := strings.FieldsFunc(strings.ToLower(name), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	return strings.Join(f, " ")
+}
+
+// NameSimilarity is 1 minus the edit distance of the normalized names
+// relative to the longer one. Word order is ignored, so "Coffee Island"
+// and "Island Coffee" are equal.
+func NameSimilarity(a, b string) float64 {
+	na, nb := NormalizeName(a), NormalizeName(b)
+	if na == "" || nb == "" { return 0 }
+	return math.Max(ratio(na, nb), ratio(sortedWords(na), sortedWords(nb)))
+}
+
+func sortedWords(s string) string {
+	w := strings.Fields(s)
+	slices.Sort(w)
+	return strings.Join(w, " ")
+}
+
+func ratio(a, b string) float64 {
+	ra, rb := []rune(a), []rune(b)
+	n := max(len(ra), len(rb))
+	if n == 0 { return 1 }
+	return 1 - float64(levenshtein(ra, rb))/float64(n)
+}
+
+func levenshtein(a, b []rune) int {
+	prev, cur := make([]int, len(b)+1), make([]int, len(b)+1)
+	for j := range prev { prev[j] = j }
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] { cost = 0 }
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
+
+// normalizePhone keeps the digits of a number without leading zeros;
+// numbers of fewer than 6 digits are ignored. Score treats two numbers as
+// equal when one ends with the other, so country codes and trunk prefixes
+// do not matter ("+357 22 123456" and "22123456").
+func normalizePhone(tel string) string {
+	var d []rune
+	for _, r := range tel {
+		if r >= '0' && r <= '9' { d = append(d, r) }
+	}
+	n := strings.TrimLeft(string(d), "0")
+	if len(n) < 6 { return "" }
+	return n
+}
+
+// normalizeWebsite drops the scheme, a leading "www.", the query and
+// trailing slashes, and lower-cases the host.
+func normalizeWebsite(u string) string {
+	u = strings.TrimSpace(u)
+	if i := strings.Index(u, "://"); i >= 0 { u = u[i+3:] }
+	if i := strings.IndexAny(u, "?#"); i >= 0 { u = u[:i] }
+	u = strings.TrimRight(u, "/")
+	host, path, _ := strings.Cut(u, "/")
+	host = strings.TrimPrefix(strings.ToLower(host), "www.")
+	if path == "" { return host }
+	return host + "/" + path
+}
+
+func jaccard(a, b []string) float64 {
+	var common int
+	for _, x := range a {
+		if slices.Contains(b, x) { common++ }
+	}
+	union := len(a) + len(b) - common
+	if union == 0 { return 0 }
+	return math.Round(float64(common)/float64(union)*1000) / 1000
+}
+
+// Entry is one place of a candidate pair, named as it was when detected.
+type Entry struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+// Candidate is a pair of places that probably are one venue, waiting for a
+// reviewer to merge or dismiss it. A.ID sorts before B.ID.
+type Candidate struct {
+	A          Entry     `json:"a"`
+	B          Entry     `json:"b"`
+	Score      float64   `json:"score"`
+	Scores     Scores    `json:"scores"`
+	DistanceM  float64   `json:"distance_m"`
+	DetectedAt time.Time `json:"detected_at"`
+}
+
+// PairSeparator joins the IDs of a pair; it cannot occur in place IDs.
+const PairSeparator = "|"
+
+// PairID identifies the pair of a and b regardless of their order.
+func PairID(a, b string) string {
+	if b < a { a, b = b, a }
+	return a + PairSeparator + b
+}
+
+// NewCandidate orders the places of a pair by ID.
+func NewCandidate(a, b model.Place, distanceM float64, s Scores, at time.Time) Candidate {
+	if b.ID < a.ID { a, b = b, a }
+	return Candidate{
+		A:          Entry{ID: a.ID, Name: a.Name},
+		B:          Entry{ID: b.ID, Name: b.Name},
+		Score:      s.Total(),
+		Scores:     s,
+		DistanceM:  math.Round(distanceM*10) / 10,
+		DetectedAt: at,
+	}
+}
+
+// ID returns the PairID of c.
+func (c Candidate) ID() string { return PairID(c.A.ID, c.B.ID) }
+
+// Merge combines a duplicate into the place that survives it. Attributes
+// the winner has are kept; empty ones are taken from the loser. Categories
+// are the winner's followed by the loser's others; the loser's labels come
+// along only when both places label every category. The earlier creation
+// date is kept.
+func Merge(winner, loser model.Place) model.Place {
+	m := winner
+	for _, f := range []struct{ dst *string; src string }{
+		{&m.Address, loser.Address}, {&m.Locality, loser.Locality}, {&m.Region, loser.Region},
+		{&m.Postcode, loser.Postcode}, {&m.AdminRegion, loser.AdminRegion}, {&m.PostTown, loser.PostTown},
+		{&m.PoBox, loser.PoBox}, {&m.Country, loser.Country}, {&m.DateRefreshed, loser.DateRefreshed},
+		{&m.Tel, loser.Tel}, {&m.Website, loser.Website}, {&m.Email, loser.Email},
+		{&m.FacebookID, loser.FacebookID}, {&m.Instagram, loser.Instagram}, {&m.Twitter, loser.Twitter},
+		{&m.PlacemakerURL, loser.PlacemakerURL},
+	} {
+		if *f.dst == "" { *f.dst = f.src }
+	}
+	if loser.DateCreated != "" && (m.DateCreated == "" || loser.DateCreated < m.DateCreated) {
+		m.DateCreated = loser.DateCreated
+	}
+	if m.BBox.XMin == 0 && m.BBox.YMin == 0 && m.BBox.XMax == 0 && m.BBox.YMax == 0 { m.BBox = loser.BBox }
+
+	labelled := len(winner.CategoryLabels) == len(winner.CategoryIDs) && len(loser.CategoryLabels) == len(loser.CategoryIDs)
+	m.CategoryIDs = slices.Clone(winner.CategoryIDs)
+	if labelled { m.CategoryLabels = slices.Clone(winner.CategoryLabels) }
+	for i, c := range loser.CategoryIDs {
+		if slices.Contains(m.CategoryIDs, c) { continue }
+		m.CategoryIDs = append(m.CategoryIDs, c)
+		if labelled { m.CategoryLabels = append(m.CategoryLabels, loser.CategoryLabels[i]) }
+	}
+	return m
+}
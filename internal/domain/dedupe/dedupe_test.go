@@ -0,0 +1,79 @@
+package dedupe
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"redcat/internal/domain/model"
+)
+
+func TestNameSimilarity(t *testing.T) {
+	cases := []struct {
+		a, b     string
+		min, max float64
+	}{
+		{"Joe's Café", "JOES CAFE", 0.7, 0.9},
+		{"Joe's Café", "joe s café!", 1, 1},
+		{"Coffee Island", "Island Coffee", 1, 1},
+		{"Starbucks", "Starbuck", 0.85, 0.9},
+		{"Starbucks", "Pizza Hut", 0, 0.3},
+		{"", "Pizza Hut", 0, 0},
+	}
+	for _, c := range cases {
+		if got := NameSimilarity(c.a, c.b); got < c.min || got > c.max {
+			t.Errorf("%q ~ %q = %v, want [%v, %v]", c.a, c.b, got, c.min, c.max)
+		}
+	}
+}
+
+func TestScore(t *testing.T) {
+	a := model.Place{ID: "a", Name: "Kafeneio Zorbas", Tel: "+357 22 123456", Website: "https://www.zorbas.cy/", CategoryIDs: []string{"cafe", "bakery"}}
+	b := model.Place{ID: "b", Name: "Kafeneio Zorba", Tel: "22123456", Website: "http://zorbas.cy", CategoryIDs: []string{"cafe"}}
+
+	s := Score(a, b, 15)
+	if s.Phone == nil || *s.Phone != 1 || s.Website == nil || *s.Website != 1 { t.Fatalf("phone and website must match: %+v", s) }
+	if s.Categories == nil || *s.Categories != 0.5 { t.Fatalf("categories: %+v", s) }
+	if s.Distance != 0.9 { t.Fatalf("distance: %v", s.Distance) }
+	if !s.Duplicate() { t.Fatalf("want a duplicate, total %v", s.Total()) }
+
+	// missing attributes neither help nor hurt
+	bare := Score(model.Place{Name: a.Name}, model.Place{Name: b.Name}, 15)
+	if bare.Phone != nil || bare.Website != nil || bare.Categories != nil || !bare.Duplicate() {
+		t.Fatalf("bare pair: %+v total %v", bare, bare.Total())
+	}
+
+	// a different phone lowers the score, a different name rules it out
+	b.Tel = "22999999"
+	if Score(a, b, 15).Total() >= s.Total() { t.Fatal("conflicting phone must lower the score") }
+	b.Name = "Pharmacy Zorbas"
+	if Score(a, b, 15).Duplicate() { t.Fatal("different names are not duplicates") }
+	if Score(a, a, MaxDistanceM).Distance != 0 { t.Fatal("distance score must be 0 at MaxDistanceM") }
+}
+
+func TestCandidate_Ordered(t *testing.T) {
+	c := NewCandidate(model.Place{ID: "z", Name: "Z"}, model.Place{ID: "m", Name: "M"}, 12.345, Scores{Name: 1, Distance: 1}, time.Time{})
+	if c.A.ID != "m" || c.B.ID != "z" || c.A.Name != "M" || c.ID() != "m|z" || PairID("z", "m") != "m|z" || c.DistanceM != 12.3 {
+		t.Fatalf("%+v", c)
+	}
+}
+
+func TestMerge(t *testing.T) {
+	w := model.Place{ID: "w", Name: "Zorbas", Tel: "1", DateCreated: "2020-05-01",
+		CategoryIDs: []string{"cafe"}, CategoryLabels: []string{"Café"}}
+	l := model.Place{ID: "l", Name: "Zorba", Tel: "2", Website: "zorbas.cy", DateCreated: "2019-01-01",
+		CategoryIDs: []string{"bakery", "cafe"}, CategoryLabels: []string{"Bakery", "Café"}}
+	l.BBox.XMin = 1
+
+	m := Merge(w, l)
+	if m.ID != "w" || m.Name != "Zorbas" || m.Tel != "1" || m.Website != "zorbas.cy" || m.DateCreated != "2019-01-01" || m.BBox.XMin != 1 {
+		t.Fatalf("merged: %+v", m)
+	}
+	if !slices.Equal(m.CategoryIDs, []string{"cafe", "bakery"}) || !slices.Equal(m.CategoryLabels, []string{"Café", "Bakery"}) {
+		t.Fatalf("categories: %v %v", m.CategoryIDs, m.CategoryLabels)
+	}
+	if len(w.CategoryIDs) != 1 { t.Fatal("winner must not be modified") }
+
+	l.CategoryLabels = nil
+	if m := Merge(w, l); len(m.CategoryLabels) != 1 || len(m.CategoryIDs) != 2 { t.Fatalf("unlabelled loser keeps the winner's labels: %+v", m) }
+}
@@ -0,0 +1,86 @@
+// Package errs defines the typed errors shared by services and handlers.
+// Each error carries a Kind that the HTTP layer maps onto a status code and
+// the machine-readable code of the Error envelope in openapi.yaml.
+package errs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+)
+
+type Kind string
+
+const (
+	Internal           Kind = "INTERNAL"
+	NotFound           Kind = "NOT_FOUND"
+	InvalidRequest     Kind = "INVALID_REQUEST"
+	Conflict           Kind = "CONFLICT"
+	PreconditionFailed Kind = "PRECONDITION_FAILED"
+	BackendUnavailable Kind = "BACKEND_UNAVAILABLE"
+	Timeout            Kind = "TIMEOUT"
+	// Gone is for a resource that existed but was discarded, such as a
+	// change feed cursor whose events were trimmed.
+	Gone Kind = "GONE"
+	// Corrupted is an Internal failure caused by a stored record that
+	// cannot be decoded; it names the record instead of hiding it.
+	Corrupted Kind = "CORRUPTED_RECORD"
+)
+
+// Error is a classified error. Message is safe to show to clients; Err is
+// the underlying cause and is only logged.
+type Error struct {
+	Kind    Kind
+	Message string
+	Details map[string]any
+	Err     error
+}
+
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("%s: %s: %v", e.Kind, e.Message, e.Err)
+	}
+	return fmt.Sprintf("%s: %s", e.Kind, e.Message)
+}
+
+func (e *Error) Unwrap() error { return e.Err }
+
+// Is matches another *Error with the same Kind and Message, so package-level
+// sentinels built with New work with errors.Is.
+func (e *Error) Is(target error) bool {
+	t, ok := target.(*Error)
+	return ok && t.Kind == e.Kind && t.Message == e.Message
+}
+
+func New(kind Kind, msg string) *Error { return &Error{Kind: kind, Message: msg} }
+
+// Wrap classifies err under kind with a client-facing message.
+func Wrap(kind Kind, err error, msg string) *Error { return &Error{Kind: kind, Message: msg, Err: err} }
+
+// Invalid builds an INVALID_REQUEST error with optional details.
+func Invalid(msg string, details map[string]any) *Error {
+	return &Error{Kind: InvalidRequest, Message: msg, Details: details}
+}
+
+// KindOf reports the Kind of err: the first *Error in its chain, otherwise
+// context deadlines become Timeout and network failures BackendUnavailable.
+func KindOf(err error) Kind {
+	if err == nil { return "" }
+	var e *Error
+	if errors.As(err, &e) { return e.Kind }
+	if errors.Is(err, context.DeadlineExceeded) { return Timeout }
+	var ne net.Error
+	if errors.As(err, &ne) {
+		if ne.Timeout() { return Timeout }
+		return BackendUnavailable
+	}
+	return Internal
+}
+
+// As returns the first *Error in err's chain, or nil.
+func As(err error) *Error {
+	var e *Error
+	if errors.As(err, &e) { return e }
+	return nil
+}
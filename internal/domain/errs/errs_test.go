@@ -0,0 +1,36 @@
+package errs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"testing"
+)
+
+func TestKindOf(t *testing.T) {
+	notFound := New(NotFound, "place not found")
+	cases := []struct {
+		err  error
+		want Kind
+	}{
+		{nil, ""},
+		{notFound, NotFound},
+		{fmt.Errorf("get: %w", notFound), NotFound},
+		{Invalid("bad", nil), InvalidRequest},
+		{context.DeadlineExceeded, Timeout},
+		{&net.OpError{Op: "dial", Err: errors.New("connection refused")}, BackendUnavailable},
+		{errors.New("boom"), Internal},
+	}
+	for _, tc := range cases {
+		if got := KindOf(tc.err); got != tc.want {
+			t.Errorf("KindOf(%v) = %q, want %q", tc.err, got, tc.want)
+		}
+	}
+}
+
+func TestIs_Sentinel(t *testing.T) {
+	sentinel := New(NotFound, "place not found")
+	if !errors.Is(fmt.Errorf("wrap: %w", sentinel), sentinel) { t.Fatal("wrapped sentinel should match") }
+	if errors.Is(New(NotFound, "other"), sentinel) { t.Fatal("different message should not match") }
+}
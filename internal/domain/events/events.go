@@ -0,0 +1,24 @@
+// Package events defines the notifications the places service emits after
+// a mutation commits, consumed by the change feed and webhooks.
+package events
+
+import (
+	"time"
+
+	"redcat/internal/domain/audit"
+	"redcat/internal/domain/model"
+)
+
+// PlaceChanged reports one committed mutation. Place is the state after the
+// change and nil for deletes; Before is the prior state when known, used to
+// match subscriptions against where a place used to be.
+type PlaceChanged struct {
+	// ID is assigned by the feed the event was read from.
+	ID      string
+	PlaceID string
+	Action  audit.Action
+	At      time.Time
+	Version int64
+	Place   *model.Place
+	Before  *model.Place
+}
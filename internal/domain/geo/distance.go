@@ -0,0 +1,124 @@
+package geo
+
+import (
+	"errors"
+	"math"
+)
+
+// EarthRadiusM is the mean Earth radius used for spherical distances.
+const EarthRadiusM = 6371008.8
+
+// WGS84 ellipsoid parameters.
+const (
+	WGS84A = 6378137.0
+	WGS84F = 1 / 298.257223563
+	WGS84B = WGS84A * (1 - WGS84F)
+)
+
+// ErrNoConvergence is returned by Vincenty for (nearly) antipodal points
+// where the iteration does not settle.
+var ErrNoConvergence = errors.New("vincenty: no convergence")
+
+// DistanceMode selects how distances between two coordinates are computed.
+type DistanceMode string
+
+const (
+	// DistanceKNN converts the vector score returned by the KNN search.
+	DistanceKNN DistanceMode = "knn"
+	// DistanceHaversine uses the great-circle formula on the mean sphere.
+	DistanceHaversine DistanceMode = "haversine"
+	// DistanceEllipsoidal uses Vincenty's inverse formula on WGS84.
+	DistanceEllipsoidal DistanceMode = "ellipsoidal"
+)
+
+// Valid reports whether m is a known mode; the empty string means the default.
+func (m DistanceMode) Valid() bool {
+	switch m {
+	case "", DistanceKNN, DistanceHaversine, DistanceEllipsoidal:
+		return true
+	}
+	return false
+}
+
+func rad(d float64) float64 { return d * math.Pi / 180 }
+
+// ChordToMeters converts a straight-line distance between two points on the
+// unit sphere (as produced by ToECEF) into great-circle metres.
+func ChordToMeters(chord float64) float64 {
+	if chord <= 0 { return 0 }
+	if chord >= 2 { return math.Pi * EarthRadiusM }
+	return 2 * math.Asin(chord/2) * EarthRadiusM
+}
+
+// L2ScoreToMeters converts a KNN L2 score (squared Euclidean distance between
+// unit-sphere vectors) into great-circle metres.
+func L2ScoreToMeters(score float64) float64 {
+	if score <= 0 { return 0 }
+	return ChordToMeters(math.Sqrt(score))
+}
+
+// Haversine returns the great-circle distance in metres on the mean sphere.
+func Haversine(lat1, lon1, lat2, lon2 float64) float64 {
+	dlat := rad(lat2 - lat1)
+	dlon := rad(lon2 - lon1)
+	s1, s2 := math.Sin(dlat/2), math.Sin(dlon/2)
+	a := s1*s1 + math.Cos(rad(lat1))*math.Cos(rad(lat2))*s2*s2
+	return 2 * EarthRadiusM * math.Asin(math.Min(1, math.Sqrt(a)))
+}
+
+// Bearing returns the initial great-circle bearing from the first point to
+// the second in degrees clockwise from north, in [0, 360). It is 0 for
+// coincident points.
+func Bearing(lat1, lon1, lat2, lon2 float64) float64 {
+	phi1, phi2 := rad(lat1), rad(lat2)
+	dlon := rad(lon2 - lon1)
+	y := math.Sin(dlon) * math.Cos(phi2)
+	x := math.Cos(phi1)*math.Sin(phi2) - math.Sin(phi1)*math.Cos(phi2)*math.Cos(dlon)
+	if x == 0 && y == 0 { return 0 }
+	return math.Mod(math.Atan2(y, x)*180/math.Pi+360, 360)
+}
+
+// Vincenty returns the geodesic distance in metres on the WGS84 ellipsoid
+// using Vincenty's inverse formula (sub-millimetre accuracy).
+func Vincenty(lat1, lon1, lat2, lon2 float64) (float64, error) {
+	L := rad(lon2 - lon1)
+	U1 := math.Atan((1 - WGS84F) * math.Tan(rad(lat1)))
+	U2 := math.Atan((1 - WGS84F) * math.Tan(rad(lat2)))
+	sinU1, cosU1 := math.Sincos(U1)
+	sinU2, cosU2 := math.Sincos(U2)
+
+	lambda := L
+	var sinSigma, cosSigma, sigma, cos2Alpha, cos2SigmaM float64
+	for i := 0; ; i++ {
+		if i == 200 { return 0, ErrNoConvergence }
+		sinLambda, cosLambda := math.Sincos(lambda)
+		t1 := cosU2 * sinLambda
+		t2 := cosU1*sinU2 - sinU1*cosU2*cosLambda
+		sinSigma = math.Sqrt(t1*t1 + t2*t2)
+		if sinSigma == 0 { return 0, nil } // coincident points
+		cosSigma = sinU1*sinU2 + cosU1*cosU2*cosLambda
+		sigma = math.Atan2(sinSigma, cosSigma)
+		sinAlpha := cosU1 * cosU2 * sinLambda / sinSigma
+		cos2Alpha = 1 - sinAlpha*sinAlpha
+		cos2SigmaM = 0
+		if cos2Alpha != 0 { cos2SigmaM = cosSigma - 2*sinU1*sinU2/cos2Alpha } // equatorial line otherwise
+		C := WGS84F / 16 * cos2Alpha * (4 + WGS84F*(4-3*cos2Alpha))
+		prev := lambda
+		lambda = L + (1-C)*WGS84F*sinAlpha*(sigma+C*sinSigma*(cos2SigmaM+C*cosSigma*(-1+2*cos2SigmaM*cos2SigmaM)))
+		if math.Abs(lambda-prev) < 1e-12 { break }
+	}
+	u2 := cos2Alpha * (WGS84A*WGS84A - WGS84B*WGS84B) / (WGS84B * WGS84B)
+	A := 1 + u2/16384*(4096+u2*(-768+u2*(320-175*u2)))
+	B := u2 / 1024 * (256 + u2*(-128+u2*(74-47*u2)))
+	deltaSigma := B * sinSigma * (cos2SigmaM + B/4*(cosSigma*(-1+2*cos2SigmaM*cos2SigmaM)-
+		B/6*cos2SigmaM*(-3+4*sinSigma*sinSigma)*(-3+4*cos2SigmaM*cos2SigmaM)))
+	return WGS84B * A * (sigma - deltaSigma), nil
+}
+
+// Ellipsoidal is Vincenty with a haversine fallback for the rare antipodal
+// inputs where the iteration does not converge.
+func Ellipsoidal(lat1, lon1, lat2, lon2 float64) float64 {
+	d, err := Vincenty(lat1, lon1, lat2, lon2)
+	if err != nil { return Haversine(lat1, lon1, lat2, lon2) }
+	return d
+}
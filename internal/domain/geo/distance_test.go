@@ -0,0 +1,58 @@
+package geo
+
+import (
+	"math"
+	"testing"
+)
+
+func TestHaversine_KnownDistance(t *testing.T) {
+	// Nicosia -> Limassol, ~60.7 km on the mean sphere
+	d := Haversine(35.1753, 33.3642, 34.7071, 33.0226)
+	if !almost(d, 60662, 50) { t.Fatalf("got %f", d) }
+	if Haversine(1, 2, 1, 2) != 0 { t.Fatalf("zero distance expected") }
+}
+
+func TestBearing(t *testing.T) {
+	cases := []struct{ lat1, lon1, lat2, lon2, want float64 }{
+		{0, 0, 1, 0, 0},
+		{0, 0, 0, 1, 90},
+		{0, 0, -1, 0, 180},
+		{0, 0, 0, -1, 270},
+		{0, 179, 0, -179, 90}, // across the antimeridian
+		{1, 2, 1, 2, 0},
+	}
+	for _, c := range cases {
+		if got := Bearing(c.lat1, c.lon1, c.lat2, c.lon2); !almost(got, c.want, 1e-9) {
+			t.Errorf("Bearing(%v, %v, %v, %v) = %v, want %v", c.lat1, c.lon1, c.lat2, c.lon2, got, c.want)
+		}
+	}
+}
+
+func TestVincenty_Reference(t *testing.T) {
+	// Flinders Peak -> Buninyong, Vincenty's 1975 reference: 54972.271 m
+	d, err := Vincenty(-37.95103341666667, 144.42486788888889, -37.65282113888889, 143.92649552777777)
+	if err != nil { t.Fatalf("vincenty: %v", err) }
+	if !almost(d, 54972.271, 0.001) { t.Fatalf("got %.4f", d) }
+	if _, err := Vincenty(0, 0, 0.5, 179.7); err != ErrNoConvergence { t.Fatalf("want ErrNoConvergence, got %v", err) }
+}
+
+func TestL2ScoreToMeters_MatchesHaversine(t *testing.T) {
+	pairs := [][4]float64{
+		{35.1753, 33.3642, 35.1760, 33.3650},
+		{51.5074, -0.1278, 48.8566, 2.3522},
+		{0, 0, 0, 90},
+	}
+	for _, p := range pairs {
+		a, b := ToECEF(p[0], p[1]), ToECEF(p[2], p[3])
+		var score float64
+		for i := range a {
+			d := float64(a[i]) - float64(b[i])
+			score += d * d
+		}
+		got := L2ScoreToMeters(score)
+		want := Haversine(p[0], p[1], p[2], p[3])
+		// float32 vectors limit precision to roughly a metre
+		if math.Abs(got-want) > 1+want*1e-6 { t.Fatalf("%v: got %f want %f", p, got, want) }
+	}
+	if L2ScoreToMeters(4) != math.Pi*EarthRadiusM { t.Fatalf("antipodal mismatch") }
+}
@@ -0,0 +1,61 @@
+package geo
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// Geometry is a GeoJSON geometry object with its coordinates left raw.
+type Geometry struct {
+	Type        string          `json:"type"`
+	Coordinates json.RawMessage `json:"coordinates"`
+}
+
+// ParsePolygonal decodes a GeoJSON Polygon or MultiPolygon and validates it.
+// Positions may carry an altitude, which is dropped.
+func ParsePolygonal(g Geometry) (MultiPolygon, error) {
+	var m MultiPolygon
+	switch g.Type {
+	case "Polygon":
+		var c [][][]float64
+		if err := json.Unmarshal(g.Coordinates, &c); err != nil { return nil, fmt.Errorf("invalid Polygon coordinates: %w", err) }
+		p, err := polygonOf(c)
+		if err != nil { return nil, err }
+		m = MultiPolygon{p}
+	case "MultiPolygon":
+		var c [][][][]float64
+		if err := json.Unmarshal(g.Coordinates, &c); err != nil { return nil, fmt.Errorf("invalid MultiPolygon coordinates: %w", err) }
+		for _, pc := range c {
+			p, err := polygonOf(pc)
+			if err != nil { return nil, err }
+			m = append(m, p)
+		}
+	default:
+		return nil, fmt.Errorf("geometry type must be Polygon or MultiPolygon, got %q", g.Type)
+	}
+	return m, m.Validate()
+}
+
+func polygonOf(c [][][]float64) (Polygon, error) {
+	p := make(Polygon, 0, len(c))
+	for _, rc := range c {
+		r := make(Ring, 0, len(rc))
+		for _, pos := range rc {
+			if len(pos) < 2 { return nil, fmt.Errorf("position must have at least 2 numbers") }
+			r = append(r, Point{pos[0], pos[1]})
+		}
+		p = append(p, r)
+	}
+	return p, nil
+}
+
+// GeoJSON encodes m as a Polygon when it has a single polygon and as a
+// MultiPolygon otherwise.
+func (m MultiPolygon) GeoJSON() Geometry {
+	if len(m) == 1 {
+		c, _ := json.Marshal(m[0])
+		return Geometry{Type: "Polygon", Coordinates: c}
+	}
+	c, _ := json.Marshal(m)
+	return Geometry{Type: "MultiPolygon", Coordinates: c}
+}
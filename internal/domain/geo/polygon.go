@@ -0,0 +1,223 @@
+package geo
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+)
+
+// Point is a [lon, lat] position in degrees, the GeoJSON axis order.
+type Point [2]float64
+
+// Ring is a closed linear ring: at least four points, the last equal to the
+// first. An edge always takes the short way around in longitude, so rings
+// may cross the antimeridian; rings around a pole are not supported.
+type Ring []Point
+
+// Polygon is an outer ring followed by any number of holes.
+type Polygon []Ring
+
+// MultiPolygon is a set of polygons; a point inside any of them is inside.
+type MultiPolygon []Polygon
+
+// BBox is a lon/lat rectangle; XMin > XMax wraps across the antimeridian.
+type BBox struct {
+	XMin, YMin, XMax, YMax float64
+}
+
+// Contains reports whether the point lies in b, edges included.
+func (b BBox) Contains(lat, lon float64) bool {
+	if lat < b.YMin || lat > b.YMax { return false }
+	if b.XMin <= b.XMax { return lon >= b.XMin && lon <= b.XMax }
+	return lon >= b.XMin || lon <= b.XMax
+}
+
+// CrossesAntimeridian reports whether b wraps across longitude ±180.
+func (b BBox) CrossesAntimeridian() bool { return b.XMin > b.XMax }
+
+// Split returns b as boxes that do not cross the antimeridian: b itself, or
+// its eastern and western halves.
+func (b BBox) Split() []BBox {
+	if !b.CrossesAntimeridian() { return []BBox{b} }
+	return []BBox{{XMin: b.XMin, YMin: b.YMin, XMax: 180, YMax: b.YMax}, {XMin: -180, YMin: b.YMin, XMax: b.XMax, YMax: b.YMax}}
+}
+
+// CircleBounds returns the smallest box around the spherical circle of
+// radiusM metres centred on lat/lon. A circle reaching a pole spans all
+// longitudes.
+func CircleBounds(lat, lon, radiusM float64) BBox {
+	d := radiusM / EarthRadiusM
+	b := BBox{XMin: -180, YMin: math.Max(lat-d*180/math.Pi, -90), XMax: 180, YMax: math.Min(lat+d*180/math.Pi, 90)}
+	if b.YMin == -90 || b.YMax == 90 { return b }
+	// the meridians tangent to the circle touch it at its widest longitude
+	s := math.Sin(d) / math.Cos(rad(lat))
+	if s >= 1 { return b }
+	dLon := math.Asin(s) * 180 / math.Pi
+	b.XMin, b.XMax = normLon(lon-dLon), normLon(lon+dLon)
+	return b
+}
+
+// lonDelta returns b-a wrapped into [-180, 180).
+func lonDelta(a, b float64) float64 { return math.Mod(math.Mod(b-a+180, 360)+360, 360) - 180 }
+
+// normLon wraps a longitude into [-180, 180].
+func normLon(x float64) float64 {
+	if x >= -180 && x <= 180 { return x }
+	return lonDelta(0, x)
+}
+
+// unwrap returns the ring's longitudes made continuous, so an edge crossing
+// the antimeridian does not jump by 360; e.g. 170, -170 becomes 170, 190.
+func (r Ring) unwrap() []float64 {
+	lons := make([]float64, len(r))
+	if len(r) == 0 { return lons }
+	lons[0] = r[0][0]
+	for i := 1; i < len(r); i++ { lons[i] = lons[i-1] + lonDelta(r[i-1][0], r[i][0]) }
+	return lons
+}
+
+// Validate checks that r is a closed ring of valid coordinates that does
+// not wind around a pole.
+func (r Ring) Validate() error {
+	if len(r) < 4 { return errors.New("ring must have at least 4 positions") }
+	for i, p := range r {
+		if p[0] < -180 || p[0] > 180 || p[1] < -90 || p[1] > 90 {
+			return fmt.Errorf("position %d is out of range", i)
+		}
+	}
+	if r[0] != r[len(r)-1] { return errors.New("ring must be closed (first and last positions equal)") }
+	if lons := r.unwrap(); math.Abs(lons[len(lons)-1]-lons[0]) > 1e-9 {
+		return errors.New("ring must not encircle a pole")
+	}
+	return nil
+}
+
+// contains is an even-odd ray cast against the unwrapped ring; the point is
+// also tried shifted by ±360 so it meets rings unwrapped past ±180.
+func (r Ring) contains(lat, lon float64) bool {
+	lons := r.unwrap()
+	for _, x := range [3]float64{lon, lon + 360, lon - 360} {
+		in := false
+		for i, j := 0, len(r)-1; i < len(r); j, i = i, i+1 {
+			yi, yj := r[i][1], r[j][1]
+			if (yi > lat) == (yj > lat) { continue }
+			if x < lons[j]+(lat-yj)*(lons[i]-lons[j])/(yi-yj) { in = !in }
+		}
+		if in { return true }
+	}
+	return false
+}
+
+// area returns the unsigned area of the ring in square metres on a sphere of
+// radius WGS84A, the model common GeoJSON tools use.
+func (r Ring) area() float64 {
+	lons := r.unwrap()
+	var sum float64
+	for i := 0; i+1 < len(r); i++ {
+		sum += rad(lons[i+1]-lons[i]) * (2 + math.Sin(rad(r[i][1])) + math.Sin(rad(r[i+1][1])))
+	}
+	return math.Abs(sum * WGS84A * WGS84A / 2)
+}
+
+// Validate checks every ring of p.
+func (p Polygon) Validate() error {
+	if len(p) == 0 { return errors.New("polygon must have an outer ring") }
+	for i, r := range p {
+		if err := r.Validate(); err != nil { return fmt.Errorf("ring %d: %w", i, err) }
+	}
+	return nil
+}
+
+// Contains reports whether the point is inside the outer ring and outside
+// every hole.
+func (p Polygon) Contains(lat, lon float64) bool {
+	if len(p) == 0 || !p[0].contains(lat, lon) { return false }
+	for _, h := range p[1:] {
+		if h.contains(lat, lon) { return false }
+	}
+	return true
+}
+
+// Area returns the area of p in square metres, holes excluded.
+func (p Polygon) Area() float64 {
+	if len(p) == 0 { return 0 }
+	a := p[0].area()
+	for _, h := range p[1:] { a -= h.area() }
+	return math.Max(a, 0)
+}
+
+// span is the longitude interval of the outer ring, unwrapped so lo <= hi.
+func (p Polygon) span() (lo, hi float64) {
+	lons := p[0].unwrap()
+	lo, hi = lons[0], lons[0]
+	for _, x := range lons { lo, hi = math.Min(lo, x), math.Max(hi, x) }
+	return lo, hi
+}
+
+func (m MultiPolygon) Validate() error {
+	if len(m) == 0 { return errors.New("geometry must have at least one polygon") }
+	for i, p := range m {
+		if err := p.Validate(); err != nil { return fmt.Errorf("polygon %d: %w", i, err) }
+	}
+	return nil
+}
+
+func (m MultiPolygon) Contains(lat, lon float64) bool {
+	for _, p := range m {
+		if p.Contains(lat, lon) { return true }
+	}
+	return false
+}
+
+// Area returns the total area of m in square metres.
+func (m MultiPolygon) Area() float64 {
+	var a float64
+	for _, p := range m { a += p.Area() }
+	return a
+}
+
+// Bounds returns the smallest box around m. Polygons crossing the
+// antimeridian, or lying on both sides of it, give a box with XMin > XMax
+// when that is narrower than one spanning the prime meridian.
+func (m MultiPolygon) Bounds() BBox {
+	b := BBox{YMin: 90, YMax: -90}
+	type iv struct{ lo, hi float64 }
+	var ivs []iv
+	for _, p := range m {
+		if len(p) == 0 { continue }
+		for _, pt := range p[0] { b.YMin, b.YMax = math.Min(b.YMin, pt[1]), math.Max(b.YMax, pt[1]) }
+		lo, hi := p.span()
+		if hi-lo >= 360 { return BBox{XMin: -180, YMin: b.YMin, XMax: 180, YMax: b.YMax} }
+		// split at the antimeridian into intervals within [-180, 180]
+		lo, hi = normLon(lo), normLon(lo)+(hi-lo)
+		if hi > 180 {
+			ivs = append(ivs, iv{lo, 180}, iv{-180, hi - 360})
+		} else {
+			ivs = append(ivs, iv{lo, hi})
+		}
+	}
+	if len(ivs) == 0 { return BBox{} }
+
+	// merge, then leave out the widest longitude gap not covered by m
+	sort.Slice(ivs, func(i, j int) bool { return ivs[i].lo < ivs[j].lo })
+	merged := ivs[:1]
+	for _, v := range ivs[1:] {
+		last := &merged[len(merged)-1]
+		if v.lo <= last.hi {
+			last.hi = math.Max(last.hi, v.hi)
+		} else {
+			merged = append(merged, v)
+		}
+	}
+	first, last := merged[0], merged[len(merged)-1]
+	b.XMin, b.XMax = first.lo, last.hi
+	gap := first.lo + 360 - last.hi
+	for i := 1; i < len(merged); i++ {
+		if g := merged[i].lo - merged[i-1].hi; g > gap {
+			gap = g
+			b.XMin, b.XMax = merged[i].lo, merged[i-1].hi
+		}
+	}
+	return b
+}
@@ -0,0 +1,110 @@
+package geo
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+)
+
+func square(x0, y0, x1, y1 float64) Ring {
+	return Ring{{x0, y0}, {x1, y0}, {x1, y1}, {x0, y1}, {x0, y0}}
+}
+
+func TestPolygon_ContainsWithHole(t *testing.T) {
+	p := Polygon{square(0, 0, 10, 10), square(4, 4, 6, 6)}
+	cases := []struct {
+		lat, lon float64
+		want     bool
+	}{
+		{2, 2, true},
+		{5, 5, false},  // in the hole
+		{5, 11, false}, // east of the polygon
+		{-1, 5, false},
+	}
+	for _, c := range cases {
+		if got := p.Contains(c.lat, c.lon); got != c.want { t.Errorf("Contains(%v, %v) = %v, want %v", c.lat, c.lon, got, c.want) }
+	}
+}
+
+func TestPolygon_AntimeridianRing(t *testing.T) {
+	// Fiji-like box from 175E to 175W
+	p := Polygon{square(175, -20, -175, -10)}
+	if err := p.Validate(); err != nil { t.Fatalf("Validate: %v", err) }
+	for _, lon := range []float64{178, 180, -180, -178} {
+		if !p.Contains(-15, lon) { t.Errorf("Contains(-15, %v) = false, want true", lon) }
+	}
+	if p.Contains(-15, 0) || p.Contains(-15, 170) || p.Contains(-15, -170) {
+		t.Error("point outside the box reported inside")
+	}
+	b := MultiPolygon{p}.Bounds()
+	if b != (BBox{XMin: 175, YMin: -20, XMax: -175, YMax: -10}) || !b.CrossesAntimeridian() {
+		t.Errorf("Bounds = %+v", b)
+	}
+}
+
+func TestMultiPolygon_BoundsAcrossAntimeridian(t *testing.T) {
+	m := MultiPolygon{{square(170, 0, 172, 1)}, {square(-172, 2, -170, 3)}}
+	if b := m.Bounds(); b != (BBox{XMin: 170, YMin: 0, XMax: -170, YMax: 3}) {
+		t.Errorf("Bounds = %+v, want the 20 degree box across the antimeridian", b)
+	}
+	m = MultiPolygon{{square(-10, 0, -5, 1)}, {square(5, 0, 10, 1)}}
+	if b := m.Bounds(); b != (BBox{XMin: -10, YMin: 0, XMax: 10, YMax: 1}) {
+		t.Errorf("Bounds = %+v", b)
+	}
+}
+
+func TestCircleBounds(t *testing.T) {
+	// 1000 km around a point at 60N: the box edges are 1000 km away
+	b := CircleBounds(60, 10, 1e6)
+	if d := Haversine(60, 10, b.YMax, 10); math.Abs(d-1e6) > 1 { t.Errorf("north edge %v m away", d) }
+	// the widest point is slightly north of the centre latitude
+	lat := math.Asin(math.Sin(rad(60))/math.Cos(1e6/EarthRadiusM)) * 180 / math.Pi
+	if d := Haversine(60, 10, lat, b.XMax); math.Abs(d-1e6) > 1 { t.Errorf("east edge %v m away at lat %v", d, lat) }
+
+	b = CircleBounds(0, 179.9, 50000)
+	if !b.CrossesAntimeridian() || len(b.Split()) != 2 { t.Errorf("box across the antimeridian: %+v", b) }
+	if b := CircleBounds(89.9, 0, 50000); b.XMin != -180 || b.XMax != 180 || b.YMax != 90 {
+		t.Errorf("box over the pole: %+v", b)
+	}
+}
+
+func TestPolygon_Area(t *testing.T) {
+	// one degree square on the equator: R² · Δλ · sin(1°)
+	want := WGS84A * WGS84A * rad(1) * math.Sin(rad(1))
+	if got := (Polygon{square(0, 0, 1, 1)}).Area(); math.Abs(got-want)/want > 1e-9 {
+		t.Errorf("Area = %v, want %v", got, want)
+	}
+	// the same square across the antimeridian has the same area
+	if got := (Polygon{square(179.5, 0, -179.5, 1)}).Area(); math.Abs(got-want)/want > 1e-9 {
+		t.Errorf("antimeridian Area = %v, want %v", got, want)
+	}
+	withHole := Polygon{square(0, 0, 1, 1), square(0.25, 0.25, 0.75, 0.75)}
+	if got := withHole.Area(); got >= want*0.76 || got <= want*0.74 {
+		t.Errorf("Area with hole = %v, want about %v", got, want*0.75)
+	}
+}
+
+func TestRing_Validate(t *testing.T) {
+	bad := map[string]Ring{
+		"too short":  {{0, 0}, {1, 0}, {0, 0}},
+		"not closed": {{0, 0}, {1, 0}, {1, 1}, {0, 1}},
+		"range":      {{0, 0}, {181, 0}, {1, 1}, {0, 0}},
+		"pole":       {{0, 80}, {120, 80}, {-120, 80}, {0, 80}},
+	}
+	for name, r := range bad {
+		if r.Validate() == nil { t.Errorf("%s: Validate accepted %v", name, r) }
+	}
+}
+
+func TestParsePolygonal(t *testing.T) {
+	var g Geometry
+	_ = json.Unmarshal([]byte(`{"type":"Polygon","coordinates":[[[0,0,5],[1,0],[1,1],[0,1],[0,0,5]]]}`), &g)
+	m, err := ParsePolygonal(g)
+	if err != nil || len(m) != 1 || !m.Contains(0.5, 0.5) { t.Fatalf("ParsePolygonal = %v, %v", m, err) }
+	if out := m.GeoJSON(); out.Type != "Polygon" || string(out.Coordinates) != "[[[0,0],[1,0],[1,1],[0,1],[0,0]]]" {
+		t.Errorf("GeoJSON = %s %s", out.Type, out.Coordinates)
+	}
+	if _, err := ParsePolygonal(Geometry{Type: "Point", Coordinates: json.RawMessage(`[0,0]`)}); err == nil {
+		t.Error("ParsePolygonal accepted a Point")
+	}
+}
@@ -0,0 +1,141 @@
+package geo
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+)
+
+// DecodePolyline decodes an encoded polyline (the Google format) with the
+// given precision, 5 for most producers and 6 for OSRM/Valhalla.
+func DecodePolyline(s string, precision int) ([]Point, error) {
+	factor := math.Pow10(precision)
+	var (
+		out      []Point
+		lat, lon int64
+	)
+	for i := 0; i < len(s); {
+		var deltas [2]int64
+		for k := range deltas {
+			var result int64
+			for shift := uint(0); ; shift += 5 {
+				if i >= len(s) { return nil, errors.New("polyline is truncated") }
+				b := int64(s[i]) - 63
+				i++
+				if b < 0 || b > 63 || shift > 60 { return nil, fmt.Errorf("invalid polyline character at %d", i-1) }
+				result |= (b & 0x1f) << shift
+				if b < 0x20 { break }
+			}
+			if result&1 != 0 {
+				deltas[k] = ^(result >> 1)
+			} else {
+				deltas[k] = result >> 1
+			}
+		}
+		lat += deltas[0]
+		lon += deltas[1]
+		out = append(out, Point{float64(lon) / factor, float64(lat) / factor})
+	}
+	return out, nil
+}
+
+// ParseLineString decodes a GeoJSON LineString; altitudes are dropped.
+func ParseLineString(g Geometry) ([]Point, error) {
+	if g.Type != "LineString" { return nil, fmt.Errorf("geometry type must be LineString, got %q", g.Type) }
+	var c [][]float64
+	if err := json.Unmarshal(g.Coordinates, &c); err != nil { return nil, fmt.Errorf("invalid LineString coordinates: %w", err) }
+	out := make([]Point, 0, len(c))
+	for _, pos := range c {
+		if len(pos) < 2 { return nil, errors.New("position must have at least 2 numbers") }
+		out = append(out, Point{pos[0], pos[1]})
+	}
+	return out, nil
+}
+
+// ValidateLine checks that line has at least two positions, all in range.
+func ValidateLine(line []Point) error {
+	if len(line) < 2 { return errors.New("line must have at least 2 positions") }
+	for i, p := range line {
+		if p[0] < -180 || p[0] > 180 || p[1] < -90 || p[1] > 90 { return fmt.Errorf("position %d is out of range", i) }
+	}
+	return nil
+}
+
+func segLen(a, b Point) float64 { return Haversine(a[1], a[0], b[1], b[0]) }
+
+// LineLength returns the great-circle length of line in metres.
+func LineLength(line []Point) float64 {
+	var d float64
+	for i := 1; i < len(line); i++ { d += segLen(line[i-1], line[i]) }
+	return d
+}
+
+// intermediate returns the point at fraction f of the great circle from a to b.
+func intermediate(a, b Point, f float64) Point {
+	d := segLen(a, b) / EarthRadiusM
+	if d == 0 { return a }
+	phi1, lam1, phi2, lam2 := rad(a[1]), rad(a[0]), rad(b[1]), rad(b[0])
+	wa, wb := math.Sin((1-f)*d)/math.Sin(d), math.Sin(f*d)/math.Sin(d)
+	x := wa*math.Cos(phi1)*math.Cos(lam1) + wb*math.Cos(phi2)*math.Cos(lam2)
+	y := wa*math.Cos(phi1)*math.Sin(lam1) + wb*math.Cos(phi2)*math.Sin(lam2)
+	z := wa*math.Sin(phi1) + wb*math.Sin(phi2)
+	return Point{math.Atan2(y, x) * 180 / math.Pi, math.Atan2(z, math.Hypot(x, y)) * 180 / math.Pi}
+}
+
+// Densify inserts great-circle points so no segment of line exceeds maxM.
+func Densify(line []Point, maxM float64) []Point {
+	if len(line) == 0 { return nil }
+	out := []Point{line[0]}
+	for i := 1; i < len(line); i++ {
+		n := int(math.Ceil(segLen(line[i-1], line[i]) / maxM))
+		for k := 1; k < n; k++ { out = append(out, intermediate(line[i-1], line[i], float64(k)/float64(n))) }
+		out = append(out, line[i])
+	}
+	return out
+}
+
+// Sample returns points every stepM metres along line, from its first to
+// its last point inclusive.
+func Sample(line []Point, stepM float64) []Point {
+	if len(line) == 0 { return nil }
+	out := []Point{line[0]}
+	next := stepM // distance along line of the next sample
+	var walked float64
+	for i := 1; i < len(line); i++ {
+		l := segLen(line[i-1], line[i])
+		for l > 0 && next <= walked+l {
+			out = append(out, intermediate(line[i-1], line[i], (next-walked)/l))
+			next += stepM
+		}
+		walked += l
+	}
+	if last := line[len(line)-1]; out[len(out)-1] != last { out = append(out, last) }
+	return out
+}
+
+// LocateOnLine finds the point of line nearest to (lat, lon) and returns
+// its distance from the start of line along it and its distance to the
+// point, both in metres. Segments are treated as straight in a local
+// equirectangular projection, so line should be densified to segments of
+// a few kilometres.
+func LocateOnLine(line []Point, lat, lon float64) (alongM, offsetM float64) {
+	offsetM = math.Inf(1)
+	kx := math.Cos(rad(lat)) * rad(1) * EarthRadiusM
+	ky := rad(1) * EarthRadiusM
+	var walked float64
+	for i := 1; i < len(line); i++ {
+		a, b := line[i-1], line[i]
+		ax, ay := lonDelta(lon, a[0])*kx, (a[1]-lat)*ky
+		bx, by := lonDelta(lon, b[0])*kx, (b[1]-lat)*ky
+		dx, dy := bx-ax, by-ay
+		t := 0.0
+		if n := dx*dx + dy*dy; n > 0 { t = math.Max(0, math.Min(1, -(ax*dx+ay*dy)/n)) }
+		l := segLen(a, b)
+		if d := math.Hypot(ax+t*dx, ay+t*dy); d < offsetM {
+			offsetM, alongM = d, walked+t*l
+		}
+		walked += l
+	}
+	return alongM, offsetM
+}
@@ -0,0 +1,56 @@
+package geo
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestDecodePolyline(t *testing.T) {
+	// the example from the format documentation
+	got, err := DecodePolyline("_p~iF~ps|U_ulLnnqC_mqNvxq`@", 5)
+	if err != nil { t.Fatalf("DecodePolyline: %v", err) }
+	want := []Point{{-120.2, 38.5}, {-120.95, 40.7}, {-126.453, 43.252}}
+	if len(got) != len(want) { t.Fatalf("got %v, want %v", got, want) }
+	for i := range want {
+		if !almost(got[i][0], want[i][0], 1e-9) || !almost(got[i][1], want[i][1], 1e-9) { t.Errorf("point %d: got %v, want %v", i, got[i], want[i]) }
+	}
+	if _, err := DecodePolyline("_p~iF~ps|", 5); err == nil { t.Error("truncated polyline accepted") }
+}
+
+func TestParseLineString(t *testing.T) {
+	var g Geometry
+	_ = json.Unmarshal([]byte(`{"type":"LineString","coordinates":[[33,35,10],[33.1,35.1]]}`), &g)
+	line, err := ParseLineString(g)
+	if err != nil || len(line) != 2 || line[1] != (Point{33.1, 35.1}) { t.Fatalf("ParseLineString = %v, %v", line, err) }
+	if ValidateLine(line[:1]) == nil { t.Error("single-point line accepted") }
+}
+
+func TestSampleAndDensify(t *testing.T) {
+	line := []Point{{0, 0}, {1, 0}} // ~111 km along the equator
+	total := LineLength(line)
+	samples := Sample(line, 10000)
+	if len(samples) != 13 { t.Fatalf("Sample: got %d points, want 13 (every 10 km plus the end)", len(samples)) }
+	if d := segLen(samples[0], samples[1]); !almost(d, 10000, 1e-3) { t.Errorf("sample spacing = %v", d) }
+	if samples[12] != line[1] { t.Errorf("last sample = %v, want the end point", samples[12]) }
+
+	dense := Densify(line, 5000)
+	if !almost(LineLength(dense), total, 1e-3) { t.Errorf("Densify changed the length: %v vs %v", LineLength(dense), total) }
+	for i := 1; i < len(dense); i++ {
+		if segLen(dense[i-1], dense[i]) > 5000 { t.Fatalf("segment %d longer than 5 km", i) }
+	}
+}
+
+func TestLocateOnLine(t *testing.T) {
+	line := Densify([]Point{{0, 0}, {1, 0}, {1, 1}}, 5000)
+	along, off := LocateOnLine(line, 0.01, 0.5) // ~1.1 km north of the first leg's middle
+	if !almost(along, LineLength([]Point{{0, 0}, {0.5, 0}}), 5) || !almost(off, 1112, 5) {
+		t.Errorf("first leg: along=%v off=%v", along, off)
+	}
+	along, _ = LocateOnLine(line, 0.5, 1.02) // east of the second leg
+	if want := LineLength([]Point{{0, 0}, {1, 0}, {1, 0.5}}); !almost(along, want, 10) {
+		t.Errorf("second leg: along=%v, want %v", along, want)
+	}
+	if along, off := LocateOnLine(line, 0, -0.1); along != 0 || !almost(off, 11120, 10) {
+		t.Errorf("before the start: along=%v off=%v", along, off)
+	}
+}
@@ -0,0 +1,137 @@
+package tile
+
+import (
+	"math"
+	"sort"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// Extent is the coordinate range of a tile in Layer.Features.
+const Extent = 4096
+
+// Feature is a point in tile coordinates (see Tile.Point) with properties
+// of type string, int, int64, float64 or bool.
+type Feature struct {
+	X, Y  int
+	Props map[string]any
+}
+
+type Layer struct {
+	Name     string
+	Features []Feature
+}
+
+// Field numbers of the Mapbox Vector Tile 2.1 protobuf schema.
+const (
+	tileLayers = 3
+
+	layerName     = 1
+	layerFeatures = 2
+	layerKeys     = 3
+	layerValues   = 4
+	layerExtent   = 5
+	layerVersion  = 15
+
+	featureTags     = 2
+	featureType     = 3
+	featureGeometry = 4
+	geomPoint       = 1
+	cmdMoveTo       = 1
+
+	valueString = 1
+	valueDouble = 3
+	valueInt    = 4
+	valueBool   = 7
+)
+
+// EncodeMVT encodes layers as a vector tile of Extent. Properties are
+// written in key order, so equal layers always encode to equal bytes;
+// layers without features are left out.
+func EncodeMVT(layers ...Layer) []byte {
+	var b []byte
+	for _, l := range layers {
+		if len(l.Features) == 0 { continue }
+		b = protowire.AppendTag(b, tileLayers, protowire.BytesType)
+		b = protowire.AppendBytes(b, encodeLayer(l))
+	}
+	return b
+}
+
+func encodeLayer(l Layer) []byte {
+	var b, keys, values []byte
+	b = protowire.AppendTag(b, layerName, protowire.BytesType)
+	b = protowire.AppendString(b, l.Name)
+
+	keyIdx, valueIdx := map[string]uint64{}, map[any]uint64{}
+	for _, f := range l.Features {
+		names := make([]string, 0, len(f.Props))
+		for k := range f.Props { names = append(names, k) }
+		sort.Strings(names)
+		var tags []byte
+		for _, k := range names {
+			v := f.Props[k]
+			if i, ok := v.(int); ok { v = int64(i) }
+			enc, ok := encodeValue(v)
+			if !ok { continue }
+			vi, ok := valueIdx[v]
+			if !ok {
+				vi = uint64(len(valueIdx))
+				valueIdx[v] = vi
+				values = protowire.AppendTag(values, layerValues, protowire.BytesType)
+				values = protowire.AppendBytes(values, enc)
+			}
+			ki, ok := keyIdx[k]
+			if !ok {
+				ki = uint64(len(keyIdx))
+				keyIdx[k] = ki
+				keys = protowire.AppendTag(keys, layerKeys, protowire.BytesType)
+				keys = protowire.AppendString(keys, k)
+			}
+			tags = protowire.AppendVarint(protowire.AppendVarint(tags, ki), vi)
+		}
+
+		// one MoveTo of one point, parameters zigzag encoded
+		var geom []byte
+		geom = protowire.AppendVarint(geom, cmdMoveTo|1<<3)
+		geom = protowire.AppendVarint(geom, protowire.EncodeZigZag(int64(f.X)))
+		geom = protowire.AppendVarint(geom, protowire.EncodeZigZag(int64(f.Y)))
+
+		var fb []byte
+		if len(tags) > 0 {
+			fb = protowire.AppendTag(fb, featureTags, protowire.BytesType)
+			fb = protowire.AppendBytes(fb, tags)
+		}
+		fb = protowire.AppendTag(fb, featureType, protowire.VarintType)
+		fb = protowire.AppendVarint(fb, geomPoint)
+		fb = protowire.AppendTag(fb, featureGeometry, protowire.BytesType)
+		fb = protowire.AppendBytes(fb, geom)
+		b = protowire.AppendTag(b, layerFeatures, protowire.BytesType)
+		b = protowire.AppendBytes(b, fb)
+	}
+	b = append(append(b, keys...), values...)
+	b = protowire.AppendTag(b, layerExtent, protowire.VarintType)
+	b = protowire.AppendVarint(b, Extent)
+	b = protowire.AppendTag(b, layerVersion, protowire.VarintType)
+	return protowire.AppendVarint(b, 2)
+}
+
+// encodeValue encodes a property value as a Value message; ok is false for
+// unsupported types.
+func encodeValue(v any) (b []byte, ok bool) {
+	switch v := v.(type) {
+	case string:
+		b = protowire.AppendTag(b, valueString, protowire.BytesType)
+		return protowire.AppendString(b, v), true
+	case float64:
+		b = protowire.AppendTag(b, valueDouble, protowire.Fixed64Type)
+		return protowire.AppendFixed64(b, math.Float64bits(v)), true
+	case int64:
+		b = protowire.AppendTag(b, valueInt, protowire.VarintType)
+		return protowire.AppendVarint(b, uint64(v)), true
+	case bool:
+		b = protowire.AppendTag(b, valueBool, protowire.VarintType)
+		return protowire.AppendVarint(b, protowire.EncodeBool(v)), true
+	}
+	return nil, false
+}
@@ -0,0 +1,81 @@
+package tile
+
+import (
+	"fmt"
+	"testing"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// fields decodes one protobuf message into its fields in order: varints
+// as uint64, length-delimited fields as []byte.
+func fields(t *testing.T, b []byte) (out [][2]any) {
+	t.Helper()
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 { t.Fatalf("bad tag: %v", protowire.ParseError(n)) }
+		b = b[n:]
+		var v any
+		switch typ {
+		case protowire.VarintType:
+			v, n = protowire.ConsumeVarint(b)
+		case protowire.BytesType:
+			v, n = protowire.ConsumeBytes(b)
+		case protowire.Fixed64Type:
+			v, n = protowire.ConsumeFixed64(b)
+		default:
+			t.Fatalf("unexpected wire type %v", typ)
+		}
+		if n < 0 { t.Fatalf("bad field %d: %v", num, protowire.ParseError(n)) }
+		out = append(out, [2]any{int(num), v})
+		b = b[n:]
+	}
+	return out
+}
+
+func TestEncodeMVT(t *testing.T) {
+	b := EncodeMVT(
+		Layer{Name: "empty"},
+		Layer{Name: "places", Features: []Feature{
+			{X: 10, Y: 20, Props: map[string]any{"name": "A", "count": 2}},
+			{X: -3, Y: 4100, Props: map[string]any{"name": "B", "count": 2, "skipped": []string{"x"}}},
+		}},
+	)
+	tile := fields(t, b)
+	if len(tile) != 1 || tile[0][0] != tileLayers { t.Fatalf("want one layer, got %v", tile) }
+
+	var name string
+	var keys, values []string
+	var features [][][2]any
+	var extent, version uint64
+	for _, f := range fields(t, tile[0][1].([]byte)) {
+		switch f[0] {
+		case layerName:
+			name = string(f[1].([]byte))
+		case layerFeatures:
+			features = append(features, fields(t, f[1].([]byte)))
+		case layerKeys:
+			keys = append(keys, string(f[1].([]byte)))
+		case layerValues:
+			values = append(values, fmt.Sprint(fields(t, f[1].([]byte))))
+		case layerExtent:
+			extent = f[1].(uint64)
+		case layerVersion:
+			version = f[1].(uint64)
+		}
+	}
+	if name != "places" || extent != Extent || version != 2 || len(features) != 2 {
+		t.Fatalf("layer: name %q extent %d version %d features %d", name, extent, version, len(features))
+	}
+	if fmt.Sprint(keys) != "[count name]" || fmt.Sprint(values) != "[[[4 2]] [[1 [65]]] [[1 [66]]]]" {
+		t.Fatalf("keys %v values %v", keys, values)
+	}
+	// tags are key/value index pairs; the geometry is MoveTo(1) x y zigzagged
+	if got := fmt.Sprint(features[1]); got != "[[2 [0 0 1 2]] [3 1] [4 [9 5 136 64]]]" {
+		t.Fatalf("second feature: %s", got)
+	}
+	if a, b := EncodeMVT(Layer{Name: "l", Features: []Feature{{Props: map[string]any{"a": 1, "b": "x", "c": true, "d": 1.5}}}}),
+		EncodeMVT(Layer{Name: "l", Features: []Feature{{Props: map[string]any{"d": 1.5, "c": true, "b": "x", "a": 1}}}}); string(a) != string(b) {
+		t.Fatal("encoding depends on map order")
+	}
+}
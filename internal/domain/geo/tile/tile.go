@@ -0,0 +1,65 @@
+// Package tile is Web Mercator (XYZ) tile math and Mapbox Vector Tile
+// encoding for point features.
+package tile
+
+import (
+	"fmt"
+	"math"
+
+	"redcat/internal/domain/geo"
+)
+
+// MaxLat is the latitude where Web Mercator tiles end; tile maps cannot
+// show places beyond it.
+const MaxLat = 85.05112877980659
+
+// MaxZoom is the deepest zoom level whose tile coordinates fit an int on
+// every platform.
+const MaxZoom = 30
+
+// Tile is tile X, Y at zoom Z; Y grows southwards.
+type Tile struct {
+	Z, X, Y int
+}
+
+// At returns the tile at zoom z containing lat/lon. Latitudes beyond
+// ±MaxLat fall in the edge rows and longitude 180 in the last column.
+func At(lat, lon float64, z int) Tile {
+	fx, fy := project(lat, lon, z)
+	n := 1 << z
+	return Tile{Z: z, X: min(max(int(math.Floor(fx)), 0), n-1), Y: min(max(int(math.Floor(fy)), 0), n-1)}
+}
+
+// Valid reports whether t exists.
+func (t Tile) Valid() bool {
+	if t.Z < 0 || t.Z > MaxZoom { return false }
+	n := 1 << t.Z
+	return t.X >= 0 && t.X < n && t.Y >= 0 && t.Y < n
+}
+
+func (t Tile) String() string { return fmt.Sprintf("%d/%d/%d", t.Z, t.X, t.Y) }
+
+// Bounds returns the lon/lat box of t.
+func (t Tile) Bounds() geo.BBox {
+	n := float64(int(1) << t.Z)
+	return geo.BBox{XMin: float64(t.X)/n*360 - 180, YMin: rowLat(t.Y+1, n), XMax: float64(t.X+1)/n*360 - 180, YMax: rowLat(t.Y, n)}
+}
+
+// Point returns lat/lon in t's coordinate space: extent units from its
+// top-left corner, outside [0, extent) for points beyond its edges.
+func (t Tile) Point(lat, lon float64, extent int) (x, y int) {
+	fx, fy := project(lat, lon, t.Z)
+	return int(math.Round((fx - float64(t.X)) * float64(extent))), int(math.Round((fy - float64(t.Y)) * float64(extent)))
+}
+
+// project returns lat/lon in tile units at zoom z.
+func project(lat, lon float64, z int) (x, y float64) {
+	n := float64(int(1) << z)
+	lat = math.Max(math.Min(lat, MaxLat), -MaxLat) * math.Pi / 180
+	return (lon + 180) / 360 * n, (1 - math.Asinh(math.Tan(lat))/math.Pi) / 2 * n
+}
+
+// rowLat is the latitude of the northern edge of tile row y of n.
+func rowLat(y int, n float64) float64 {
+	return math.Atan(math.Sinh(math.Pi*(1-2*float64(y)/n))) * 180 / math.Pi
+}
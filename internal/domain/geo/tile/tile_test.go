@@ -0,0 +1,54 @@
+package tile
+
+import (
+	"math"
+	"testing"
+)
+
+func TestAt(t *testing.T) {
+	cases := []struct {
+		lat, lon float64
+		want     Tile
+	}{
+		{0, 0, Tile{0, 0, 0}},
+		{35.1753, 33.3642, Tile{10, 606, 404}},
+		{51.5074, -0.1278, Tile{16, 32744, 21792}},
+		{89, 180, Tile{3, 7, 0}},
+		{-89, -180, Tile{3, 0, 7}},
+	}
+	for _, tc := range cases {
+		got := At(tc.lat, tc.lon, tc.want.Z)
+		if got != tc.want { t.Errorf("At(%v, %v, %d) = %v, want %v", tc.lat, tc.lon, tc.want.Z, got, tc.want) }
+		if tc.lat > MaxLat || tc.lat < -MaxLat { continue }
+		if b := got.Bounds(); !b.Contains(tc.lat, tc.lon) {
+			t.Errorf("%v.Bounds() = %+v does not contain %v, %v", got, b, tc.lat, tc.lon)
+		}
+	}
+	if b := (Tile{}).Bounds(); math.Abs(b.YMax-MaxLat) > 1e-9 || b.XMin != -180 || b.XMax != 180 {
+		t.Fatalf("world tile: %+v", b)
+	}
+}
+
+func TestPoint(t *testing.T) {
+	tl := Tile{1, 1, 0}
+	cases := []struct {
+		lat, lon float64
+		x, y     int
+	}{
+		{MaxLat, 0, 0, 0},
+		{0, 180, 4096, 4096},
+		{0, 90, 2048, 4096},
+		{10, -10, -228, 3867},
+	}
+	for _, tc := range cases {
+		if x, y := tl.Point(tc.lat, tc.lon, 4096); x != tc.x || y != tc.y {
+			t.Errorf("Point(%v, %v) = %d, %d, want %d, %d", tc.lat, tc.lon, x, y, tc.x, tc.y)
+		}
+	}
+}
+
+func TestValid(t *testing.T) {
+	for tl, want := range map[Tile]bool{{0, 0, 0}: true, {2, 3, 3}: true, {2, 4, 0}: false, {-1, 0, 0}: false, {1, 0, -1}: false, {31, 0, 0}: false} {
+		if tl.Valid() != want { t.Errorf("%v.Valid() = %v", tl, !want) }
+	}
+}
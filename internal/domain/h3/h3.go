@@ -0,0 +1,272 @@
+// Package h3 indexes coordinates into H3 cells, the hexagonal grid the
+// offline balancer scripts used. Only point indexing is implemented, ported
+// from the reference C library (github.com/uber/h3, Apache License 2.0) so
+// the service keeps building without cgo; cell IDs match the library's.
+package h3
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+)
+
+// Cell is an H3 cell index.
+type Cell uint64
+
+// MaxResolution is the finest H3 resolution.
+const MaxResolution = 15
+
+const (
+	resOffset  = 52
+	baseOffset = 45
+	modeOffset = 59
+	digitBits  = 3
+	digitMask  = 7
+	cellMode   = 1
+	// initIndex has every digit set to 7, the marker of unused resolutions.
+	initIndex = 1<<45 - 1
+
+	sqrt7        = 2.6457513110645905905016157536392604257102
+	rsin60       = 1.1547005383792515290182975610039149112953
+	ap7RotRads   = 0.333473172251832115336090755351601070065900389
+	invRes0UGnom = 2.61803398874989588842
+	epsilon      = 1e-16
+	maxFaceCoord = 2
+)
+
+// String returns the canonical lowercase hexadecimal form.
+func (c Cell) String() string { return strconv.FormatUint(uint64(c), 16) }
+
+// Resolution returns the resolution of c.
+func (c Cell) Resolution() int { return int(c>>resOffset) & 15 }
+
+// Parse reads the hexadecimal form of a cell.
+func Parse(s string) (Cell, error) {
+	v, err := strconv.ParseUint(s, 16, 64)
+	if err != nil || v>>modeOffset&15 != cellMode { return 0, fmt.Errorf("h3: invalid cell %q", s) }
+	return Cell(v), nil
+}
+
+func (c Cell) digit(r int) int { return int(c>>((MaxResolution-r)*digitBits)) & digitMask }
+
+func (c *Cell) setDigit(r, d int) {
+	shift := (MaxResolution - r) * digitBits
+	*c = *c&^(digitMask<<shift) | Cell(d)<<shift
+}
+
+// FromLatLng returns the cell of resolution res containing the point, in
+// degrees. res must be within [0, MaxResolution].
+func FromLatLng(lat, lng float64, res int) Cell {
+	f := toFaceIJK(lat*math.Pi/180, lng*math.Pi/180, res)
+	return f.toCell(res)
+}
+
+// coordIJK is a hex coordinate on the three 120° axes i, j and k.
+type coordIJK struct{ i, j, k int }
+
+// unitVecs are the coordinates of the seven digits: the centre and the six
+// neighbour directions.
+var unitVecs = [7]coordIJK{{0, 0, 0}, {0, 0, 1}, {0, 1, 0}, {0, 1, 1}, {1, 0, 0}, {1, 0, 1}, {1, 1, 0}}
+
+const kAxesDigit = 1
+
+func (c *coordIJK) normalize() {
+	if c.i < 0 { c.j -= c.i; c.k -= c.i; c.i = 0 }
+	if c.j < 0 { c.i -= c.j; c.k -= c.j; c.j = 0 }
+	if c.k < 0 { c.i -= c.k; c.j -= c.k; c.k = 0 }
+	if m := min(c.i, c.j, c.k); m > 0 { c.i -= m; c.j -= m; c.k -= m }
+}
+
+// upAp7 moves to the aperture 7 parent of a Class III coordinate, upAp7r
+// to that of a Class II one.
+func (c *coordIJK) upAp7() {
+	i, j := c.i-c.k, c.j-c.k
+	*c = coordIJK{int(math.Round(float64(3*i-j) / 7)), int(math.Round(float64(i+2*j) / 7)), 0}
+	c.normalize()
+}
+
+func (c *coordIJK) upAp7r() {
+	i, j := c.i-c.k, c.j-c.k
+	*c = coordIJK{int(math.Round(float64(2*i+j) / 7)), int(math.Round(float64(3*j-i) / 7)), 0}
+	c.normalize()
+}
+
+// downAp7 is the centre child of c one resolution finer.
+func (c *coordIJK) downAp7(iv, jv, kv coordIJK) {
+	*c = coordIJK{
+		iv.i*c.i + jv.i*c.j + kv.i*c.k,
+		iv.j*c.i + jv.j*c.j + kv.j*c.k,
+		iv.k*c.i + jv.k*c.j + kv.k*c.k,
+	}
+	c.normalize()
+}
+
+func (c coordIJK) digit() int {
+	c.normalize()
+	for d, u := range unitVecs {
+		if c == u { return d }
+	}
+	return digitMask
+}
+
+type faceIJK struct {
+	face  int
+	coord coordIJK
+}
+
+// toFaceIJK projects the point (radians) gnomonically onto the nearest
+// icosahedron face and quantises it to a hex of resolution res.
+func toFaceIJK(lat, lng float64, res int) faceIJK {
+	cl := math.Cos(lat)
+	x, y, z := math.Cos(lng)*cl, math.Sin(lng)*cl, math.Sin(lat)
+	face, sqd := 0, 5.0
+	for f, fc := range faces {
+		if d := (fc.x-x)*(fc.x-x) + (fc.y-y)*(fc.y-y) + (fc.z-z)*(fc.z-z); d < sqd {
+			face, sqd = f, d
+		}
+	}
+	r := math.Acos(1 - sqd/2)
+	if r < epsilon { return faceIJK{face: face} }
+
+	fc := faces[face]
+	az := math.Atan2(math.Cos(lat)*math.Sin(lng-fc.lng), math.Cos(fc.lat)*math.Sin(lat)-math.Sin(fc.lat)*math.Cos(lat)*math.Cos(lng-fc.lng))
+	theta := posAngle(fc.az - posAngle(az))
+	if res%2 == 1 { theta = posAngle(theta - ap7RotRads) }
+	r = math.Tan(r) * invRes0UGnom
+	for i := 0; i < res; i++ { r *= sqrt7 }
+	return faceIJK{face: face, coord: hex2dToIJK(r*math.Cos(theta), r*math.Sin(theta))}
+}
+
+func posAngle(a float64) float64 {
+	t := a
+	if a < 0 { t = a + 2*math.Pi }
+	if a >= 2*math.Pi { t -= 2 * math.Pi }
+	return t
+}
+
+// hex2dToIJK returns the hex containing the planar point.
+func hex2dToIJK(x, y float64) coordIJK {
+	var h coordIJK
+	a1, a2 := math.Abs(x), math.Abs(y)
+	x2 := a2 * rsin60
+	x1 := a1 + x2/2
+	m1, m2 := int(x1), int(x2)
+	r1, r2 := x1-float64(m1), x2-float64(m2)
+
+	if r1 < 0.5 {
+		if r1 < 1.0/3 {
+			h.i = m1
+			if r2 < (1+r1)/2 { h.j = m2 } else { h.j = m2 + 1 }
+		} else {
+			if r2 < 1-r1 { h.j = m2 } else { h.j = m2 + 1 }
+			if 1-r1 <= r2 && r2 < 2*r1 { h.i = m1 + 1 } else { h.i = m1 }
+		}
+	} else {
+		if r1 < 2.0/3 {
+			if r2 < 1-r1 { h.j = m2 } else { h.j = m2 + 1 }
+			if 2*r1-1 < r2 && r2 < 1-r1 { h.i = m1 } else { h.i = m1 + 1 }
+		} else {
+			h.i = m1 + 1
+			if r2 < r1/2 { h.j = m2 } else { h.j = m2 + 1 }
+		}
+	}
+
+	// fold across the axes
+	if x < 0 {
+		if h.j%2 == 0 {
+			h.i -= 2 * (h.i - h.j/2)
+		} else {
+			h.i -= 2*(h.i-(h.j+1)/2) + 1
+		}
+	}
+	if y < 0 {
+		h.i -= (2*h.j + 1) / 2
+		h.j = -h.j
+	}
+	h.normalize()
+	return h
+}
+
+// unit vectors of a resolution in the next finer one, for Class III
+// (counter-clockwise) and Class II (clockwise) children
+var (
+	ap7I, ap7J, ap7K    = coordIJK{3, 0, 1}, coordIJK{1, 3, 0}, coordIJK{0, 1, 3}
+	ap7rI, ap7rJ, ap7rK = coordIJK{3, 1, 0}, coordIJK{0, 3, 1}, coordIJK{1, 0, 3}
+)
+
+// toCell builds the index digit by digit from res up to the base cell, then
+// rotates it into the base cell's orientation.
+func (f faceIJK) toCell(res int) Cell {
+	h := Cell(cellMode)<<modeOffset | Cell(res)<<resOffset | initIndex
+	ijk := &f.coord
+	for r := res - 1; r >= 0; r-- {
+		last := *ijk
+		var center coordIJK
+		if (r+1)%2 == 1 {
+			ijk.upAp7()
+			center = *ijk
+			center.downAp7(ap7I, ap7J, ap7K)
+		} else {
+			ijk.upAp7r()
+			center = *ijk
+			center.downAp7(ap7rI, ap7rJ, ap7rK)
+		}
+		h.setDigit(r+1, coordIJK{last.i - center.i, last.j - center.j, last.k - center.k}.digit())
+	}
+	if ijk.i > maxFaceCoord || ijk.j > maxFaceCoord || ijk.k > maxFaceCoord { return 0 }
+
+	bc := faceBaseCells[f.face][ijk.i][ijk.j][ijk.k]
+	h |= Cell(bc.cell) << baseOffset
+	pent, isPent := pentagons[bc.cell]
+	if !isPent {
+		for i := 0; i < bc.rot; i++ { h = h.rotate60ccw() }
+		return h
+	}
+	// pentagons have no k-axes sub-sequence; rotate out of it
+	if h.leadingDigit() == kAxesDigit {
+		if pent[0] == f.face || pent[1] == f.face {
+			h = h.rotate60cw()
+		} else {
+			h = h.rotate60ccw()
+		}
+	}
+	for i := 0; i < bc.rot; i++ { h = h.rotatePent60ccw() }
+	return h
+}
+
+func (c Cell) leadingDigit() int {
+	for r := 1; r <= c.Resolution(); r++ {
+		if d := c.digit(r); d != 0 { return d }
+	}
+	return 0
+}
+
+// digit rotations by 60° counter-clockwise and clockwise
+var (
+	ccwDigit = [7]int{0, 5, 3, 1, 6, 4, 2}
+	cwDigit  = [7]int{0, 3, 6, 2, 5, 1, 4}
+)
+
+func (c Cell) rotate60ccw() Cell {
+	for r := 1; r <= c.Resolution(); r++ { c.setDigit(r, ccwDigit[c.digit(r)]) }
+	return c
+}
+
+func (c Cell) rotate60cw() Cell {
+	for r := 1; r <= c.Resolution(); r++ { c.setDigit(r, cwDigit[c.digit(r)]) }
+	return c
+}
+
+// rotatePent60ccw rotates a pentagon index, skipping the deleted k-axes
+// sub-sequence.
+func (c Cell) rotatePent60ccw() Cell {
+	found := false
+	for r := 1; r <= c.Resolution(); r++ {
+		c.setDigit(r, ccwDigit[c.digit(r)])
+		if !found && c.digit(r) != 0 {
+			found = true
+			if c.leadingDigit() == kAxesDigit { c = c.rotate60ccw() }
+		}
+	}
+	return c
+}
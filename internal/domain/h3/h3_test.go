@@ -0,0 +1,58 @@
+package h3
+
+import (
+	"encoding/csv"
+	"errors"
+	"os"
+	"strconv"
+	"testing"
+)
+
+// Expected cells computed with the reference library.
+func TestFromLatLng(t *testing.T) {
+	cases := []struct {
+		lat, lng float64
+		res      int
+		want     string
+	}{
+		{35.1753, 33.3642, 5, "852da443fffffff"},
+		{35.1753, 33.3642, 8, "882da4425bfffff"},
+		{0, 0, 0, "8075fffffffffff"},
+		{90, 0, 7, "870326233ffffff"},
+		{-33.8688, 151.2093, 15, "8fbe0e35cbad0a8"},
+		{64.7, 10.53, 9, "89080000013ffff"}, // next to the pentagon of base cell 4
+		{-17, 179.9, 6, "869b43757ffffff"},
+	}
+	for _, c := range cases {
+		got := FromLatLng(c.lat, c.lng, c.res)
+		if got.String() != c.want { t.Errorf("FromLatLng(%v, %v, %d) = %s, want %s", c.lat, c.lng, c.res, got, c.want) }
+		if got.Resolution() != c.res { t.Errorf("%s: resolution %d, want %d", got, got.Resolution(), c.res) }
+		if p, err := Parse(c.want); err != nil || p != got { t.Errorf("Parse(%s) = %s, %v", c.want, p, err) }
+	}
+	if _, err := Parse("zz"); err == nil { t.Error("Parse accepted garbage") }
+}
+
+// TestFromLatLng_Reference checks the port against cells the reference
+// library computed for every resolution, around all twelve pentagons, on the
+// antimeridian and near the poles (testdata/gen.go).
+func TestFromLatLng_Reference(t *testing.T) {
+	f, err := os.Open("testdata/cells.csv")
+	if err != nil { t.Fatal(err) }
+	defer f.Close()
+	r := csv.NewReader(f)
+	r.Comment = '#'
+	rows, err := r.ReadAll()
+	if err != nil { t.Fatal(err) }
+	seen := map[int]bool{}
+	for _, row := range rows[1:] {
+		lat, err1 := strconv.ParseFloat(row[0], 64)
+		lng, err2 := strconv.ParseFloat(row[1], 64)
+		res, err3 := strconv.Atoi(row[2])
+		if err := errors.Join(err1, err2, err3); err != nil { t.Fatalf("row %v: %v", row, err) }
+		seen[res] = true
+		if got := FromLatLng(lat, lng, res).String(); got != row[3] {
+			t.Errorf("FromLatLng(%v, %v, %d) = %s, want %s", lat, lng, res, got, row[3])
+		}
+	}
+	if len(seen) != MaxResolution+1 { t.Fatalf("testdata covers %d resolutions", len(seen)) }
+}
@@ -0,0 +1,53 @@
+//go:build ignore
+
+// gen writes cells.csv with the reference library. It is not part of the
+// module; run it from a scratch module that requires
+// github.com/uber/h3-go/v4:
+//
+//	go run gen.go > cells.csv
+package main
+
+import (
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"strconv"
+
+	"github.com/uber/h3-go/v4"
+)
+
+func main() {
+	rng := rand.New(rand.NewPCG(44, 2026))
+	fmt.Println("# lat,lng,res,cell from github.com/uber/h3-go/v4 v4.4.0 (H3 4.x C library)")
+	fmt.Println("lat,lng,res,cell")
+	emit := func(lat, lng float64, res int) {
+		c, err := h3.LatLngToCell(h3.NewLatLng(lat, lng), res)
+		if err != nil { panic(err) }
+		fmt.Printf("%s,%s,%d,%s\n", strconv.FormatFloat(lat, 'g', -1, 64), strconv.FormatFloat(lng, 'g', -1, 64), res, c)
+	}
+	for res := 0; res <= 15; res++ {
+		// pentagon centres and points just off them, two pentagons per
+		// resolution so all twelve are covered
+		pents, err := h3.Pentagons(res)
+		if err != nil { panic(err) }
+		edge := 1107.712591 * math.Pow(7, -float64(res)/2) / 111.2 // edge length in degrees, roughly
+		for _, i := range []int{(2 * res) % 12, (2*res + 1) % 12} {
+			ll, _ := pents[i].LatLng()
+			emit(ll.Lat, ll.Lng, res)
+			emit(ll.Lat+edge*0.6, ll.Lng+edge*0.3, res)
+		}
+		// the antimeridian from both sides and on it
+		lat := rng.Float64()*160 - 80
+		emit(lat, 180, res)
+		emit(lat, -180, res)
+		emit(lat, 179.99999999, res)
+		emit(-lat, -179.99999999, res)
+		// near the poles
+		emit(89.9+rng.Float64()*0.1, rng.Float64()*360-180, res)
+		emit(-89.9-rng.Float64()*0.1, rng.Float64()*360-180, res)
+		// uniformly on the sphere
+		for range 12 {
+			emit(math.Asin(rng.Float64()*2-1)*180/math.Pi, rng.Float64()*360-180, res)
+		}
+	}
+}
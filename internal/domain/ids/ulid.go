@@ -0,0 +1,58 @@
+// Package ids generates place identifiers for records created without one.
+//
+// IDs are ULIDs: 48 bits of millisecond timestamp followed by 80 random bits,
+// Crockford base32 encoded to 26 characters. They sort lexicographically by
+// creation time and need no coordination between API replicas.
+package ids
+
+import (
+	"crypto/rand"
+	"io"
+	"time"
+)
+
+const alphabet = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// Len is the length of a generated ID.
+const Len = 26
+
+// New returns a ULID for the current time.
+func New() string {
+	id, err := NewAt(time.Now(), rand.Reader)
+	if err != nil {
+		// crypto/rand does not fail on supported platforms
+		panic("ids: " + err.Error())
+	}
+	return id
+}
+
+// NewAt returns a ULID for t using entropy from r.
+func NewAt(t time.Time, r io.Reader) (string, error) {
+	var b [16]byte
+	ms := uint64(t.UnixMilli())
+	for i := 5; i >= 0; i-- {
+		b[i] = byte(ms)
+		ms >>= 8
+	}
+	if _, err := io.ReadFull(r, b[6:]); err != nil { return "", err }
+	return encode(b), nil
+}
+
+// encode writes the 128-bit value as 26 base32 digits, most significant first.
+func encode(b [16]byte) string {
+	var out [Len]byte
+	// 130 bits of output; the top two bits are always zero.
+	var acc uint64
+	bits := 2
+	j := 0
+	for _, v := range b {
+		acc = acc<<8 | uint64(v)
+		bits += 8
+		for bits >= 5 {
+			bits -= 5
+			out[j] = alphabet[(acc>>uint(bits))&31]
+			j++
+		}
+	}
+	return string(out[:])
+}
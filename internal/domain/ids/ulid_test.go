@@ -0,0 +1,37 @@
+package ids
+
+import (
+	"bytes"
+	"sort"
+	"testing"
+	"time"
+)
+
+func TestNewAt_Encoding(t *testing.T) {
+	// Reference vector: all-zero entropy at the epoch and max values.
+	id, _ := NewAt(time.UnixMilli(0), bytes.NewReader(make([]byte, 10)))
+	if id != "00000000000000000000000000" { t.Fatalf("zero: got %s", id) }
+	id, _ = NewAt(time.UnixMilli(1<<48-1), bytes.NewReader(bytes.Repeat([]byte{0xff}, 10)))
+	if id != "7ZZZZZZZZZZZZZZZZZZZZZZZZZ" { t.Fatalf("max: got %s", id) }
+	// 1469922850259 ms from the ULID spec example 01ARZ3NDEKTSV4RRFFQ69G5FAV
+	id, _ = NewAt(time.UnixMilli(1469922850259), bytes.NewReader(make([]byte, 10)))
+	if id[:10] != "01ARZ3NDEK" { t.Fatalf("spec timestamp: got %s", id) }
+}
+
+func TestNew_SortableAndUnique(t *testing.T) {
+	seen := map[string]bool{}
+	var list []string
+	for i := 0; i < 1000; i++ {
+		id := New()
+		if len(id) != Len { t.Fatalf("len %d", len(id)) }
+		if seen[id] { t.Fatalf("duplicate %s", id) }
+		seen[id] = true
+		list = append(list, id)
+		if i%250 == 0 { time.Sleep(2 * time.Millisecond) }
+	}
+	sorted := append([]string(nil), list...)
+	sort.Strings(sorted)
+	if sorted[0][:10] != list[0][:10] || sorted[len(sorted)-1][:10] != list[len(list)-1][:10] {
+		t.Fatalf("ids not time ordered")
+	}
+}
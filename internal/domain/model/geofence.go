@@ -0,0 +1,12 @@
+package model
+
+import "redcat/internal/domain/geo"
+
+// Geofence is a named area. Properties are free-form attributes returned
+// with lookups, e.g. a delivery zone's fee tier.
+type Geofence struct {
+	ID         string
+	Name       string
+	Geometry   geo.MultiPolygon
+	Properties map[string]any
+}
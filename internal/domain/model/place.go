@@ -33,6 +33,9 @@ type Place struct {
 		YMax float64 `json:"ymax,omitempty"`
 	} `json:"bbox,omitempty"`
 	Dt string `json:"dt,omitempty"`
+	// Version is bumped by every write and backs the HTTP ETag; 0 for
+	// records written before versioning.
+	Version int64 `json:"version,omitempty"`
 }
 
 // PlaceDoc: storage representation for HSET; vector stored separately in `location`.
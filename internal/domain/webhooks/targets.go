@@ -0,0 +1,37 @@
+package webhooks
+
+import "net/netip"
+
+// nonPublic are the special-purpose ranges netip has no predicate for:
+// "this network", carrier-grade NAT, IETF protocol assignments, benchmarking,
+// documentation, reserved space (including broadcast) and NAT64, which
+// could embed any of the others.
+var nonPublic = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"),
+	netip.MustParsePrefix("192.0.0.0/24"),
+	netip.MustParsePrefix("192.0.2.0/24"),
+	netip.MustParsePrefix("198.18.0.0/15"),
+	netip.MustParsePrefix("198.51.100.0/24"),
+	netip.MustParsePrefix("203.0.113.0/24"),
+	netip.MustParsePrefix("240.0.0.0/4"),
+	netip.MustParsePrefix("64:ff9b::/96"),
+	netip.MustParsePrefix("64:ff9b:1::/48"),
+	netip.MustParsePrefix("2001:db8::/32"),
+}
+
+// PublicAddr reports whether deliveries may be sent to a: it is not
+// loopback, private (RFC 1918, fc00::/7), link-local (which holds cloud
+// metadata endpoints such as 169.254.169.254), multicast, unspecified or
+// another special-purpose address.
+func PublicAddr(a netip.Addr) bool {
+	a = a.Unmap()
+	if !a.IsValid() || a.IsLoopback() || a.IsPrivate() || a.IsUnspecified() || a.IsMulticast() ||
+		a.IsLinkLocalUnicast() || a.IsLinkLocalMulticast() || a.IsInterfaceLocalMulticast() {
+		return false
+	}
+	for _, p := range nonPublic {
+		if p.Contains(a) { return false }
+	}
+	return true
+}
@@ -0,0 +1,72 @@
+// Package webhooks describes partner subscriptions to place mutations and
+// the deliveries made for them.
+package webhooks
+
+import (
+	"encoding/json"
+	"slices"
+	"time"
+
+	"redcat/internal/domain/events"
+	"redcat/internal/domain/model"
+)
+
+// BBox is a lon/lat rectangle; XMin > XMax wraps across the antimeridian.
+type BBox struct {
+	XMin float64 `json:"xmin"`
+	YMin float64 `json:"ymin"`
+	XMax float64 `json:"xmax"`
+	YMax float64 `json:"ymax"`
+}
+
+func (b BBox) Contains(lat, lon float64) bool {
+	if lat < b.YMin || lat > b.YMax { return false }
+	if b.XMin <= b.XMax { return lon >= b.XMin && lon <= b.XMax }
+	return lon >= b.XMin || lon <= b.XMax
+}
+
+// Filter narrows a subscription; every set criterion must hold. An empty
+// filter matches everything.
+type Filter struct {
+	BBox        *BBox    `json:"bbox,omitempty"`
+	Countries   []string `json:"countries,omitempty"`
+	CategoryIDs []string `json:"category_ids,omitempty"`
+}
+
+// MatchPlace reports whether p satisfies every criterion of f.
+func (f Filter) MatchPlace(p model.Place) bool {
+	if f.BBox != nil && !f.BBox.Contains(p.Lat, p.Lon) { return false }
+	if len(f.Countries) > 0 && !slices.Contains(f.Countries, p.Country) { return false }
+	if len(f.CategoryIDs) > 0 && !slices.ContainsFunc(p.CategoryIDs, func(c string) bool { return slices.Contains(f.CategoryIDs, c) }) {
+		return false
+	}
+	return true
+}
+
+// Match reports whether ev concerns a place that matched f before or after
+// the change, so subscribers also hear about places leaving their area.
+func (f Filter) Match(ev events.PlaceChanged) bool {
+	if ev.Place != nil && f.MatchPlace(*ev.Place) { return true }
+	return ev.Before != nil && f.MatchPlace(*ev.Before)
+}
+
+type Subscription struct {
+	ID        string    `json:"id"`
+	URL       string    `json:"url"`
+	Secret    string    `json:"secret"`
+	Filter    Filter    `json:"filter"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Delivery is one payload owed to a subscription. Payload is sent verbatim
+// as the request body.
+type Delivery struct {
+	ID             string          `json:"id"`
+	SubscriptionID string          `json:"subscription_id"`
+	Event          string          `json:"event"`
+	Payload        json.RawMessage `json:"payload"`
+	Attempts       int             `json:"attempts"`
+	LastError      string          `json:"last_error,omitempty"`
+	CreatedAt      time.Time       `json:"created_at"`
+	NextAt         time.Time       `json:"next_at"`
+}
@@ -0,0 +1,54 @@
+package webhooks
+
+import (
+	"net/netip"
+	"testing"
+
+	"redcat/internal/domain/events"
+	"redcat/internal/domain/model"
+)
+
+func TestFilter_Match(t *testing.T) {
+	in := model.Place{Lat: 35.17, Lon: 33.36, Country: "CY", CategoryIDs: []string{"cafe", "bar"}}
+	out := model.Place{Lat: 51.5, Lon: -0.12, Country: "GB", CategoryIDs: []string{"cafe"}}
+	f := Filter{BBox: &BBox{XMin: 32, YMin: 34, XMax: 35, YMax: 36}, Countries: []string{"CY"}, CategoryIDs: []string{"bar"}}
+
+	if !f.MatchPlace(in) || f.MatchPlace(out) { t.Fatal("bbox/country/category filter") }
+	if (Filter{CategoryIDs: []string{"hotel"}}).MatchPlace(in) { t.Fatal("category filter must need an overlap") }
+	if !(Filter{}).MatchPlace(out) { t.Fatal("empty filter must match everything") }
+
+	// A place moving out of the area still notifies the subscriber.
+	if !f.Match(events.PlaceChanged{Place: &out, Before: &in}) { t.Fatal("before state must be matched") }
+	if !f.Match(events.PlaceChanged{Before: &in}) { t.Fatal("deletes match on the before state") }
+}
+
+func TestBBox_Antimeridian(t *testing.T) {
+	b := BBox{XMin: 170, YMin: -20, XMax: -170, YMax: 20}
+	if !b.Contains(0, 179) || !b.Contains(0, -179) || b.Contains(0, 0) { t.Fatal("wrapped bbox") }
+}
+
+func TestPublicAddr(t *testing.T) {
+	cases := map[string]bool{
+		"93.184.216.34":          true,
+		"2606:4700::1111":        true,
+		"127.0.0.1":              false,
+		"10.1.2.3":               false,
+		"172.16.0.1":             false,
+		"192.168.1.1":            false,
+		"169.254.169.254":        false,
+		"100.64.0.1":             false,
+		"0.0.0.0":                false,
+		"255.255.255.255":        false,
+		"::1":                    false,
+		"fd00::1":                false,
+		"fe80::1":                false,
+		"::ffff:127.0.0.1":       false,
+		"::ffff:169.254.169.254": false,
+		"64:ff9b::a9fe:a9fe":     false,
+	}
+	for addr, want := range cases {
+		if got := PublicAddr(netip.MustParseAddr(addr)); got != want {
+			t.Errorf("PublicAddr(%s) = %v, want %v", addr, got, want)
+		}
+	}
+}
@@ -0,0 +1,75 @@
+// Package geofences manages named polygons and answers which of them
+// contain a point.
+package geofences
+
+import (
+	"context"
+	"sort"
+
+	"redcat/internal/domain/ids"
+	"redcat/internal/domain/model"
+	"redcat/internal/storage/valkey"
+)
+
+// Storage errors re-exported so handlers don't import the storage package.
+var (
+	ErrNotFound = valkey.ErrGeofenceNotFound
+	ErrConflict = valkey.ErrGeofenceConflict
+)
+
+// Store persists fences; *valkey.GeofenceStorage implements it.
+type Store interface {
+	Create(ctx context.Context, f model.Geofence) error
+	Replace(ctx context.Context, f model.Geofence) error
+	Get(ctx context.Context, id string) (model.Geofence, error)
+	Delete(ctx context.Context, id string) error
+	Candidates(ctx context.Context, lat, lon float64) ([]model.Geofence, error)
+}
+
+type Service struct {
+	store Store
+}
+
+func New(store Store) *Service { return &Service{store: store} }
+
+// Create stores a new fence, generating an ID when f has none. It returns
+// ErrConflict when the ID is already taken.
+func (s *Service) Create(ctx context.Context, f model.Geofence) (model.Geofence, error) {
+	if f.ID == "" { f.ID = ids.New() }
+	if err := s.store.Create(ctx, f); err != nil { return model.Geofence{}, err }
+	return f, nil
+}
+
+// Replace overwrites the fence with f.ID; it returns ErrNotFound when there
+// is none.
+func (s *Service) Replace(ctx context.Context, f model.Geofence) (model.Geofence, error) {
+	if err := s.store.Replace(ctx, f); err != nil { return model.Geofence{}, err }
+	return f, nil
+}
+
+func (s *Service) Get(ctx context.Context, id string) (model.Geofence, error) {
+	return s.store.Get(ctx, id)
+}
+
+func (s *Service) Delete(ctx context.Context, id string) error {
+	return s.store.Delete(ctx, id)
+}
+
+// Lookup returns the fences containing the point, smallest area first so
+// the most specific zone leads.
+func (s *Service) Lookup(ctx context.Context, lat, lon float64) ([]model.Geofence, error) {
+	cands, err := s.store.Candidates(ctx, lat, lon)
+	if err != nil { return nil, err }
+	type hit struct {
+		f    model.Geofence
+		area float64
+	}
+	var hits []hit
+	for _, f := range cands {
+		if f.Geometry.Contains(lat, lon) { hits = append(hits, hit{f, f.Geometry.Area()}) }
+	}
+	sort.SliceStable(hits, func(i, j int) bool { return hits[i].area < hits[j].area })
+	out := make([]model.Geofence, len(hits))
+	for i, h := range hits { out[i] = h.f }
+	return out, nil
+}
@@ -0,0 +1,77 @@
+package places
+
+import (
+	"context"
+
+	"redcat/internal/domain/errs"
+	"redcat/internal/storage/valkey"
+)
+
+// ErrAliasNotFound is returned for IDs that are not aliases.
+var ErrAliasNotFound = valkey.ErrAliasNotFound
+
+// aliasHops bounds how many aliases MovedTo follows, e.g. for a place
+// merged into one that was re-keyed later.
+const aliasHops = 8
+
+// RegisterAliases maps old place IDs to the IDs the places live on as, e.g.
+// after an upstream dataset re-issued them. An alias only takes effect
+// while no place has the old ID. A batch closing a cycle, through its own
+// aliases or the stored ones, is rejected.
+func (s *Service) RegisterAliases(ctx context.Context, aliases map[string]string) error {
+	stored := map[string]string{}
+	next := func(id string) (string, error) {
+		if to, ok := aliases[id]; ok { return to, nil }
+		if to, ok := stored[id]; ok { return to, nil }
+		to, err := s.store.Alias(ctx, id)
+		if err != nil { return "", err }
+		stored[id] = to
+		return to, nil
+	}
+	for from, to := range aliases {
+		if from == to { return errs.Invalid("an id cannot be an alias of itself", map[string]any{"id": from}) }
+		// follow the chain from the new alias on; loops that do not pass
+		// through from are found from one of their own members, or predate
+		// the batch
+		seen := map[string]bool{from: true}
+		for id := to; id != "" && !seen[id]; {
+			seen[id] = true
+			n, err := next(id)
+			if err != nil { return err }
+			if n == from { return errs.Invalid("aliases form a cycle", map[string]any{"id": from}) }
+			id = n
+		}
+	}
+	return s.store.SetAliases(ctx, aliases)
+}
+
+// Alias returns the ID an alias ends at after following every hop, or
+// ErrAliasNotFound.
+func (s *Service) Alias(ctx context.Context, id string) (string, error) {
+	to, err := s.MovedTo(ctx, id)
+	if err != nil { return "", err }
+	if to == "" { return "", ErrAliasNotFound }
+	return to, nil
+}
+
+// DeleteAlias forgets an alias; it returns ErrAliasNotFound when id is none.
+func (s *Service) DeleteAlias(ctx context.Context, id string) error {
+	return s.store.DeleteAlias(ctx, id)
+}
+
+// MovedTo returns the ID that id, a merged or re-keyed place, now lives on
+// as, following chained aliases, or "" when id is not an alias. Aliases
+// looping back on themselves resolve to "".
+func (s *Service) MovedTo(ctx context.Context, id string) (string, error) {
+	seen := map[string]bool{id: true}
+	to := ""
+	for range aliasHops {
+		next, err := s.store.Alias(ctx, id)
+		if err != nil { return "", err }
+		if next == "" { break }
+		if seen[next] { return "", nil }
+		seen[next] = true
+		to, id = next, next
+	}
+	return to, nil
+}
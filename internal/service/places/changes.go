@@ -0,0 +1,92 @@
+package places
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"redcat/internal/domain/events"
+	"redcat/internal/storage/valkey"
+)
+
+// ErrCursorExpired is returned by Changes for a cursor the feed has trimmed
+// past; the consumer has to resync and start over.
+var ErrCursorExpired = valkey.ErrCursorExpired
+
+// changesPollInterval is how often Changes re-reads an idle feed while a
+// long-poll is waiting.
+const changesPollInterval = 200 * time.Millisecond
+
+// Changes returns up to limit mutations published after cursor ("" for the
+// oldest retained) and the cursor to resume from. When none are available
+// it waits up to wait for new ones before returning an empty page.
+// Events after cursor that were trimmed return ErrCursorExpired.
+func (s *Service) Changes(ctx context.Context, cursor string, limit int64, wait time.Duration) ([]events.PlaceChanged, string, error) {
+	if s.feed == nil { return nil, cursor, nil }
+	deadline := time.Now().Add(wait)
+	t := time.NewTicker(changesPollInterval)
+	defer t.Stop()
+	for {
+		evs, next, err := s.feed.Read(ctx, cursor, limit)
+		if err != nil || len(evs) > 0 || !time.Now().Before(deadline) {
+			return evs, next, err
+		}
+		select {
+		case <-ctx.Done():
+			return nil, next, nil
+		case <-t.C:
+		}
+	}
+}
+
+// relayBatch is the SCAN count of a relay sweep.
+const relayBatch = 500
+
+// relay moves the pending events of place id from its outbox to the feed.
+func (s *Service) relay(ctx context.Context, id string) {
+	if _, err := s.store.DrainOutbox(ctx, id, s.feed.Publish); err != nil {
+		slog.Error("relay place changes to feed failed",
+			slog.String("id", id),
+			slog.String("error", err.Error()),
+		)
+	}
+}
+
+// Relay sweeps every outbox with pending events, such as those a write
+// could not relay itself, into the change feed and returns how many events
+// it moved.
+func (s *Service) Relay(ctx context.Context) (int, error) {
+	if s.feed == nil { return 0, nil }
+	var n int
+	err := s.store.ScanOutboxes(ctx, relayBatch, func(ids []string) error {
+		for _, id := range ids {
+			m, err := s.store.DrainOutbox(ctx, id, s.feed.Publish)
+			n += m
+			if err != nil { return err }
+		}
+		return ctx.Err()
+	})
+	return n, err
+}
+
+// RunRelay calls Relay every interval until ctx is done. Failures are
+// logged and retried on the next tick.
+func (s *Service) RunRelay(ctx context.Context, interval time.Duration) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+		n, err := s.Relay(ctx)
+		if err != nil {
+			slog.Error("relay place changes to feed failed", slog.String("error", err.Error()))
+			continue
+		}
+		if n > 0 {
+			slog.Info("relayed pending place changes", slog.Int("count", n))
+		}
+	}
+}
@@ -0,0 +1,102 @@
+package places
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+
+	"redcat/internal/domain/errs"
+	"redcat/internal/domain/geo"
+	"redcat/internal/domain/geo/tile"
+	"redcat/internal/storage/valkey"
+)
+
+// Clusters at a zoom level are the Web Mercator tiles clusterGridShift
+// levels deeper, i.e. 64 px cells on 256 px tiles, so a cell is the same
+// whatever bbox it was requested with.
+const (
+	clusterGridShift = 2
+	// ClusterCellsMax bounds the grid cells one request may span.
+	ClusterCellsMax = 4096
+	// clusterPlaces and clusterCategories cap the representative places
+	// and the category breakdown of a cluster.
+	clusterPlaces     = 3
+	clusterCategories = 5
+)
+
+type ClusterParams struct {
+	BBox        geo.BBox
+	Zoom        int
+	CategoryIDs []string
+}
+
+// Cluster is the places of one grid cell, the tile Cell. Lat/Lon is their
+// mean position and PlaceIDs the places nearest it, nearest first.
+type Cluster struct {
+	Cell       tile.Tile
+	Count      int64
+	Lat, Lon   float64
+	PlaceIDs   []string
+	Categories []FacetCount
+}
+
+// Clusters groups the places in cp.BBox by grid cell, ordered north to
+// south, then west to east. Places beyond the latitudes Web Mercator maps
+// show are left out.
+func (s *Service) Clusters(ctx context.Context, cp ClusterParams) ([]Cluster, error) {
+	z := cp.Zoom + clusterGridShift
+	b := cp.BBox
+	b.YMin, b.YMax = math.Max(b.YMin, -tile.MaxLat), math.Min(b.YMax, tile.MaxLat)
+	if b.YMin > b.YMax { return nil, nil }
+
+	north, south := tile.At(b.YMax, 0, z).Y, tile.At(b.YMin, 0, z).Y
+	cols := 0
+	for _, part := range b.Split() { cols += tile.At(0, part.XMax, z).X - tile.At(0, part.XMin, z).X + 1 }
+	if cells := (south - north + 1) * cols; cells > ClusterCellsMax {
+		return nil, errs.Invalid(fmt.Sprintf("bbox spans %d cluster cells at this zoom, at most %d", cells, ClusterCellsMax),
+			map[string]any{"zoom": cp.Zoom, "cells": cells})
+	}
+
+	// band i of the grid is tile row south-i
+	edges := []float64{tile.Tile{Z: z, Y: south}.Bounds().YMin}
+	for y := south; y >= north; y-- { edges = append(edges, tile.Tile{Z: z, Y: y}.Bounds().YMax) }
+	grid, err := s.store.Grid(ctx, valkey.GridParams{
+		BBox: b, LatEdges: edges, ColWidth: 360 / float64(int(1)<<z), CategoryIDs: cp.CategoryIDs,
+		TopCategories: clusterCategories, ExcludeDeleted: s.softDelete,
+	})
+	if err != nil { return nil, err }
+
+	out := make([]Cluster, len(grid))
+	sps := make([]SearchParams, len(grid))
+	for i, g := range grid {
+		cell := tile.Tile{Z: z, X: g.Col, Y: south - g.Row}
+		out[i] = Cluster{Cell: cell, Count: g.Count, Lat: g.Lat, Lon: g.Lon, Categories: facetCounts(g.Categories)}
+		within := clip(cell.Bounds(), b)
+		sps[i] = SearchParams{Lat: g.Lat, Lon: g.Lon, Limit: min(g.Count, clusterPlaces), CategoryIDs: cp.CategoryIDs, Fields: []string{"id"}, Within: &within}
+	}
+	for i, r := range s.SearchNearestBatch(ctx, sps) {
+		if r.Err != nil { return nil, r.Err }
+		for _, hit := range r.Results { out[i].PlaceIDs = append(out[i].PlaceIDs, hit.Place.ID) }
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Cell.Y != out[j].Cell.Y { return out[i].Cell.Y < out[j].Cell.Y }
+		return out[i].Cell.X < out[j].Cell.X
+	})
+	return out, nil
+}
+
+// clip returns the part of tile t inside b; t lies on one side of the
+// antimeridian when b crosses it.
+func clip(t, b geo.BBox) geo.BBox {
+	t.YMin, t.YMax = math.Max(t.YMin, b.YMin), math.Min(t.YMax, b.YMax)
+	switch {
+	case !b.CrossesAntimeridian():
+		t.XMin, t.XMax = math.Max(t.XMin, b.XMin), math.Min(t.XMax, b.XMax)
+	case t.XMax > b.XMin:
+		t.XMin = math.Max(t.XMin, b.XMin)
+	default:
+		t.XMax = math.Min(t.XMax, b.XMax)
+	}
+	return t
+}
@@ -0,0 +1,78 @@
+package places
+
+import "context"
+
+// Diversify orders the results of a per-category search.
+type Diversify string
+
+const (
+	// DiversifyInterleave takes the nearest place of every category, then
+	// the second nearest of every category, and so on.
+	DiversifyInterleave Diversify = "interleave"
+	// DiversifyGrouped lists the places of each category together, in the
+	// order the categories were requested.
+	DiversifyGrouped Diversify = "grouped"
+)
+
+// Valid reports whether d is a known order; the empty string means the default.
+func (d Diversify) Valid() bool {
+	switch d {
+	case "", DiversifyInterleave, DiversifyGrouped:
+		return true
+	}
+	return false
+}
+
+// defaultSearchLimit mirrors the storage default for a search without limit.
+const defaultSearchLimit = 100
+
+// CategoryResult is a hit of a diversified search; CategoryID is the
+// requested category it was selected for.
+type CategoryResult struct {
+	SearchResult
+	CategoryID string
+}
+
+// SearchDiversified runs one KNN search of perCategory places for each of
+// sp.CategoryIDs, so a dense category cannot crowd out the others, and
+// merges them in the given order capped at sp.Limit. A place in several
+// requested categories is kept only for the first of them.
+func (s *Service) SearchDiversified(ctx context.Context, sp SearchParams, perCategory int64, order Diversify) ([]CategoryResult, error) {
+	sps := make([]SearchParams, len(sp.CategoryIDs))
+	for i, cat := range sp.CategoryIDs {
+		sps[i] = sp
+		sps[i].CategoryIDs, sps[i].Limit = []string{cat}, perCategory
+	}
+	groups := make([][]SearchResult, len(sps))
+	for i, r := range s.SearchNearestBatch(ctx, sps) {
+		if r.Err != nil { return nil, r.Err }
+		groups[i] = r.Results
+	}
+
+	limit := sp.Limit
+	if limit <= 0 { limit = defaultSearchLimit }
+	seen := map[string]bool{}
+	var out []CategoryResult
+	add := func(g, i int) bool {
+		r := groups[g][i]
+		if !seen[r.Place.ID] {
+			seen[r.Place.ID] = true
+			out = append(out, CategoryResult{SearchResult: r, CategoryID: sp.CategoryIDs[g]})
+		}
+		return int64(len(out)) < limit
+	}
+	if order == DiversifyGrouped {
+		for g := range groups {
+			for i := range groups[g] {
+				if !add(g, i) { return out, nil }
+			}
+		}
+		return out, nil
+	}
+	for i := int64(0); i < perCategory; i++ {
+		for g := range groups {
+			if i < int64(len(groups[g])) && !add(g, int(i)) { return out, nil }
+		}
+	}
+	return out, nil
+}
@@ -0,0 +1,166 @@
+package places
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"redcat/internal/domain/dedupe"
+	"redcat/internal/domain/errs"
+	"redcat/internal/domain/geo"
+	"redcat/internal/domain/model"
+	"redcat/internal/storage/valkey"
+	"redcat/internal/validate"
+)
+
+// ErrCandidateNotFound is returned by DismissDuplicate for pairs that are
+// not queued.
+var ErrCandidateNotFound = valkey.ErrCandidateNotFound
+
+// errNoDuplicateQueue is returned by the duplicate workflow when the
+// service was built without WithDuplicateQueue.
+var errNoDuplicateQueue = errors.New("no duplicate review queue configured")
+
+const (
+	// dedupeBatch is how many places one round of DetectDuplicates checks.
+	dedupeBatch = 100
+	// dedupeNeighbours is how many nearest places are compared to each place.
+	dedupeNeighbours = 10
+)
+
+// DuplicateQueue holds duplicate candidates awaiting review;
+// *valkey.DuplicateStorage implements it.
+type DuplicateQueue interface {
+	Add(ctx context.Context, cs []dedupe.Candidate) (int, error)
+	List(ctx context.Context, limit int64) ([]dedupe.Candidate, error)
+	Dismiss(ctx context.Context, a, b string) error
+	RemovePlace(ctx context.Context, id string) error
+}
+
+// WithDuplicateQueue enables DetectDuplicates and the review workflow,
+// keeping candidates in q.
+func WithDuplicateQueue(q DuplicateQueue) Option { return func(s *Service) { s.dupes = q } }
+
+// DedupeStats summarises one DetectDuplicates pass.
+type DedupeStats struct {
+	// Places is how many live places were compared to their neighbours.
+	Places int
+	// Queued is how many pairs were new to the review queue.
+	Queued int
+}
+
+// DetectDuplicates compares every place to its nearest neighbours within
+// dedupe.MaxDistanceM and queues the pairs that score as duplicates.
+// Queued pairs are rescored and dismissed ones are left alone, so passes
+// can be repeated.
+func (s *Service) DetectDuplicates(ctx context.Context) (DedupeStats, error) {
+	if s.dupes == nil { return DedupeStats{}, errNoDuplicateQueue }
+	var st DedupeStats
+	err := s.store.ScanIDs(ctx, dedupeBatch, func(ids []string) error {
+		locs, err := s.store.Locate(ctx, ids)
+		if err != nil { return err }
+		sps := make([]SearchParams, 0, len(locs))
+		srcIDs := make([]string, 0, len(locs))
+		for _, id := range ids {
+			p, ok := locs[id]
+			if !ok { continue }
+			sps = append(sps, SearchParams{
+				Lat: p.Lat, Lon: p.Lon, Limit: dedupeNeighbours + 1,
+				DistanceMode: geo.DistanceHaversine, Hydrate: true,
+			})
+			srcIDs = append(srcIDs, id)
+		}
+
+		now := s.now()
+		var cs []dedupe.Candidate
+		for i, r := range s.SearchNearestBatch(ctx, sps) {
+			if r.Err != nil { return r.Err }
+			src, ok := findPlace(r.Results, srcIDs[i])
+			if !ok { continue }
+			st.Places++
+			for _, h := range r.Results {
+				if h.Place.ID == src.ID || h.DistanceM > dedupe.MaxDistanceM { continue }
+				sc := dedupe.Score(src, h.Place, h.DistanceM)
+				if sc.Duplicate() { cs = append(cs, dedupe.NewCandidate(src, h.Place, h.DistanceM, sc, now)) }
+			}
+		}
+		n, err := s.dupes.Add(ctx, cs)
+		st.Queued += n
+		return err
+	})
+	return st, err
+}
+
+// findPlace picks a place out of the neighbours of its own location; it is
+// missing only when more than dedupeNeighbours places share the spot.
+func findPlace(res []SearchResult, id string) (model.Place, bool) {
+	for _, r := range res {
+		if r.Place.ID == id { return r.Place, true }
+	}
+	return model.Place{}, false
+}
+
+// RunDeduper calls DetectDuplicates every interval until ctx is done.
+// Failures are logged and retried on the next tick.
+func (s *Service) RunDeduper(ctx context.Context, interval time.Duration) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+		st, err := s.DetectDuplicates(ctx)
+		if err != nil {
+			slog.Error("detect duplicate places failed", slog.String("error", err.Error()))
+			continue
+		}
+		slog.Info("detected duplicate places", slog.Int("places", st.Places), slog.Int("queued", st.Queued))
+	}
+}
+
+// Duplicates returns the limit most likely duplicate pairs awaiting review.
+func (s *Service) Duplicates(ctx context.Context, limit int64) ([]dedupe.Candidate, error) {
+	if s.dupes == nil { return []dedupe.Candidate{}, nil }
+	return s.dupes.List(ctx, limit)
+}
+
+// DismissDuplicate marks the pair of a and b as distinct places; it is not
+// queued again.
+func (s *Service) DismissDuplicate(ctx context.Context, a, b string) error {
+	if s.dupes == nil { return ErrCandidateNotFound }
+	return s.dupes.Dismiss(ctx, a, b)
+}
+
+// Merge folds the place loserID into winnerID (see dedupe.Merge), deletes
+// the loser and makes its ID an alias of the winner. ifVersion applies to the
+// winner as in Update. Categories beyond the most a place may have are
+// dropped, the loser's first.
+func (s *Service) Merge(ctx context.Context, winnerID, loserID string, ifVersion int64) (model.Place, error) {
+	if winnerID == loserID { return model.Place{}, errs.Invalid("a place cannot be merged into itself", nil) }
+	loser, err := s.store.Get(ctx, loserID)
+	if err != nil { return model.Place{}, err }
+	p, err := s.Update(ctx, winnerID, ifVersion, func(p *model.Place) {
+		*p = dedupe.Merge(*p, loser)
+		if len(p.CategoryIDs) > validate.PlaceCategoriesMax { p.CategoryIDs = p.CategoryIDs[:validate.PlaceCategoriesMax] }
+		if len(p.CategoryLabels) > validate.PlaceCategoriesMax { p.CategoryLabels = p.CategoryLabels[:validate.PlaceCategoriesMax] }
+	})
+	if err != nil { return model.Place{}, err }
+
+	// alias before deleting, so the loser's ID never answers 404
+	if err := s.store.SetAliases(ctx, map[string]string{loserID: winnerID}); err != nil { return model.Place{}, err }
+	if err := s.Delete(ctx, loserID, AnyVersion); err != nil && !errors.Is(err, ErrNotFound) {
+		return model.Place{}, err
+	}
+	if s.dupes != nil {
+		if err := s.dupes.RemovePlace(ctx, loserID); err != nil {
+			slog.Error("drop merged place from duplicate queue failed",
+				slog.String("id", loserID),
+				slog.String("error", err.Error()),
+			)
+		}
+	}
+	return p, nil
+}
@@ -0,0 +1,42 @@
+package places
+
+import (
+	"context"
+	"slices"
+
+	"redcat/internal/domain/geo"
+	"redcat/internal/domain/model"
+)
+
+// exportBatch is how many places Export reads per round trip.
+const exportBatch = 500
+
+// ExportParams filters an export; the zero value exports every place.
+type ExportParams struct {
+	// BBox may cross the antimeridian.
+	BBox        *geo.BBox
+	CategoryIDs []string
+}
+
+func (ep ExportParams) matches(p model.Place) bool {
+	if ep.BBox != nil && !ep.BBox.Contains(p.Lat, p.Lon) { return false }
+	if len(ep.CategoryIDs) > 0 && !slices.ContainsFunc(p.CategoryIDs, func(c string) bool { return slices.Contains(ep.CategoryIDs, c) }) {
+		return false
+	}
+	return true
+}
+
+// Export calls fn with the live places matching ep, up to exportBatch at a
+// time and in no particular order, until all were visited or fn fails. It
+// scans the keyspace rather than the index, so it is not limited by search
+// result caps; places written during the export may be missed or visited
+// twice.
+func (s *Service) Export(ctx context.Context, ep ExportParams, fn func([]model.Place) error) error {
+	return s.store.ScanIDs(ctx, exportBatch, func(ids []string) error {
+		ps, err := s.store.GetMany(ctx, ids)
+		if err != nil { return err }
+		ps = slices.DeleteFunc(ps, func(p model.Place) bool { return !ep.matches(p) })
+		if len(ps) == 0 { return nil }
+		return fn(ps)
+	})
+}
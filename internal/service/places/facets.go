@@ -0,0 +1,80 @@
+package places
+
+import (
+	"context"
+
+	"redcat/internal/domain/geo"
+	"redcat/internal/storage/valkey"
+)
+
+// FacetParams selects the area to count: RadiusM metres around Lat/Lon,
+// or BBox when it is set.
+type FacetParams struct {
+	Lat, Lon, RadiusM float64
+	BBox              *geo.BBox
+	CategoryIDs       []string
+	Top               int64
+	// Labels resolves the category IDs of the result to labels.
+	Labels bool
+}
+
+// FacetCount is one bucket; Label is set for categories when requested and
+// known.
+type FacetCount struct {
+	Value string
+	Count int64
+	Label string
+}
+
+type Facets struct {
+	Total      int64
+	Categories []FacetCount
+	Countries  []FacetCount
+}
+
+// Facets counts the places in the area by category and by country, the
+// Top largest buckets of each.
+func (s *Service) Facets(ctx context.Context, fp FacetParams) (Facets, error) {
+	p := valkey.FacetParams{CategoryIDs: fp.CategoryIDs, Top: fp.Top, ExcludeDeleted: s.softDelete}
+	if fp.BBox != nil {
+		p.BBox = *fp.BBox
+	} else {
+		p.BBox, p.Lat, p.Lon, p.RadiusM = geo.CircleBounds(fp.Lat, fp.Lon, fp.RadiusM), fp.Lat, fp.Lon, fp.RadiusM
+	}
+	res, err := s.store.Facets(ctx, p)
+	if err != nil { return Facets{}, err }
+
+	out := Facets{Total: res.Total, Categories: facetCounts(res.Categories), Countries: facetCounts(res.Countries)}
+	if fp.Labels && len(out.Categories) > 0 {
+		ids := make([]string, len(out.Categories))
+		for i, c := range out.Categories { ids[i] = c.Value }
+		labels, err := s.store.CategoryLabels(ctx, ids)
+		if err != nil { return Facets{}, err }
+		for i := range out.Categories { out.Categories[i].Label = labels[out.Categories[i].Value] }
+	}
+	return out, nil
+}
+
+func facetCounts(in []valkey.FacetCount) []FacetCount {
+	out := make([]FacetCount, len(in))
+	for i, c := range in { out[i] = FacetCount{Value: c.Value, Count: c.Count} }
+	return out
+}
+
+// HeatmapCell is the number of places in an H3 cell.
+type HeatmapCell struct {
+	Cell  string
+	Count int64
+}
+
+// Heatmap counts the places in bbox per H3 cell of resolution res, at most
+// maxCells of the fullest cells; truncated reports that there were more.
+func (s *Service) Heatmap(ctx context.Context, bbox geo.BBox, res int, categoryIDs []string, maxCells int64) (cells []HeatmapCell, truncated bool, err error) {
+	counts, truncated, err := s.store.Heatmap(ctx, valkey.HeatmapParams{
+		BBox: bbox, Res: res, CategoryIDs: categoryIDs, MaxCells: maxCells, ExcludeDeleted: s.softDelete,
+	})
+	if err != nil { return nil, false, err }
+	cells = make([]HeatmapCell, len(counts))
+	for i, c := range counts { cells[i] = HeatmapCell{Cell: c.Value, Count: c.Count} }
+	return cells, truncated, nil
+}
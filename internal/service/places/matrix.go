@@ -0,0 +1,75 @@
+package places
+
+import (
+	"context"
+	"slices"
+
+	"redcat/internal/domain/errs"
+	"redcat/internal/domain/geo"
+)
+
+// MatrixPoint is an origin or destination: a place ID or a coordinate.
+// PlaceID wins when both are set.
+type MatrixPoint struct {
+	PlaceID  string
+	Lat, Lon float64
+}
+
+// MatrixCell is the distance and initial bearing from an origin to a
+// destination.
+type MatrixCell struct {
+	DistanceM  float64
+	BearingDeg float64
+}
+
+// Matrix holds the resolved points and Cells[origin][destination].
+type Matrix struct {
+	Origins, Destinations []MatrixPoint
+	Cells                 [][]MatrixCell
+}
+
+// DistanceMatrix resolves place IDs to their coordinates and computes the
+// distance between every origin and destination with mode, which must be
+// haversine or ellipsoidal (empty means haversine). Unknown place IDs fail
+// the whole request with a NOT_FOUND error listing them.
+func (s *Service) DistanceMatrix(ctx context.Context, origins, destinations []MatrixPoint, mode geo.DistanceMode) (Matrix, error) {
+	var ids []string
+	seen := make(map[string]struct{})
+	for _, p := range slices.Concat(origins, destinations) {
+		if p.PlaceID == "" { continue }
+		if _, ok := seen[p.PlaceID]; ok { continue }
+		seen[p.PlaceID] = struct{}{}
+		ids = append(ids, p.PlaceID)
+	}
+	if len(ids) > 0 {
+		found, err := s.store.Locate(ctx, ids)
+		if err != nil { return Matrix{}, err }
+		var missing []string
+		for _, id := range ids {
+			if _, ok := found[id]; !ok { missing = append(missing, id) }
+		}
+		if len(missing) > 0 {
+			return Matrix{}, &errs.Error{Kind: errs.NotFound, Message: "places not found", Details: map[string]any{"place_ids": missing}}
+		}
+		resolve := func(pts []MatrixPoint) []MatrixPoint {
+			out := slices.Clone(pts)
+			for i, p := range out {
+				if p.PlaceID != "" { out[i].Lat, out[i].Lon = found[p.PlaceID].Lat, found[p.PlaceID].Lon }
+			}
+			return out
+		}
+		origins, destinations = resolve(origins), resolve(destinations)
+	}
+
+	dist := geo.Haversine
+	if mode == geo.DistanceEllipsoidal { dist = geo.Ellipsoidal }
+	m := Matrix{Origins: origins, Destinations: destinations, Cells: make([][]MatrixCell, len(origins))}
+	for i, o := range origins {
+		row := make([]MatrixCell, len(destinations))
+		for j, d := range destinations {
+			row[j] = MatrixCell{DistanceM: dist(o.Lat, o.Lon, d.Lat, d.Lon), BearingDeg: geo.Bearing(o.Lat, o.Lon, d.Lat, d.Lon)}
+		}
+		m.Cells[i] = row
+	}
+	return m, nil
+}
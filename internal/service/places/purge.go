@@ -0,0 +1,38 @@
+package places
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+// purgeBatch is how many tombstones one storage round trip handles.
+const purgeBatch = 100
+
+// Purge permanently removes places soft-deleted more than retention ago and
+// returns how many were removed.
+func (s *Service) Purge(ctx context.Context, retention time.Duration) (int, error) {
+	return s.store.PurgeDeleted(ctx, s.now().Add(-retention), purgeBatch)
+}
+
+// RunPurger calls Purge every interval until ctx is done. Failures are
+// logged and retried on the next tick.
+func (s *Service) RunPurger(ctx context.Context, retention, interval time.Duration) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+		n, err := s.Purge(ctx, retention)
+		if err != nil {
+			slog.Error("purge deleted places failed", slog.String("error", err.Error()))
+			continue
+		}
+		if n > 0 {
+			slog.Info("purged deleted places", slog.Int("count", n))
+		}
+	}
+}
@@ -0,0 +1,112 @@
+package places
+
+import (
+	"context"
+	"math"
+	"strings"
+
+	"redcat/internal/domain/errs"
+	"redcat/internal/domain/geo"
+	"redcat/internal/domain/model"
+)
+
+// Reverse geocoding lets the reverseNeighbours places nearest the point,
+// within reverseMaxDistanceM, vote on each address component. A vote weighs
+// reverseScaleM/(reverseScaleM+d) for a place d metres away.
+const (
+	reverseNeighbours   = 25
+	reverseMaxDistanceM = 25000
+	reverseScaleM       = 500
+)
+
+var reverseFields = []string{"id", "location", "address", "locality", "region", "postcode", "country"}
+
+// ErrNoAddressData is returned by Reverse when no nearby place has address
+// data.
+var ErrNoAddressData = errs.New(errs.NotFound, "no places with address data near this location")
+
+// ReverseComponent is the inferred value of an address component.
+// Confidence, in [0, 1], is the share of the vote weight the value won,
+// scaled down by the distance of the nearest place having it.
+type ReverseComponent struct {
+	Value      string
+	Confidence float64
+}
+
+// ReverseResult is the address inferred for a point; components no
+// neighbour has are empty. Address is the street address of the nearest
+// place having one.
+type ReverseResult struct {
+	Address                             string
+	AddressPlaceID                      string
+	AddressDistanceM                    float64
+	Locality, Region, Postcode, Country ReverseComponent
+	// Neighbours is the number of places that voted.
+	Neighbours int
+}
+
+// Reverse infers the address of lat/lon from the nearest places with
+// address data. Places without any are filtered in the query, so they
+// cannot crowd the neighbours out.
+func (s *Service) Reverse(ctx context.Context, lat, lon float64) (ReverseResult, error) {
+	r := s.SearchNearestBatch(ctx, []SearchParams{{
+		Lat: lat, Lon: lon, Limit: reverseNeighbours, DistanceMode: geo.DistanceHaversine, Fields: reverseFields, HasAddress: true,
+	}})[0]
+	if r.Err != nil { return ReverseResult{}, r.Err }
+
+	var hits []SearchResult
+	for _, h := range r.Results {
+		if h.DistanceM > reverseMaxDistanceM { break }
+		hits = append(hits, h)
+	}
+	if len(hits) == 0 { return ReverseResult{}, ErrNoAddressData }
+
+	out := ReverseResult{
+		Locality:   vote(hits, func(p model.Place) string { return p.Locality }),
+		Region:     vote(hits, func(p model.Place) string { return p.Region }),
+		Postcode:   vote(hits, func(p model.Place) string { return p.Postcode }),
+		Country:    vote(hits, func(p model.Place) string { return p.Country }),
+		Neighbours: len(hits),
+	}
+	for _, h := range hits {
+		if a := strings.TrimSpace(h.Place.Address); a != "" {
+			out.Address, out.AddressPlaceID, out.AddressDistanceM = a, h.Place.ID, h.DistanceM
+			break
+		}
+	}
+	return out, nil
+}
+
+// vote picks the value of field with the most vote weight among hits,
+// which are ordered by distance. Values differing only in case are one
+// value, spelled as the nearest place spells it; ties go to the value whose
+// nearest place is nearer.
+func vote(hits []SearchResult, field func(model.Place) string) ReverseComponent {
+	type tally struct {
+		value    string
+		weight   float64
+		nearestM float64
+	}
+	var order []string
+	tallies := map[string]*tally{}
+	total := 0.0
+	for _, h := range hits {
+		v := strings.TrimSpace(field(h.Place))
+		if v == "" { continue }
+		w := reverseScaleM / (reverseScaleM + h.DistanceM)
+		total += w
+		k := strings.ToLower(v)
+		if tallies[k] == nil {
+			tallies[k] = &tally{value: v, nearestM: h.DistanceM}
+			order = append(order, k)
+		}
+		tallies[k].weight += w
+	}
+	if len(order) == 0 { return ReverseComponent{} }
+	best := tallies[order[0]]
+	for _, k := range order[1:] {
+		if t := tallies[k]; t.weight > best.weight { best = t }
+	}
+	conf := best.weight / total * reverseScaleM / (reverseScaleM + best.nearestM)
+	return ReverseComponent{Value: best.value, Confidence: math.Round(conf*100) / 100}
+}
@@ -0,0 +1,90 @@
+package places
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"slices"
+	"sort"
+
+	"redcat/internal/domain/errs"
+	"redcat/internal/domain/geo"
+	"redcat/internal/domain/model"
+)
+
+// Corridor search samples the route every BufferM metres and runs one KNN
+// search of routeCircleLimit places per sample, routeBatchSize searches at
+// a time. Routes are densified to routeSegmentM so projecting places onto
+// them stays accurate.
+const (
+	routeCircleLimit = 100
+	routeSegmentM    = 2000
+	routeBatchSize   = 100
+	// RouteSamplesMax bounds the searches one request may run: the route
+	// may be at most RouteSamplesMax*BufferM long.
+	RouteSamplesMax = 2000
+)
+
+type RouteParams struct {
+	Line        []geo.Point
+	BufferM     float64
+	CategoryIDs []string
+	Limit       int64
+	Fields      []string
+	Hydrate     bool
+}
+
+// RouteResult is a place near the route: AlongM is the distance from the
+// start of the route to the route point nearest the place and OffsetM the
+// distance from there to the place. DetourM approximates the extra travel
+// as going there and back.
+type RouteResult struct {
+	Place   model.Place
+	AlongM  float64
+	OffsetM float64
+	DetourM float64
+}
+
+// AlongRoute returns the places within rp.BufferM of the route, ordered by
+// position along it and cut to rp.Limit. complete is false when a search
+// circle was saturated (all routeCircleLimit hits inside its radius), so
+// dense areas may hold places that were not found.
+func (s *Service) AlongRoute(ctx context.Context, rp RouteParams) (res []RouteResult, complete bool, err error) {
+	line := geo.Densify(rp.Line, routeSegmentM)
+	samples := geo.Sample(line, rp.BufferM)
+	if len(samples) > RouteSamplesMax {
+		length := geo.LineLength(line)
+		return nil, false, errs.Invalid(fmt.Sprintf("route needs %d search circles for this buffer_m, at most %d", len(samples), RouteSamplesMax),
+			map[string]any{"length_m": math.Round(length), "buffer_m": rp.BufferM, "samples": len(samples),
+				"max_samples": RouteSamplesMax, "min_buffer_m": math.Ceil(length / RouteSamplesMax)})
+	}
+	// samples are at most BufferM apart, so every corridor point is within
+	// this radius of one of them
+	radius := math.Hypot(rp.BufferM/2, rp.BufferM)
+
+	sps := make([]SearchParams, len(samples))
+	for i, p := range samples {
+		sps[i] = SearchParams{Lat: p[1], Lon: p[0], Limit: routeCircleLimit, CategoryIDs: rp.CategoryIDs, Fields: rp.Fields, Hydrate: rp.Hydrate}
+	}
+	complete = true
+	seen := map[string]bool{}
+	// chunks keep the searches in flight, and the results held, bounded on
+	// long routes
+	for chunk := range slices.Chunk(sps, routeBatchSize) {
+		if err := ctx.Err(); err != nil { return nil, false, err }
+		for _, r := range s.SearchNearestBatch(ctx, chunk) {
+			if r.Err != nil { return nil, false, r.Err }
+			if n := len(r.Results); n == routeCircleLimit && r.Results[n-1].DistanceM < radius { complete = false }
+			for _, hit := range r.Results {
+				if seen[hit.Place.ID] { continue }
+				seen[hit.Place.ID] = true
+				along, off := geo.LocateOnLine(line, hit.Place.Lat, hit.Place.Lon)
+				if off > rp.BufferM { continue }
+				res = append(res, RouteResult{Place: hit.Place, AlongM: along, OffsetM: off, DetourM: 2 * off})
+			}
+		}
+	}
+	sort.SliceStable(res, func(i, j int) bool { return res[i].AlongM < res[j].AlongM })
+	if rp.Limit > 0 && int64(len(res)) > rp.Limit { res = res[:rp.Limit] }
+	return res, complete, nil
+}
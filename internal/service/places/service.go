@@ -2,32 +2,273 @@ package places
 
 import (
 	"context"
+	"errors"
+	"log/slog"
+	"time"
+	"redcat/internal/domain/audit"
+	"redcat/internal/domain/events"
+	"redcat/internal/domain/geo"
+	"redcat/internal/domain/ids"
 	"redcat/internal/domain/model"
 	"redcat/internal/storage/valkey"
 )
 
+// Storage errors re-exported so handlers don't import the storage package.
+var (
+	ErrNotFound  = valkey.ErrNotFound
+	ErrCorrupted = valkey.ErrCorrupted
+	ErrConflict  = valkey.ErrConflict
+
+	ErrPreconditionFailed = valkey.ErrPreconditionFailed
+	ErrNotDeleted         = valkey.ErrNotDeleted
+)
+
+// AnyVersion disables the version check of Update and Delete.
+const AnyVersion = valkey.AnyVersion
+
+// updateAttempts bounds the read-modify-write retries of an unconditional
+// Update that loses a race with another writer.
+const updateAttempts = 3
+
+// ValidateFields checks a projection for Get/SearchNearest; it returns a
+// *valkey.UnknownFieldError naming the unsupported entries.
+func ValidateFields(fields []string) error {
+	_, err := valkey.ResolveFields(fields)
+	return err
+}
+
+// Store is the persistence used by Service; *valkey.PlacesStorage implements it.
+type Store interface {
+	Upsert(ctx context.Context, p model.Place) (int64, model.Place, error)
+	Create(ctx context.Context, p model.Place) (int64, error)
+	Replace(ctx context.Context, p model.Place, ifVersion int64) (int64, error)
+	Get(ctx context.Context, id string, fields ...string) (model.Place, error)
+	Locate(ctx context.Context, ids []string) (map[string]model.Place, error)
+	GetMany(ctx context.Context, ids []string) ([]model.Place, error)
+	Delete(ctx context.Context, id string, ifVersion int64) (model.Place, error)
+	SoftDelete(ctx context.Context, id string, ifVersion int64, at time.Time) (model.Place, error)
+	Restore(ctx context.Context, id string) (int64, error)
+	PurgeDeleted(ctx context.Context, cutoff time.Time, batch int64) (int, error)
+	SearchNearest(ctx context.Context, sp valkey.SearchParams) ([]valkey.SearchResult, error)
+	SearchNearestBatch(ctx context.Context, sps []valkey.SearchParams) []valkey.BatchResult
+	Facets(ctx context.Context, fp valkey.FacetParams) (valkey.Facets, error)
+	CategoryLabels(ctx context.Context, ids []string) (map[string]string, error)
+	Heatmap(ctx context.Context, hp valkey.HeatmapParams) ([]valkey.FacetCount, bool, error)
+	Grid(ctx context.Context, gp valkey.GridParams) ([]valkey.GridCell, error)
+	ScanIDs(ctx context.Context, batch int64, fn func(ids []string) error) error
+	SetAliases(ctx context.Context, aliases map[string]string) error
+	Alias(ctx context.Context, id string) (string, error)
+	DeleteAlias(ctx context.Context, id string) error
+	DrainOutbox(ctx context.Context, id string, publish func(context.Context, events.PlaceChanged) error) (int, error)
+	ScanOutboxes(ctx context.Context, batch int64, fn func(ids []string) error) error
+}
+
+// HistoryStore keeps the audit trail; *valkey.HistoryStorage implements it.
+type HistoryStore interface {
+	Append(ctx context.Context, e audit.Entry) error
+	List(ctx context.Context, id, before string, limit int64) ([]audit.Entry, string, error)
+}
+
+// Publisher is notified of every committed mutation.
+type Publisher interface {
+	Publish(ctx context.Context, ev events.PlaceChanged) error
+}
+
+// ChangeFeed is a Publisher whose events can be read back in order;
+// *valkey.ChangeFeed implements it. It is fed from the outboxes the store
+// writes along with every mutation, not by emit.
+type ChangeFeed interface {
+	Publisher
+	Read(ctx context.Context, cursor string, limit int64) ([]events.PlaceChanged, string, error)
+}
+
 type Service struct {
-	store *valkey.PlacesStorage
+	store      Store
+	history    HistoryStore
+	feed       ChangeFeed
+	publishers []Publisher
+	dupes      DuplicateQueue
+	softDelete bool
+	now        func() time.Time
+}
+
+// Option configures a Service.
+type Option func(*Service)
+
+// WithSoftDelete makes Delete flag places as deleted instead of removing
+// them; see Restore and RunPurger.
+func WithSoftDelete() Option { return func(s *Service) { s.softDelete = true } }
+
+// WithHistory records an audit entry for every mutation in h.
+func WithHistory(h HistoryStore) Option { return func(s *Service) { s.history = h } }
+
+// WithPublisher sends every mutation to p.
+func WithPublisher(p Publisher) Option {
+	return func(s *Service) { s.publishers = append(s.publishers, p) }
+}
+
+// WithChangeFeed relays every mutation from the store's outbox to f and
+// serves Changes from it; see RunRelay.
+func WithChangeFeed(f ChangeFeed) Option { return func(s *Service) { s.feed = f } }
+
+func New(store Store, opts ...Option) *Service {
+	s := &Service{store: store, now: time.Now}
+	for _, o := range opts { o(s) }
+	return s
+}
+
+// Create stores a new place, generating an ID when p has none. It returns
+// ErrConflict when the ID is already taken.
+func (s *Service) Create(ctx context.Context, p model.Place) (model.Place, error) {
+	if p.ID == "" { p.ID = ids.New() }
+	v, err := s.store.Create(ctx, p)
+	if err != nil { return model.Place{}, err }
+	p.Version = v
+	s.emit(ctx, audit.Create, p.ID, model.Place{}, p)
+	return p, nil
+}
+
+// Upsert stores p, overwriting any existing place with the same ID.
+func (s *Service) Upsert(ctx context.Context, p model.Place) (model.Place, error) {
+	v, before, err := s.store.Upsert(ctx, p)
+	if err != nil { return model.Place{}, err }
+	p.Version = v
+	action := audit.Update
+	if before.ID == "" { action = audit.Create }
+	s.emit(ctx, action, p.ID, before, p)
+	return p, nil
+}
+
+// Get loads a place; fields optionally limits which attributes are read.
+func (s *Service) Get(ctx context.Context, id string, fields ...string) (model.Place, error) {
+	return s.store.Get(ctx, id, fields...)
+}
+
+// Update loads the place, applies fn and writes the result back only if no
+// other write happened in between. With ifVersion set the stored version
+// must match it, otherwise ErrPreconditionFailed is returned; with AnyVersion
+// a lost race is retried on fresh data. It returns ErrNotFound when the
+// place does not exist.
+func (s *Service) Update(ctx context.Context, id string, ifVersion int64, fn func(*model.Place)) (model.Place, error) {
+	for attempt := 1; ; attempt++ {
+		p, err := s.store.Get(ctx, id)
+		if err != nil { return model.Place{}, err }
+		expect := ifVersion
+		if expect == AnyVersion { expect = p.Version }
+		if p.Version != expect { return model.Place{}, ErrPreconditionFailed }
+		before := p
+		fn(&p)
+		p.ID = id
+		v, err := s.store.Replace(ctx, p, expect)
+		if errors.Is(err, ErrPreconditionFailed) && ifVersion == AnyVersion && attempt < updateAttempts {
+			continue
+		}
+		if err != nil { return model.Place{}, err }
+		p.Version = v
+		s.emit(ctx, audit.Update, id, before, p)
+		return p, nil
+	}
 }
 
-func New(store *valkey.PlacesStorage) *Service { return &Service{store: store} }
+// Delete removes a place, or only flags it as deleted in soft-delete mode;
+// ifVersion works as in Update. It returns ErrNotFound when the place does
+// not exist or is already deleted.
+func (s *Service) Delete(ctx context.Context, id string, ifVersion int64) error {
+	var before model.Place
+	var err error
+	if s.softDelete {
+		before, err = s.store.SoftDelete(ctx, id, ifVersion, s.now())
+		if errors.Is(err, valkey.ErrTombstoneNotRecorded) {
+			// the place is deleted; only the purger misses it until a backfill
+			slog.Error("record place tombstone failed",
+				slog.String("id", id),
+				slog.String("error", err.Error()),
+			)
+			err = nil
+		}
+	} else {
+		before, err = s.store.Delete(ctx, id, ifVersion)
+	}
+	if err != nil { return err }
+	s.emit(ctx, audit.Delete, id, before, model.Place{})
+	return nil
+}
 
-func (s *Service) Add(ctx context.Context, p model.Place) error {
-	return s.store.Upsert(ctx, p)
+// Restore brings back a soft-deleted place that has not been purged yet.
+// It returns ErrNotFound when there is nothing to restore and ErrNotDeleted
+// when the place is live.
+func (s *Service) Restore(ctx context.Context, id string) (model.Place, error) {
+	if _, err := s.store.Restore(ctx, id); err != nil { return model.Place{}, err }
+	p, err := s.store.Get(ctx, id)
+	if err != nil { return model.Place{}, err }
+	s.emit(ctx, audit.Restore, id, model.Place{}, p)
+	return p, nil
 }
 
-func (s *Service) Get(ctx context.Context, id string) (model.Place, error) {
-	return s.store.Get(ctx, id)
+// History returns a page of the audit trail of a place, newest first; see
+// valkey.HistoryStorage.List for the cursor. Places without recorded
+// changes, including unknown IDs, have an empty history.
+func (s *Service) History(ctx context.Context, id, before string, limit int64) ([]audit.Entry, string, error) {
+	if s.history == nil { return nil, "", nil }
+	return s.history.List(ctx, id, before, limit)
 }
 
-func (s *Service) Delete(ctx context.Context, id string) error {
-	return s.store.Delete(ctx, id)
+// emit records an audit entry, relays the outbox of the place to the change
+// feed and notifies publishers of a committed mutation. after is the zero
+// Place for deletes. The write already happened, so failures are only
+// logged: history and other publishers are best-effort, and events left in
+// the outbox are relayed by RunRelay. Publishing outlives a cancelled
+// request.
+func (s *Service) emit(ctx context.Context, action audit.Action, id string, before, after model.Place) {
+	ctx = context.WithoutCancel(ctx)
+	at := s.now()
+	if s.history != nil {
+		e := audit.Entry{
+			PlaceID: id,
+			Action:  action,
+			Actor:   audit.ActorFrom(ctx),
+			At:      at,
+			Changes: audit.Diff(before, after),
+		}
+		if err := s.history.Append(ctx, e); err != nil {
+			slog.Error("record place history failed",
+				slog.String("id", id),
+				slog.String("action", string(action)),
+				slog.String("error", err.Error()),
+			)
+		}
+	}
+	if s.feed != nil { s.relay(ctx, id) }
+	if len(s.publishers) == 0 { return }
+	ev := events.PlaceChanged{PlaceID: id, Action: action, At: at, Version: after.Version}
+	if before.ID != "" { ev.Before = &before }
+	if action == audit.Delete {
+		ev.Version = before.Version
+	} else {
+		ev.Place = &after
+	}
+	for _, p := range s.publishers {
+		if err := p.Publish(ctx, ev); err != nil {
+			slog.Error("publish place change failed",
+				slog.String("id", id),
+				slog.String("action", string(action)),
+				slog.String("error", err.Error()),
+			)
+		}
+	}
 }
 
 type SearchParams struct {
 	Lat, Lon float64
 	Limit    int64
 	CategoryIDs []string
+	DistanceMode geo.DistanceMode
+	Fields      []string
+	Hydrate     bool
+	// Within restricts the hits to a box not crossing the antimeridian.
+	Within *geo.BBox
+	// HasAddress keeps only places with an address component.
+	HasAddress bool
 }
 
 type SearchResult struct {
@@ -36,13 +277,41 @@ type SearchResult struct {
 }
 
 func (s *Service) SearchNearest(ctx context.Context, sp SearchParams) ([]SearchResult, error) {
-	res, err := s.store.SearchNearest(ctx, valkey.SearchParams{
-		Lat: sp.Lat, Lon: sp.Lon, Limit: sp.Limit, CategoryIDs: sp.CategoryIDs,
-	})
+	res, err := s.store.SearchNearest(ctx, s.storeParams(sp))
 	if err != nil { return nil, err }
+	return searchResults(res), nil
+}
+
+// BatchResult is the outcome of one search of SearchNearestBatch.
+type BatchResult struct {
+	Results []SearchResult
+	Err     error
+}
+
+// SearchNearestBatch runs many searches concurrently and returns their
+// outcomes in input order; one failing search does not fail the others.
+func (s *Service) SearchNearestBatch(ctx context.Context, sps []SearchParams) []BatchResult {
+	params := make([]valkey.SearchParams, len(sps))
+	for i, sp := range sps { params[i] = s.storeParams(sp) }
+	res := s.store.SearchNearestBatch(ctx, params)
+	out := make([]BatchResult, len(res))
+	for i, r := range res { out[i] = BatchResult{Results: searchResults(r.Results), Err: r.Err} }
+	return out
+}
+
+func (s *Service) storeParams(sp SearchParams) valkey.SearchParams {
+	return valkey.SearchParams{
+		Lat: sp.Lat, Lon: sp.Lon, Limit: sp.Limit, CategoryIDs: sp.CategoryIDs,
+		DistanceMode: sp.DistanceMode,
+		Fields: sp.Fields, Hydrate: sp.Hydrate,
+		ExcludeDeleted: s.softDelete, Within: sp.Within, HasAddress: sp.HasAddress,
+	}
+}
+
+func searchResults(res []valkey.SearchResult) []SearchResult {
 	out := make([]SearchResult, 0, len(res))
 	for _, r := range res {
 		out = append(out, SearchResult{Place: r.Place, DistanceM: r.DistanceM})
 	}
-	return out, nil
+	return out
 }
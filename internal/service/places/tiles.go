@@ -0,0 +1,57 @@
+package places
+
+import (
+	"context"
+	"sort"
+
+	"redcat/internal/domain/geo/tile"
+	"redcat/internal/domain/model"
+)
+
+// Map tiles show places from TilePlacesMinZoom on, when they hold fewer
+// than tilePlacesMax; other tiles show clusters.
+const (
+	TilePlacesMinZoom = 14
+	tilePlacesMax     = 200
+)
+
+// tileFields are the place fields tiles carry.
+var tileFields = []string{"id", "name", "category_ids", "category_labels", "country"}
+
+// TileContent is what a map tile shows: either Places, ordered by ID, or
+// Clusters.
+type TileContent struct {
+	Places   []model.Place
+	Clusters []Cluster
+}
+
+// Tile returns the places or clusters of t. A place on the edge between two
+// tiles belongs to the one east or south of it, as in Tile math, so
+// neighbouring tiles never repeat it.
+func (s *Service) Tile(ctx context.Context, t tile.Tile, categoryIDs []string) (TileContent, error) {
+	b := t.Bounds()
+	if t.Z >= TilePlacesMinZoom {
+		sp := SearchParams{
+			Lat: (b.YMin + b.YMax) / 2, Lon: (b.XMin + b.XMax) / 2, Limit: tilePlacesMax,
+			CategoryIDs: categoryIDs, Fields: tileFields, Within: &b,
+		}
+		r := s.SearchNearestBatch(ctx, []SearchParams{sp})[0]
+		if r.Err != nil { return TileContent{}, r.Err }
+		if len(r.Results) < tilePlacesMax {
+			var out TileContent
+			for _, hit := range r.Results {
+				if tile.At(hit.Place.Lat, hit.Place.Lon, t.Z) == t { out.Places = append(out.Places, hit.Place) }
+			}
+			sort.Slice(out.Places, func(i, j int) bool { return out.Places[i].ID < out.Places[j].ID })
+			return out, nil
+		}
+	}
+
+	cs, err := s.Clusters(ctx, ClusterParams{BBox: b, Zoom: t.Z, CategoryIDs: categoryIDs})
+	if err != nil { return TileContent{}, err }
+	var out TileContent
+	for _, c := range cs {
+		if c.Cell.X>>clusterGridShift == t.X && c.Cell.Y>>clusterGridShift == t.Y { out.Clusters = append(out.Clusters, c) }
+	}
+	return out, nil
+}
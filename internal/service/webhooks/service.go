@@ -0,0 +1,259 @@
+// Package webhooks delivers place mutations to partner endpoints. Publish
+// matches each mutation against the subscriptions and queues one delivery
+// per match; the dispatcher (RunDispatcher) sends them as signed JSON POSTs,
+// retrying failures with exponential backoff until they are dead-lettered.
+package webhooks
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/netip"
+	"sync"
+	"time"
+
+	"redcat/internal/domain/events"
+	"redcat/internal/domain/ids"
+	"redcat/internal/domain/model"
+	wh "redcat/internal/domain/webhooks"
+	"redcat/internal/storage/valkey"
+)
+
+// ErrNotFound is returned for unknown subscription IDs.
+var ErrNotFound = valkey.ErrSubscriptionNotFound
+
+// Store persists subscriptions and the delivery queue;
+// *valkey.WebhookStorage implements it.
+type Store interface {
+	SaveSubscription(ctx context.Context, sub wh.Subscription) error
+	GetSubscription(ctx context.Context, id string) (wh.Subscription, error)
+	DeleteSubscription(ctx context.Context, id string) error
+	ListSubscriptions(ctx context.Context) ([]wh.Subscription, error)
+	Enqueue(ctx context.Context, d wh.Delivery) error
+	Claim(ctx context.Context, now time.Time, lease time.Duration, n int) ([]wh.Delivery, error)
+	Complete(ctx context.Context, id string) error
+	Retry(ctx context.Context, d wh.Delivery) error
+	DeadLetter(ctx context.Context, d wh.Delivery) error
+	DeadLetters(ctx context.Context, limit int64) ([]wh.Delivery, error)
+}
+
+const (
+	// subsCacheTTL bounds how long other replicas' subscription changes
+	// take to affect matching.
+	subsCacheTTL = 5 * time.Second
+	// claimBatch is how many deliveries one dispatch round sends in parallel.
+	claimBatch = 32
+)
+
+type Service struct {
+	store       Store
+	client      *http.Client
+	render      func(model.Place) any
+	maxAttempts int
+	backoff     time.Duration
+	maxBackoff  time.Duration
+	lease       time.Duration
+	now         func() time.Time
+
+	allowPrivate bool
+	lookup       func(ctx context.Context, host string) ([]netip.Addr, error)
+
+	mu       sync.Mutex
+	subs     []wh.Subscription
+	subsTime time.Time
+}
+
+// Option configures a Service.
+type Option func(*Service)
+
+// WithHTTPClient sets the client used for deliveries; its Timeout should be
+// well below the lease of a claimed delivery (one minute). Build it with
+// NewHTTPClient so it refuses private addresses.
+func WithHTTPClient(c *http.Client) Option { return func(s *Service) { s.client = c } }
+
+// WithRenderer sets how places are serialised in payloads, so they match
+// the public API schema.
+func WithRenderer(fn func(model.Place) any) Option { return func(s *Service) { s.render = fn } }
+
+// WithRetry sets the attempt limit and the backoff range: the n-th retry
+// waits base*2^(n-1), capped at max.
+func WithRetry(maxAttempts int, base, max time.Duration) Option {
+	return func(s *Service) { s.maxAttempts, s.backoff, s.maxBackoff = maxAttempts, base, max }
+}
+
+func New(store Store, opts ...Option) *Service {
+	s := &Service{
+		store:       store,
+		render:      func(p model.Place) any { return p },
+		maxAttempts: 8,
+		backoff:     time.Second,
+		maxBackoff:  time.Hour,
+		lease:       time.Minute,
+		now:         time.Now,
+		lookup:      lookupHost,
+	}
+	for _, o := range opts { o(s) }
+	if s.client == nil { s.client = NewHTTPClient(10*time.Second, s.allowPrivate) }
+	return s
+}
+
+// Subscribe registers sub under a new ID. Its URL must resolve to public
+// addresses only, unless WithPrivateTargets is set.
+func (s *Service) Subscribe(ctx context.Context, sub wh.Subscription) (wh.Subscription, error) {
+	if err := s.checkTarget(ctx, sub.URL); err != nil { return wh.Subscription{}, err }
+	sub.ID, sub.CreatedAt = ids.New(), s.now().UTC()
+	if err := s.store.SaveSubscription(ctx, sub); err != nil { return wh.Subscription{}, err }
+	s.invalidate()
+	return sub, nil
+}
+
+func (s *Service) Unsubscribe(ctx context.Context, id string) error {
+	if err := s.store.DeleteSubscription(ctx, id); err != nil { return err }
+	s.invalidate()
+	return nil
+}
+
+func (s *Service) Subscription(ctx context.Context, id string) (wh.Subscription, error) {
+	return s.store.GetSubscription(ctx, id)
+}
+
+func (s *Service) Subscriptions(ctx context.Context) ([]wh.Subscription, error) {
+	return s.store.ListSubscriptions(ctx)
+}
+
+// DeadLetters returns the newest deliveries that exhausted their attempts.
+func (s *Service) DeadLetters(ctx context.Context, limit int64) ([]wh.Delivery, error) {
+	return s.store.DeadLetters(ctx, limit)
+}
+
+func (s *Service) invalidate() {
+	s.mu.Lock()
+	s.subsTime = time.Time{}
+	s.mu.Unlock()
+}
+
+// subscriptions returns the cached subscription list, reloading it when
+// older than subsCacheTTL.
+func (s *Service) subscriptions(ctx context.Context) ([]wh.Subscription, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.subsTime.IsZero() && s.now().Sub(s.subsTime) < subsCacheTTL { return s.subs, nil }
+	subs, err := s.store.ListSubscriptions(ctx)
+	if err != nil { return nil, err }
+	s.subs, s.subsTime = subs, s.now()
+	return subs, nil
+}
+
+// Payload is the JSON body of a delivery.
+type Payload struct {
+	ID      string `json:"id"`
+	Event   string `json:"event"`
+	PlaceID string `json:"place_id"`
+	At      string `json:"at"`
+	Version int64  `json:"version"`
+	Place   any    `json:"place,omitempty"`
+}
+
+// Publish queues a delivery for every subscription matching ev. It
+// implements places.Publisher.
+func (s *Service) Publish(ctx context.Context, ev events.PlaceChanged) error {
+	subs, err := s.subscriptions(ctx)
+	if err != nil { return err }
+	now := s.now().UTC()
+	for _, sub := range subs {
+		if !sub.Filter.Match(ev) { continue }
+		d := wh.Delivery{ID: ids.New(), SubscriptionID: sub.ID, Event: string(ev.Action), CreatedAt: now, NextAt: now}
+		p := Payload{ID: d.ID, Event: d.Event, PlaceID: ev.PlaceID, At: ev.At.UTC().Format(time.RFC3339Nano), Version: ev.Version}
+		if ev.Place != nil { p.Place = s.render(*ev.Place) }
+		if d.Payload, err = json.Marshal(p); err != nil { return err }
+		if err := s.store.Enqueue(ctx, d); err != nil { return err }
+	}
+	return nil
+}
+
+// RunDispatcher calls Dispatch every interval until ctx is done.
+func (s *Service) RunDispatcher(ctx context.Context, interval time.Duration) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+		if _, err := s.Dispatch(ctx); err != nil {
+			slog.Error("webhook dispatch failed", slog.String("error", err.Error()))
+		}
+	}
+}
+
+// Dispatch sends the deliveries that are due and returns how many
+// succeeded. Failures are rescheduled or dead-lettered, not returned.
+func (s *Service) Dispatch(ctx context.Context) (int, error) {
+	ds, err := s.store.Claim(ctx, s.now(), s.lease, claimBatch)
+	if errors.Is(err, valkey.ErrCorrupted) {
+		// the store dropped them; the rest is still sent
+		slog.Error("dropped corrupted webhook deliveries", slog.String("error", err.Error()))
+	} else if err != nil {
+		return 0, err
+	}
+	var (
+		wg sync.WaitGroup
+		mu sync.Mutex
+		ok int
+	)
+	for _, d := range ds {
+		wg.Add(1)
+		go func(d wh.Delivery) {
+			defer wg.Done()
+			if s.deliver(ctx, d) {
+				mu.Lock(); ok++; mu.Unlock()
+			}
+		}(d)
+	}
+	wg.Wait()
+	return ok, nil
+}
+
+// deliver makes one attempt at d and records the outcome.
+func (s *Service) deliver(ctx context.Context, d wh.Delivery) bool {
+	sub, err := s.store.GetSubscription(ctx, d.SubscriptionID)
+	if errors.Is(err, ErrNotFound) {
+		s.logErr("drop webhook delivery", d, s.store.Complete(ctx, d.ID))
+		return false
+	}
+	if err == nil { err = s.post(ctx, sub, d) }
+	if err == nil {
+		s.logErr("complete webhook delivery", d, s.store.Complete(ctx, d.ID))
+		return true
+	}
+
+	d.Attempts++
+	d.LastError = err.Error()
+	if d.Attempts >= s.maxAttempts {
+		slog.Warn("webhook delivery dead-lettered",
+			slog.String("delivery", d.ID),
+			slog.String("subscription", d.SubscriptionID),
+			slog.String("error", d.LastError),
+		)
+		s.logErr("dead-letter webhook delivery", d, s.store.DeadLetter(ctx, d))
+		return false
+	}
+	d.NextAt = s.now().Add(s.retryDelay(d.Attempts))
+	s.logErr("reschedule webhook delivery", d, s.store.Retry(ctx, d))
+	return false
+}
+
+// retryDelay is the backoff before retry n (1-based).
+func (s *Service) retryDelay(n int) time.Duration {
+	d := s.backoff
+	for i := 1; i < n && d < s.maxBackoff; i++ { d *= 2 }
+	return min(d, s.maxBackoff)
+}
+
+func (s *Service) logErr(msg string, d wh.Delivery, err error) {
+	if err == nil { return }
+	slog.Error(msg, slog.String("delivery", d.ID), slog.String("error", err.Error()))
+}
@@ -0,0 +1,76 @@
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	wh "redcat/internal/domain/webhooks"
+)
+
+// Headers set on every delivery. SignatureHeader is "t=<unix>,v1=<hex>",
+// where v1 is HMAC-SHA256 of "<unix>.<body>" keyed with the subscription
+// secret; receivers should also reject stale timestamps to stop replays.
+const (
+	SignatureHeader = "X-Redcat-Signature"
+	EventHeader     = "X-Redcat-Event"
+	DeliveryHeader  = "X-Redcat-Delivery"
+)
+
+// Sign returns the SignatureHeader value for body sent at ts.
+func Sign(secret string, ts time.Time, body []byte) string {
+	unix := strconv.FormatInt(ts.Unix(), 10)
+	return "t=" + unix + ",v1=" + mac(secret, unix, body)
+}
+
+// Verify checks a SignatureHeader value against body and returns the
+// signing time.
+func Verify(secret, header string, body []byte) (time.Time, bool) {
+	var unix, sig string
+	for _, part := range strings.Split(header, ",") {
+		k, v, _ := strings.Cut(part, "=")
+		switch k {
+		case "t":
+			unix = v
+		case "v1":
+			sig = v
+		}
+	}
+	sec, err := strconv.ParseInt(unix, 10, 64)
+	if err != nil || !hmac.Equal([]byte(sig), []byte(mac(secret, unix, body))) { return time.Time{}, false }
+	return time.Unix(sec, 0), true
+}
+
+func mac(secret, unix string, body []byte) string {
+	m := hmac.New(sha256.New, []byte(secret))
+	m.Write([]byte(unix))
+	m.Write([]byte("."))
+	m.Write(body)
+	return hex.EncodeToString(m.Sum(nil))
+}
+
+// post sends d to sub; any non-2xx status is an error.
+func (s *Service) post(ctx context.Context, sub wh.Subscription, d wh.Delivery) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, sub.URL, bytes.NewReader(d.Payload))
+	if err != nil { return err }
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(EventHeader, d.Event)
+	req.Header.Set(DeliveryHeader, d.ID)
+	req.Header.Set(SignatureHeader, Sign(sub.Secret, s.now(), d.Payload))
+	resp, err := s.client.Do(req)
+	if err != nil { return err }
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("receiver responded %s", resp.Status)
+	}
+	return nil
+}
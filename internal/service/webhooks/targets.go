@@ -0,0 +1,73 @@
+package webhooks
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"syscall"
+	"time"
+
+	"redcat/internal/domain/errs"
+	wh "redcat/internal/domain/webhooks"
+)
+
+// errPrivateTarget is returned by the dialer of NewHTTPClient for
+// connections to non-public addresses.
+var errPrivateTarget = errors.New("webhook target is not a public address")
+
+// WithPrivateTargets lets subscriptions point at loopback, private and
+// link-local addresses, for local development. The HTTP client must allow
+// them too, see NewHTTPClient.
+func WithPrivateTargets() Option { return func(s *Service) { s.allowPrivate = true } }
+
+// NewHTTPClient returns a delivery client with the given timeout. Unless
+// allowPrivate is set it refuses to connect to addresses wh.PublicAddr
+// rejects; the check runs on the resolved address of every connection,
+// redirects included, so a host re-pointed after Subscribe (DNS rebinding)
+// is refused too. Proxies are not used, since they would dial instead.
+func NewHTTPClient(timeout time.Duration, allowPrivate bool) *http.Client {
+	tr := http.DefaultTransport.(*http.Transport).Clone()
+	if !allowPrivate {
+		d := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second, Control: publicOnly}
+		tr.DialContext, tr.Proxy = d.DialContext, nil
+	}
+	return &http.Client{Timeout: timeout, Transport: tr}
+}
+
+// publicOnly is a net.Dialer Control hook rejecting non-public addresses.
+func publicOnly(_, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil { return err }
+	a, err := netip.ParseAddr(host)
+	if err != nil { return err }
+	if !wh.PublicAddr(a) { return fmt.Errorf("%w: %s", errPrivateTarget, a) }
+	return nil
+}
+
+// checkTarget resolves the host of rawURL and rejects it unless every
+// address is public or private targets are allowed.
+func (s *Service) checkTarget(ctx context.Context, rawURL string) error {
+	if s.allowPrivate { return nil }
+	u, err := url.Parse(rawURL)
+	if err != nil { return errs.Invalid("invalid url", map[string]any{"url": rawURL}) }
+	addrs, err := s.lookup(ctx, u.Hostname())
+	if err != nil || len(addrs) == 0 {
+		return errs.Invalid("url host does not resolve", map[string]any{"url": rawURL})
+	}
+	for _, a := range addrs {
+		if !wh.PublicAddr(a) {
+			return errs.Invalid("url must point to a public address", map[string]any{"url": rawURL, "address": a.String()})
+		}
+	}
+	return nil
+}
+
+// lookupHost resolves host, which may be an IP literal.
+func lookupHost(ctx context.Context, host string) ([]netip.Addr, error) {
+	if a, err := netip.ParseAddr(host); err == nil { return []netip.Addr{a}, nil }
+	return net.DefaultResolver.LookupNetIP(ctx, "ip", host)
+}
@@ -0,0 +1,48 @@
+package valkey
+
+import (
+	"context"
+	"errors"
+
+	"redcat/internal/domain/errs"
+
+	"github.com/redis/rueidis"
+)
+
+// ErrAliasNotFound is returned for IDs that are not aliases.
+var ErrAliasNotFound = errs.New(errs.NotFound, "alias not found")
+
+// aliasesKey is the hash mapping old place IDs, of merged places or of
+// places an upstream dataset re-keyed, to the ID they live on as. Like
+// tombstonesKey it lives outside the place key space.
+func (s *PlacesStorage) aliasesKey() string { return s.index + ":aliases" }
+
+// SetAliases records, for every old ID in aliases, the ID it maps to,
+// replacing earlier mappings of the same old IDs.
+func (s *PlacesStorage) SetAliases(ctx context.Context, aliases map[string]string) error {
+	if len(aliases) == 0 { return nil }
+	cmd := s.cli.B().Hset().Key(s.aliasesKey()).FieldValue()
+	for from, to := range aliases {
+		if from == "" || to == "" { return errors.New("empty id") }
+		cmd = cmd.FieldValue(from, to)
+	}
+	return backendErr(s.cli.Do(ctx, cmd.Build()).Error())
+}
+
+// Alias returns the ID recorded by SetAliases for id, or "" when id is not
+// an alias.
+func (s *PlacesStorage) Alias(ctx context.Context, id string) (string, error) {
+	to, err := s.cli.Do(ctx, s.cli.B().Hget().Key(s.aliasesKey()).Field(id).Build()).ToString()
+	if rueidis.IsRedisNil(err) { return "", nil }
+	if err != nil { return "", backendErr(err) }
+	return to, nil
+}
+
+// DeleteAlias removes the mapping of id; it returns ErrAliasNotFound when
+// there is none.
+func (s *PlacesStorage) DeleteAlias(ctx context.Context, id string) error {
+	n, err := s.cli.Do(ctx, s.cli.B().Hdel().Key(s.aliasesKey()).Field(id).Build()).AsInt64()
+	if err != nil { return backendErr(err) }
+	if n == 0 { return ErrAliasNotFound }
+	return nil
+}
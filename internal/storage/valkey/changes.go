@@ -0,0 +1,204 @@
+package valkey
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"redcat/internal/domain/audit"
+	"redcat/internal/domain/errs"
+	"redcat/internal/domain/events"
+	"redcat/internal/domain/model"
+
+	"github.com/redis/rueidis"
+)
+
+// ChangeFeed publishes place mutations to a set of streams. Each stream key
+// carries its shard number as hash tag, spreading the shards over cluster
+// slots; a place always maps to the same shard so its events stay ordered.
+//
+// Consumers resume with a cursor holding the last stream ID read from every
+// shard, comma separated in shard order.
+type ChangeFeed struct {
+	cli    rueidis.Client
+	base   string
+	shards int
+	maxLen int64
+}
+
+// NewChangeFeed returns a feed over shards streams named base:{0}, base:{1},
+// ... each trimmed to about maxLen entries (0 keeps everything).
+func NewChangeFeed(cli rueidis.Client, base string, shards int, maxLen int64) *ChangeFeed {
+	if shards < 1 { shards = 1 }
+	return &ChangeFeed{cli: cli, base: base, shards: shards, maxLen: maxLen}
+}
+
+func (f *ChangeFeed) key(shard int) string { return f.base + ":{" + strconv.Itoa(shard) + "}" }
+
+func (f *ChangeFeed) shard(placeID string) int {
+	h := fnv.New32a()
+	h.Write([]byte(placeID))
+	return int(h.Sum32() % uint32(f.shards))
+}
+
+// Publish appends ev to the shard of its place.
+func (f *ChangeFeed) Publish(ctx context.Context, ev events.PlaceChanged) error {
+	var place []byte
+	if ev.Place != nil {
+		var err error
+		if place, err = json.Marshal(ev.Place); err != nil { return err }
+	}
+	k := f.key(f.shard(ev.PlaceID))
+	cmds := rueidis.Commands{
+		f.cli.B().Xadd().Key(k).Id("*").FieldValue().
+			FieldValue("place_id", ev.PlaceID).
+			FieldValue("action", string(ev.Action)).
+			FieldValue("at", strconv.FormatInt(ev.At.UnixMilli(), 10)).
+			FieldValue("version", strconv.FormatInt(ev.Version, 10)).
+			FieldValue("place", string(place)).
+			Build(),
+	}
+	if f.maxLen > 0 {
+		cmds = append(cmds, f.cli.B().Xtrim().Key(k).Maxlen().Almost().Threshold(strconv.FormatInt(f.maxLen, 10)).Build())
+	}
+	for _, r := range f.cli.DoMulti(ctx, cmds...) {
+		if err := r.Error(); err != nil { return backendErr(err) }
+	}
+	return nil
+}
+
+// ErrCursorExpired is returned by Read for a cursor behind entries a shard
+// has since trimmed (see maxLen): resuming from it would silently skip
+// them, so the consumer has to resync and start over from "".
+var ErrCursorExpired = errs.New(errs.Gone, "cursor expired: the change feed was trimmed past it")
+
+var cursorIDRe = regexp.MustCompile(`^[0-9]+-[0-9]+$`)
+
+// parseCursor splits a cursor into per-shard stream IDs; "" starts every
+// shard from its oldest retained entry.
+func (f *ChangeFeed) parseCursor(cursor string) ([]string, error) {
+	ids := make([]string, f.shards)
+	if cursor == "" {
+		for i := range ids { ids[i] = "0-0" }
+		return ids, nil
+	}
+	parts := strings.Split(cursor, ",")
+	if len(parts) != f.shards {
+		return nil, errs.Invalid(fmt.Sprintf("cursor must have %d comma-separated stream IDs", f.shards), map[string]any{"since": cursor})
+	}
+	for i, p := range parts {
+		if !cursorIDRe.MatchString(p) {
+			return nil, errs.Invalid("cursor contains an invalid stream ID", map[string]any{"since": cursor})
+		}
+		ids[i] = p
+	}
+	return ids, nil
+}
+
+// Read returns up to limit events after cursor, oldest first, and the
+// cursor to continue from. Events of different shards are interleaved by
+// stream ID, i.e. by publish time. A cursor some shard has trimmed past
+// returns ErrCursorExpired.
+func (f *ChangeFeed) Read(ctx context.Context, cursor string, limit int64) ([]events.PlaceChanged, string, error) {
+	pos, err := f.parseCursor(cursor)
+	if err != nil { return nil, "", err }
+	cmds := make(rueidis.Commands, f.shards, 2*f.shards)
+	for i := range f.shards {
+		cmds[i] = f.cli.B().Xread().Count(limit).Streams().Key(f.key(i)).Id(pos[i]).Build()
+	}
+	// the trims are checked after the reads, so one racing them is not missed
+	var checked []int
+	for i, id := range pos {
+		if id == "0-0" { continue }
+		checked = append(checked, i)
+		cmds = append(cmds, f.cli.B().XinfoStream().Key(f.key(i)).Build())
+	}
+	res := f.cli.DoMulti(ctx, cmds...)
+	for j, i := range checked {
+		trimmed, err := lastTrimmedID(res[f.shards+j])
+		if err != nil { return nil, "", err }
+		if streamIDLess(pos[i], trimmed) {
+			return nil, "", &errs.Error{Kind: ErrCursorExpired.Kind, Message: ErrCursorExpired.Message, Details: map[string]any{"since": cursor, "shard": i}}
+		}
+	}
+	type shardEvent struct {
+		shard int
+		ev    events.PlaceChanged
+	}
+	var all []shardEvent
+	for i, r := range res[:f.shards] {
+		streams, err := r.AsXRead()
+		if rueidis.IsRedisNil(err) { continue }
+		if err != nil { return nil, "", backendErr(err) }
+		for _, row := range streams[f.key(i)] {
+			ev, err := decodeEvent(row)
+			if err != nil { return nil, "", err }
+			all = append(all, shardEvent{shard: i, ev: ev})
+		}
+	}
+	sort.SliceStable(all, func(i, j int) bool { return streamIDLess(all[i].ev.ID, all[j].ev.ID) })
+	if int64(len(all)) > limit { all = all[:limit] }
+	out := make([]events.PlaceChanged, len(all))
+	for i, se := range all {
+		out[i] = se.ev
+		pos[se.shard] = se.ev.ID
+	}
+	return out, strings.Join(pos, ","), nil
+}
+
+// lastTrimmedID returns the newest entry ID a stream has dropped, from its
+// XINFO STREAM reply; entries are only ever trimmed from the front, so a
+// cursor older than it missed events. A stream that does not exist yet
+// dropped nothing.
+func lastTrimmedID(r rueidis.RedisResult) (string, error) {
+	info, err := r.AsMap()
+	if err != nil {
+		if strings.Contains(err.Error(), "no such key") { return "0-0", nil }
+		return "", backendErr(err)
+	}
+	m, ok := info["max-deleted-entry-id"]
+	if !ok { return "", errors.New("XINFO STREAM: no max-deleted-entry-id, Valkey 7.2 or later is required") }
+	id, err := m.ToString()
+	if err != nil { return "", backendErr(err) }
+	return id, nil
+}
+
+// streamIDLess orders "ms-seq" stream IDs numerically.
+func streamIDLess(a, b string) bool {
+	am, as, _ := strings.Cut(a, "-")
+	bm, bs, _ := strings.Cut(b, "-")
+	ami, _ := strconv.ParseUint(am, 10, 64)
+	bmi, _ := strconv.ParseUint(bm, 10, 64)
+	if ami != bmi { return ami < bmi }
+	asi, _ := strconv.ParseUint(as, 10, 64)
+	bsi, _ := strconv.ParseUint(bs, 10, 64)
+	return asi < bsi
+}
+
+func decodeEvent(r rueidis.XRangeEntry) (events.PlaceChanged, error) {
+	f := r.FieldValues
+	ev := events.PlaceChanged{ID: r.ID, PlaceID: f["place_id"], Action: audit.Action(f["action"])}
+	ms, err := strconv.ParseInt(f["at"], 10, 64)
+	if err != nil {
+		return events.PlaceChanged{}, &CorruptedRecordError{ID: ev.PlaceID, Field: "change.at", Value: f["at"], Err: err}
+	}
+	ev.At = time.UnixMilli(ms).UTC()
+	if ev.Version, err = strconv.ParseInt(f["version"], 10, 64); err != nil {
+		return events.PlaceChanged{}, &CorruptedRecordError{ID: ev.PlaceID, Field: "change.version", Value: f["version"], Err: err}
+	}
+	if v := f["place"]; v != "" {
+		var p model.Place
+		if err := json.Unmarshal([]byte(v), &p); err != nil {
+			return events.PlaceChanged{}, &CorruptedRecordError{ID: ev.PlaceID, Field: "change.place", Value: v, Err: err}
+		}
+		ev.Place = &p
+	}
+	return ev, nil
+}
@@ -0,0 +1,35 @@
+package valkey
+
+import (
+	"testing"
+
+	"redcat/internal/domain/errs"
+)
+
+func TestChangeFeed_Cursor(t *testing.T) {
+	f := NewChangeFeed(nil, "changes", 3, 0)
+	ids, err := f.parseCursor("")
+	if err != nil || len(ids) != 3 || ids[2] != "0-0" { t.Fatalf("empty cursor: got %v, %v", ids, err) }
+	ids, err = f.parseCursor("1-0,0-0,1718000000000-3")
+	if err != nil || ids[2] != "1718000000000-3" { t.Fatalf("valid cursor: got %v, %v", ids, err) }
+	for _, bad := range []string{"1-0,0-0", "1-0,0-0,x", "1-0,0-0,5"} {
+		if _, err := f.parseCursor(bad); errs.KindOf(err) != errs.InvalidRequest {
+			t.Errorf("cursor %q: want INVALID_REQUEST, got %v", bad, err)
+		}
+	}
+}
+
+func TestChangeFeed_ShardIsStable(t *testing.T) {
+	f := NewChangeFeed(nil, "changes", 16, 0)
+	if f.shard("p1") != f.shard("p1") { t.Fatal("shard must be deterministic") }
+	seen := map[int]bool{}
+	for _, id := range []string{"a", "b", "c", "d", "e", "f", "g", "h"} { seen[f.shard(id)] = true }
+	if len(seen) < 2 { t.Fatalf("expected places to spread over shards, got %v", seen) }
+	if k := f.key(3); k != "changes:{3}" { t.Fatalf("key: got %q", k) }
+}
+
+func TestStreamIDLess(t *testing.T) {
+	if !streamIDLess("999-5", "1000-0") || !streamIDLess("1000-2", "1000-10") || streamIDLess("1000-0", "1000-0") {
+		t.Fatal("stream IDs must compare numerically")
+	}
+}
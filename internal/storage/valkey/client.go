@@ -38,8 +38,94 @@ func NewClient(addrs []string, username, password string) (*Client, error) {
 
 func (c *Client) Close() { c.R.Close() }
 
-func EnsurePlacesIndex(ctx context.Context, r rueidis.Client, index, prefix string) error {
-	// FT.INFO to check existence
+// placesSchema is the schema of the places index, one attribute per entry.
+// EnsurePlacesIndex adds the attributes an existing index lacks, so entries
+// may be appended but not changed.
+func placesSchema() [][]string {
+	schema := [][]string{
+		{"category_ids", "TAG"},
+		{"country", "TAG"},
+		{"deleted", "TAG"},
+		{hasAddressField, "TAG"},
+		{"lat", "NUMERIC"},
+		{"lon", "NUMERIC"},
+	}
+	for _, res := range H3Resolutions { schema = append(schema, []string{H3Field(res), "TAG"}) }
+	return append(schema, []string{"location", "VECTOR", "FLAT", "6", "TYPE", "FLOAT32", "DIM", "3", "DISTANCE_METRIC", "L2"})
+}
+
+// EnsurePlacesIndex creates the places index, or adds the attributes of
+// placesSchema an index created by an older version lacks with FT.ALTER,
+// and returns the names of the attributes it added. The index reindexes
+// existing hashes in the background; values a new attribute derives from
+// other fields (has_address, the H3 cells) only appear once the places are
+// rewritten or backfilled (see PlacesStorage.Backfill).
+func EnsurePlacesIndex(ctx context.Context, r rueidis.Client, index, prefix string) ([]string, error) {
+	schema := placesSchema()
+	info, err := r.Do(ctx, r.B().FtInfo().Index(index).Build()).AsMap()
+	if err == nil { return alterIndex(ctx, r, index, info, schema) }
+
+	args := []string{index, "ON", "HASH", "PREFIX", "1", prefix, "SCHEMA"}
+	for _, a := range schema { args = append(args, a...) }
+	if err := r.Do(ctx, r.B().Arbitrary("FT.CREATE").Args(args...).Build()).Error(); err != nil {
+		if strings.Contains(err.Error(), "Index already exists") {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("FT.CREATE %s failed: %w", index, err)
+	}
+	return nil, nil
+}
+
+// alterIndex adds the attributes of schema missing from the FT.INFO reply
+// info, one FT.ALTER each.
+func alterIndex(ctx context.Context, r rueidis.Client, index string, info map[string]rueidis.RedisMessage, schema [][]string) ([]string, error) {
+	have, err := indexAttributes(info)
+	if err != nil { return nil, fmt.Errorf("FT.INFO %s: %w", index, err) }
+	var added []string
+	for _, a := range schema {
+		if have[a[0]] { continue }
+		cmd := r.B().FtAlter().Index(index).Schema().Add().Field(a[0]).Options(a[1:]...).Build()
+		if err := r.Do(ctx, cmd).Error(); err != nil {
+			return added, fmt.Errorf("FT.ALTER %s SCHEMA ADD %s failed: %w", index, a[0], err)
+		}
+		added = append(added, a[0])
+	}
+	return added, nil
+}
+
+// indexAttributes returns the hash fields indexed according to an FT.INFO
+// reply. Attributes are flat key/value lists in RESP2 and maps in RESP3.
+func indexAttributes(info map[string]rueidis.RedisMessage) (map[string]bool, error) {
+	raw := info["attributes"]
+	attrs, err := raw.ToArray()
+	if err != nil { return nil, err }
+	have := make(map[string]bool, len(attrs))
+	for i := range attrs {
+		if attrs[i].IsMap() {
+			m, err := attrs[i].AsMap()
+			if err != nil { return nil, err }
+			v := m["identifier"]
+			id, err := v.ToString()
+			if err != nil { return nil, err }
+			have[id] = true
+			continue
+		}
+		kv, err := attrs[i].ToArray()
+		if err != nil { return nil, err }
+		for j := 0; j+1 < len(kv); j++ {
+			if k, _ := kv[j].ToString(); k == "identifier" {
+				id, err := kv[j+1].ToString()
+				if err != nil { return nil, err }
+				have[id] = true
+			}
+		}
+	}
+	return have, nil
+}
+
+// EnsureGeofencesIndex creates the bounding-box index used to prefilter
+// geofence lookups.
+func EnsureGeofencesIndex(ctx context.Context, r rueidis.Client, index, prefix string) error {
 	if err := r.Do(ctx, r.B().FtInfo().Index(index).Build()).Error(); err == nil {
 		return nil
 	}
@@ -47,16 +133,12 @@ func EnsurePlacesIndex(ctx context.Context, r rueidis.Client, index, prefix stri
 		Index(index).
 		OnHash().
 		Prefix(1).Prefix(prefix).
-Schema().
-		FieldName("category_ids").Tag().
-		FieldName("country").Tag().
-		FieldName("lat").Numeric().
-		FieldName("lon").Numeric().
-		FieldName("location").Vector(
-			"FLAT",
-			6,
-			[]string{"TYPE", "FLOAT32", "DIM", "3", "DISTANCE_METRIC", "L2"}...,
-		).Build()
+		Schema().
+		FieldName("xmin").Numeric().
+		FieldName("ymin").Numeric().
+		FieldName("xmax").Numeric().
+		FieldName("ymax").Numeric().
+		Build()
 	if err := r.Do(ctx, create).Error(); err != nil {
 		if strings.Contains(err.Error(), "Index already exists") {
 			return nil
@@ -0,0 +1,127 @@
+package valkey
+
+import (
+	"context"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"redcat/internal/domain/geo"
+
+	"github.com/redis/rueidis"
+)
+
+// GridParams bins the places in BBox into a grid: rows are the latitude
+// bands between consecutive LatEdges, ascending, and columns are ColWidth
+// degrees wide counted from longitude -180.
+type GridParams struct {
+	BBox        geo.BBox
+	LatEdges    []float64
+	ColWidth    float64
+	CategoryIDs []string
+	// TopCategories caps the category breakdown of each cell.
+	TopCategories  int64
+	ExcludeDeleted bool
+}
+
+// GridCell is a cell holding places; Lat/Lon is their mean position.
+type GridCell struct {
+	Row, Col   int
+	Count      int64
+	Lat, Lon   float64
+	Categories []FacetCount
+}
+
+// Grid counts the places of every grid cell with two FT.AGGREGATE per row,
+// grouping on the column computed from @lon: one for the counts and mean
+// positions, one for the categories. A place on the edge between two rows
+// counts in the southern one, as in Web Mercator tiles. Cells are ordered by row, then column.
+func (s *PlacesStorage) Grid(ctx context.Context, gp GridParams) ([]GridCell, error) {
+	type key struct{ row, col int }
+	type acc struct {
+		count          int64
+		latSum, lonSum float64
+		cats           map[string]int64
+	}
+	cols := int(math.Round(360 / gp.ColWidth))
+	col := func(v string) int {
+		c, _ := strconv.ParseFloat(v, 64)
+		return min(max(int(c), 0), cols-1)
+	}
+	apply := "floor((@lon + 180) / " + formatFloat(gp.ColWidth) + ")"
+
+	var cmds rueidis.Commands
+	var rows []int
+	for i := 0; i+1 < len(gp.LatEdges); i++ {
+		lo, hi := math.Max(gp.LatEdges[i], gp.BBox.YMin), math.Min(gp.LatEdges[i+1], gp.BBox.YMax)
+		if lo > hi { continue }
+		// the band below already holds its upper edge
+		lat := "@lat:[" + formatFloat(lo) + " " + formatFloat(hi) + "]"
+		if i > 0 && lo == gp.LatEdges[i] { lat = "@lat:[(" + formatFloat(lo) + " " + formatFloat(hi) + "]" }
+		for _, b := range gp.BBox.Split() {
+			base := []string{s.index, gridQuery(gp, lat, b), "LOAD", "3", "@lat", "@lon", "@category_ids", "APPLY", apply, "AS", "col"}
+			cmds = append(cmds,
+				s.cli.B().Arbitrary("FT.AGGREGATE").Args(append(append([]string{}, base...),
+					"GROUPBY", "1", "@col", "REDUCE", "COUNT", "0", "AS", "count",
+					"REDUCE", "AVG", "1", "@lat", "AS", "lat", "REDUCE", "AVG", "1", "@lon", "AS", "lon", "DIALECT", "2")...).ReadOnly(),
+				s.cli.B().Arbitrary("FT.AGGREGATE").Args(append(base,
+					"APPLY", "split(@category_ids, \",\")", "AS", "category_id",
+					"GROUPBY", "2", "@col", "@category_id", "REDUCE", "COUNT", "0", "AS", "count", "DIALECT", "2")...).ReadOnly(),
+			)
+			rows = append(rows, i, i)
+		}
+	}
+
+	cells := map[key]*acc{}
+	cell := func(k key) *acc {
+		if cells[k] == nil { cells[k] = &acc{cats: map[string]int64{}} }
+		return cells[k]
+	}
+	for i, r := range s.cli.DoMulti(ctx, cmds...) {
+		res, err := aggregateRows(r)
+		if err != nil { return nil, err }
+		for _, row := range res {
+			n, _ := strconv.ParseInt(row["count"], 10, 64)
+			c := cell(key{rows[i], col(row["col"])})
+			if i%2 == 1 {
+				c.cats[row["category_id"]] += n
+				continue
+			}
+			lat, _ := strconv.ParseFloat(row["lat"], 64)
+			lon, _ := strconv.ParseFloat(row["lon"], 64)
+			c.count += n
+			c.latSum += lat * float64(n)
+			c.lonSum += lon * float64(n)
+		}
+	}
+
+	out := make([]GridCell, 0, len(cells))
+	for k, c := range cells {
+		if c.count == 0 { continue }
+		out = append(out, GridCell{
+			Row: k.row, Col: k.col, Count: c.count,
+			// rounded so the result does not depend on the order the
+			// shards' partial means were combined in
+			Lat: roundDeg(c.latSum / float64(c.count)), Lon: roundDeg(c.lonSum / float64(c.count)),
+			Categories: topCounts(c.cats, gp.TopCategories),
+		})
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Row != out[j].Row { return out[i].Row < out[j].Row }
+		return out[i].Col < out[j].Col
+	})
+	return out, nil
+}
+
+// gridQuery selects gp's places in the lat range inside b, which must not
+// cross the antimeridian.
+func gridQuery(gp GridParams, lat string, b geo.BBox) string {
+	parts := []string{lat, "@lon:[" + formatFloat(b.XMin) + " " + formatFloat(b.XMax) + "]"}
+	if len(gp.CategoryIDs) > 0 { parts = append(parts, "@category_ids:{"+strings.Join(gp.CategoryIDs, "|")+"}") }
+	if gp.ExcludeDeleted { parts = append(parts, "-@deleted:{1}") }
+	return strings.Join(parts, " ")
+}
+
+// roundDeg rounds to 1e-6 degrees, about 10 cm.
+func roundDeg(x float64) float64 { return math.Round(x*1e6) / 1e6 }
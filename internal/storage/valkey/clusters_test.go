@@ -0,0 +1,15 @@
+package valkey
+
+import (
+	"testing"
+
+	"redcat/internal/domain/geo"
+)
+
+func TestGridQuery(t *testing.T) {
+	gp := GridParams{CategoryIDs: []string{"a", "b"}, ExcludeDeleted: true}
+	got := gridQuery(gp, "@lat:[(35 35.5]", geo.BBox{XMin: 33, XMax: 34})
+	if want := "@lat:[(35 35.5] @lon:[33 34] @category_ids:{a|b} -@deleted:{1}"; got != want {
+		t.Fatalf("want %q got %q", want, got)
+	}
+}
@@ -0,0 +1,131 @@
+package valkey
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+	"strings"
+
+	"redcat/internal/domain/dedupe"
+	"redcat/internal/domain/errs"
+
+	"github.com/redis/rueidis"
+)
+
+// ErrCandidateNotFound is returned for pairs that are not in the review queue.
+var ErrCandidateNotFound = errs.New(errs.NotFound, "duplicate candidate not found")
+
+// DuplicateStorage is the review queue of duplicate candidates. All keys
+// share one hash tag so the scripts below can update them together:
+//
+//	<base>:queue       zset  pair ID scored by candidate score
+//	<base>:pairs       hash  pair ID -> candidate JSON
+//	<base>:dismissed   set   pair IDs a reviewer rejected; never queued again
+//	<base>:place:<id>  set   pair IDs of queued candidates involving the place
+type DuplicateStorage struct {
+	cli  rueidis.Client
+	base string
+}
+
+// NewDuplicateStorage returns a queue under dupes:{name}.
+func NewDuplicateStorage(cli rueidis.Client, name string) *DuplicateStorage {
+	return &DuplicateStorage{cli: cli, base: "dupes:{" + name + "}"}
+}
+
+func (s *DuplicateStorage) queueKey() string          { return s.base + ":queue" }
+func (s *DuplicateStorage) pairsKey() string          { return s.base + ":pairs" }
+func (s *DuplicateStorage) dismissedKey() string      { return s.base + ":dismissed" }
+func (s *DuplicateStorage) placeKey(id string) string { return s.base + ":place:" + id }
+
+// addCandidateScript queues or rescores a pair unless it was dismissed; it
+// returns 1 for a pair new to the queue.
+var addCandidateScript = rueidis.NewLuaScript(`
+if redis.call('SISMEMBER', KEYS[3], ARGV[1]) == 1 then return 0 end
+redis.call('HSET', KEYS[2], ARGV[1], ARGV[3])
+redis.call('SADD', KEYS[4], ARGV[1])
+redis.call('SADD', KEYS[5], ARGV[1])
+return redis.call('ZADD', KEYS[1], ARGV[2], ARGV[1])
+`)
+
+// removeCandidateScript drops a queued pair, remembering it as dismissed
+// when ARGV[2] is 1; it returns 0 when the pair was not queued.
+var removeCandidateScript = rueidis.NewLuaScript(`
+if redis.call('ZREM', KEYS[1], ARGV[1]) == 0 then return 0 end
+redis.call('HDEL', KEYS[2], ARGV[1])
+redis.call('SREM', KEYS[4], ARGV[1])
+redis.call('SREM', KEYS[5], ARGV[1])
+if ARGV[2] == '1' then redis.call('SADD', KEYS[3], ARGV[1]) end
+return 1
+`)
+
+func (s *DuplicateStorage) pairExec(a, b string, args ...string) rueidis.LuaExec {
+	keys := []string{s.queueKey(), s.pairsKey(), s.dismissedKey(), s.placeKey(a), s.placeKey(b)}
+	return rueidis.LuaExec{Keys: keys, Args: append([]string{dedupe.PairID(a, b)}, args...)}
+}
+
+// Add queues candidates, updating the score of pairs already queued and
+// skipping dismissed ones, and returns how many pairs are new.
+func (s *DuplicateStorage) Add(ctx context.Context, cs []dedupe.Candidate) (int, error) {
+	if len(cs) == 0 { return 0, nil }
+	execs := make([]rueidis.LuaExec, len(cs))
+	for i, c := range cs {
+		b, err := json.Marshal(c)
+		if err != nil { return 0, err }
+		execs[i] = s.pairExec(c.A.ID, c.B.ID, strconv.FormatFloat(c.Score, 'f', -1, 64), string(b))
+	}
+	var added int
+	for _, r := range addCandidateScript.ExecMulti(ctx, s.cli, execs...) {
+		n, err := r.AsInt64()
+		if err != nil { return added, backendErr(err) }
+		added += int(n)
+	}
+	return added, nil
+}
+
+// List returns the limit highest scoring candidates, best first.
+func (s *DuplicateStorage) List(ctx context.Context, limit int64) ([]dedupe.Candidate, error) {
+	cmd := s.cli.B().Zrange().Key(s.queueKey()).Min("+inf").Max("-inf").Byscore().Rev().Limit(0, limit).Build()
+	pairs, err := s.cli.Do(ctx, cmd).AsStrSlice()
+	if err != nil { return nil, backendErr(err) }
+	if len(pairs) == 0 { return []dedupe.Candidate{}, nil }
+	vals, err := s.cli.Do(ctx, s.cli.B().Hmget().Key(s.pairsKey()).Field(pairs...).Build()).ToArray()
+	if err != nil { return nil, backendErr(err) }
+	out := make([]dedupe.Candidate, 0, len(pairs))
+	for i, v := range vals {
+		raw, err := v.ToString()
+		if rueidis.IsRedisNil(err) { continue }
+		if err != nil { return nil, backendErr(err) }
+		var c dedupe.Candidate
+		if err := json.Unmarshal([]byte(raw), &c); err != nil {
+			return nil, &CorruptedRecordError{ID: pairs[i], Field: "candidate", Value: raw, Err: err}
+		}
+		out = append(out, c)
+	}
+	return out, nil
+}
+
+// Dismiss removes the pair of a and b from the queue for good; it returns
+// ErrCandidateNotFound when the pair is not queued.
+func (s *DuplicateStorage) Dismiss(ctx context.Context, a, b string) error {
+	e := s.pairExec(a, b, "1")
+	n, err := removeCandidateScript.Exec(ctx, s.cli, e.Keys, e.Args).AsInt64()
+	if err != nil { return backendErr(err) }
+	if n == 0 { return ErrCandidateNotFound }
+	return nil
+}
+
+// RemovePlace drops every queued pair involving id, e.g. once it was merged.
+func (s *DuplicateStorage) RemovePlace(ctx context.Context, id string) error {
+	pairs, err := s.cli.Do(ctx, s.cli.B().Smembers().Key(s.placeKey(id)).Build()).AsStrSlice()
+	if err != nil { return backendErr(err) }
+	if len(pairs) == 0 { return nil }
+	execs := make([]rueidis.LuaExec, len(pairs))
+	for i, p := range pairs {
+		a, b, _ := strings.Cut(p, dedupe.PairSeparator)
+		execs[i] = s.pairExec(a, b, "0")
+	}
+	for _, r := range removeCandidateScript.ExecMulti(ctx, s.cli, execs...) {
+		if err := r.Error(); err != nil { return backendErr(err) }
+	}
+	return backendErr(s.cli.Do(ctx, s.cli.B().Del().Key(s.placeKey(id)).Build()).Error())
+}
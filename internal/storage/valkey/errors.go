@@ -0,0 +1,36 @@
+package valkey
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+
+	"redcat/internal/domain/errs"
+
+	"github.com/redis/rueidis"
+)
+
+// backendErr classifies a rueidis error so the API can tell an unreachable
+// or slow Valkey apart from a bug. The raw error is kept as the cause.
+func backendErr(err error) error {
+	if err == nil || errs.As(err) != nil { return err }
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		return errs.Wrap(errs.Timeout, err, "backend timeout")
+	case errors.Is(err, context.Canceled):
+		return err
+	case errors.Is(err, rueidis.ErrClosing), errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF):
+		return errs.Wrap(errs.BackendUnavailable, err, "backend unavailable")
+	}
+	var ne net.Error
+	if errors.As(err, &ne) {
+		if ne.Timeout() { return errs.Wrap(errs.Timeout, err, "backend timeout") }
+		return errs.Wrap(errs.BackendUnavailable, err, "backend unavailable")
+	}
+	var ve *rueidis.RedisError
+	if errors.As(err, &ve) && (ve.IsLoading() || ve.IsTryAgain() || ve.IsClusterDown()) {
+		return errs.Wrap(errs.BackendUnavailable, err, "backend unavailable")
+	}
+	return errs.Wrap(errs.Internal, err, "backend error")
+}
@@ -0,0 +1,211 @@
+package valkey
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"redcat/internal/domain/geo"
+
+	"github.com/redis/rueidis"
+)
+
+// FacetParams selects the places counted by Facets: those in BBox and, when
+// RadiusM > 0, within RadiusM of Lat/Lon.
+type FacetParams struct {
+	BBox              geo.BBox
+	Lat, Lon, RadiusM float64
+	CategoryIDs       []string
+	// Top caps the buckets returned per facet.
+	Top            int64
+	ExcludeDeleted bool
+}
+
+// FacetCount is one bucket of a facet.
+type FacetCount struct {
+	Value string
+	Count int64
+}
+
+type Facets struct {
+	Total      int64
+	Categories []FacetCount
+	Countries  []FacetCount
+}
+
+// Facets counts the matching places by category and by country with
+// FT.AGGREGATE GROUPBY on the TAG fields. A box crossing the antimeridian is
+// aggregated as its two halves and the counts summed; places without a
+// country are counted in Total only.
+//
+// The radius is applied as a FILTER on the equirectangular distance around
+// the centre, which is exact enough for the radii the API accepts.
+func (s *PlacesStorage) Facets(ctx context.Context, fp FacetParams) (Facets, error) {
+	parts := fp.BBox.Split()
+	// per-part top-N would drop buckets that only make the cut once summed
+	top := fp.Top
+	if len(parts) > 1 { top = 0 }
+
+	cmds := make(rueidis.Commands, 0, 3*len(parts))
+	for _, b := range parts {
+		base := s.facetBase(fp, b)
+		cmds = append(cmds,
+			s.cli.B().Arbitrary("FT.AGGREGATE").Args(append(base, "GROUPBY", "0", "REDUCE", "COUNT", "0", "AS", "count", "DIALECT", "2")...).ReadOnly(),
+			s.facetCmd(base, "@category_ids", top),
+			s.facetCmd(base, "@country", top),
+		)
+	}
+	var out Facets
+	cats, countries := map[string]int64{}, map[string]int64{}
+	for i, r := range s.cli.DoMulti(ctx, cmds...) {
+		rows, err := aggregateRows(r)
+		if err != nil { return Facets{}, err }
+		for _, row := range rows {
+			n, _ := strconv.ParseInt(row["count"], 10, 64)
+			switch i % 3 {
+			case 0:
+				out.Total += n
+			case 1:
+				cats[row["category_id"]] += n
+			case 2:
+				if c := row["country"]; c != "" { countries[c] += n }
+			}
+		}
+	}
+	out.Categories, out.Countries = topCounts(cats, fp.Top), topCounts(countries, fp.Top)
+	return out, nil
+}
+
+// facetBase returns the FT.AGGREGATE arguments selecting fp's places inside
+// b, which must not cross the antimeridian.
+func (s *PlacesStorage) facetBase(fp FacetParams, b geo.BBox) []string {
+	parts := []string{
+		"@lat:[" + formatFloat(b.YMin) + " " + formatFloat(b.YMax) + "]",
+		"@lon:[" + formatFloat(b.XMin) + " " + formatFloat(b.XMax) + "]",
+	}
+	if len(fp.CategoryIDs) > 0 { parts = append(parts, "@category_ids:{"+strings.Join(fp.CategoryIDs, "|")+"}") }
+	if fp.ExcludeDeleted { parts = append(parts, "-@deleted:{1}") }
+	args := []string{s.index, strings.Join(parts, " ")}
+	if fp.RadiusM <= 0 { return args }
+
+	// b is the eastern or western half when the circle crosses the
+	// antimeridian; shift the centre by 360 so it sits next to the half
+	lon := fp.Lon
+	if b.XMax-lon > 180 { lon += 360 }
+	if lon-b.XMin > 180 { lon -= 360 }
+	k := math.Cos(fp.Lat * math.Pi / 180)
+	r := fp.RadiusM / geo.EarthRadiusM * 180 / math.Pi
+	filter := "((@lon - " + formatFloat(lon) + ") * " + formatFloat(k) + ") * ((@lon - " + formatFloat(lon) + ") * " + formatFloat(k) + ")" +
+		" + (@lat - " + formatFloat(fp.Lat) + ") * (@lat - " + formatFloat(fp.Lat) + ") <= " + formatFloat(r*r)
+	return append(args, "LOAD", "2", "@lat", "@lon", "FILTER", filter)
+}
+
+// facetCmd groups the places selected by base by field, largest first.
+func (s *PlacesStorage) facetCmd(base []string, field string, top int64) rueidis.Completed {
+	args := append(append([]string{}, base...), "LOAD", "1", field)
+	name := strings.TrimPrefix(field, "@")
+	if field == "@category_ids" {
+		// a place counts once for each of its categories
+		name = "category_id"
+		args = append(args, "APPLY", "split("+field+", \",\")", "AS", name)
+	}
+	args = append(args, "GROUPBY", "1", "@"+name, "REDUCE", "COUNT", "0", "AS", "count", "SORTBY", "2", "@count", "DESC")
+	if top > 0 { args = append(args, "MAX", strconv.FormatInt(top, 10)) }
+	return s.cli.B().Arbitrary("FT.AGGREGATE").Args(append(args, "DIALECT", "2")...).ReadOnly()
+}
+
+// aggregateRows decodes an FT.AGGREGATE reply: the row count followed by a
+// field/value array per row.
+func aggregateRows(resp rueidis.RedisResult) ([]map[string]string, error) {
+	arr, err := resp.ToArray()
+	if err != nil { return nil, backendErr(err) }
+	if len(arr) == 0 { return nil, nil }
+	rows := make([]map[string]string, 0, len(arr)-1)
+	for _, v := range arr[1:] {
+		m, err := v.AsStrMap()
+		if err != nil { return nil, backendErr(err) }
+		rows = append(rows, m)
+	}
+	return rows, nil
+}
+
+// topCounts returns the n largest buckets of m, ties by value; n <= 0
+// returns all of them.
+func topCounts(m map[string]int64, n int64) []FacetCount {
+	out := make([]FacetCount, 0, len(m))
+	for v, c := range m {
+		if v == "" { continue }
+		out = append(out, FacetCount{Value: v, Count: c})
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Count != out[j].Count { return out[i].Count > out[j].Count }
+		return out[i].Value < out[j].Value
+	})
+	if n > 0 && int64(len(out)) > n { out = out[:n] }
+	return out
+}
+
+type HeatmapParams struct {
+	BBox geo.BBox
+	// Res is one of H3Resolutions.
+	Res         int
+	CategoryIDs []string
+	// MaxCells caps the cells returned, the fullest first.
+	MaxCells       int64
+	ExcludeDeleted bool
+}
+
+// Heatmap counts the places in hp.BBox per H3 cell of resolution hp.Res
+// with FT.AGGREGATE GROUPBY on the stored cell TAG. truncated reports that
+// more than hp.MaxCells cells hold places.
+func (s *PlacesStorage) Heatmap(ctx context.Context, hp HeatmapParams) (cells []FacetCount, truncated bool, err error) {
+	parts := hp.BBox.Split()
+	cmds := make(rueidis.Commands, 0, len(parts))
+	for _, b := range parts {
+		base := s.facetBase(FacetParams{CategoryIDs: hp.CategoryIDs, ExcludeDeleted: hp.ExcludeDeleted}, b)
+		cmds = append(cmds, s.facetCmd(base, "@"+H3Field(hp.Res), hp.MaxCells+1))
+	}
+	counts := map[string]int64{}
+	for _, r := range s.cli.DoMulti(ctx, cmds...) {
+		rows, err := aggregateRows(r)
+		if err != nil { return nil, false, err }
+		if int64(len(rows)) > hp.MaxCells { truncated = true }
+		for _, row := range rows {
+			n, _ := strconv.ParseInt(row["count"], 10, 64)
+			// a cell straddling the antimeridian has places in both halves
+			counts[row[H3Field(hp.Res)]] += n
+		}
+	}
+	cells = topCounts(counts, hp.MaxCells)
+	return cells, truncated || int64(len(counts)) > hp.MaxCells, nil
+}
+
+// CategoryLabels resolves category IDs to labels using one place of each
+// category, whose category_labels run parallel to its category_ids. IDs
+// without places or labels are left out.
+func (s *PlacesStorage) CategoryLabels(ctx context.Context, ids []string) (map[string]string, error) {
+	if len(ids) == 0 { return map[string]string{}, nil }
+	cmds := make(rueidis.Commands, len(ids))
+	for i, id := range ids {
+		cmds[i] = s.cli.B().FtSearch().Index(s.index).Query("@category_ids:{"+id+"}").
+			Return("2").Identifier("category_ids").Identifier("category_labels").
+			Limit().OffsetNum(0, 1).Dialect(2).Build()
+	}
+	out := make(map[string]string, len(ids))
+	for i, r := range s.cli.DoMulti(ctx, cmds...) {
+		arr, err := r.ToArray()
+		if err != nil { return nil, backendErr(err) }
+		if len(arr) < 3 { continue }
+		m, err := arr[2].AsStrMap()
+		if err != nil { return nil, backendErr(err) }
+		var labels []string
+		if json.Unmarshal([]byte(m["category_labels"]), &labels) != nil { continue }
+		for j, c := range strings.Split(m["category_ids"], ",") {
+			if c == ids[i] && j < len(labels) { out[c] = labels[j] }
+		}
+	}
+	return out, nil
+}
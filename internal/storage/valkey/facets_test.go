@@ -0,0 +1,29 @@
+package valkey
+
+import (
+	"strings"
+	"testing"
+
+	"redcat/internal/domain/geo"
+)
+
+func TestFacetBase(t *testing.T) {
+	s := &PlacesStorage{index: "idx"}
+	args := s.facetBase(FacetParams{CategoryIDs: []string{"a", "b"}, ExcludeDeleted: true}, geo.BBox{XMin: 1, YMin: 2, XMax: 3, YMax: 4})
+	if len(args) != 2 || args[1] != "@lat:[2 4] @lon:[1 3] @category_ids:{a|b} -@deleted:{1}" {
+		t.Fatalf("bbox query: %q", args)
+	}
+
+	// a circle around 179.9E: the western half sees the centre at -180.1
+	fp := FacetParams{Lat: 0, Lon: 179.9, RadiusM: 50000}
+	halves := geo.CircleBounds(fp.Lat, fp.Lon, fp.RadiusM).Split()
+	east, west := s.facetBase(fp, halves[0]), s.facetBase(fp, halves[1])
+	if f := east[len(east)-1]; !strings.HasPrefix(f, "((@lon - 179.9) *") { t.Errorf("east filter: %s", f) }
+	if f := west[len(west)-1]; !strings.HasPrefix(f, "((@lon - -180.1) *") { t.Errorf("west filter: %s", f) }
+}
+
+func TestTopCounts(t *testing.T) {
+	got := topCounts(map[string]int64{"a": 1, "b": 3, "c": 3, "": 9}, 2)
+	if len(got) != 2 || got[0] != (FacetCount{"b", 3}) || got[1] != (FacetCount{"c", 3}) { t.Fatalf("got %+v", got) }
+	if got := topCounts(map[string]int64{"a": 1, "b": 2}, 0); len(got) != 2 { t.Fatalf("no cap: %+v", got) }
+}
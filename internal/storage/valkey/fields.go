@@ -0,0 +1,110 @@
+package valkey
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"redcat/internal/domain/errs"
+)
+
+// Projection names accepted by callers map onto one or more hash fields.
+// Names follow the JSON names of model.Place; "location" and "bbox" expand
+// to their flat storage columns.
+var projections = map[string][]string{
+	"id":              {"id"},
+	"name":            {"name"},
+	"location":        {"lat", "lon"},
+	"address":         {"address"},
+	"locality":        {"locality"},
+	"region":          {"region"},
+	"postcode":        {"postcode"},
+	"admin_region":    {"admin_region"},
+	"post_town":       {"post_town"},
+	"po_box":          {"po_box"},
+	"country":         {"country"},
+	"date_created":    {"date_created"},
+	"date_refreshed":  {"date_refreshed"},
+	"date_closed":     {"date_closed"},
+	"tel":             {"tel"},
+	"website":         {"website"},
+	"email":           {"email"},
+	"facebook_id":     {"facebook_id"},
+	"instagram":       {"instagram"},
+	"twitter":         {"twitter"},
+	"category_ids":    {"category_ids"},
+	"category_labels": {"category_labels"},
+	"placemaker_url":  {"placemaker_url"},
+	"bbox":            {"bbox_xmin", "bbox_ymin", "bbox_xmax", "bbox_ymax"},
+	"dt":              {"dt"},
+}
+
+// requiredFields are always fetched: decodePlace needs coordinates, every
+// response carries id, name and category_ids, version feeds the ETag and
+// deleted hides soft-deleted places.
+var requiredFields = []string{"id", "name", "lat", "lon", "category_ids", versionField, deletedField}
+
+// DefaultSearchFields is the projection used by search when none is given.
+var DefaultSearchFields = []string{
+	"id", "name", "location", "address", "locality", "region", "postcode", "country", "category_ids",
+}
+
+// FieldNames lists every accepted projection name in sorted order.
+func FieldNames() []string {
+	out := make([]string, 0, len(projections))
+	for k := range projections {
+		out = append(out, k)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// UnknownFieldError reports projection names that do not map to a place field.
+type UnknownFieldError struct {
+	Fields []string
+}
+
+func (e *UnknownFieldError) Error() string {
+	return fmt.Sprintf("unknown fields: %s", strings.Join(e.Fields, ","))
+}
+
+// ResolveFields expands projection names into the hash fields to fetch,
+// always including requiredFields. An empty projection returns nil, which
+// callers treat as "all fields".
+func ResolveFields(names []string) ([]string, error) {
+	if len(names) == 0 { return nil, nil }
+	seen := make(map[string]bool, len(names)+len(requiredFields))
+	out := make([]string, 0, len(names)+len(requiredFields))
+	add := func(f string) {
+		if !seen[f] { seen[f] = true; out = append(out, f) }
+	}
+	for _, f := range requiredFields { add(f) }
+	var unknown []string
+	for _, n := range names {
+		n = strings.TrimSpace(n)
+		if n == "" { continue }
+		cols, ok := projections[n]
+		if !ok { unknown = append(unknown, n); continue }
+		for _, c := range cols { add(c) }
+	}
+	if len(unknown) > 0 {
+		ufe := &UnknownFieldError{Fields: unknown}
+		return nil, &errs.Error{Kind: errs.InvalidRequest, Message: ufe.Error(), Details: map[string]any{"fields": unknown}, Err: ufe}
+	}
+	return out, nil
+}
+
+// allFields is every stored hash field except the binary location vector:
+// requiredFields followed by the fields of every projection, each once.
+func allFields() []string {
+	seen := make(map[string]bool, 32)
+	out := make([]string, 0, 32)
+	add := func(f string) {
+		if !seen[f] { seen[f] = true; out = append(out, f) }
+	}
+	for _, f := range requiredFields { add(f) }
+	for _, n := range FieldNames() {
+		for _, c := range projections[n] { add(c) }
+	}
+	return out
+}
@@ -0,0 +1,47 @@
+package valkey
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestResolveFields(t *testing.T) {
+	got, err := ResolveFields([]string{"location", "address", "bbox", "name"})
+	if err != nil { t.Fatalf("resolve: %v", err) }
+	want := []string{"id", "name", "lat", "lon", "category_ids", "version", "deleted", "address", "bbox_xmin", "bbox_ymin", "bbox_xmax", "bbox_ymax"}
+	if !reflect.DeepEqual(got, want) { t.Fatalf("want %v got %v", want, got) }
+
+	if got, _ := ResolveFields(nil); got != nil { t.Fatalf("empty projection should mean all fields, got %v", got) }
+
+	_, err = ResolveFields([]string{"name", "location_vec", "secret"})
+	var ufe *UnknownFieldError
+	if !errors.As(err, &ufe) || !reflect.DeepEqual(ufe.Fields, []string{"location_vec", "secret"}) {
+		t.Fatalf("want UnknownFieldError, got %v", err)
+	}
+}
+
+func TestAllFields(t *testing.T) {
+	all := allFields()
+	seen := map[string]bool{}
+	for _, f := range all {
+		if seen[f] { t.Fatalf("%s listed twice: %v", f, all) }
+		seen[f] = true
+	}
+	for _, f := range append([]string{"address", "bbox_xmin", "date_created"}, requiredFields...) {
+		if !seen[f] { t.Fatalf("%s missing from %v", f, all) }
+	}
+}
+
+func TestSearchColumns(t *testing.T) {
+	cols, _ := searchColumns(SearchParams{Hydrate: true, Fields: []string{"name"}})
+	if len(cols) != len(allFields())+1 || cols[len(cols)-1] != scoreField {
+		t.Fatalf("hydrate should return all fields plus score, got %v", cols)
+	}
+	cols, _ = searchColumns(SearchParams{})
+	for _, c := range []string{"address", "country", scoreField} {
+		found := false
+		for _, v := range cols { found = found || v == c }
+		if !found { t.Fatalf("default columns missing %s: %v", c, cols) }
+	}
+}
@@ -0,0 +1,170 @@
+package valkey
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"strings"
+
+	"redcat/internal/domain/errs"
+	"redcat/internal/domain/geo"
+	"redcat/internal/domain/model"
+
+	"github.com/redis/rueidis"
+)
+
+var (
+	ErrGeofenceNotFound = errs.New(errs.NotFound, "geofence not found")
+	ErrGeofenceConflict = errs.New(errs.Conflict, "geofence already exists")
+)
+
+// geofencePage is how many bbox prefilter hits a lookup fetches per FT.SEARCH.
+const geofencePage = 1000
+
+// geofenceReturn lists the hash fields a lookup needs.
+var geofenceReturn = []string{"id", "name", "geometry", "properties"}
+
+// replaceGeofenceScript overwrites an existing fence; DEL first so fields
+// dropped from the new version (properties) do not linger.
+var replaceGeofenceScript = rueidis.NewLuaScript(`
+if redis.call('EXISTS', KEYS[1]) == 0 then return 0 end
+redis.call('DEL', KEYS[1])
+redis.call('HSET', KEYS[1], unpack(ARGV))
+return 1
+`)
+
+var createGeofenceScript = rueidis.NewLuaScript(`
+if redis.call('EXISTS', KEYS[1]) == 1 then return 0 end
+redis.call('HSET', KEYS[1], unpack(ARGV))
+return 1
+`)
+
+// GeofenceStorage keeps one hash per fence under prefix{id}. The geometry
+// is stored as GeoJSON next to its bounding box in NUMERIC fields indexed
+// by EnsureGeofencesIndex, which serves as the prefilter for lookups.
+type GeofenceStorage struct {
+	cli    rueidis.Client
+	index  string
+	prefix string
+}
+
+func NewGeofenceStorage(cli rueidis.Client, index, prefix string) *GeofenceStorage {
+	return &GeofenceStorage{cli: cli, index: index, prefix: prefix}
+}
+
+func (s *GeofenceStorage) key(id string) string { return s.prefix + "{" + id + "}" }
+
+// geofenceFields flattens f into HSET arguments. A box crossing the
+// antimeridian is indexed as spanning every longitude, since one numeric
+// range cannot express it; the exact containment test drops the extra hits.
+func geofenceFields(f model.Geofence) ([]string, error) {
+	g, err := json.Marshal(f.Geometry.GeoJSON())
+	if err != nil { return nil, err }
+	b := f.Geometry.Bounds()
+	if b.CrossesAntimeridian() { b.XMin, b.XMax = -180, 180 }
+	out := []string{
+		"id", f.ID,
+		"name", f.Name,
+		"geometry", string(g),
+		"xmin", formatFloat(b.XMin), "ymin", formatFloat(b.YMin),
+		"xmax", formatFloat(b.XMax), "ymax", formatFloat(b.YMax),
+	}
+	if len(f.Properties) > 0 {
+		p, err := json.Marshal(f.Properties)
+		if err != nil { return nil, err }
+		out = append(out, "properties", string(p))
+	}
+	return out, nil
+}
+
+// Create stores a new fence and returns ErrGeofenceConflict when the ID is taken.
+func (s *GeofenceStorage) Create(ctx context.Context, f model.Geofence) error {
+	if f.ID == "" { return errors.New("empty id") }
+	args, err := geofenceFields(f)
+	if err != nil { return err }
+	n, err := createGeofenceScript.Exec(ctx, s.cli, []string{s.key(f.ID)}, args).AsInt64()
+	if err != nil { return backendErr(err) }
+	if n == 0 { return ErrGeofenceConflict }
+	return nil
+}
+
+// Replace overwrites an existing fence and returns ErrGeofenceNotFound when
+// there is none.
+func (s *GeofenceStorage) Replace(ctx context.Context, f model.Geofence) error {
+	if f.ID == "" { return errors.New("empty id") }
+	args, err := geofenceFields(f)
+	if err != nil { return err }
+	n, err := replaceGeofenceScript.Exec(ctx, s.cli, []string{s.key(f.ID)}, args).AsInt64()
+	if err != nil { return backendErr(err) }
+	if n == 0 { return ErrGeofenceNotFound }
+	return nil
+}
+
+func (s *GeofenceStorage) Get(ctx context.Context, id string) (model.Geofence, error) {
+	m, err := s.cli.Do(ctx, s.cli.B().Hgetall().Key(s.key(id)).Build()).AsStrMap()
+	if err != nil { return model.Geofence{}, backendErr(err) }
+	if len(m) == 0 { return model.Geofence{}, ErrGeofenceNotFound }
+	return decodeGeofence(id, m)
+}
+
+func (s *GeofenceStorage) Delete(ctx context.Context, id string) error {
+	n, err := s.cli.Do(ctx, s.cli.B().Del().Key(s.key(id)).Build()).AsInt64()
+	if err != nil { return backendErr(err) }
+	if n == 0 { return ErrGeofenceNotFound }
+	return nil
+}
+
+// Candidates returns the fences whose bounding box contains the point. The
+// caller still has to test the geometry. Hits are fetched geofencePage at a
+// time until the total the index reports is covered.
+func (s *GeofenceStorage) Candidates(ctx context.Context, lat, lon float64) ([]model.Geofence, error) {
+	y, x := formatFloat(lat), formatFloat(lon)
+	query := "@ymin:[-inf " + y + "] @ymax:[" + y + " +inf] @xmin:[-inf " + x + "] @xmax:[" + x + " +inf]"
+	var out []model.Geofence
+	for offset := int64(0); ; offset += geofencePage {
+		ret := s.cli.B().FtSearch().
+			Index(s.index).
+			Query(query).
+			Return(strconv.Itoa(len(geofenceReturn))).Identifier(geofenceReturn[0])
+		for _, c := range geofenceReturn[1:] { ret = ret.Identifier(c) }
+		cmd := ret.Limit().OffsetNum(offset, geofencePage).Dialect(2).Build()
+		arr, err := s.cli.Do(ctx, cmd).ToArray()
+		if err != nil { return nil, backendErr(err) }
+		if len(arr) == 0 { return out, nil }
+		total, err := arr[0].AsInt64()
+		if err != nil { return nil, backendErr(err) }
+
+		for i := 1; i+1 < len(arr); i += 2 {
+			m, err := arr[i+1].AsStrMap()
+			if err != nil { return nil, backendErr(err) }
+			key, _ := arr[i].ToString()
+			f, err := decodeGeofence(s.idFromKey(key), m)
+			if err != nil { return nil, err }
+			out = append(out, f)
+		}
+		// a short page means fences were deleted while paging
+		if offset+geofencePage >= total || len(arr) < 1+2*geofencePage { return out, nil }
+	}
+}
+
+func (s *GeofenceStorage) idFromKey(key string) string {
+	return strings.TrimSuffix(strings.TrimPrefix(strings.TrimPrefix(key, s.prefix), "{"), "}")
+}
+
+func decodeGeofence(id string, m map[string]string) (model.Geofence, error) {
+	f := model.Geofence{ID: id, Name: m["name"]}
+	var g geo.Geometry
+	if err := json.Unmarshal([]byte(m["geometry"]), &g); err != nil {
+		return model.Geofence{}, &CorruptedRecordError{ID: id, Field: "geometry", Value: m["geometry"], Err: err}
+	}
+	mp, err := geo.ParsePolygonal(g)
+	if err != nil { return model.Geofence{}, &CorruptedRecordError{ID: id, Field: "geometry", Value: m["geometry"], Err: err} }
+	f.Geometry = mp
+	if p := m["properties"]; p != "" {
+		if err := json.Unmarshal([]byte(p), &f.Properties); err != nil {
+			return model.Geofence{}, &CorruptedRecordError{ID: id, Field: "properties", Value: p, Err: err}
+		}
+	}
+	return f, nil
+}
@@ -0,0 +1,95 @@
+package valkey
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+	"time"
+
+	"redcat/internal/domain/audit"
+
+	"github.com/redis/rueidis"
+)
+
+// HistoryStorage keeps the audit trail of each place in its own stream,
+// newest entries last. The key shares the place's hash tag so both live in
+// the same cluster slot.
+type HistoryStorage struct {
+	cli       rueidis.Client
+	keyPrefix string
+	maxLen    int64
+	maxAge    time.Duration
+}
+
+// NewHistoryStorage returns a store that trims every stream to about maxLen
+// entries and drops entries older than maxAge; zero disables either limit.
+func NewHistoryStorage(cli rueidis.Client, prefix string, maxLen int64, maxAge time.Duration) *HistoryStorage {
+	return &HistoryStorage{cli: cli, keyPrefix: prefix, maxLen: maxLen, maxAge: maxAge}
+}
+
+func (s *HistoryStorage) key(id string) string { return "history:" + s.keyPrefix + "{" + id + "}" }
+
+// Append records e and applies the retention limits. Trimming is
+// approximate (~), so streams may briefly exceed them.
+func (s *HistoryStorage) Append(ctx context.Context, e audit.Entry) error {
+	changes, err := json.Marshal(e.Changes)
+	if err != nil { return err }
+	k := s.key(e.PlaceID)
+	cmds := rueidis.Commands{
+		s.cli.B().Xadd().Key(k).Id("*").FieldValue().
+			FieldValue("action", string(e.Action)).
+			FieldValue("actor", e.Actor).
+			FieldValue("at", strconv.FormatInt(e.At.UnixMilli(), 10)).
+			FieldValue("changes", string(changes)).
+			Build(),
+	}
+	if s.maxLen > 0 {
+		cmds = append(cmds, s.cli.B().Xtrim().Key(k).Maxlen().Almost().Threshold(strconv.FormatInt(s.maxLen, 10)).Build())
+	}
+	if s.maxAge > 0 {
+		minID := strconv.FormatInt(e.At.Add(-s.maxAge).UnixMilli(), 10)
+		cmds = append(cmds, s.cli.B().Xtrim().Key(k).Minid().Almost().Threshold(minID).Build())
+	}
+	for _, r := range s.cli.DoMulti(ctx, cmds...) {
+		if err := r.Error(); err != nil { return backendErr(err) }
+	}
+	return nil
+}
+
+// List returns up to limit entries of a place, newest first, starting
+// after the entry ID before ("" for the newest). next is the cursor for the
+// following page, "" when there is none.
+func (s *HistoryStorage) List(ctx context.Context, id, before string, limit int64) (entries []audit.Entry, next string, err error) {
+	end := "+"
+	if before != "" { end = "(" + before }
+	cmd := s.cli.B().Xrevrange().Key(s.key(id)).End(end).Start("-").Count(limit + 1).Build()
+	rows, err := s.cli.Do(ctx, cmd).AsXRange()
+	if err != nil { return nil, "", backendErr(err) }
+	if int64(len(rows)) > limit {
+		rows = rows[:limit]
+		next = rows[len(rows)-1].ID
+	}
+	entries = make([]audit.Entry, 0, len(rows))
+	for _, r := range rows {
+		e, err := decodeEntry(id, r)
+		if err != nil { return nil, "", err }
+		entries = append(entries, e)
+	}
+	return entries, next, nil
+}
+
+func decodeEntry(placeID string, r rueidis.XRangeEntry) (audit.Entry, error) {
+	f := r.FieldValues
+	e := audit.Entry{ID: r.ID, PlaceID: placeID, Action: audit.Action(f["action"]), Actor: f["actor"]}
+	ms, err := strconv.ParseInt(f["at"], 10, 64)
+	if err != nil {
+		return audit.Entry{}, &CorruptedRecordError{ID: placeID, Field: "history.at", Value: f["at"], Err: err}
+	}
+	e.At = time.UnixMilli(ms).UTC()
+	if v := f["changes"]; v != "" {
+		if err := json.Unmarshal([]byte(v), &e.Changes); err != nil {
+			return audit.Entry{}, &CorruptedRecordError{ID: placeID, Field: "history.changes", Value: v, Err: err}
+		}
+	}
+	return e, nil
+}
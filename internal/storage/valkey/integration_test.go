@@ -2,12 +2,23 @@ package valkey
 
 import (
 	"context"
+	"errors"
 	"os"
+	"slices"
+	"strconv"
 	"strings"
 	"testing"
 	"time"
 
+	"redcat/internal/domain/audit"
+	"redcat/internal/domain/dedupe"
+	"redcat/internal/domain/events"
+	"redcat/internal/domain/geo"
+	"redcat/internal/domain/h3"
 	"redcat/internal/domain/model"
+	"redcat/internal/domain/webhooks"
+
+	"github.com/redis/rueidis"
 )
 
 func getEnvAddrs() []string {
@@ -39,7 +50,7 @@ func TestIntegration_Storage_KNN(t *testing.T) {
 
 	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
 	defer cancel()
-	if err := EnsurePlacesIndex(ctx, cli.R, idx, prefix); err != nil {
+	if _, err := EnsurePlacesIndex(ctx, cli.R, idx, prefix); err != nil {
 		t.Fatalf("EnsurePlacesIndex: %v", err)
 	}
 
@@ -51,10 +62,28 @@ func TestIntegration_Storage_KNN(t *testing.T) {
 		{ID: "c", Name: "C", Lat: 51.5074, Lon: -0.1278, CategoryIDs: []string{"othercat"}}, // London
 	}
 	for _, p := range seed {
-		if err := s.Upsert(ctx, p); err != nil {
+		if _, _, err := s.Upsert(ctx, p); err != nil {
 			t.Fatalf("Upsert %s: %v", p.ID, err)
 		}
 	}
+	if _, before, err := s.Upsert(ctx, seed[0]); err != nil || before.ID != "a" || before.Name != "A" {
+		t.Fatalf("Upsert existing: want the replaced place, got %+v (%v)", before, err)
+	}
+	if _, err := s.Create(ctx, seed[0]); !errors.Is(err, ErrConflict) {
+		t.Fatalf("Create existing: want ErrConflict, got %v", err)
+	}
+	got, err := s.Get(ctx, "a")
+	if err != nil { t.Fatalf("Get: %v", err) }
+	if _, err := s.Replace(ctx, got, got.Version+1); !errors.Is(err, ErrPreconditionFailed) {
+		t.Fatalf("Replace stale version: want ErrPreconditionFailed, got %v", err)
+	}
+	v, err := s.Replace(ctx, got, got.Version)
+	if err != nil || v != got.Version+1 {
+		t.Fatalf("Replace: want version %d, got %d (%v)", got.Version+1, v, err)
+	}
+	if _, err := s.Delete(ctx, "missing", v); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Delete missing with version: want ErrNotFound, got %v", err)
+	}
 	// allow index to catch up
 	time.Sleep(500 * time.Millisecond)
 
@@ -66,6 +95,251 @@ func TestIntegration_Storage_KNN(t *testing.T) {
 		t.Fatalf("distance not sorted ascending: %f > %f", res[0].DistanceM, res[1].DistanceM)
 	}
 
+	batch := s.SearchNearestBatch(ctx, []SearchParams{
+		{Lat: 51.5, Lon: -0.12, Limit: 1},
+		{Lat: 35.1705, Lon: 33.3605, Limit: 1, CategoryIDs: []string{"testcat"}},
+		{Lat: 35.1705, Lon: 33.3605, Limit: 1, Fields: []string{"bogus"}},
+	})
+	if batch[0].Err != nil || len(batch[0].Results) != 1 || batch[0].Results[0].Place.ID != "c" {
+		t.Fatalf("batch[0]: want c, got %+v", batch[0])
+	}
+	if batch[1].Err != nil || len(batch[1].Results) != 1 || batch[1].Results[0].Place.ID == "c" {
+		t.Fatalf("batch[1]: want a or b, got %+v", batch[1])
+	}
+	if batch[2].Err == nil { t.Fatal("batch[2]: want an error for the unknown field") }
+
+	locs, err := s.Locate(ctx, []string{"a", "missing", "c"})
+	if err != nil || len(locs) != 2 || locs["c"].Lat != 51.5074 {
+		t.Fatalf("Locate: want a and c, got %+v (%v)", locs, err)
+	}
+
+	if ps, err := s.GetMany(ctx, []string{"c", "missing", "a"}); err != nil || len(ps) != 2 || ps[0].ID != "c" || ps[1].Name != seed[0].Name {
+		t.Fatalf("GetMany: want c and a in order, got %+v (%v)", ps, err)
+	}
+
+	fp := FacetParams{Lat: 35.17, Lon: 33.36, RadiusM: 1000, Top: 5}
+	fp.BBox = geo.CircleBounds(fp.Lat, fp.Lon, fp.RadiusM)
+	facets, err := s.Facets(ctx, fp)
+	if err != nil || facets.Total != 2 || len(facets.Categories) != 1 || facets.Categories[0] != (FacetCount{"testcat", 2}) {
+		t.Fatalf("Facets: want 2 testcat places, got %+v (%v)", facets, err)
+	}
+	cells, truncated, err := s.Heatmap(ctx, HeatmapParams{BBox: fp.BBox, Res: 8, CategoryIDs: []string{"testcat"}, MaxCells: 10})
+	var inCells int64
+	for _, c := range cells { inCells += c.Count }
+	if err != nil || inCells != 2 || truncated {
+		t.Fatalf("Heatmap: want 2 testcat places, got %+v truncated=%v (%v)", cells, truncated, err)
+	}
+	grid, err := s.Grid(ctx, GridParams{BBox: fp.BBox, LatEdges: []float64{fp.BBox.YMin, fp.BBox.YMax}, ColWidth: 360, CategoryIDs: []string{"testcat"}, TopCategories: 5})
+	if err != nil || len(grid) != 1 || grid[0].Count != 2 || grid[0].Categories[0] != (FacetCount{"testcat", 2}) {
+		t.Fatalf("Grid: want one cell of 2 testcat places, got %+v (%v)", grid, err)
+	}
+
+	var scanned []string
+	if err := s.ScanIDs(ctx, 10, func(ids []string) error { scanned = append(scanned, ids...); return nil }); err != nil {
+		t.Fatalf("ScanIDs: %v", err)
+	}
+	for _, p := range seed {
+		if !slices.Contains(scanned, p.ID) { t.Fatalf("ScanIDs: %s missing from %v", p.ID, scanned) }
+	}
+	if err := s.SetAliases(ctx, map[string]string{"gone": "a", "old": "b"}); err != nil { t.Fatalf("SetAliases: %v", err) }
+	if to, err := s.Alias(ctx, "gone"); err != nil || to != "a" { t.Fatalf("Alias: want a, got %q (%v)", to, err) }
+	if to, err := s.Alias(ctx, "a"); err != nil || to != "" { t.Fatalf("Alias: want none, got %q (%v)", to, err) }
+	if err := s.DeleteAlias(ctx, "old"); err != nil { t.Fatalf("DeleteAlias: %v", err) }
+	if err := s.DeleteAlias(ctx, "old"); !errors.Is(err, ErrAliasNotFound) { t.Fatalf("DeleteAlias twice: %v", err) }
+
+	dq := NewDuplicateStorage(cli.R, idx)
+	pair := dedupe.NewCandidate(seed[1], seed[0], 140, dedupe.Scores{Name: 1, Distance: 0.1}, time.Now())
+	if n, err := dq.Add(ctx, []dedupe.Candidate{pair, pair}); err != nil || n != 1 { t.Fatalf("Add: want 1 new pair, got %d (%v)", n, err) }
+	if cs, err := dq.List(ctx, 10); err != nil || len(cs) != 1 || cs[0].ID() != "a|b" { t.Fatalf("List: %+v (%v)", cs, err) }
+	if err := dq.Dismiss(ctx, "b", "a"); err != nil { t.Fatalf("Dismiss: %v", err) }
+	if err := dq.Dismiss(ctx, "a", "b"); !errors.Is(err, ErrCandidateNotFound) { t.Fatalf("Dismiss twice: %v", err) }
+	if n, _ := dq.Add(ctx, []dedupe.Candidate{pair}); n != 0 { t.Fatal("Add: dismissed pair queued again") }
+	other := dedupe.NewCandidate(seed[0], seed[2], 1, dedupe.Scores{Name: 1, Distance: 1}, time.Now())
+	dq.Add(ctx, []dedupe.Candidate{other})
+	if err := dq.RemovePlace(ctx, "c"); err != nil { t.Fatalf("RemovePlace: %v", err) }
+	if cs, _ := dq.List(ctx, 10); len(cs) != 0 { t.Fatalf("RemovePlace: left %+v", cs) }
+
+	h := NewHistoryStorage(cli.R, prefix, 2, 0)
+	for _, a := range []audit.Action{audit.Create, audit.Update, audit.Delete} {
+		e := audit.Entry{PlaceID: "a", Action: a, Actor: "itest", At: time.Now(), Changes: []audit.Change{{Field: "name", After: "A"}}}
+		if err := h.Append(ctx, e); err != nil { t.Fatalf("history append: %v", err) }
+	}
+	entries, next, err := h.List(ctx, "a", "", 10)
+	if err != nil { t.Fatalf("history list: %v", err) }
+	if len(entries) == 0 || entries[0].Action != audit.Delete || next != "" {
+		t.Fatalf("history: want newest first without cursor, got %+v next=%q", entries, next)
+	}
+
 	// cleanup keys
-	for _, p := range seed { _ = s.Delete(ctx, p.ID) }
+	for _, p := range seed { _, _ = s.Delete(ctx, p.ID, AnyVersion) }
+	_ = cli.R.Do(ctx, cli.R.B().Del().Key(h.key("a"), s.aliasesKey(), dq.queueKey(), dq.pairsKey(), dq.dismissedKey()).Build()).Error()
+}
+
+func TestIntegration_EnsurePlacesIndex_UpgradesOldSchema(t *testing.T) {
+	addrs := getEnvAddrs()
+	if len(addrs) == 0 {
+		t.Skip("VALKEY_ADDRS not set; skipping integration test")
+	}
+	cli, err := NewClient(addrs, os.Getenv("VALKEY_USER"), os.Getenv("VALKEY_PASS"))
+	if err != nil { t.Fatalf("client: %v", err) }
+	defer cli.Close()
+
+	idx := "idx:itest:old:" + time.Now().Format("20060102T150405.000000000")
+	prefix := "itest:old:" + time.Now().Format("150405.000000") + ":"
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+	defer cli.R.Do(context.Background(), cli.R.B().FtDropindex().Index(idx).Build())
+
+	// the schema before soft delete and H3 cells
+	old := cli.R.B().Arbitrary("FT.CREATE").Args(idx, "ON", "HASH", "PREFIX", "1", prefix, "SCHEMA",
+		"category_ids", "TAG", "country", "TAG", "lat", "NUMERIC", "lon", "NUMERIC",
+		"location", "VECTOR", "FLAT", "6", "TYPE", "FLOAT32", "DIM", "3", "DISTANCE_METRIC", "L2").Build()
+	if err := cli.R.Do(ctx, old).Error(); err != nil { t.Fatalf("create old index: %v", err) }
+
+	s := NewPlacesStorage(cli.R, idx, prefix)
+	for _, p := range []model.Place{{ID: "live", Lat: 35.17, Lon: 33.36}, {ID: "gone", Lat: 35.17, Lon: 33.36}} {
+		if _, _, err := s.Upsert(ctx, p); err != nil { t.Fatalf("Upsert %s: %v", p.ID, err) }
+		defer s.Delete(context.Background(), p.ID, AnyVersion)
+	}
+	deletedAt := time.UnixMilli(1700000000000)
+	if before, err := s.SoftDelete(ctx, "gone", AnyVersion, deletedAt); err != nil || before.ID != "gone" { t.Fatalf("SoftDelete: %+v (%v)", before, err) }
+	defer cli.R.Do(context.Background(), cli.R.B().Del().Key(s.tombstonesKey()).Build())
+	// failed deletes leave no tombstone and keep the first one's time
+	if _, err := s.SoftDelete(ctx, "gone", AnyVersion, time.Now()); !errors.Is(err, ErrNotFound) { t.Fatalf("SoftDelete twice: %v", err) }
+	if _, err := s.SoftDelete(ctx, "nothing", AnyVersion, time.Now()); !errors.Is(err, ErrNotFound) { t.Fatalf("SoftDelete missing: %v", err) }
+	tombstone := func(id string) (float64, error) {
+		return cli.R.Do(ctx, cli.R.B().Zscore().Key(s.tombstonesKey()).Member(id).Build()).AsFloat64()
+	}
+	if at, err := tombstone("gone"); err != nil || at != float64(deletedAt.UnixMilli()) { t.Fatalf("tombstone: want %d, got %v (%v)", deletedAt.UnixMilli(), at, err) }
+	if _, err := tombstone("nothing"); !rueidis.IsRedisNil(err) { t.Fatalf("tombstone of missing place: %v", err) }
+
+	added, err := EnsurePlacesIndex(ctx, cli.R, idx, prefix)
+	if err != nil { t.Fatalf("EnsurePlacesIndex: %v", err) }
+	want := []string{"deleted", hasAddressField}
+	for _, res := range H3Resolutions { want = append(want, H3Field(res)) }
+	if !slices.Equal(added, want) { t.Fatalf("added: want %v, got %v", want, added) }
+	if added, err := EnsurePlacesIndex(ctx, cli.R, idx, prefix); err != nil || len(added) != 0 {
+		t.Fatalf("second EnsurePlacesIndex: want nothing added, got %v (%v)", added, err)
+	}
+
+	// existing hashes are reindexed in the background
+	var res []SearchResult
+	for range 20 {
+		res, err = s.SearchNearest(ctx, SearchParams{Lat: 35.17, Lon: 33.36, Limit: 10, ExcludeDeleted: true})
+		if err == nil && len(res) == 1 { break }
+		time.Sleep(250 * time.Millisecond)
+	}
+	if err != nil || len(res) != 1 || res[0].Place.ID != "live" {
+		t.Fatalf("search on upgraded index: want only live, got %+v (%v)", res, err)
+	}
+
+	// records written by the old code lack the derived fields until backfilled
+	legacy := cli.R.B().Hset().Key(s.key("legacy")).FieldValue().FieldValue("id", "legacy").FieldValue("lat", "35.17").FieldValue("lon", "33.36").FieldValue("version", "3").Build()
+	broken := cli.R.B().Hset().Key(s.key("broken")).FieldValue().FieldValue("id", "broken").FieldValue("lat", "north").FieldValue("lon", "33.36").Build()
+	for _, cmd := range []rueidis.Completed{legacy, broken} {
+		if err := cli.R.Do(ctx, cmd).Error(); err != nil { t.Fatalf("write old record: %v", err) }
+	}
+	defer cli.R.Do(context.Background(), cli.R.B().Del().Key(s.key("legacy"), s.key("broken")).Build())
+	n, corrupted, err := s.Backfill(ctx, []string{"legacy", "broken", "missing"})
+	if err != nil || n != 1 || !slices.Equal(corrupted, []string{"broken"}) {
+		t.Fatalf("Backfill: want legacy updated and broken skipped, got %d %v (%v)", n, corrupted, err)
+	}
+	fields, err := cli.R.Do(ctx, cli.R.B().Hmget().Key(s.key("legacy")).Field(H3Field(8), "version").Build()).AsStrSlice()
+	if err != nil || fields[0] != h3.FromLatLng(35.17, 33.36, 8).String() || fields[1] != "3" {
+		t.Fatalf("backfilled record: want the h3_8 cell and version 3, got %v (%v)", fields, err)
+	}
+
+	// a tombstone SoftDelete failed to write is recorded by the backfill
+	if err := cli.R.Do(ctx, cli.R.B().Zrem().Key(s.tombstonesKey()).Member("gone").Build()).Error(); err != nil { t.Fatal(err) }
+	if _, _, err := s.Backfill(ctx, []string{"gone", "live"}); err != nil { t.Fatalf("Backfill deleted: %v", err) }
+	if at, err := tombstone("gone"); err != nil || at != float64(deletedAt.UnixMilli()) { t.Fatalf("backfilled tombstone: got %v (%v)", at, err) }
+	if _, err := tombstone("live"); !rueidis.IsRedisNil(err) { t.Fatalf("tombstone of live place: %v", err) }
+}
+
+func TestIntegration_WebhookClaim_SkipsCorrupted(t *testing.T) {
+	addrs := getEnvAddrs()
+	if len(addrs) == 0 {
+		t.Skip("VALKEY_ADDRS not set; skipping integration test")
+	}
+	cli, err := NewClient(addrs, os.Getenv("VALKEY_USER"), os.Getenv("VALKEY_PASS"))
+	if err != nil { t.Fatalf("client: %v", err) }
+	defer cli.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	s := NewWebhookStorage(cli.R, "itest:"+time.Now().Format("150405.000000"), 10)
+	defer cli.R.Do(context.Background(), cli.R.B().Del().Key(s.deliveriesKey(), s.queueKey(), s.deadKey()).Build())
+	now := time.Now()
+	if err := s.Enqueue(ctx, webhooks.Delivery{ID: "good", NextAt: now}); err != nil { t.Fatalf("Enqueue: %v", err) }
+	bad := scheduleScript.Exec(ctx, cli.R, []string{s.deliveriesKey(), s.queueKey()}, []string{"bad", "{", strconv.FormatInt(now.UnixMilli(), 10)})
+	if err := bad.Error(); err != nil { t.Fatalf("write corrupted delivery: %v", err) }
+
+	ds, err := s.Claim(ctx, now, time.Minute, 10)
+	var cre *CorruptedRecordError
+	if !errors.As(err, &cre) || cre.ID != "bad" { t.Fatalf("Claim: want a corrupted record error naming bad, got %v", err) }
+	if len(ds) != 1 || ds[0].ID != "good" { t.Fatalf("Claim: want the good delivery, got %+v", ds) }
+	// the corrupted delivery is gone, the good one leased
+	if ds, err := s.Claim(ctx, now.Add(2*time.Minute), time.Minute, 10); err != nil || len(ds) != 1 || ds[0].ID != "good" {
+		t.Fatalf("second Claim: want only good, got %+v (%v)", ds, err)
+	}
+}
+
+func TestIntegration_Outbox(t *testing.T) {
+	addrs := getEnvAddrs()
+	if len(addrs) == 0 {
+		t.Skip("VALKEY_ADDRS not set; skipping integration test")
+	}
+	cli, err := NewClient(addrs, os.Getenv("VALKEY_USER"), os.Getenv("VALKEY_PASS"))
+	if err != nil { t.Fatalf("client: %v", err) }
+	defer cli.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	s := NewPlacesStorage(cli.R, "idx:itest:outbox", "itest:"+time.Now().Format("150405.000000")+":")
+	defer cli.R.Do(context.Background(), cli.R.B().Del().Key(s.key("p1"), s.outboxKey("p1")).Build())
+	if _, _, err := s.Upsert(ctx, model.Place{ID: "p1", Name: "A", Lat: 1, Lon: 2}); err != nil { t.Fatalf("Upsert: %v", err) }
+	if _, err := s.Delete(ctx, "p1", AnyVersion); err != nil { t.Fatalf("Delete: %v", err) }
+
+	var pending []string
+	if err := s.ScanOutboxes(ctx, 100, func(ids []string) error { pending = append(pending, ids...); return nil }); err != nil { t.Fatalf("ScanOutboxes: %v", err) }
+	if !slices.Contains(pending, "p1") { t.Fatalf("ScanOutboxes: want p1 pending, got %v", pending) }
+
+	// a failed publish keeps the event and everything after it
+	if n, err := s.DrainOutbox(ctx, "p1", func(context.Context, events.PlaceChanged) error { return errors.New("feed down") }); err == nil || n != 0 {
+		t.Fatalf("failing DrainOutbox: got %d, %v", n, err)
+	}
+	var got []events.PlaceChanged
+	n, err := s.DrainOutbox(ctx, "p1", func(_ context.Context, ev events.PlaceChanged) error { got = append(got, ev); return nil })
+	if err != nil || n != 2 { t.Fatalf("DrainOutbox: got %d, %v", n, err) }
+	if got[0].Action != audit.Create || got[0].Version != 1 || got[0].Place == nil || got[0].Place.Version != 1 || got[1].Action != audit.Delete || got[1].Version != 1 {
+		t.Fatalf("drained events: %+v", got)
+	}
+	if n, err := cli.R.Do(ctx, cli.R.B().Exists().Key(s.outboxKey("p1")).Build()).AsInt64(); err != nil || n != 0 {
+		t.Fatalf("outbox left behind after drain: %d (%v)", n, err)
+	}
+}
+
+func TestIntegration_ChangeFeed_ExpiredCursor(t *testing.T) {
+	addrs := getEnvAddrs()
+	if len(addrs) == 0 {
+		t.Skip("VALKEY_ADDRS not set; skipping integration test")
+	}
+	cli, err := NewClient(addrs, os.Getenv("VALKEY_USER"), os.Getenv("VALKEY_PASS"))
+	if err != nil { t.Fatalf("client: %v", err) }
+	defer cli.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	f := NewChangeFeed(cli.R, "itest:changes:"+time.Now().Format("150405.000000"), 1, 0)
+	defer cli.R.Do(context.Background(), cli.R.B().Del().Key(f.key(0)).Build())
+	for v := int64(1); v <= 3; v++ {
+		if err := f.Publish(ctx, events.PlaceChanged{PlaceID: "p1", Action: audit.Update, At: time.Now(), Version: v}); err != nil { t.Fatalf("Publish: %v", err) }
+	}
+	evs, _, err := f.Read(ctx, "", 10)
+	if err != nil || len(evs) != 3 { t.Fatalf("Read: got %d events (%v)", len(evs), err) }
+	if err := cli.R.Do(ctx, cli.R.B().Xtrim().Key(f.key(0)).Maxlen().Threshold("1").Build()).Error(); err != nil { t.Fatal(err) }
+
+	if _, _, err := f.Read(ctx, evs[0].ID, 10); !errors.Is(err, ErrCursorExpired) { t.Fatalf("cursor before the trim: want ErrCursorExpired, got %v", err) }
+	got, _, err := f.Read(ctx, evs[1].ID, 10)
+	if err != nil || len(got) != 1 || got[0].Version != 3 { t.Fatalf("cursor at the trim: want the last event, got %+v (%v)", got, err) }
 }
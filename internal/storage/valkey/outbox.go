@@ -0,0 +1,127 @@
+package valkey
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math/rand/v2"
+	"strconv"
+	"strings"
+	"time"
+
+	"redcat/internal/domain/events"
+	"redcat/internal/domain/model"
+
+	"github.com/redis/rueidis"
+)
+
+// Every write script appends the change feed event of its mutation to the
+// outbox of the place, a stream under the place's hash tag, in the same
+// script as the write: a committed write always leaves its event behind.
+// DrainOutbox moves the events on to the feed and removes them; what a
+// failed relay leaves is found again by ScanOutboxes.
+//
+// The scripts take three trailing arguments for the event (see eventArgs):
+// the place ID, the time in unix ms and the place JSON, "" for deletes.
+const outboxPrelude = `
+local function outbox(action, version, place)
+  local n = #ARGV
+  redis.call('XADD', KEYS[2], '*', 'place_id', ARGV[n-2], 'action', action,
+    'at', ARGV[n-1], 'version', version, 'place', place)
+end
+`
+
+// outboxBatch is how many events DrainOutbox reads per XRANGE.
+const outboxBatch = 100
+
+// outboxLease bounds how long a dead relay keeps others from draining an
+// outbox.
+const outboxLease = 30 * time.Second
+
+// ackOutboxScript removes a relayed event, and the outbox with its last one
+// so that ScanOutboxes only finds pending work.
+var ackOutboxScript = rueidis.NewLuaScript(`
+redis.call('XDEL', KEYS[1], ARGV[1])
+if redis.call('XLEN', KEYS[1]) == 0 then redis.call('DEL', KEYS[1]) end
+return 1
+`)
+
+// unlockOutboxScript releases the relay lease if it is still ours.
+var unlockOutboxScript = rueidis.NewLuaScript(`
+if redis.call('GET', KEYS[1]) == ARGV[1] then return redis.call('DEL', KEYS[1]) end
+return 0
+`)
+
+// outboxKey is outside the place prefix, so the index never sees it, but
+// shares the place's hash tag.
+func (s *PlacesStorage) outboxKey(id string) string { return "outbox:" + s.key(id) }
+
+func (s *PlacesStorage) outboxLockKey(id string) string { return s.outboxKey(id) + ":lock" }
+
+// writeKeys are the KEYS of a write script for place id.
+func (s *PlacesStorage) writeKeys(id string) []string {
+	return []string{s.key(id), s.outboxKey(id)}
+}
+
+// eventArgs are the trailing script arguments describing the event of a
+// write of place id at at; p is the place after the write, nil for deletes.
+// Its version is set from the event when relayed.
+func eventArgs(id string, at time.Time, p *model.Place) ([]string, error) {
+	var place []byte
+	if p != nil {
+		var err error
+		if place, err = json.Marshal(p); err != nil { return nil, err }
+	}
+	return []string{id, strconv.FormatInt(at.UnixMilli(), 10), string(place)}, nil
+}
+
+// DrainOutbox hands the pending events of place id to publish, oldest
+// first, removing each once published, and returns how many it published.
+// It stops at the first publish error, so the events keep their order. A
+// drain already running for the place makes it return 0 at once. Events
+// that cannot be decoded are dropped and reported as CorruptedRecordErrors.
+func (s *PlacesStorage) DrainOutbox(ctx context.Context, id string, publish func(context.Context, events.PlaceChanged) error) (int, error) {
+	token := strconv.FormatUint(rand.Uint64(), 36)
+	lock := s.cli.B().Set().Key(s.outboxLockKey(id)).Value(token).Nx().Px(outboxLease).Build()
+	if err := s.cli.Do(ctx, lock).Error(); rueidis.IsRedisNil(err) {
+		return 0, nil
+	} else if err != nil {
+		return 0, backendErr(err)
+	}
+	defer unlockOutboxScript.Exec(context.WithoutCancel(ctx), s.cli, []string{s.outboxLockKey(id)}, []string{token})
+
+	key := s.outboxKey(id)
+	var n int
+	var corrupted []error
+	for {
+		rows, err := s.cli.Do(ctx, s.cli.B().Xrange().Key(key).Start("-").End("+").Count(outboxBatch).Build()).AsXRange()
+		if err != nil { return n, backendErr(err) }
+		if len(rows) == 0 { return n, errors.Join(corrupted...) }
+		for _, row := range rows {
+			ev, err := decodeEvent(row)
+			if err != nil {
+				corrupted = append(corrupted, err)
+			} else {
+				ev.ID = ""
+				if ev.Place != nil { ev.Place.Version = ev.Version }
+				if err := publish(ctx, ev); err != nil { return n, errors.Join(append(corrupted, err)...) }
+				n++
+			}
+			if err := ackOutboxScript.Exec(ctx, s.cli, []string{key}, []string{row.ID}).Error(); err != nil {
+				return n, backendErr(err)
+			}
+		}
+	}
+}
+
+// ScanOutboxes calls fn with the IDs of places that have pending events,
+// about batch at a time, until every primary has been scanned or fn fails.
+func (s *PlacesStorage) ScanOutboxes(ctx context.Context, batch int64, fn func(ids []string) error) error {
+	prefix := s.outboxKey("")
+	prefix = prefix[:len(prefix)-1] // up to and including the "{"
+	return s.scanKeys(ctx, prefix+"*", "stream", batch, func(keys []string) error {
+		ids := make([]string, len(keys))
+		for i, k := range keys { ids[i] = strings.TrimSuffix(strings.TrimPrefix(k, prefix), "}") }
+		return fn(ids)
+	})
+}
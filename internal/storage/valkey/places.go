@@ -2,18 +2,60 @@ package valkey
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"math"
 	"sort"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
 
+	"redcat/internal/domain/errs"
 	"redcat/internal/domain/geo"
+	"redcat/internal/domain/h3"
 	"redcat/internal/domain/model"
 
 	"github.com/redis/rueidis"
 )
 
+// ErrNotFound is returned when no hash exists for the requested place ID.
+var ErrNotFound = errs.New(errs.NotFound, "place not found")
+
+// ErrCorrupted is the sentinel matched by CorruptedRecordError via errors.Is.
+var ErrCorrupted = errors.New("corrupted place record")
+
+// CorruptedRecordError reports a stored hash whose field cannot be decoded
+// (missing or unparsable coordinates, bad bbox numbers). It is returned
+// instead of a zero-valued place so callers never see Null Island.
+type CorruptedRecordError struct {
+	ID    string
+	Field string
+	Value string
+	Err   error
+}
+
+func (e *CorruptedRecordError) Error() string {
+	return fmt.Sprintf("corrupted place record %q: field %s=%q: %v", e.ID, e.Field, e.Value, e.Err)
+}
+
+func (e *CorruptedRecordError) Unwrap() error { return e.Err }
+
+func (e *CorruptedRecordError) Is(target error) bool { return target == ErrCorrupted }
+
+// As classifies the error as errs.Corrupted, naming the record and field
+// but not the stored value.
+func (e *CorruptedRecordError) As(target any) bool {
+	t, ok := target.(**errs.Error)
+	if !ok { return false }
+	*t = &errs.Error{
+		Kind: errs.Corrupted, Message: "corrupted record",
+		Details: map[string]any{"id": e.ID, "field": e.Field}, Err: e,
+	}
+	return true
+}
+
 type PlacesStorage struct {
 	cli       rueidis.Client
 	index     string
@@ -26,6 +68,11 @@ func NewPlacesStorage(cli rueidis.Client, index, prefix string) *PlacesStorage {
 
 func (s *PlacesStorage) key(id string) string { return s.keyPrefix + "{" + id + "}" }
 
+// idFromKey reverses key; used to attribute errors when the hash lacks an id field.
+func (s *PlacesStorage) idFromKey(k string) string {
+	return strings.TrimSuffix(strings.TrimPrefix(strings.TrimPrefix(k, s.keyPrefix), "{"), "}")
+}
+
 func joinCats(ids []string) string {
 	clean := make([]string, 0, len(ids))
 	for _, v := range ids {
@@ -37,53 +84,250 @@ func joinCats(ids []string) string {
 	return strings.Join(clean, ",")
 }
 
-func (s *PlacesStorage) Upsert(ctx context.Context, p model.Place) error {
-	if p.ID == "" {
-		return errors.New("empty id")
+// H3Resolutions are the resolutions whose cell of each place is stored in
+// the TAG field H3Field(res), for heatmap aggregation.
+var H3Resolutions = []int{5, 6, 7, 8}
+
+func H3Field(res int) string { return "h3_" + strconv.Itoa(res) }
+
+// hashFields flattens p into HSET field/value pairs, derived fields
+// included.
+func hashFields(p model.Place) []string {
+	f := []string{
+		"id", p.ID,
+		"name", p.Name,
+		"lat", formatFloat(p.Lat),
+		"lon", formatFloat(p.Lon),
+		"address", p.Address,
+		"locality", p.Locality,
+		"region", p.Region,
+		"postcode", p.Postcode,
+		"admin_region", p.AdminRegion,
+		"post_town", p.PostTown,
+		"po_box", p.PoBox,
+		"country", p.Country,
+		"date_created", p.DateCreated,
+		"date_refreshed", p.DateRefreshed,
+		"date_closed", p.DateClosed,
+		"tel", p.Tel,
+		"website", p.Website,
+		"email", p.Email,
+		"facebook_id", p.FacebookID,
+		"instagram", p.Instagram,
+		"twitter", p.Twitter,
+		"category_ids", joinCats(p.CategoryIDs),
+		"category_labels", encodeLabels(p.CategoryLabels),
+		"placemaker_url", p.PlacemakerURL,
+		"bbox_xmin", formatFloat(p.BBox.XMin),
+		"bbox_ymin", formatFloat(p.BBox.YMin),
+		"bbox_xmax", formatFloat(p.BBox.XMax),
+		"bbox_ymax", formatFloat(p.BBox.YMax),
+		"dt", p.Dt,
 	}
+	return append(f, derivedFields(p)...)
+}
+
+// hasAddressField is "1" for places with any address component, so that
+// reverse geocoding can skip the others in the query.
+const hasAddressField = "has_address"
+
+// derivedFields are the indexed fields computed from the place rather than
+// given by the client: the location vector, the address flag and the H3
+// cells. Backfill rewrites them for records stored before a field was added.
+func derivedFields(p model.Place) []string {
 	vec := geo.ToECEF(p.Lat, p.Lon)
-	cmd := s.cli.B().
-		Hset().
-		Key(s.key(p.ID)).
-		FieldValue().
-		FieldValue("id", p.ID).
-		FieldValue("name", p.Name).
-		FieldValue("lat", fmt.Sprintf("%f", p.Lat)).
-		FieldValue("lon", fmt.Sprintf("%f", p.Lon)).
-		FieldValue("address", p.Address).
-		FieldValue("locality", p.Locality).
-		FieldValue("region", p.Region).
-		FieldValue("postcode", p.Postcode).
-		FieldValue("admin_region", p.AdminRegion).
-		FieldValue("post_town", p.PostTown).
-		FieldValue("po_box", p.PoBox).
-		FieldValue("country", p.Country).
-		FieldValue("date_created", p.DateCreated).
-		FieldValue("date_refreshed", p.DateRefreshed).
-		FieldValue("date_closed", p.DateClosed).
-		FieldValue("tel", p.Tel).
-		FieldValue("website", p.Website).
-		FieldValue("email", p.Email).
-		FieldValue("facebook_id", p.FacebookID).
-		FieldValue("instagram", p.Instagram).
-		FieldValue("twitter", p.Twitter).
-		FieldValue("category_ids", joinCats(p.CategoryIDs)).
-		FieldValue("placemaker_url", p.PlacemakerURL).
-		FieldValue("bbox_xmin", fmt.Sprintf("%f", p.BBox.XMin)).
-		FieldValue("bbox_ymin", fmt.Sprintf("%f", p.BBox.YMin)).
-		FieldValue("bbox_xmax", fmt.Sprintf("%f", p.BBox.XMax)).
-		FieldValue("bbox_ymax", fmt.Sprintf("%f", p.BBox.YMax)).
-		FieldValue("dt", p.Dt).
-		FieldValue("location", rueidis.VectorString32(vec[:])).
-		Build()
-	return s.cli.Do(ctx, cmd).Error()
-}
-
-func (s *PlacesStorage) Get(ctx context.Context, id string) (model.Place, error) {
+	hasAddress := "0"
+	if p.Address+p.Locality+p.Region+p.Postcode+p.Country != "" { hasAddress = "1" }
+	f := []string{"location", rueidis.VectorString32(vec[:]), hasAddressField, hasAddress}
+	for _, res := range H3Resolutions { f = append(f, H3Field(res), h3.FromLatLng(p.Lat, p.Lon, res).String()) }
+	return f
+}
+
+// versionField holds the per-place write counter. Every write script bumps
+// it atomically with the HSET, so it doubles as the ETag of the record.
+const versionField = "version"
+
+// AnyVersion disables the version check of Replace and Delete.
+const AnyVersion int64 = -1
+
+// ErrConflict is returned by Create when a place with the same ID exists.
+var ErrConflict = errs.New(errs.Conflict, "place already exists")
+
+// ErrPreconditionFailed is returned by Replace and Delete when the stored
+// version differs from the expected one.
+var ErrPreconditionFailed = errs.New(errs.PreconditionFailed, "place version mismatch")
+
+// upsertScript overwrites the hash, reviving a soft-deleted place, and bumps
+// its version in one step. It returns the new version and the fields the
+// hash had before. Like all write scripts it appends the event to the
+// outbox KEYS[2] (see outboxPrelude).
+var upsertScript = rueidis.NewLuaScript(outboxPrelude + `
+local before = redis.call('HGETALL', KEYS[1])
+local action = 'update'
+if #before == 0 or redis.call('HGET', KEYS[1], 'deleted') == '1' then action = 'create' end
+redis.call('HDEL', KEYS[1], 'deleted', 'deleted_at')
+redis.call('HSET', KEYS[1], unpack(ARGV, 1, #ARGV - 3))
+local v = redis.call('HINCRBY', KEYS[1], 'version', 1)
+outbox(action, v, ARGV[#ARGV])
+return {v, before}
+`)
+
+// createScript writes the hash only if the key does not exist yet, so two
+// concurrent creates with the same ID cannot both succeed.
+var createScript = rueidis.NewLuaScript(outboxPrelude + `
+if redis.call('EXISTS', KEYS[1]) == 1 then return 0 end
+redis.call('HSET', KEYS[1], unpack(ARGV, 1, #ARGV - 3))
+local v = redis.call('HINCRBY', KEYS[1], 'version', 1)
+outbox('create', v, ARGV[#ARGV])
+return v
+`)
+
+// casPrelude resolves the current version of KEYS[1] (0 for records written
+// before versioning) and returns -1 when the key is missing or soft-deleted
+// or -2 when ARGV[1] names another version. ARGV[1] = "-1" skips the
+// comparison.
+const casPrelude = `
+if redis.call('HGET', KEYS[1], 'deleted') == '1' then return -1 end
+local cur = redis.call('HGET', KEYS[1], 'version')
+if cur == false then
+  if redis.call('EXISTS', KEYS[1]) == 0 then return -1 end
+  cur = '0'
+end
+if ARGV[1] ~= '-1' and ARGV[1] ~= cur then return -2 end
+`
+
+// replaceScript overwrites an existing hash if its version matches.
+var replaceScript = rueidis.NewLuaScript(outboxPrelude + casPrelude + `
+redis.call('HSET', KEYS[1], unpack(ARGV, 2, #ARGV - 3))
+local v = redis.call('HINCRBY', KEYS[1], 'version', 1)
+outbox('update', v, ARGV[#ARGV])
+return v
+`)
+
+// deleteScript removes an existing hash if its version matches and returns
+// its fields. With ARGV[1] = "-1" soft-deleted places are removed too. The
+// event carries the version of the removed record.
+var deleteScript = rueidis.NewLuaScript(outboxPrelude + `
+if ARGV[1] ~= '-1' then` + casPrelude + `end
+local before = redis.call('HGETALL', KEYS[1])
+if #before == 0 then return -1 end
+local v = redis.call('HGET', KEYS[1], 'version') or '0'
+redis.call('DEL', KEYS[1])
+outbox('delete', v, '')
+return before
+`)
+
+// Upsert writes p, overwriting any existing record with the same ID, and
+// returns the new version and the place it replaced, which is the zero Place
+// when there was none or it was soft-deleted.
+func (s *PlacesStorage) Upsert(ctx context.Context, p model.Place) (int64, model.Place, error) {
+	if p.ID == "" {
+		return 0, model.Place{}, errors.New("empty id")
+	}
+	ev, err := eventArgs(p.ID, time.Now(), &p)
+	if err != nil { return 0, model.Place{}, err }
+	arr, err := upsertScript.Exec(ctx, s.cli, s.writeKeys(p.ID), append(hashFields(p), ev...)).ToArray()
+	if err != nil { return 0, model.Place{}, backendErr(err) }
+	v, err := arr[0].AsInt64()
+	if err != nil { return 0, model.Place{}, backendErr(err) }
+	return v, previousPlace(p.ID, arr[1]), nil
+}
+
+// previousPlace decodes the fields a write script read before changing the
+// hash; soft-deleted records count as none. A record that cannot be decoded
+// is reported by its ID alone, since the write already happened.
+func previousPlace(id string, fields rueidis.RedisMessage) model.Place {
+	m, err := fields.AsStrMap()
+	if err != nil || len(m) == 0 || m[deletedField] == "1" { return model.Place{} }
+	p, err := decodePlace(id, m)
+	if err != nil { return model.Place{ID: id} }
+	return p
+}
+
+// scriptPlace reads the reply of a CAS script that returns either a
+// casPrelude error code or the fields of the hash before the write.
+func scriptPlace(id string, r rueidis.RedisResult) (model.Place, error) {
+	msg, err := r.ToMessage()
+	if err != nil { return model.Place{}, backendErr(err) }
+	if v, err := msg.AsInt64(); err == nil { return model.Place{}, casErr(v) }
+	return previousPlace(id, msg), nil
+}
+
+// Create writes p only when no place with p.ID exists; otherwise it returns
+// ErrConflict. It returns the version of the new record.
+func (s *PlacesStorage) Create(ctx context.Context, p model.Place) (int64, error) {
+	if p.ID == "" {
+		return 0, errors.New("empty id")
+	}
+	ev, err := eventArgs(p.ID, time.Now(), &p)
+	if err != nil { return 0, err }
+	v, err := createScript.Exec(ctx, s.cli, s.writeKeys(p.ID), append(hashFields(p), ev...)).AsInt64()
+	if err != nil { return 0, backendErr(err) }
+	if v == 0 { return 0, ErrConflict }
+	return v, nil
+}
+
+// Replace overwrites an existing place when its stored version equals
+// ifVersion (or ifVersion is AnyVersion) and returns the new version. The
+// check and the write run as one script. It returns ErrNotFound for a
+// missing place and ErrPreconditionFailed on a version mismatch.
+func (s *PlacesStorage) Replace(ctx context.Context, p model.Place, ifVersion int64) (int64, error) {
+	if p.ID == "" {
+		return 0, errors.New("empty id")
+	}
+	ev, err := eventArgs(p.ID, time.Now(), &p)
+	if err != nil { return 0, err }
+	args := append(append([]string{strconv.FormatInt(ifVersion, 10)}, hashFields(p)...), ev...)
+	v, err := replaceScript.Exec(ctx, s.cli, s.writeKeys(p.ID), args).AsInt64()
+	if err != nil { return 0, backendErr(err) }
+	return v, casErr(v)
+}
+
+// casErr maps the negative replies of casPrelude onto storage errors.
+func casErr(v int64) error {
+	switch v {
+	case -1:
+		return ErrNotFound
+	case -2:
+		return ErrPreconditionFailed
+	}
+	return nil
+}
+
+// encodeLabels stores labels as a JSON array: Foursquare labels contain
+// commas, so the TAG-style comma join used for category_ids would be lossy.
+func encodeLabels(labels []string) string {
+	if len(labels) == 0 { return "" }
+	b, _ := json.Marshal(labels)
+	return string(b)
+}
+
+// Get loads a place. fields is a projection (see ResolveFields); when empty
+// the whole hash is returned.
+func (s *PlacesStorage) Get(ctx context.Context, id string, fields ...string) (model.Place, error) {
 	if id == "" { return model.Place{}, errors.New("empty id") }
-	m, err := s.cli.Do(ctx, s.cli.B().Hgetall().Key(s.key(id)).Build()).AsStrMap()
+	cols, err := ResolveFields(fields)
 	if err != nil { return model.Place{}, err }
-p := model.Place{
+	if cols == nil {
+		m, err := s.cli.Do(ctx, s.cli.B().Hgetall().Key(s.key(id)).Build()).AsStrMap()
+		if err != nil { return model.Place{}, backendErr(err) }
+		if len(m) == 0 || m[deletedField] == "1" { return model.Place{}, ErrNotFound }
+		return decodePlace(id, m)
+	}
+	vals, err := s.cli.Do(ctx, s.cli.B().Hmget().Key(s.key(id)).Field(cols...).Build()).ToArray()
+	if err != nil { return model.Place{}, backendErr(err) }
+	m := make(map[string]string, len(cols))
+	for i, v := range vals {
+		if str, err := v.ToString(); err == nil { m[cols[i]] = str }
+	}
+	if len(m) == 0 || m[deletedField] == "1" { return model.Place{}, ErrNotFound }
+	return decodePlace(id, m)
+}
+
+// decodePlace maps a stored hash to model.Place. Coordinates are required;
+// bbox fields are optional but must parse when present.
+func decodePlace(id string, m map[string]string) (model.Place, error) {
+	p := model.Place{
 		ID:      m["id"],
 		Name:    m["name"],
 		Address: m["address"],
@@ -106,28 +350,247 @@ p := model.Place{
 		PlacemakerURL: m["placemaker_url"],
 		Dt: m["dt"],
 	}
-fmt.Sscanf(m["lat"], "%f", &p.Lat)
-fmt.Sscanf(m["lon"], "%f", &p.Lon)
-if cats := strings.TrimSpace(m["category_ids"]); cats != "" {
-		p.CategoryIDs = strings.Split(cats, ",")
+	if p.ID == "" { p.ID = id }
+	var err error
+	if v := m[versionField]; v != "" {
+		if p.Version, err = strconv.ParseInt(v, 10, 64); err != nil {
+			return model.Place{}, &CorruptedRecordError{ID: p.ID, Field: versionField, Value: v, Err: err}
+		}
+	}
+	if p.Lat, err = parseCoord(p.ID, m, "lat", -90, 90); err != nil { return model.Place{}, err }
+	if p.Lon, err = parseCoord(p.ID, m, "lon", -180, 180); err != nil { return model.Place{}, err }
+	p.CategoryIDs = splitCats(m["category_ids"])
+	if v := m["category_labels"]; v != "" {
+		if err := json.Unmarshal([]byte(v), &p.CategoryLabels); err != nil {
+			return model.Place{}, &CorruptedRecordError{ID: p.ID, Field: "category_labels", Value: v, Err: err}
+		}
+	}
+	// bbox (optional fields)
+	for _, f := range []struct {
+		name string
+		dst  *float64
+	}{
+		{"bbox_xmin", &p.BBox.XMin},
+		{"bbox_ymin", &p.BBox.YMin},
+		{"bbox_xmax", &p.BBox.XMax},
+		{"bbox_ymax", &p.BBox.YMax},
+	} {
+		v, ok := m[f.name]
+		if !ok || v == "" { continue }
+		if *f.dst, err = parseFloat(v); err != nil {
+			return model.Place{}, &CorruptedRecordError{ID: p.ID, Field: f.name, Value: v, Err: err}
+		}
+	}
+	return p, nil
 }
-// bbox (optional fields)
-fmt.Sscanf(m["bbox_xmin"], "%f", &p.BBox.XMin)
-fmt.Sscanf(m["bbox_ymin"], "%f", &p.BBox.YMin)
-fmt.Sscanf(m["bbox_xmax"], "%f", &p.BBox.XMax)
-fmt.Sscanf(m["bbox_ymax"], "%f", &p.BBox.YMax)
-return p, nil
+
+// formatFloat renders v with the shortest representation that round-trips
+// to the same float64.
+func formatFloat(v float64) string { return strconv.FormatFloat(v, 'f', -1, 64) }
+
+func parseFloat(v string) (float64, error) {
+	f, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+	if err != nil { return 0, err }
+	if math.IsNaN(f) || math.IsInf(f, 0) { return 0, fmt.Errorf("non-finite value %v", f) }
+	return f, nil
+}
+
+func parseCoord(id string, m map[string]string, field string, min, max float64) (float64, error) {
+	v, ok := m[field]
+	if !ok || strings.TrimSpace(v) == "" {
+		return 0, &CorruptedRecordError{ID: id, Field: field, Value: v, Err: errors.New("missing")}
+	}
+	f, err := parseFloat(v)
+	if err != nil {
+		return 0, &CorruptedRecordError{ID: id, Field: field, Value: v, Err: err}
+	}
+	if f < min || f > max {
+		return 0, &CorruptedRecordError{ID: id, Field: field, Value: v, Err: fmt.Errorf("out of range [%g, %g]", min, max)}
+	}
+	return f, nil
+}
+
+func splitCats(s string) []string {
+	s = strings.TrimSpace(s)
+	if s == "" { return nil }
+	return strings.Split(s, ",")
+}
+
+// Locate reads the coordinates of many places in one pipeline. The result
+// has ID, Lat and Lon set for every place found; missing and soft-deleted
+// IDs are absent.
+func (s *PlacesStorage) Locate(ctx context.Context, ids []string) (map[string]model.Place, error) {
+	cmds := make(rueidis.Commands, len(ids))
+	for i, id := range ids {
+		cmds[i] = s.cli.B().Hmget().Key(s.key(id)).Field("lat", "lon", deletedField).Build()
+	}
+	out := make(map[string]model.Place, len(ids))
+	for i, r := range s.cli.DoMulti(ctx, cmds...) {
+		vals, err := r.ToArray()
+		if err != nil { return nil, backendErr(err) }
+		m := make(map[string]string, 3)
+		for j, f := range []string{"lat", "lon", deletedField} {
+			if v, err := vals[j].ToString(); err == nil { m[f] = v }
+		}
+		if len(m) == 0 || m[deletedField] == "1" { continue }
+		p := model.Place{ID: ids[i]}
+		if p.Lat, err = parseCoord(p.ID, m, "lat", -90, 90); err != nil { return nil, err }
+		if p.Lon, err = parseCoord(p.ID, m, "lon", -180, 180); err != nil { return nil, err }
+		out[p.ID] = p
+	}
+	return out, nil
+}
+
+// GetMany loads many whole places in one pipeline, in the order of ids.
+// Missing and soft-deleted places are left out.
+func (s *PlacesStorage) GetMany(ctx context.Context, ids []string) ([]model.Place, error) {
+	cmds := make(rueidis.Commands, len(ids))
+	for i, id := range ids { cmds[i] = s.cli.B().Hgetall().Key(s.key(id)).Build() }
+	out := make([]model.Place, 0, len(ids))
+	for i, r := range s.cli.DoMulti(ctx, cmds...) {
+		m, err := r.AsStrMap()
+		if err != nil { return nil, backendErr(err) }
+		if len(m) == 0 || m[deletedField] == "1" { continue }
+		p, err := decodePlace(ids[i], m)
+		if err != nil { return nil, err }
+		out = append(out, p)
+	}
+	return out, nil
 }
 
-func (s *PlacesStorage) Delete(ctx context.Context, id string) error {
-	if id == "" { return errors.New("empty id") }
-	return s.cli.Do(ctx, s.cli.B().Del().Key(s.key(id)).Build()).Error()
+// ScanIDs calls fn with the IDs of stored places, about batch at a time,
+// until every primary has been scanned or fn fails. Replicas are skipped,
+// their keys being copies of a primary's. Soft-deleted places are included,
+// and places written during the scan may be missed or reported twice.
+func (s *PlacesStorage) ScanIDs(ctx context.Context, batch int64, fn func(ids []string) error) error {
+	return s.scanKeys(ctx, s.keyPrefix+"{*", "hash", batch, func(keys []string) error {
+		ids := make([]string, len(keys))
+		for i, k := range keys { ids[i] = s.idFromKey(k) }
+		return fn(ids)
+	})
+}
+
+// scanKeys SCANs every primary for keys of type typ matching match and
+// calls fn with each non-empty batch.
+func (s *PlacesStorage) scanKeys(ctx context.Context, match, typ string, batch int64, fn func(keys []string) error) error {
+	for _, node := range s.cli.Nodes() {
+		primary, err := isPrimary(ctx, node)
+		if err != nil { return err }
+		if !primary { continue }
+		var cursor uint64
+		for {
+			cmd := node.B().Scan().Cursor(cursor).Match(match).Count(batch).Type(typ).Build()
+			e, err := node.Do(ctx, cmd).AsScanEntry()
+			if err != nil { return backendErr(err) }
+			if len(e.Elements) > 0 {
+				if err := fn(e.Elements); err != nil { return err }
+			}
+			if e.Cursor == 0 { break }
+			cursor = e.Cursor
+		}
+	}
+	return nil
+}
+
+// isPrimary reports whether node is a primary, per ROLE; the client lists
+// replicas among its nodes when it reads from them.
+func isPrimary(ctx context.Context, node rueidis.Client) (bool, error) {
+	role, err := node.Do(ctx, node.B().Role().Build()).ToArray()
+	if err != nil { return false, backendErr(err) }
+	if len(role) == 0 { return false, backendErr(errors.New("empty ROLE reply")) }
+	r, err := role[0].ToString()
+	if err != nil { return false, backendErr(err) }
+	return r == "master", nil
+}
+
+// backfillScript writes the derived fields ARGV[2:] of a record whose
+// version is still ARGV[1], without bumping it: the place as clients see it
+// does not change. It returns 0 when the record is gone or was rewritten.
+var backfillScript = rueidis.NewLuaScript(`
+local cur = redis.call('HGET', KEYS[1], 'version')
+if cur == false then
+  if redis.call('EXISTS', KEYS[1]) == 0 then return 0 end
+  cur = '0'
+end
+if ARGV[1] ~= cur then return 0 end
+redis.call('HSET', KEYS[1], unpack(ARGV, 2))
+return 1
+`)
+
+// Backfill recomputes the derived fields of the given places, soft-deleted
+// ones included, so attributes EnsurePlacesIndex added cover records written
+// before them. Places rewritten in the meantime already carry the fields and
+// are left alone. Soft-deleted places get their tombstone recorded again in
+// case SoftDelete could not write it. It returns how many places were updated and the IDs of
+// records that could not be decoded, which are skipped.
+func (s *PlacesStorage) Backfill(ctx context.Context, ids []string) (updated int, corrupted []string, err error) {
+	cmds := make(rueidis.Commands, len(ids))
+	for i, id := range ids { cmds[i] = s.cli.B().Hgetall().Key(s.key(id)).Build() }
+	var execs []rueidis.LuaExec
+	tombstones := s.cli.B().Zadd().Key(s.tombstonesKey()).Nx().ScoreMember()
+	deleted := 0
+	for i, r := range s.cli.DoMulti(ctx, cmds...) {
+		m, err := r.AsStrMap()
+		if err != nil { return 0, nil, backendErr(err) }
+		if len(m) == 0 { continue }
+		p, err := decodePlace(ids[i], m)
+		if err != nil {
+			corrupted = append(corrupted, ids[i])
+			continue
+		}
+		if m[deletedField] == "1" {
+			if at, err := strconv.ParseInt(m[deletedAtField], 10, 64); err == nil {
+				tombstones = tombstones.ScoreMember(float64(at), ids[i])
+				deleted++
+			}
+		}
+		version := m[versionField]
+		if version == "" { version = "0" }
+		args := append([]string{version}, derivedFields(p)...)
+		execs = append(execs, rueidis.LuaExec{Keys: []string{s.key(ids[i])}, Args: args})
+	}
+	if deleted > 0 {
+		if err := s.cli.Do(ctx, tombstones.Build()).Error(); err != nil { return 0, corrupted, backendErr(err) }
+	}
+	if len(execs) == 0 { return 0, corrupted, nil }
+	for _, r := range backfillScript.ExecMulti(ctx, s.cli, execs...) {
+		n, err := r.AsInt64()
+		if err != nil { return updated, corrupted, backendErr(err) }
+		updated += int(n)
+	}
+	return updated, corrupted, nil
+}
+
+// Delete permanently removes a place and returns it, or ErrNotFound when
+// there was none. With ifVersion other than AnyVersion the stored version
+// must match, otherwise ErrPreconditionFailed is returned.
+func (s *PlacesStorage) Delete(ctx context.Context, id string, ifVersion int64) (model.Place, error) {
+	if id == "" { return model.Place{}, errors.New("empty id") }
+	ev, err := eventArgs(id, time.Now(), nil)
+	if err != nil { return model.Place{}, err }
+	args := append([]string{strconv.FormatInt(ifVersion, 10)}, ev...)
+	return scriptPlace(id, deleteScript.Exec(ctx, s.cli, s.writeKeys(id), args))
 }
 
 type SearchParams struct {
 	Lat, Lon float64
 	Limit    int64
 	CategoryIDs []string
+	// DistanceMode picks how DistanceM is computed; empty means geo.DistanceKNN.
+	DistanceMode geo.DistanceMode
+	// Fields is a projection (see ResolveFields); empty means DefaultSearchFields.
+	Fields []string
+	// Hydrate returns every stored field, ignoring Fields.
+	Hydrate bool
+	// ExcludeDeleted filters soft-deleted places in the query itself, with
+	// the deleted TAG EnsurePlacesIndex adds to indexes that predate it.
+	ExcludeDeleted bool
+	// Within restricts the hits to a box, which must not cross the
+	// antimeridian.
+	Within *geo.BBox
+	// HasAddress keeps only places with an address component, filtering on
+	// the has_address TAG (see Backfill for records older than it).
+	HasAddress bool
 }
 
 type SearchResult struct {
@@ -135,66 +598,172 @@ type SearchResult struct {
 	DistanceM  float64
 }
 
-func knnQuery(limit int64, cats []string) string {
-	filter := "*"
+// scoreField is the KNN distance FT.SEARCH attaches to each hit for @location.
+const scoreField = "__location_score"
+
+func knnQuery(limit int64, cats []string, excludeDeleted, hasAddress bool, within *geo.BBox) string {
+	var parts []string
+	if within != nil {
+		parts = append(parts,
+			"@lat:["+formatFloat(within.YMin)+" "+formatFloat(within.YMax)+"]",
+			"@lon:["+formatFloat(within.XMin)+" "+formatFloat(within.XMax)+"]")
+	}
 	if len(cats) > 0 {
 		or := strings.Join(cats, "|")
-		filter = fmt.Sprintf("@category_ids:{%s}", or)
+		parts = append(parts, fmt.Sprintf("@category_ids:{%s}", or))
+	}
+	if excludeDeleted {
+		parts = append(parts, "-@deleted:{1}")
+	}
+	if hasAddress {
+		parts = append(parts, "@"+hasAddressField+":{1}")
+	}
+	filter := "*"
+	switch len(parts) {
+	case 0:
+	case 1:
+		filter = parts[0]
+	default:
+		filter = "(" + strings.Join(parts, " ") + ")"
 	}
 	return fmt.Sprintf("%s=>[KNN %d @location $vec]", filter, limit)
 }
 
 func (s *PlacesStorage) SearchNearest(ctx context.Context, sp SearchParams) ([]SearchResult, error) {
+	sp = sp.normalized()
+	cmd, err := s.searchCmd(sp)
+	if err != nil { return nil, err }
+	return s.searchResults(sp, s.cli.Do(ctx, cmd))
+}
+
+// Batch searches are pipelined batchChunk at a time with DoMulti, running at
+// most batchParallel pipelines at once so one batch cannot monopolise the
+// connections.
+const (
+	batchChunk    = 50
+	batchParallel = 4
+)
+
+// BatchResult is the outcome of one search of SearchNearestBatch.
+type BatchResult struct {
+	Results []SearchResult
+	Err     error
+}
+
+// SearchNearestBatch runs every search of sps and returns their outcomes in
+// the same order; a failed search does not affect the others.
+func (s *PlacesStorage) SearchNearestBatch(ctx context.Context, sps []SearchParams) []BatchResult {
+	out := make([]BatchResult, len(sps))
+	sem := make(chan struct{}, batchParallel)
+	var wg sync.WaitGroup
+	for start := 0; start < len(sps); start += batchChunk {
+		end := min(start+batchChunk, len(sps))
+		sem <- struct{}{}
+		wg.Add(1)
+		go func(start, end int) {
+			defer func() { <-sem; wg.Done() }()
+			cmds := make(rueidis.Commands, 0, end-start)
+			idx := make([]int, 0, end-start)
+			for i := start; i < end; i++ {
+				cmd, err := s.searchCmd(sps[i].normalized())
+				if err != nil {
+					out[i].Err = err
+					continue
+				}
+				cmds = append(cmds, cmd)
+				idx = append(idx, i)
+			}
+			if len(cmds) == 0 { return }
+			for j, r := range s.cli.DoMulti(ctx, cmds...) {
+				i := idx[j]
+				out[i].Results, out[i].Err = s.searchResults(sps[i].normalized(), r)
+			}
+		}(start, end)
+	}
+	wg.Wait()
+	return out
+}
+
+// normalized applies the default limit.
+func (sp SearchParams) normalized() SearchParams {
 	if sp.Limit <= 0 || sp.Limit > 200 { sp.Limit = 100 }
+	return sp
+}
+
+// searchCmd builds the FT.SEARCH KNN command for sp.
+func (s *PlacesStorage) searchCmd(sp SearchParams) (rueidis.Completed, error) {
 	vec := geo.ToECEF(sp.Lat, sp.Lon)
-	query := knnQuery(sp.Limit, sp.CategoryIDs)
+	query := knnQuery(sp.Limit, sp.CategoryIDs, sp.ExcludeDeleted, sp.HasAddress, sp.Within)
+	cols, err := searchColumns(sp)
+	if err != nil { return rueidis.Completed{}, err }
 
-cmd := s.cli.B().FtSearch().
+	ret := s.cli.B().FtSearch().
 		Index(s.index).
 		Query(query).
-		Return("5").Identifier("id").Identifier("name").Identifier("lat").Identifier("lon").Identifier("category_ids").
+		Return(strconv.Itoa(len(cols))).Identifier(cols[0])
+	for _, c := range cols[1:] { ret = ret.Identifier(c) }
+	return ret.
 		Limit().OffsetNum(0, sp.Limit).
 		Params().Nargs(2).NameValue().NameValue("vec", rueidis.VectorString32(vec[:])).
 		Dialect(2).
-		Build()
-	arr, err := s.cli.Do(ctx, cmd).ToArray()
-	if err != nil { return nil, err }
+		Build(), nil
+}
+
+// searchResults decodes the FT.SEARCH reply to sp's command.
+func (s *PlacesStorage) searchResults(sp SearchParams, resp rueidis.RedisResult) ([]SearchResult, error) {
+	arr, err := resp.ToArray()
+	if err != nil { return nil, backendErr(err) }
 	if len(arr) == 0 { return nil, nil }
-	total, _ := arr[0].AsInt64()
-	_ = total
 
 	res := make([]SearchResult, 0, (len(arr)-1)/2)
 	for i := 1; i+1 < len(arr); i += 2 {
-		m, err := arr[i+1].AsStrMap(); if err != nil { continue }
-		var p model.Place
-p.ID = m["id"]
-		p.Name = m["name"]
-		fmt.Sscanf(m["lat"], "%f", &p.Lat)
-		fmt.Sscanf(m["lon"], "%f", &p.Lon)
-		if cats := strings.TrimSpace(m["category_ids"]); cats != "" {
-			p.CategoryIDs = strings.Split(cats, ",")
-		}
-		d := haversineMeters(sp.Lat, sp.Lon, p.Lat, p.Lon)
+		m, err := arr[i+1].AsStrMap(); if err != nil { return nil, backendErr(err) }
+		if m[deletedField] == "1" { continue }
+		key, _ := arr[i].ToString()
+		p, err := decodePlace(s.idFromKey(key), m)
+		if err != nil { return nil, err }
+		d, err := resultDistance(sp, p, m[scoreField])
+		if err != nil { return nil, &CorruptedRecordError{ID: p.ID, Field: scoreField, Value: m[scoreField], Err: err} }
 		res = append(res, SearchResult{Place: p, DistanceM: d})
 	}
-	// results already sorted by KNN ASC; ensure stable by distance
-	sort.SliceStable(res, func(i,j int) bool { return res[i].DistanceM < res[j].DistanceM })
+	// KNN order is by chord length, which is monotonic in great-circle distance,
+	// so only the exact-coordinate modes can change ranks. Stable keeps KNN
+	// order for ties.
+	if sp.DistanceMode != "" && sp.DistanceMode != geo.DistanceKNN {
+		sort.SliceStable(res, func(i,j int) bool { return res[i].DistanceM < res[j].DistanceM })
+	}
 	if int64(len(res)) > sp.Limit { res = res[:sp.Limit] }
 	return res, nil
 }
 
-func haversineMeters(lat1, lon1, lat2, lon2 float64) float64 {
-	const R = 6371000.0
-	toRad := func(d float64) float64 { return d * 3.141592653589793 / 180 }
-	dlat := toRad(lat2-lat1)
-	dlon := toRad(lon2-lon1)
-	alat := toRad(lat1)
-	blat := toRad(lat2)
-	a := (sin2(dlat/2) + mathCos(alat)*mathCos(blat)*sin2(dlon/2))
-	c := 2 * mathAtan2Sqrt(a)
-	return R * c
+// searchColumns lists the hash fields to RETURN for sp, score included.
+func searchColumns(sp SearchParams) ([]string, error) {
+	var cols []string
+	switch {
+	case sp.Hydrate:
+		cols = allFields()
+	case len(sp.Fields) > 0:
+		c, err := ResolveFields(sp.Fields)
+		if err != nil { return nil, err }
+		cols = c
+	default:
+		cols, _ = ResolveFields(DefaultSearchFields)
+	}
+	return append(cols, scoreField), nil
 }
 
-func sin2(x float64) float64 { s := math.Sin(x); return s * s }
-func mathCos(x float64) float64 { return math.Cos(x) }
-func mathAtan2Sqrt(a float64) float64 { return math.Asin(math.Min(1, math.Sqrt(a))) }
+// resultDistance computes the distance from the query point to p according to
+// sp.DistanceMode. In KNN mode the vector score is used directly, falling back
+// to haversine when the backend did not return it.
+func resultDistance(sp SearchParams, p model.Place, score string) (float64, error) {
+	switch sp.DistanceMode {
+	case geo.DistanceHaversine:
+		return geo.Haversine(sp.Lat, sp.Lon, p.Lat, p.Lon), nil
+	case geo.DistanceEllipsoidal:
+		return geo.Ellipsoidal(sp.Lat, sp.Lon, p.Lat, p.Lon), nil
+	}
+	if score == "" { return geo.Haversine(sp.Lat, sp.Lon, p.Lat, p.Lon), nil }
+	v, err := parseFloat(score)
+	if err != nil { return 0, err }
+	return geo.L2ScoreToMeters(v), nil
+}
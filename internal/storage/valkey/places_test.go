@@ -0,0 +1,115 @@
+package valkey
+
+import (
+	"errors"
+	"slices"
+	"strconv"
+	"testing"
+
+	"redcat/internal/domain/errs"
+	"redcat/internal/domain/geo"
+	"redcat/internal/domain/model"
+)
+
+func TestFormatFloat_RoundTrip(t *testing.T) {
+	for _, v := range []float64{35.17531234567891, -0.000000123, 179.99999999999997, -90, 0} {
+		s := formatFloat(v)
+		got, err := strconv.ParseFloat(s, 64)
+		if err != nil { t.Fatalf("parse %q: %v", s, err) }
+		if got != v { t.Fatalf("round trip %v -> %q -> %v", v, s, got) }
+	}
+}
+
+func TestDecodePlace(t *testing.T) {
+	p, err := decodePlace("x", map[string]string{
+		"id": "x", "name": "X", "lat": "35.17531234567891", "lon": "33.3642", "category_ids": "a,b",
+		"bbox_xmin": "", "bbox_ymax": "1.5", "version": "7",
+	})
+	if err != nil { t.Fatalf("decode: %v", err) }
+	if p.Lat != 35.17531234567891 || p.Lon != 33.3642 { t.Fatalf("coords: %v,%v", p.Lat, p.Lon) }
+	if len(p.CategoryIDs) != 2 || p.BBox.YMax != 1.5 || p.Version != 7 { t.Fatalf("unexpected: %+v", p) }
+}
+
+func TestDecodePlace_Corrupted(t *testing.T) {
+	cases := map[string]map[string]string{
+		"missing lat":  {"id": "x", "lon": "1"},
+		"bad lon":      {"id": "x", "lat": "1", "lon": "abc"},
+		"lat range":    {"id": "x", "lat": "91", "lon": "1"},
+		"nan":          {"id": "x", "lat": "NaN", "lon": "1"},
+		"bad bbox":     {"id": "x", "lat": "1", "lon": "1", "bbox_xmin": "?"},
+		"bad version":  {"id": "x", "lat": "1", "lon": "1", "version": "v1"},
+	}
+	for name, m := range cases {
+		t.Run(name, func(t *testing.T) {
+			_, err := decodePlace("x", m)
+			if !errors.Is(err, ErrCorrupted) { t.Fatalf("want ErrCorrupted, got %v", err) }
+			var ce *CorruptedRecordError
+			if !errors.As(err, &ce) || ce.ID != "x" { t.Fatalf("want CorruptedRecordError for x, got %v", err) }
+			if e := errs.As(err); e == nil || e.Kind != errs.Corrupted || e.Details["id"] != "x" { t.Fatalf("want errs.Corrupted for x, got %#v", e) }
+		})
+	}
+}
+
+func TestResultDistance_Modes(t *testing.T) {
+	p := model.Place{Lat: 35.18, Lon: 33.37}
+	sp := SearchParams{Lat: 35.17, Lon: 33.36}
+	hav := geo.Haversine(sp.Lat, sp.Lon, p.Lat, p.Lon)
+
+	d, err := resultDistance(sp, p, "")
+	if err != nil || d != hav { t.Fatalf("missing score should fall back to haversine: %v %v", d, err) }
+	d, err = resultDistance(sp, p, "0.0000005")
+	if err != nil || d != geo.L2ScoreToMeters(0.0000005) { t.Fatalf("knn: %v %v", d, err) }
+	if _, err = resultDistance(sp, p, "bogus"); err == nil { t.Fatalf("expected parse error") }
+
+	sp.DistanceMode = geo.DistanceEllipsoidal
+	d, _ = resultDistance(sp, p, "bogus")
+	if d == hav || d == 0 { t.Fatalf("ellipsoidal should differ from haversine: %v", d) }
+}
+
+func TestKnnQuery(t *testing.T) {
+	cases := []struct {
+		cats       []string
+		exclude    bool
+		hasAddress bool
+		within     *geo.BBox
+		want       string
+	}{
+		{nil, false, false, nil, "*=>[KNN 5 @location $vec]"},
+		{[]string{"a", "b"}, false, false, nil, "@category_ids:{a|b}=>[KNN 5 @location $vec]"},
+		{nil, true, false, nil, "-@deleted:{1}=>[KNN 5 @location $vec]"},
+		{[]string{"a"}, true, false, nil, "(@category_ids:{a} -@deleted:{1})=>[KNN 5 @location $vec]"},
+		{nil, false, false, &geo.BBox{XMin: 33, YMin: 35, XMax: 33.5, YMax: 35.25}, "(@lat:[35 35.25] @lon:[33 33.5])=>[KNN 5 @location $vec]"},
+		{nil, false, true, nil, "@has_address:{1}=>[KNN 5 @location $vec]"},
+		{nil, true, true, nil, "(-@deleted:{1} @has_address:{1})=>[KNN 5 @location $vec]"},
+	}
+	for _, tc := range cases {
+		if got := knnQuery(5, tc.cats, tc.exclude, tc.hasAddress, tc.within); got != tc.want { t.Errorf("want %q got %q", tc.want, got) }
+	}
+}
+
+func TestHashFields_H3(t *testing.T) {
+	f := hashFields(model.Place{ID: "x", Lat: 35.1753, Lon: 33.3642})
+	m := map[string]string{}
+	for i := 0; i+1 < len(f); i += 2 { m[f[i]] = f[i+1] }
+	if m[H3Field(5)] != "852da443fffffff" || m[H3Field(8)] != "882da4425bfffff" {
+		t.Fatalf("h3 cells: %v %v", m[H3Field(5)], m[H3Field(8)])
+	}
+	for _, res := range H3Resolutions {
+		if m[H3Field(res)] == "" { t.Fatalf("no cell at resolution %d", res) }
+	}
+}
+
+func TestHashFields_HasAddress(t *testing.T) {
+	for _, tc := range []struct {
+		p    model.Place
+		want string
+	}{
+		{model.Place{ID: "bare"}, "0"},
+		{model.Place{ID: "street", Address: "1 Main St"}, "1"},
+		{model.Place{ID: "country", Country: "CY"}, "1"},
+	} {
+		f := hashFields(tc.p)
+		i := slices.Index(f, hasAddressField)
+		if i < 0 || f[i+1] != tc.want { t.Errorf("%s: want has_address=%s, got %v", tc.p.ID, tc.want, f) }
+	}
+}
@@ -0,0 +1,159 @@
+package valkey
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"redcat/internal/domain/errs"
+	"redcat/internal/domain/model"
+
+	"github.com/redis/rueidis"
+)
+
+// Soft-deleted places keep their hash with deleted=1 and deleted_at (unix
+// ms). The TAG-indexed deleted flag keeps them out of search; a sorted set
+// of tombstones scored by deletion time lets PurgeDeleted find expired ones
+// without scanning the keyspace.
+const (
+	deletedField   = "deleted"
+	deletedAtField = "deleted_at"
+)
+
+// ErrNotDeleted is returned by Restore for a place that is not soft-deleted.
+var ErrNotDeleted = errs.New(errs.Conflict, "place is not deleted")
+
+// ErrTombstoneNotRecorded is returned, wrapped with the cause, by SoftDelete
+// when the place was deleted but its tombstone could not be written.
+var ErrTombstoneNotRecorded = errors.New("tombstone not recorded")
+
+// softDeleteScript flags an existing, live place as deleted if its version
+// matches (see casPrelude), bumps the version and returns the fields the
+// hash had before. Its event carries the version before the delete.
+var softDeleteScript = rueidis.NewLuaScript(outboxPrelude + casPrelude + `
+local before = redis.call('HGETALL', KEYS[1])
+redis.call('HSET', KEYS[1], 'deleted', '1', 'deleted_at', ARGV[2])
+redis.call('HINCRBY', KEYS[1], 'version', 1)
+outbox('delete', cur, '')
+return before
+`)
+
+// restoreScript clears the deleted flag if the version is still ARGV[1];
+// -1 for a missing key, -2 for another version, -3 when the place is live.
+var restoreScript = rueidis.NewLuaScript(outboxPrelude + `
+if redis.call('EXISTS', KEYS[1]) == 0 then return -1 end
+if redis.call('HGET', KEYS[1], 'deleted') ~= '1' then return -3 end
+if (redis.call('HGET', KEYS[1], 'version') or '0') ~= ARGV[1] then return -2 end
+redis.call('HDEL', KEYS[1], 'deleted', 'deleted_at')
+local v = redis.call('HINCRBY', KEYS[1], 'version', 1)
+outbox('restore', v, ARGV[#ARGV])
+return v
+`)
+
+// restoreAttempts bounds how often Restore rereads a place written while
+// it was restoring it.
+const restoreAttempts = 3
+
+// purgeScript removes a place deleted at or before ARGV[1]. It returns 1
+// when removed, 0 when the place is gone or live again (stale tombstone) and
+// -1 when it was deleted too recently.
+var purgeScript = rueidis.NewLuaScript(`
+if redis.call('HGET', KEYS[1], 'deleted') ~= '1' then return 0 end
+local at = tonumber(redis.call('HGET', KEYS[1], 'deleted_at'))
+if at ~= nil and at > tonumber(ARGV[1]) then return -1 end
+return redis.call('DEL', KEYS[1])
+`)
+
+// tombstonesKey is the sorted set of soft-deleted IDs. It lives outside the
+// place key space so it never matches the index prefix pattern as a place.
+func (s *PlacesStorage) tombstonesKey() string { return s.index + ":deleted" }
+
+// SoftDelete marks a live place as deleted at the given time and returns it
+// as it was; ifVersion works as in Delete. The tombstone is recorded once
+// the script has deleted the place, so a missing, already deleted or
+// mismatched place leaves none. When that second write fails the place is
+// returned with ErrTombstoneNotRecorded: it stays deleted but is not purged
+// until Backfill records the tombstone.
+func (s *PlacesStorage) SoftDelete(ctx context.Context, id string, ifVersion int64, at time.Time) (model.Place, error) {
+	if id == "" { return model.Place{}, errors.New("empty id") }
+	ms := at.UnixMilli()
+	ev, err := eventArgs(id, at, nil)
+	if err != nil { return model.Place{}, err }
+	args := append([]string{strconv.FormatInt(ifVersion, 10), strconv.FormatInt(ms, 10)}, ev...)
+	before, err := scriptPlace(id, softDeleteScript.Exec(ctx, s.cli, s.writeKeys(id), args))
+	if err != nil { return model.Place{}, err }
+	zadd := s.cli.B().Zadd().Key(s.tombstonesKey()).Nx().ScoreMember().ScoreMember(float64(ms), id).Build()
+	if err := s.cli.Do(ctx, zadd).Error(); err != nil {
+		return before, fmt.Errorf("%w: %w", ErrTombstoneNotRecorded, backendErr(err))
+	}
+	return before, nil
+}
+
+// Restore undoes SoftDelete and returns the new version. It returns
+// ErrNotFound for a missing place and ErrNotDeleted for a live one. The
+// place is read first for the event; the script checks it was not written
+// in between.
+func (s *PlacesStorage) Restore(ctx context.Context, id string) (int64, error) {
+	if id == "" { return 0, errors.New("empty id") }
+	var v int64
+	for attempt := 1; ; attempt++ {
+		m, err := s.cli.Do(ctx, s.cli.B().Hgetall().Key(s.key(id)).Build()).AsStrMap()
+		if err != nil { return 0, backendErr(err) }
+		if len(m) == 0 { return 0, ErrNotFound }
+		if m[deletedField] != "1" { return 0, ErrNotDeleted }
+		p, err := decodePlace(id, m)
+		if err != nil { return 0, err }
+		ev, err := eventArgs(id, time.Now(), &p)
+		if err != nil { return 0, err }
+		version := m[versionField]
+		if version == "" { version = "0" }
+		v, err = restoreScript.Exec(ctx, s.cli, s.writeKeys(id), append([]string{version}, ev...)).AsInt64()
+		if err != nil { return 0, backendErr(err) }
+		if v != -2 || attempt == restoreAttempts { break }
+	}
+	switch v {
+	case -1:
+		return 0, ErrNotFound
+	case -2:
+		return 0, ErrPreconditionFailed
+	case -3:
+		return 0, ErrNotDeleted
+	}
+	zrem := s.cli.B().Zrem().Key(s.tombstonesKey()).Member(id).Build()
+	if err := s.cli.Do(ctx, zrem).Error(); err != nil { return 0, backendErr(err) }
+	return v, nil
+}
+
+// PurgeDeleted permanently removes places soft-deleted at or before cutoff,
+// batch tombstones at a time, and returns how many were removed.
+func (s *PlacesStorage) PurgeDeleted(ctx context.Context, cutoff time.Time, batch int64) (int, error) {
+	if batch <= 0 { batch = 100 }
+	max := strconv.FormatInt(cutoff.UnixMilli(), 10)
+	var purged int
+	var offset int64
+	for {
+		cmd := s.cli.B().Zrange().Key(s.tombstonesKey()).Min("-inf").Max(max).Byscore().Limit(offset, batch).Build()
+		ids, err := s.cli.Do(ctx, cmd).AsStrSlice()
+		if err != nil { return purged, backendErr(err) }
+		if len(ids) == 0 { return purged, nil }
+
+		execs := make([]rueidis.LuaExec, len(ids))
+		for i, id := range ids {
+			execs[i] = rueidis.LuaExec{Keys: []string{s.key(id)}, Args: []string{max}}
+		}
+		done := make([]string, 0, len(ids))
+		for i, r := range purgeScript.ExecMulti(ctx, s.cli, execs...) {
+			n, err := r.AsInt64()
+			if err != nil { return purged, backendErr(err) }
+			if n == 1 { purged++ }
+			if n >= 0 { done = append(done, ids[i]) } else { offset++ }
+		}
+		if len(done) > 0 {
+			zrem := s.cli.B().Zrem().Key(s.tombstonesKey()).Member(done...).Build()
+			if err := s.cli.Do(ctx, zrem).Error(); err != nil { return purged, backendErr(err) }
+		}
+		if int64(len(ids)) < batch { return purged, nil }
+	}
+}
@@ -0,0 +1,195 @@
+package valkey
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sort"
+	"strconv"
+	"time"
+
+	"redcat/internal/domain/errs"
+	"redcat/internal/domain/webhooks"
+
+	"github.com/redis/rueidis"
+)
+
+// ErrSubscriptionNotFound is returned for unknown webhook subscription IDs.
+var ErrSubscriptionNotFound = errs.New(errs.NotFound, "webhook subscription not found")
+
+// WebhookStorage keeps subscriptions and the delivery queue. All keys share
+// one hash tag so the scripts below can touch several of them atomically:
+//
+//	<base>:subs        hash  subscription ID -> JSON
+//	<base>:deliveries  hash  delivery ID -> JSON
+//	<base>:queue       zset  delivery ID scored by next attempt (unix ms)
+//	<base>:dead        list  JSON of deliveries that exhausted their attempts
+type WebhookStorage struct {
+	cli     rueidis.Client
+	base    string
+	maxDead int64
+}
+
+// NewWebhookStorage returns a store under webhooks:{name}; the dead-letter
+// list keeps the newest maxDead entries.
+func NewWebhookStorage(cli rueidis.Client, name string, maxDead int64) *WebhookStorage {
+	return &WebhookStorage{cli: cli, base: "webhooks:{" + name + "}", maxDead: maxDead}
+}
+
+func (s *WebhookStorage) subsKey() string       { return s.base + ":subs" }
+func (s *WebhookStorage) deliveriesKey() string { return s.base + ":deliveries" }
+func (s *WebhookStorage) queueKey() string      { return s.base + ":queue" }
+func (s *WebhookStorage) deadKey() string       { return s.base + ":dead" }
+
+func (s *WebhookStorage) SaveSubscription(ctx context.Context, sub webhooks.Subscription) error {
+	b, err := json.Marshal(sub)
+	if err != nil { return err }
+	cmd := s.cli.B().Hset().Key(s.subsKey()).FieldValue().FieldValue(sub.ID, string(b)).Build()
+	return backendErr(s.cli.Do(ctx, cmd).Error())
+}
+
+func (s *WebhookStorage) GetSubscription(ctx context.Context, id string) (webhooks.Subscription, error) {
+	v, err := s.cli.Do(ctx, s.cli.B().Hget().Key(s.subsKey()).Field(id).Build()).ToString()
+	if rueidis.IsRedisNil(err) { return webhooks.Subscription{}, ErrSubscriptionNotFound }
+	if err != nil { return webhooks.Subscription{}, backendErr(err) }
+	var sub webhooks.Subscription
+	if err := json.Unmarshal([]byte(v), &sub); err != nil {
+		return webhooks.Subscription{}, &CorruptedRecordError{ID: id, Field: "subscription", Value: v, Err: err}
+	}
+	return sub, nil
+}
+
+// DeleteSubscription removes a subscription; deliveries still queued for it
+// are dropped by the dispatcher.
+func (s *WebhookStorage) DeleteSubscription(ctx context.Context, id string) error {
+	n, err := s.cli.Do(ctx, s.cli.B().Hdel().Key(s.subsKey()).Field(id).Build()).AsInt64()
+	if err != nil { return backendErr(err) }
+	if n == 0 { return ErrSubscriptionNotFound }
+	return nil
+}
+
+// ListSubscriptions returns every subscription, oldest first.
+func (s *WebhookStorage) ListSubscriptions(ctx context.Context) ([]webhooks.Subscription, error) {
+	m, err := s.cli.Do(ctx, s.cli.B().Hgetall().Key(s.subsKey()).Build()).AsStrMap()
+	if err != nil { return nil, backendErr(err) }
+	out := make([]webhooks.Subscription, 0, len(m))
+	for id, v := range m {
+		var sub webhooks.Subscription
+		if err := json.Unmarshal([]byte(v), &sub); err != nil {
+			return nil, &CorruptedRecordError{ID: id, Field: "subscription", Value: v, Err: err}
+		}
+		out = append(out, sub)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].ID < out[j].ID })
+	return out, nil
+}
+
+// scheduleScript stores a delivery and (re)schedules it at ARGV[3].
+var scheduleScript = rueidis.NewLuaScript(`
+redis.call('HSET', KEYS[1], ARGV[1], ARGV[2])
+redis.call('ZADD', KEYS[2], ARGV[3], ARGV[1])
+return 1
+`)
+
+// claimScript leases up to ARGV[3] deliveries due at ARGV[1] by pushing
+// their score to ARGV[2]; a dispatcher that dies mid-delivery thus only
+// delays the retry until the lease expires. It returns ID, JSON pairs.
+var claimScript = rueidis.NewLuaScript(`
+local ids = redis.call('ZRANGEBYSCORE', KEYS[2], '-inf', ARGV[1], 'LIMIT', 0, ARGV[3])
+local out = {}
+for _, id in ipairs(ids) do
+  local d = redis.call('HGET', KEYS[1], id)
+  if d then
+    redis.call('ZADD', KEYS[2], ARGV[2], id)
+    table.insert(out, id)
+    table.insert(out, d)
+  else
+    redis.call('ZREM', KEYS[2], id)
+  end
+end
+return out
+`)
+
+// deadScript moves a delivery from the queue to the dead-letter list.
+var deadScript = rueidis.NewLuaScript(`
+redis.call('ZREM', KEYS[2], ARGV[1])
+redis.call('HDEL', KEYS[1], ARGV[1])
+redis.call('LPUSH', KEYS[3], ARGV[2])
+if tonumber(ARGV[3]) > 0 then redis.call('LTRIM', KEYS[3], 0, tonumber(ARGV[3]) - 1) end
+return 1
+`)
+
+// Enqueue stores d and schedules it at d.NextAt.
+func (s *WebhookStorage) Enqueue(ctx context.Context, d webhooks.Delivery) error { return s.schedule(ctx, d) }
+
+// Retry stores the updated d and reschedules it at d.NextAt.
+func (s *WebhookStorage) Retry(ctx context.Context, d webhooks.Delivery) error { return s.schedule(ctx, d) }
+
+func (s *WebhookStorage) schedule(ctx context.Context, d webhooks.Delivery) error {
+	b, err := json.Marshal(d)
+	if err != nil { return err }
+	args := []string{d.ID, string(b), strconv.FormatInt(d.NextAt.UnixMilli(), 10)}
+	return backendErr(scheduleScript.Exec(ctx, s.cli, []string{s.deliveriesKey(), s.queueKey()}, args).Error())
+}
+
+// Claim leases up to n deliveries due at now for the lease duration.
+// Deliveries that cannot be decoded are removed from the queue, so they do
+// not come back with every claim, and reported as CorruptedRecordErrors
+// joined into the error returned along with the deliveries that could.
+func (s *WebhookStorage) Claim(ctx context.Context, now time.Time, lease time.Duration, n int) ([]webhooks.Delivery, error) {
+	args := []string{
+		strconv.FormatInt(now.UnixMilli(), 10),
+		strconv.FormatInt(now.Add(lease).UnixMilli(), 10),
+		strconv.Itoa(n),
+	}
+	vals, err := claimScript.Exec(ctx, s.cli, []string{s.deliveriesKey(), s.queueKey()}, args).AsStrSlice()
+	if err != nil { return nil, backendErr(err) }
+	out := make([]webhooks.Delivery, 0, len(vals)/2)
+	var corrupted []error
+	for i := 0; i+1 < len(vals); i += 2 {
+		id, v := vals[i], vals[i+1]
+		var d webhooks.Delivery
+		if err := json.Unmarshal([]byte(v), &d); err != nil {
+			if err := s.Complete(ctx, id); err != nil { return nil, err }
+			corrupted = append(corrupted, &CorruptedRecordError{ID: id, Field: "delivery", Value: v, Err: err})
+			continue
+		}
+		out = append(out, d)
+	}
+	return out, errors.Join(corrupted...)
+}
+
+// Complete forgets a delivery that succeeded or is no longer wanted.
+func (s *WebhookStorage) Complete(ctx context.Context, id string) error {
+	for _, r := range s.cli.DoMulti(ctx,
+		s.cli.B().Zrem().Key(s.queueKey()).Member(id).Build(),
+		s.cli.B().Hdel().Key(s.deliveriesKey()).Field(id).Build(),
+	) {
+		if err := r.Error(); err != nil { return backendErr(err) }
+	}
+	return nil
+}
+
+// DeadLetter moves d, which exhausted its attempts, to the dead-letter list.
+func (s *WebhookStorage) DeadLetter(ctx context.Context, d webhooks.Delivery) error {
+	b, err := json.Marshal(d)
+	if err != nil { return err }
+	keys := []string{s.deliveriesKey(), s.queueKey(), s.deadKey()}
+	args := []string{d.ID, string(b), strconv.FormatInt(s.maxDead, 10)}
+	return backendErr(deadScript.Exec(ctx, s.cli, keys, args).Error())
+}
+
+// DeadLetters returns up to limit dead deliveries, newest first.
+func (s *WebhookStorage) DeadLetters(ctx context.Context, limit int64) ([]webhooks.Delivery, error) {
+	vals, err := s.cli.Do(ctx, s.cli.B().Lrange().Key(s.deadKey()).Start(0).Stop(limit-1).Build()).AsStrSlice()
+	if err != nil { return nil, backendErr(err) }
+	out := make([]webhooks.Delivery, 0, len(vals))
+	for _, v := range vals {
+		var d webhooks.Delivery
+		if err := json.Unmarshal([]byte(v), &d); err != nil {
+			return nil, &CorruptedRecordError{Field: "dead_letter", Value: v, Err: err}
+		}
+		out = append(out, d)
+	}
+	return out, nil
+}
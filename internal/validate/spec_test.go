@@ -0,0 +1,131 @@
+package validate
+
+import (
+	"os"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+// TestConstraints_MatchOpenAPI fails when a constant above drifts from the
+// corresponding keyword in api/openapi.yaml.
+func TestConstraints_MatchOpenAPI(t *testing.T) {
+	raw, err := os.ReadFile("../../api/openapi.yaml")
+	if err != nil { t.Fatalf("read spec: %v", err) }
+	var doc map[string]any
+	if err := yaml.Unmarshal(raw, &doc); err != nil { t.Fatalf("parse spec: %v", err) }
+	schemas := doc["components"].(map[string]any)["schemas"].(map[string]any)
+
+	kw := func(schema, prop, key string) float64 {
+		t.Helper()
+		s, ok := schemas[schema].(map[string]any)
+		if !ok { t.Fatalf("schema %s missing", schema) }
+		p, ok := s["properties"].(map[string]any)[prop].(map[string]any)
+		if !ok { t.Fatalf("%s.%s missing", schema, prop) }
+		switch v := p[key].(type) {
+		case int:
+			return float64(v)
+		case float64:
+			return v
+		}
+		t.Fatalf("%s.%s has no numeric %s", schema, prop, key)
+		return 0
+	}
+
+	// param reads a keyword of a GET parameter's schema.
+	param := func(path, name, key string) float64 {
+		t.Helper()
+		op, ok := doc["paths"].(map[string]any)[path].(map[string]any)["get"].(map[string]any)
+		if !ok { t.Fatalf("GET %s missing", path) }
+		for _, raw := range op["parameters"].([]any) {
+			p := raw.(map[string]any)
+			if p["name"] != name { continue }
+			if v, ok := p["schema"].(map[string]any)[key].(int); ok { return float64(v) }
+		}
+		t.Fatalf("GET %s: parameter %s has no numeric %s", path, name, key)
+		return 0
+	}
+
+	checks := []struct {
+		name      string
+		got, want float64
+	}{
+		{"Location.lat.minimum", LatMin, kw("Location", "lat", "minimum")},
+		{"Location.lat.maximum", LatMax, kw("Location", "lat", "maximum")},
+		{"Location.lon.minimum", LonMin, kw("Location", "lon", "minimum")},
+		{"Location.lon.maximum", LonMax, kw("Location", "lon", "maximum")},
+		{"PlaceCreate.name.minLength", NameMinLen, kw("PlaceCreate", "name", "minLength")},
+		{"PlaceCreate.name.maxLength", NameMaxLen, kw("PlaceCreate", "name", "maxLength")},
+		{"PlaceUpdate.name.minLength", NameMinLen, kw("PlaceUpdate", "name", "minLength")},
+		{"PlaceUpdate.name.maxLength", NameMaxLen, kw("PlaceUpdate", "name", "maxLength")},
+		{"PlaceCreate.category_ids.minItems", PlaceCategoriesMin, kw("PlaceCreate", "category_ids", "minItems")},
+		{"PlaceCreate.category_ids.maxItems", PlaceCategoriesMax, kw("PlaceCreate", "category_ids", "maxItems")},
+		{"PlaceUpdate.category_ids.minItems", PlaceCategoriesMin, kw("PlaceUpdate", "category_ids", "minItems")},
+		{"PlaceUpdate.category_ids.maxItems", PlaceCategoriesMax, kw("PlaceUpdate", "category_ids", "maxItems")},
+		{"SearchRequest.category_ids.maxItems", SearchCategoriesMax, kw("SearchRequest", "category_ids", "maxItems")},
+		{"SearchRequest.limit.minimum", LimitMin, kw("SearchRequest", "limit", "minimum")},
+		{"SearchRequest.limit.maximum", LimitMax, kw("SearchRequest", "limit", "maximum")},
+		{"BatchSearchRequest.queries.minItems", BatchQueriesMin, kw("BatchSearchRequest", "queries", "minItems")},
+		{"BatchSearchRequest.queries.maxItems", BatchQueriesMax, kw("BatchSearchRequest", "queries", "maxItems")},
+		{"BatchSearchRequest.category_ids.maxItems", SearchCategoriesMax, kw("BatchSearchRequest", "category_ids", "maxItems")},
+		{"BatchSearchRequest.limit.maximum", LimitMax, kw("BatchSearchRequest", "limit", "maximum")},
+		{"BatchSearchQuery.category_ids.maxItems", SearchCategoriesMax, kw("BatchSearchQuery", "category_ids", "maxItems")},
+		{"BatchSearchQuery.limit.maximum", LimitMax, kw("BatchSearchQuery", "limit", "maximum")},
+		{"DistanceMatrixRequest.origins.minItems", MatrixPointsMin, kw("DistanceMatrixRequest", "origins", "minItems")},
+		{"DistanceMatrixRequest.origins.maxItems", MatrixPointsMax, kw("DistanceMatrixRequest", "origins", "maxItems")},
+		{"DistanceMatrixRequest.destinations.minItems", MatrixPointsMin, kw("DistanceMatrixRequest", "destinations", "minItems")},
+		{"DistanceMatrixRequest.destinations.maxItems", MatrixPointsMax, kw("DistanceMatrixRequest", "destinations", "maxItems")},
+		{"SearchRequest.per_category_limit.minimum", PerCategoryLimitMin, kw("SearchRequest", "per_category_limit", "minimum")},
+		{"SearchRequest.per_category_limit.maximum", PerCategoryLimitMax, kw("SearchRequest", "per_category_limit", "maximum")},
+		{"FacetsRequest.radius_m.minimum", FacetRadiusMin, kw("FacetsRequest", "radius_m", "minimum")},
+		{"FacetsRequest.radius_m.maximum", FacetRadiusMax, kw("FacetsRequest", "radius_m", "maximum")},
+		{"FacetsRequest.top.minimum", FacetTopMin, kw("FacetsRequest", "top", "minimum")},
+		{"FacetsRequest.top.maximum", FacetTopMax, kw("FacetsRequest", "top", "maximum")},
+		{"FacetsRequest.top.default", FacetTopDefault, kw("FacetsRequest", "top", "default")},
+		{"FacetsRequest.category_ids.maxItems", SearchCategoriesMax, kw("FacetsRequest", "category_ids", "maxItems")},
+		{"RouteSearchRequest.buffer_m.minimum", RouteBufferMin, kw("RouteSearchRequest", "buffer_m", "minimum")},
+		{"RouteSearchRequest.buffer_m.maximum", RouteBufferMax, kw("RouteSearchRequest", "buffer_m", "maximum")},
+		{"LineStringGeometry.coordinates.minItems", RoutePointsMin, kw("LineStringGeometry", "coordinates", "minItems")},
+		{"LineStringGeometry.coordinates.maxItems", RoutePointsMax, kw("LineStringGeometry", "coordinates", "maxItems")},
+		{"RouteSearchRequest.limit.minimum", RouteLimitMin, kw("RouteSearchRequest", "limit", "minimum")},
+		{"RouteSearchRequest.limit.maximum", RouteLimitMax, kw("RouteSearchRequest", "limit", "maximum")},
+		{"RouteSearchRequest.category_ids.maxItems", SearchCategoriesMax, kw("RouteSearchRequest", "category_ids", "maxItems")},
+		{"history limit.minimum", HistoryLimitMin, param("/places/{id}/history", "limit", "minimum")},
+		{"history limit.maximum", HistoryLimitMax, param("/places/{id}/history", "limit", "maximum")},
+		{"history limit.default", HistoryLimitDefault, param("/places/{id}/history", "limit", "default")},
+		{"changes limit.minimum", ChangesLimitMin, param("/changes", "limit", "minimum")},
+		{"changes limit.maximum", ChangesLimitMax, param("/changes", "limit", "maximum")},
+		{"changes limit.default", ChangesLimitDefault, param("/changes", "limit", "default")},
+		{"changes wait.maximum", ChangesWaitMax, param("/changes", "wait", "maximum")},
+		{"heatmap res.minimum", HeatmapResMin, param("/heatmap", "res", "minimum")},
+		{"heatmap res.maximum", HeatmapResMax, param("/heatmap", "res", "maximum")},
+		{"heatmap res.default", HeatmapResDefault, param("/heatmap", "res", "default")},
+		{"clusters zoom.minimum", ClusterZoomMin, param("/clusters", "zoom", "minimum")},
+		{"clusters zoom.maximum", ClusterZoomMax, param("/clusters", "zoom", "maximum")},
+		{"tiles z.minimum", TileZoomMin, param("/tiles/places/{z}/{x}/{y}.mvt", "z", "minimum")},
+		{"tiles z.maximum", TileZoomMax, param("/tiles/places/{z}/{x}/{y}.mvt", "z", "maximum")},
+		{"reverse lat.minimum", LatMin, param("/reverse", "lat", "minimum")},
+		{"reverse lat.maximum", LatMax, param("/reverse", "lat", "maximum")},
+		{"reverse lon.minimum", LonMin, param("/reverse", "lon", "minimum")},
+		{"reverse lon.maximum", LonMax, param("/reverse", "lon", "maximum")},
+		{"AliasBatch.aliases.minItems", AliasesMin, kw("AliasBatch", "aliases", "minItems")},
+		{"AliasBatch.aliases.maxItems", AliasesMax, kw("AliasBatch", "aliases", "maxItems")},
+		{"duplicates limit.minimum", DuplicatesLimitMin, param("/duplicates", "limit", "minimum")},
+		{"duplicates limit.maximum", DuplicatesLimitMax, param("/duplicates", "limit", "maximum")},
+		{"duplicates limit.default", DuplicatesLimitDefault, param("/duplicates", "limit", "default")},
+		{"WebhookCreate.url.maxLength", WebhookURLMaxLen, kw("WebhookCreate", "url", "maxLength")},
+		{"WebhookCreate.secret.minLength", WebhookSecretMinLen, kw("WebhookCreate", "secret", "minLength")},
+		{"WebhookCreate.secret.maxLength", WebhookSecretMaxLen, kw("WebhookCreate", "secret", "maxLength")},
+		{"WebhookFilter.countries.maxItems", WebhookFilterItemsMax, kw("WebhookFilter", "countries", "maxItems")},
+		{"WebhookFilter.category_ids.maxItems", WebhookFilterItemsMax, kw("WebhookFilter", "category_ids", "maxItems")},
+		{"GeofenceCreate.name.minLength", NameMinLen, kw("GeofenceCreate", "name", "minLength")},
+		{"GeofenceCreate.name.maxLength", NameMaxLen, kw("GeofenceCreate", "name", "maxLength")},
+		{"GeofenceUpdate.name.maxLength", NameMaxLen, kw("GeofenceUpdate", "name", "maxLength")},
+		{"dead-letters limit.minimum", DeadLettersLimitMin, param("/webhooks/dead-letters", "limit", "minimum")},
+		{"dead-letters limit.maximum", DeadLettersLimitMax, param("/webhooks/dead-letters", "limit", "maximum")},
+		{"dead-letters limit.default", DeadLettersLimitDefault, param("/webhooks/dead-letters", "limit", "default")},
+	}
+	for _, c := range checks {
+		if c.got != c.want { t.Errorf("%s: code has %v, spec has %v", c.name, c.got, c.want) }
+	}
+}
@@ -0,0 +1,194 @@
+// Package validate checks request bodies against the constraints declared in
+// api/openapi.yaml. Every handler decodes with DecodeJSON and collects field
+// problems in a Validator, so clients get all violations in one response.
+package validate
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"redcat/internal/domain/errs"
+)
+
+// Constraints mirrored from api/openapi.yaml; spec_test.go fails when they drift.
+const (
+	LatMin, LatMax = -90.0, 90.0
+	LonMin, LonMax = -180.0, 180.0
+
+	NameMinLen, NameMaxLen = 1, 500
+
+	// PlaceCreate / PlaceUpdate category_ids
+	PlaceCategoriesMin, PlaceCategoriesMax = 1, 20
+	// SearchRequest category_ids (optional; empty means any category)
+	SearchCategoriesMax = 50
+	// SearchRequest per_category_limit
+	PerCategoryLimitMin, PerCategoryLimitMax = 1, 200
+
+	LimitMin, LimitMax = 1, 200
+
+	// BatchSearchRequest queries
+	BatchQueriesMin, BatchQueriesMax = 1, 1000
+
+	// DistanceMatrixRequest: points per side and origins × destinations
+	MatrixPointsMin, MatrixPointsMax = 1, 1000
+	MatrixCellsMax = 10000
+
+	// FacetsRequest
+	FacetRadiusMin, FacetRadiusMax = 1.0, 50000.0
+	FacetTopMin, FacetTopMax, FacetTopDefault = 1, 100, 10
+
+	// GET /heatmap H3 resolution and cells returned; the resolutions are
+	// those indexed by the places storage
+	HeatmapResMin, HeatmapResMax, HeatmapResDefault = 5, 8, 7
+	HeatmapCellsMax = 10000
+	// GET /clusters map zoom
+	ClusterZoomMin, ClusterZoomMax = 0, 20
+	// GET /tiles/places/{z}/{x}/{y}.mvt zoom
+	TileZoomMin, TileZoomMax = 0, 22
+
+	// RouteSearchRequest
+	RoutePointsMin, RoutePointsMax = 2, 10000
+	RouteBufferMin, RouteBufferMax = 1.0, 10000.0
+	RouteLimitMin, RouteLimitMax, RouteLimitDefault = 1, 500, 100
+
+	// GET /places/{id}/history page size
+	HistoryLimitMin, HistoryLimitMax, HistoryLimitDefault = 1, 100, 20
+	// GET /changes page size and long-poll wait (seconds)
+	ChangesLimitMin, ChangesLimitMax, ChangesLimitDefault = 1, 500, 100
+	ChangesWaitMax = 30
+
+	// WebhookCreate url, secret and filter lists
+	WebhookURLMaxLen = 2048
+	WebhookSecretMinLen, WebhookSecretMaxLen = 16, 256
+	WebhookFilterItemsMax = 50
+	// GET /webhooks/dead-letters page size
+	DeadLettersLimitMin, DeadLettersLimitMax, DeadLettersLimitDefault = 1, 100, 20
+
+	// AliasBatch aliases
+	AliasesMin, AliasesMax = 1, 1000
+	// GET /duplicates page size
+	DuplicatesLimitMin, DuplicatesLimitMax, DuplicatesLimitDefault = 1, 100, 20
+
+	// GeofenceCreate / GeofenceUpdate geometry: positions across all rings
+	GeofenceVerticesMax = 10000
+
+	// StreamIDPattern matches Valkey stream entry IDs used as page cursors.
+	StreamIDPattern = `^[0-9]+-[0-9]+$`
+
+	// IDPattern restricts client-supplied IDs to characters that are safe
+	// inside a Valkey hash tag ("places:{id}") and in URL paths.
+	IDPattern = `^[A-Za-z0-9._:-]{1,128}$`
+)
+
+var (
+	idRe       = regexp.MustCompile(IDPattern)
+	streamIDRe = regexp.MustCompile(StreamIDPattern)
+)
+
+// FieldError is one violation, reported under details.fields of the Error envelope.
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// Validator accumulates FieldErrors.
+type Validator struct {
+	fields []FieldError
+}
+
+func (v *Validator) Add(field, format string, args ...any) {
+	v.fields = append(v.fields, FieldError{Field: field, Message: fmt.Sprintf(format, args...)})
+}
+
+// Check records err under field when it is non-nil.
+func (v *Validator) Check(field string, err error) {
+	if err == nil { return }
+	msg := err.Error()
+	if e := errs.As(err); e != nil { msg = e.Message }
+	v.Add(field, "%s", msg)
+}
+
+func (v *Validator) Required(field string, present bool) {
+	if !present { v.Add(field, "is required") }
+}
+
+// Range checks that x lies within [min, max]; NaN never does.
+func (v *Validator) Range(field string, x, min, max float64) {
+	if !(x >= min && x <= max) { v.Add(field, "must be between %g and %g", min, max) }
+}
+
+// Length checks the length of s in characters.
+func (v *Validator) Length(field, s string, min, max int) {
+	n := utf8.RuneCountInString(s)
+	if strings.TrimSpace(s) == "" && min > 0 {
+		v.Add(field, "must not be blank")
+		return
+	}
+	if n < min || n > max { v.Add(field, "length must be between %d and %d", min, max) }
+}
+
+// Items checks the number of elements in a list.
+func (v *Validator) Items(field string, n, min, max int) {
+	if n < min || n > max { v.Add(field, "must have between %d and %d items", min, max) }
+}
+
+// ID checks a client-supplied place ID against IDPattern.
+func (v *Validator) ID(field, id string) {
+	if !idRe.MatchString(id) { v.Add(field, "must match %s", IDPattern) }
+}
+
+// StreamID checks a page cursor against StreamIDPattern.
+func (v *Validator) StreamID(field, id string) {
+	if !streamIDRe.MatchString(id) { v.Add(field, "must match %s", StreamIDPattern) }
+}
+
+// Location checks a lat/lon pair under field.lat and field.lon.
+func (v *Validator) Location(field string, lat, lon float64) {
+	v.Range(field+".lat", lat, LatMin, LatMax)
+	v.Range(field+".lon", lon, LonMin, LonMax)
+}
+
+// Fields returns the violations collected so far.
+func (v *Validator) Fields() []FieldError { return v.fields }
+
+// Err returns an INVALID_REQUEST error listing every violation, or nil.
+func (v *Validator) Err() error {
+	if len(v.fields) == 0 { return nil }
+	msg := "validation failed"
+	if len(v.fields) == 1 { msg = v.fields[0].Field + " " + v.fields[0].Message }
+	return errs.Invalid(msg, map[string]any{"fields": v.fields})
+}
+
+// DecodeJSON decodes body into dst. In strict mode unknown properties are
+// rejected. Decoding problems are returned as INVALID_REQUEST errors naming
+// the offending field.
+func DecodeJSON(body []byte, dst any, strict bool) error {
+	if len(bytes.TrimSpace(body)) == 0 { return errs.Invalid("invalid JSON", map[string]any{"reason": "empty body"}) }
+	dec := json.NewDecoder(bytes.NewReader(body))
+	if strict { dec.DisallowUnknownFields() }
+	err := dec.Decode(dst)
+	if err == nil {
+		if _, err = dec.Token(); err != io.EOF {
+			return errs.Invalid("invalid JSON", map[string]any{"reason": "trailing data after JSON value"})
+		}
+		return nil
+	}
+	var te *json.UnmarshalTypeError
+	if errors.As(err, &te) {
+		v := &Validator{}
+		v.Add(te.Field, "must be %s", te.Type.String())
+		return v.Err()
+	}
+	if name, ok := strings.CutPrefix(err.Error(), "json: unknown field "); ok {
+		v := &Validator{}
+		v.Add(strings.Trim(name, `"`), "is not allowed")
+		return v.Err()
+	}
+	return errs.Invalid("invalid JSON", map[string]any{"reason": err.Error()})
+}
@@ -0,0 +1,61 @@
+package validate
+
+import (
+	"math"
+	"testing"
+
+	"redcat/internal/domain/errs"
+)
+
+func fieldsOf(t *testing.T, err error) []FieldError {
+	t.Helper()
+	e := errs.As(err)
+	if e == nil || e.Kind != errs.InvalidRequest {
+		t.Fatalf("want INVALID_REQUEST, got %v", err)
+	}
+	f, _ := e.Details["fields"].([]FieldError)
+	return f
+}
+
+func TestValidator_CollectsAll(t *testing.T) {
+	v := &Validator{}
+	v.Location("location", 500, -181)
+	v.Length("name", "   ", NameMinLen, NameMaxLen)
+	v.Items("category_ids", 21, PlaceCategoriesMin, PlaceCategoriesMax)
+	v.Required("id", true)
+	got := fieldsOf(t, v.Err())
+	want := []string{"location.lat", "location.lon", "name", "category_ids"}
+	if len(got) != len(want) { t.Fatalf("want %d errors, got %+v", len(want), got) }
+	for i, f := range got {
+		if f.Field != want[i] { t.Errorf("error %d: want field %s, got %s", i, want[i], f.Field) }
+	}
+
+	nan := &Validator{}
+	nan.Range("radius_m", math.NaN(), 0, 1)
+	if len(fieldsOf(t, nan.Err())) != 1 { t.Fatal("Range: NaN accepted") }
+
+	ok := &Validator{}
+	ok.Location("location", 90, -180)
+	ok.Length("name", "Кафе", NameMinLen, NameMaxLen)
+	if err := ok.Err(); err != nil { t.Fatalf("unexpected error: %v", err) }
+}
+
+func TestDecodeJSON(t *testing.T) {
+	type body struct {
+		Name  string `json:"name"`
+		Limit int64  `json:"limit"`
+	}
+	var b body
+	if err := DecodeJSON([]byte(`{"name":"x","extra":1}`), &b, false); err != nil { t.Fatalf("lenient: %v", err) }
+	if f := fieldsOf(t, DecodeJSON([]byte(`{"name":"x","extra":1}`), &b, true)); len(f) != 1 || f[0].Field != "extra" {
+		t.Fatalf("strict should name the unknown field, got %+v", f)
+	}
+	if f := fieldsOf(t, DecodeJSON([]byte(`{"limit":"ten"}`), &b, false)); len(f) != 1 || f[0].Field != "limit" {
+		t.Fatalf("type error should name the field, got %+v", f)
+	}
+	for _, in := range []string{``, `{invalid}`, `{} {}`} {
+		if e := errs.As(DecodeJSON([]byte(in), &b, false)); e == nil || e.Kind != errs.InvalidRequest {
+			t.Fatalf("%q: want INVALID_REQUEST, got %v", in, e)
+		}
+	}
+}
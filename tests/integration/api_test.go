@@ -45,8 +45,44 @@ type Place struct {
 	CategoryIDs []string `json:"category_ids,omitempty"`
 }
 
-type CreateResponse struct {
-	Item Place `json:"item"`
+// PlaceCreate is the POST /places body (PlaceCreate schema).
+type PlaceCreate struct {
+	ID          string   `json:"id,omitempty"`
+	Name        string   `json:"name"`
+	Location    Location `json:"location"`
+	Address     *Address `json:"address,omitempty"`
+	CategoryIDs []string `json:"category_ids"`
+}
+
+type Address struct {
+	Street   string `json:"street,omitempty"`
+	Locality string `json:"locality,omitempty"`
+	Region   string `json:"region,omitempty"`
+	Postcode string `json:"postcode,omitempty"`
+	Country  string `json:"country,omitempty"`
+}
+
+// PlaceResponse is the Place schema returned by create/get.
+type PlaceResponse struct {
+	ID          string   `json:"id"`
+	Name        string   `json:"name"`
+	Location    Location `json:"location"`
+	Address     *Address `json:"address,omitempty"`
+	CategoryIDs []string `json:"category_ids"`
+}
+
+// createBody converts a flat fixture place into the API create shape.
+func (p Place) createBody() PlaceCreate {
+	return PlaceCreate{
+		ID:       p.ID,
+		Name:     p.Name,
+		Location: Location{Lat: p.Lat, Lon: p.Lon},
+		Address: &Address{
+			Street: p.Address, Locality: p.Locality, Region: p.Region,
+			Postcode: p.Postcode, Country: p.Country,
+		},
+		CategoryIDs: p.CategoryIDs,
+	}
 }
 
 type SearchRequest struct {
@@ -108,7 +144,7 @@ func TestPlaceCRUD(t *testing.T) {
 
 	// Create
 	t.Run("Create", func(t *testing.T) {
-		body, _ := json.Marshal(place)
+		body, _ := json.Marshal(place.createBody())
 		resp, err := httpClient.Post(apiURL("/places"), "application/json", bytes.NewReader(body))
 		if err != nil {
 			t.Fatalf("create request failed: %v", err)
@@ -120,13 +156,28 @@ func TestPlaceCRUD(t *testing.T) {
 			t.Fatalf("expected status 201, got %d: %s", resp.StatusCode, respBody)
 		}
 
-		var result CreateResponse
+		var result PlaceResponse
 		if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
 			t.Fatalf("failed to decode response: %v", err)
 		}
 
-		if result.Item.ID != testID {
-			t.Errorf("expected ID %s, got %s", testID, result.Item.ID)
+		if result.ID != testID {
+			t.Errorf("expected ID %s, got %s", testID, result.ID)
+		}
+	})
+
+	// Create again with the same ID
+	t.Run("CreateDuplicate", func(t *testing.T) {
+		body, _ := json.Marshal(place.createBody())
+		resp, err := httpClient.Post(apiURL("/places"), "application/json", bytes.NewReader(body))
+		if err != nil {
+			t.Fatalf("create request failed: %v", err)
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode != http.StatusConflict {
+			respBody, _ := io.ReadAll(resp.Body)
+			t.Fatalf("expected status 409, got %d: %s", resp.StatusCode, respBody)
 		}
 	})
 
@@ -143,10 +194,13 @@ func TestPlaceCRUD(t *testing.T) {
 			t.Fatalf("expected status 200, got %d: %s", resp.StatusCode, respBody)
 		}
 
-		var result Place
+		var result PlaceResponse
 		if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
 			t.Fatalf("failed to decode response: %v", err)
 		}
+		if result.Location.Lat != place.Lat || result.Location.Lon != place.Lon {
+			t.Errorf("expected location %v,%v, got %+v", place.Lat, place.Lon, result.Location)
+		}
 
 		if result.ID != testID {
 			t.Errorf("expected ID %s, got %s", testID, result.ID)
@@ -247,14 +301,22 @@ func TestPlaceCRUD(t *testing.T) {
 		}
 		defer resp.Body.Close()
 
-		// API currently returns 200 with empty place for non-existent IDs
-		// TODO: API should return 404 for deleted places
-		if resp.StatusCode == http.StatusOK {
-			var result Place
-			json.NewDecoder(resp.Body).Decode(&result)
-			if result.ID == testID {
-				t.Errorf("place should have been deleted but still exists")
-			}
+		if resp.StatusCode != http.StatusNotFound {
+			t.Errorf("expected status 404 for deleted place, got %d", resp.StatusCode)
+		}
+	})
+
+	// Deleting again reports the place as missing
+	t.Run("DeleteMissing", func(t *testing.T) {
+		req, _ := http.NewRequest(http.MethodDelete, apiURL("/places/"+testID), nil)
+		resp, err := httpClient.Do(req)
+		if err != nil {
+			t.Fatalf("delete request failed: %v", err)
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode != http.StatusNotFound {
+			t.Errorf("expected status 404, got %d", resp.StatusCode)
 		}
 	})
 }
@@ -289,7 +351,7 @@ func TestCreateValidation(t *testing.T) {
 
 	for _, tc := range tests {
 		t.Run(tc.name, func(t *testing.T) {
-			body, _ := json.Marshal(tc.place)
+			body, _ := json.Marshal(tc.place.createBody())
 			resp, err := httpClient.Post(apiURL("/places"), "application/json", bytes.NewReader(body))
 			if err != nil {
 				t.Fatalf("request failed: %v", err)
@@ -228,14 +228,15 @@ func loadPaphosFixture(t *testing.T) []Place {
 func createPlace(t *testing.T, p Place) {
 	t.Helper()
 
-	body, _ := json.Marshal(p)
-	resp, err := httpClient.Post(apiURL("/places"), "application/json", bytes.NewReader(body))
+	body, _ := json.Marshal(p.createBody())
+	// upsert keeps fixture loading idempotent if a previous run didn't clean up
+	resp, err := httpClient.Post(apiURL("/places?upsert=true"), "application/json", bytes.NewReader(body))
 	if err != nil {
 		t.Fatalf("create place failed: %v", err)
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusCreated {
+	if resp.StatusCode != http.StatusOK {
 		respBody, _ := io.ReadAll(resp.Body)
 		t.Fatalf("create place %s failed: %d: %s", p.ID, resp.StatusCode, respBody)
 	}